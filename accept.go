@@ -0,0 +1,66 @@
+package wrap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptValue is a value of an Accept* header with its quality
+type acceptValue struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses the comma separated values of Accept* headers (RFC 7231, 5.3) with their q-values,
+// sorted by descending quality (stable, so that the order of the header decides between equal qualities).
+// Values are lowercased, parameters other than q are kept as part of the value. Invalid q-values count as 0.
+func parseAccept(headers []string) (values []acceptValue) {
+	for _, h := range headers {
+		for _, part := range strings.Split(h, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			av := acceptValue{q: 1}
+			params := strings.Split(part, ";")
+			kept := []string{strings.ToLower(strings.TrimSpace(params[0]))}
+			for _, p := range params[1:] {
+				name, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(strings.TrimSpace(name), "q") {
+					q, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+					if err != nil || q < 0 || q > 1 {
+						q = 0
+					}
+					av.q = q
+					continue
+				}
+				kept = append(kept, strings.ToLower(strings.TrimSpace(name))+"="+strings.TrimSpace(val))
+			}
+			av.value = strings.Join(kept, ";")
+			values = append(values, av)
+		}
+	}
+	sort.SliceStable(values, func(a, b int) bool { return values[a].q > values[b].q })
+	return
+}
+
+// acceptQuality returns the quality of the given value, taking the wildcard "*" into account,
+// and if the value is mentioned explicitly
+func acceptQuality(values []acceptValue, value string) (q float64, explicit bool) {
+	wildcard := -1.0
+	for _, v := range values {
+		switch v.value {
+		case value:
+			return v.q, true
+		case "*":
+			if wildcard < 0 {
+				wildcard = v.q
+			}
+		}
+	}
+	if wildcard >= 0 {
+		return wildcard, false
+	}
+	return 0, false
+}
@@ -0,0 +1,36 @@
+package wrap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	values := parseAccept([]string{"text/html;level=1, text/*;q=0.5", "application/json;q=0.9, image/png;q=x, */*;Q=0.1"})
+	expected := "[{text/html;level=1 1} {application/json 0.9} {text/* 0.5} {*/* 0.1} {image/png 0}]"
+	if got := fmt.Sprint(values); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	values := parseAccept([]string{"gzip;q=0.5, *;q=0.2, br;q=0"})
+	tests := []struct {
+		value    string
+		q        float64
+		explicit bool
+	}{
+		{"gzip", 0.5, true},
+		{"br", 0, true},
+		{"zstd", 0.2, false},
+	}
+	for _, test := range tests {
+		if q, explicit := acceptQuality(values, test.value); q != test.q || explicit != test.explicit {
+			t.Errorf("%s: expected %v %v, got %v %v", test.value, test.q, test.explicit, q, explicit)
+		}
+	}
+
+	if q, explicit := acceptQuality(parseAccept([]string{"gzip"}), "br"); q != 0 || explicit {
+		t.Errorf("unmentioned value should have quality 0, got %v %v", q, explicit)
+	}
+}
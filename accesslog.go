@@ -0,0 +1,200 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogEntry is a request served by a stack with AccessLog
+type AccessLogEntry struct {
+	// Time is the point in time the request has been received
+	Time time.Time
+
+	// RemoteHost is the remote address of the request without port
+	RemoteHost string
+
+	// User is the user name of basic authentication or of the URL, empty if there is none
+	User string
+
+	Method string
+
+	// URI is the request URI as sent by the client
+	URI   string
+	Proto string
+
+	// Status is the status code of the response, http.StatusOK if nothing has been written
+	Status int
+
+	// Bytes is the number of body bytes of the response
+	Bytes int
+
+	// Duration is the time the next handlers took to serve the request
+	Duration time.Duration
+
+	Referer   string
+	UserAgent string
+
+	// RequestID is the request id (see RequestIDOf), empty if there is none
+	RequestID string
+
+	// Context are the values of the context types of AccessLog.Context, by name. It contains
+	// only the values that are set.
+	Context map[string]interface{}
+}
+
+// AccessLogFormat formats an entry as a line (without the trailing newline)
+type AccessLogFormat func(e *AccessLogEntry) []byte
+
+// accessLogTime is the time format of the common log format
+const accessLogTime = "02/Jan/2006:15:04:05 -0700"
+
+// dash returns "-" for an empty string, as the common log format requires
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// CommonLogFormat formats an entry in the common log format of the Apache HTTP server
+func CommonLogFormat(e *AccessLogEntry) []byte {
+	b := []byte(dash(e.RemoteHost) + " - " + dash(e.User) + " [" + e.Time.Format(accessLogTime) + "] ")
+	b = strconv.AppendQuote(b, e.Method+" "+e.URI+" "+e.Proto)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Bytes == 0 {
+		return append(b, '-')
+	}
+	return strconv.AppendInt(b, int64(e.Bytes), 10)
+}
+
+// CombinedLogFormat formats an entry in the combined log format of the Apache HTTP server,
+// that is the common log format followed by referer and user agent
+func CombinedLogFormat(e *AccessLogEntry) []byte {
+	b := append(CommonLogFormat(e), ' ')
+	b = strconv.AppendQuote(b, dash(e.Referer))
+	b = append(b, ' ')
+	return strconv.AppendQuote(b, dash(e.UserAgent))
+}
+
+// JSONLogFormat formats an entry as a JSON object with all fields of the entry. The duration
+// is given in seconds as "duration", empty fields are omitted.
+func JSONLogFormat(e *AccessLogEntry) []byte {
+	b, err := json.Marshal(struct {
+		Time       time.Time              `json:"time"`
+		RemoteHost string                 `json:"remote_host,omitempty"`
+		User       string                 `json:"user,omitempty"`
+		Method     string                 `json:"method"`
+		URI        string                 `json:"uri"`
+		Proto      string                 `json:"proto"`
+		Status     int                    `json:"status"`
+		Bytes      int                    `json:"bytes"`
+		Duration   float64                `json:"duration"`
+		Referer    string                 `json:"referer,omitempty"`
+		UserAgent  string                 `json:"user_agent,omitempty"`
+		RequestID  string                 `json:"request_id,omitempty"`
+		Context    map[string]interface{} `json:"context,omitempty"`
+	}{e.Time, e.RemoteHost, e.User, e.Method, e.URI, e.Proto, e.Status, e.Bytes, e.Duration.Seconds(),
+		e.Referer, e.UserAgent, e.RequestID, e.Context})
+	if err != nil {
+		// a context value that can't be marshalled
+		return []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+	}
+	return b
+}
+
+// AccessLog is a Wrapper that logs every request after the next handlers have served it.
+// The status code and the number of bytes are tracked by a lightweight recorder that passes
+// everything through, so AccessLog is suitable for streaming responses and keeps the Contexter
+// of the ResponseWriter. Probe requests (see WithStrict) are not logged.
+type AccessLog struct {
+	// Format formats the entries written to Out. If it is nil, CombinedLogFormat is used.
+	Format AccessLogFormat
+
+	// Out is the writer the formatted entries are written to, one per line.
+	// If it is nil, os.Stderr is used. The writes are serialized.
+	Out io.Writer
+
+	// Sink receives the entries instead of Out, e.g. to pass them to a structured logger.
+	// It must be safe for concurrent use.
+	Sink func(*AccessLogEntry)
+
+	// Context are the context types whose values are logged, by name, given as pointers,
+	// e.g. map[string]interface{}{"user": new(User)}. The values are taken from the Contexter after the
+	// next handlers have served the request. Unset or unsupported types and ResponseWriters that are no
+	// Contexter are silently skipped.
+	Context map[string]interface{}
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = AccessLog{}
+
+// newAccessLogEntry returns the entry for the request served by rec
+func (a AccessLog) newAccessLogEntry(rw http.ResponseWriter, req *http.Request, rec *statusRecorder, start time.Time) *AccessLogEntry {
+	e := &AccessLogEntry{
+		Time:       start,
+		RemoteHost: hostOnly(req.RemoteAddr),
+		Method:     req.Method,
+		URI:        req.RequestURI,
+		Proto:      req.Proto,
+		Status:     rec.Status(),
+		Bytes:      rec.bytes,
+		Duration:   time.Since(start),
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+		Context:    contextValues(rw, a.Context),
+	}
+	if e.Status == 0 {
+		e.Status = http.StatusOK
+	}
+	if e.URI == "" && req.URL != nil {
+		e.URI = req.URL.RequestURI()
+	}
+	if user, _, ok := req.BasicAuth(); ok {
+		e.User = user
+	} else if req.URL != nil && req.URL.User != nil {
+		e.User = req.URL.User.Username()
+	}
+	e.RequestID = RequestIDOf(rw, req)
+	return e
+}
+
+// Wrap implements the Wrapper interface
+func (a AccessLog) Wrap(next http.Handler) http.Handler {
+	sink := a.Sink
+	if sink == nil {
+		format, out := a.Format, a.Out
+		if format == nil {
+			format = CombinedLogFormat
+		}
+		if out == nil {
+			out = os.Stderr
+		}
+		var mu sync.Mutex
+		sink = func(e *AccessLogEntry) {
+			line := append(format(e), '\n')
+			mu.Lock()
+			out.Write(line)
+			mu.Unlock()
+		}
+	}
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := newStatusRecorder(rw)
+		next.ServeHTTP(rec.responseWriter(), req)
+		if IsProbe(req) {
+			return
+		}
+		sink(a.newAccessLogEntry(rw, req, rec, start))
+	}
+	return f
+}
@@ -0,0 +1,100 @@
+package wrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogCombined(t *testing.T) {
+	var buf bytes.Buffer
+	rec, req := newTestRequest("GET", "/path?q=1")
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.RequestURI = "/path?q=1"
+	req.Proto = "HTTP/1.1"
+	req.Header.Set("User-Agent", "test")
+	req.SetBasicAuth("bob", "secret")
+
+	New(AccessLog{Out: &buf}, write("hello")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "hello", 200)
+
+	line := buf.String()
+	if !strings.HasPrefix(line, `192.0.2.1 - bob [`) {
+		t.Errorf("unexpected start of line %q", line)
+	}
+	if !strings.HasSuffix(line, `] "GET /path?q=1 HTTP/1.1" 200 5 "-" "test"`+"\n") {
+		t.Errorf("unexpected end of line %q", line)
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set(RequestIDHeader, "abc")
+
+	New(AccessLog{Out: &buf, Format: JSONLogFormat}, writeStop("")).ServeHTTP(rec, req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if entry["request_id"] != "abc" || entry["status"] != float64(200) || entry["bytes"] != float64(0) || entry["uri"] != "/" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+}
+
+func TestAccessLogSink(t *testing.T) {
+	var entries []*AccessLogEntry
+	sink := func(e *AccessLogEntry) { entries = append(entries, e) }
+	rec, req := newTestRequest("POST", "/")
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	h := New(
+		SetRequestID{Generate: func() string { return "generated" }},
+		&context{},
+		AccessLog{Sink: sink, Context: map[string]interface{}{"ip": new(userIP), "err": new(error), "unsupported": new(RequestID)}},
+		setUserIP{},
+		writeString("created"),
+	)
+	h.ServeHTTP(rec, req)
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Method != "POST" || e.Bytes != 7 || e.RequestID != "generated" || e.Duration < 0 || time.Since(e.Time) > time.Minute {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if len(e.Context) != 1 {
+		t.Fatalf("expected only the ip in the context, got %v", e.Context)
+	}
+	if ip, ok := e.Context["ip"].(userIP); !ok || net.IP(ip).String() != "192.0.2.1" {
+		t.Errorf("expected ip 192.0.2.1, got %v", e.Context["ip"])
+	}
+}
+
+func TestAccessLogStatus(t *testing.T) {
+	var entries []*AccessLogEntry
+	rec, req := newTestRequest("GET", "/")
+
+	New(AccessLog{Sink: func(e *AccessLogEntry) { entries = append(entries, e) }}, Handler(http.NotFoundHandler())).ServeHTTP(rec, req)
+
+	if len(entries) != 1 || entries[0].Status != 404 {
+		t.Errorf("expected status 404, got %v", entries)
+	}
+}
+
+func TestCommonLogFormat(t *testing.T) {
+	e := &AccessLogEntry{
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Method: "GET", URI: "/a \"b\"", Proto: "HTTP/1.0", Status: 304,
+	}
+	expected := `- - - [02/Jan/2020:03:04:05 +0000] "GET /a \"b\" HTTP/1.0" 304 -`
+	if got := string(CommonLogFormat(e)); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
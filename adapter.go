@@ -1,50 +1,75 @@
 package wrap
 
-import "net/http"
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
 
 // Handler returns a Wrapper for a http.Handler.
 // The returned Wrapper simply runs the given handler and ignores the
-// next handler in the stack.
+// next handler in the stack, so it should be the last Wrapper of a stack
+// (it is treated like a terminating Terminator, and the unreachable check of cmd/wrapvet reports
+// Wrappers after it).
 func Handler(h http.Handler) Wrapper {
 	var nf NextHandlerFunc
 
-	if DEBUG {
-		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
-			(&debug{Object: h, Role: asHandler, Handler: h}).ServeHTTP(rw, req)
-		}
-		return nf
+	if IsDebug() {
+		d := newDebug(h, asHandler, h)
+		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) { d.ServeHTTP(rw, req) }
+		return markAdapter(nf)
 	}
 
 	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) { h.ServeHTTP(rw, req) }
-	return nf
+	return markAdapter(nf)
 }
 
 // HandlerFunc is like Handler but for a function with the type signature of http.HandlerFunc
 func HandlerFunc(fn func(http.ResponseWriter, *http.Request)) Wrapper {
 	var nf NextHandlerFunc
 
-	if DEBUG {
-		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
-			(&debug{Object: fn, Role: asHandlerFunc, Handler: http.HandlerFunc(fn)}).ServeHTTP(rw, req)
-		}
-		return nf
+	if IsDebug() {
+		d := newDebug(fn, asHandlerFunc, http.HandlerFunc(fn))
+		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) { d.ServeHTTP(rw, req) }
+		return markAdapter(nf)
 	}
 
 	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) { fn(rw, req) }
+	return markAdapter(nf)
+}
+
+// adapterFuncs are the code pointers of the functions returned by Handler and HandlerFunc. All closures
+// of a function literal share its code pointer, so there are only a few of them.
+var adapterFuncs sync.Map
+
+// markAdapter registers the code pointer of nf, so that isAdapter recognizes it, and returns nf
+func markAdapter(nf NextHandlerFunc) NextHandlerFunc {
+	adapterFuncs.LoadOrStore(reflect.ValueOf(nf).Pointer(), true)
 	return nf
 }
 
+// isAdapter returns if w has been returned by Handler or HandlerFunc, i.e. if it never calls the next handler
+func isAdapter(w Wrapper) bool {
+	nf, ok := w.(NextHandlerFunc)
+	if !ok || nf == nil {
+		return false
+	}
+	_, has := adapterFuncs.Load(reflect.ValueOf(nf).Pointer())
+	return has
+}
+
 // NextHandler returns a Wrapper for an interface with a ServeHTTPNext method
 func NextHandler(sh interface {
 	ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request)
 }) Wrapper {
 	var nf NextHandlerFunc
 
-	if DEBUG {
+	if IsDebug() {
+		d := newDebug(sh, asNextHandler, nil)
 		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
 			var f http.HandlerFunc
 			f = func(rw http.ResponseWriter, req *http.Request) { sh.ServeHTTPNext(next, rw, req) }
-			(&debug{Object: sh, Role: asNextHandler, Handler: f}).ServeHTTP(rw, req)
+			d.serve(f, rw, req)
 		}
 		return nf
 	}
@@ -60,9 +85,9 @@ type NextHandlerFunc func(next http.Handler, rw http.ResponseWriter, req *http.R
 func (f NextHandlerFunc) Wrap(next http.Handler) http.Handler {
 	var fn http.HandlerFunc
 
-	if DEBUG {
+	if IsDebug() {
 		fn = func(rw http.ResponseWriter, req *http.Request) { f(next, rw, req) }
-		return (&debug{Object: f, Role: asNextHandlerFunc, Handler: fn})
+		return newDebug(f, asNextHandlerFunc, fn)
 	}
 
 	fn = func(rw http.ResponseWriter, req *http.Request) { f(next, rw, req) }
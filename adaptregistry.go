@@ -0,0 +1,153 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// AdapterFunc converts a middleware value into a Wrapper. It returns a nil Wrapper and a nil error
+// if it does not know the type of the value and an error if it knows the type but can't convert the value.
+type AdapterFunc func(v interface{}) (Wrapper, error)
+
+// ErrNoAdapter is the error returned if no adapter could convert a value into a Wrapper
+type ErrNoAdapter struct {
+	Value interface{}
+}
+
+// Error returns the error message
+func (e *ErrNoAdapter) Error() string {
+	return fmt.Sprintf("no adapter for %T", e.Value)
+}
+
+type namedAdapter struct {
+	name string
+	fn   AdapterFunc
+}
+
+var adapters = struct {
+	mx   sync.RWMutex
+	list []namedAdapter
+}{}
+
+// RegisterAdapter registers an adapter under the given name that is used by Adapt.
+// Adapters are tried in the reverse order of their registration, so that later registered
+// adapters take precedence. Registering an adapter with the name of an already registered adapter
+// replaces it.
+//
+// Predefined adapters (tried after the registered ones) are "Wrapper", "constructor" (func(http.Handler) http.Handler),
+// "negroni" (ServeHTTP(http.ResponseWriter, *http.Request, http.HandlerFunc)), "NextHandler"
+// (ServeHTTPNext and NextHandlerFunc signature), "http.Handler" and "http.HandlerFunc" (signature).
+func RegisterAdapter(name string, fn AdapterFunc) {
+	adapters.mx.Lock()
+	defer adapters.mx.Unlock()
+	for i, a := range adapters.list {
+		if a.name == name {
+			adapters.list[i].fn = fn
+			return
+		}
+	}
+	adapters.list = append(adapters.list, namedAdapter{name, fn})
+}
+
+// TryAdapt converts v into a Wrapper with the registered adapters (see RegisterAdapter).
+// It returns an *ErrNoAdapter if no adapter knows the type of v.
+func TryAdapt(v interface{}) (Wrapper, error) {
+	adapters.mx.RLock()
+	list := adapters.list
+	adapters.mx.RUnlock()
+
+	for i := len(list) - 1; i >= 0; i-- {
+		w, err := list[i].fn(v)
+		if err != nil {
+			return nil, fmt.Errorf("adapter %s: %w", list[i].name, err)
+		}
+		if w != nil {
+			return w, nil
+		}
+	}
+	return nil, &ErrNoAdapter{v}
+}
+
+// Adapt is like TryAdapt but panics on errors. It allows to assemble stacks from heterogeneous middleware
+// values, e.g. driven by configuration.
+func Adapt(v interface{}) Wrapper {
+	w, err := TryAdapt(v)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// AdaptAll adapts all given values (see Adapt)
+func AdaptAll(v ...interface{}) []Wrapper {
+	wrappers := make([]Wrapper, len(v))
+	for i, val := range v {
+		wrappers[i] = Adapt(val)
+	}
+	return wrappers
+}
+
+// negroniHandler is the interface of negroni middleware
+type negroniHandler interface {
+	ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc)
+}
+
+// negroniFunc is the signature of negroni middleware functions
+type negroniFunc = func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc)
+
+// negroni returns a Wrapper for negroni middleware
+func negroni(fn negroniFunc) Wrapper {
+	var nf NextHandlerFunc
+	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		fn(rw, req, next.ServeHTTP)
+	}
+	return nf
+}
+
+func init() {
+	RegisterAdapter("http.HandlerFunc", func(v interface{}) (Wrapper, error) {
+		if fn, ok := v.(func(http.ResponseWriter, *http.Request)); ok {
+			return HandlerFunc(fn), nil
+		}
+		return nil, nil
+	})
+	RegisterAdapter("http.Handler", func(v interface{}) (Wrapper, error) {
+		if h, ok := v.(http.Handler); ok {
+			return Handler(h), nil
+		}
+		return nil, nil
+	})
+	RegisterAdapter("NextHandler", func(v interface{}) (Wrapper, error) {
+		switch x := v.(type) {
+		case func(http.Handler, http.ResponseWriter, *http.Request):
+			return NextHandlerFunc(x), nil
+		case interface {
+			ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request)
+		}:
+			return NextHandler(x), nil
+		}
+		return nil, nil
+	})
+	RegisterAdapter("negroni", func(v interface{}) (Wrapper, error) {
+		switch x := v.(type) {
+		case negroniFunc:
+			return negroni(x), nil
+		case negroniHandler:
+			return negroni(x.ServeHTTP), nil
+		}
+		return nil, nil
+	})
+	RegisterAdapter("constructor", func(v interface{}) (Wrapper, error) {
+		if c, ok := v.(func(http.Handler) http.Handler); ok {
+			return FromConstructors(c)[0], nil
+		}
+		return nil, nil
+	})
+	RegisterAdapter("Wrapper", func(v interface{}) (Wrapper, error) {
+		if w, ok := v.(Wrapper); ok {
+			return w, nil
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,67 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type negroniWrite string
+
+func (n negroniWrite) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	fmt.Fprint(rw, string(n))
+	next(rw, req)
+}
+
+// prefix is a middleware type known only by a registered adapter
+type prefix string
+
+func TestAdapt(t *testing.T) {
+	RegisterAdapter("prefix", func(v interface{}) (Wrapper, error) {
+		p, ok := v.(prefix)
+		if !ok {
+			return nil, nil
+		}
+		if p == "" {
+			return nil, errors.New("empty prefix")
+		}
+		return write(string(p)), nil
+	})
+
+	h := New(AdaptAll(
+		prefix("0"),
+		write("1"),
+		writeConstructor("2"),
+		negroniWrite("3"),
+		func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			fmt.Fprint(rw, "4")
+			next(rw, req)
+		},
+		func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, "5")
+			next.ServeHTTP(rw, req)
+		},
+		func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(rw, "6")
+		},
+	)...)
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "0123456", 200)
+
+	if _, err := TryAdapt(prefix("")); err == nil || err.Error() != "adapter prefix: empty prefix" {
+		t.Errorf("expected adapter error, got %v", err)
+	}
+
+	var noAdapter *ErrNoAdapter
+	if _, err := TryAdapt(42); !errors.As(err, &noAdapter) {
+		t.Errorf("expected *ErrNoAdapter, got %v", err)
+	}
+
+	// write is a Wrapper and a http.Handler, the Wrapper adapter wins
+	if w := Adapt(writeStop("x")); fmt.Sprintf("%T", w) != "wrap.writeStop" {
+		t.Errorf("Wrapper should be taken as is, got %T", w)
+	}
+}
@@ -0,0 +1,41 @@
+package wrap
+
+import "net/http"
+
+// asConstructor is the role of a constructor converted by FromConstructors
+const asConstructor = "Constructor"
+
+// FromConstructors converts alice-style constructors (func(http.Handler) http.Handler) to Wrappers,
+// so that they may be used in a stack. If DEBUG is set, each constructed handler is debugged in the
+// role "Constructor".
+func FromConstructors(constructors ...func(http.Handler) http.Handler) []Wrapper {
+	wrappers := make([]Wrapper, len(constructors))
+	for i, c := range constructors {
+		if IsDebug() {
+			c := c
+			wrappers[i] = WrapperFunc(func(next http.Handler) http.Handler {
+				return newDebug(c, asConstructor, c(next))
+			})
+			continue
+		}
+		wrappers[i] = WrapperFunc(c)
+	}
+	return wrappers
+}
+
+// ToConstructor converts the given wrappers to an alice-style constructor, i.e. a function that
+// receives the next handler and returns a handler that runs the wrappers before it.
+// The wrappers are stacked like in New, but the last wrapper receives the given next handler instead
+// of NoOp. If DEBUG is set when the constructor is called, each wrapper is debugged.
+func ToConstructor(wrapper ...Wrapper) func(http.Handler) http.Handler {
+	return func(next http.Handler) (h http.Handler) {
+		h = next
+		for i := len(wrapper) - 1; i >= 0; i-- {
+			h = wrapper[i].Wrap(h)
+			if IsDebug() {
+				h = newDebug(wrapper[i], asWrapper, h)
+			}
+		}
+		return
+	}
+}
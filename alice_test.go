@@ -0,0 +1,51 @@
+package wrap
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func writeConstructor(s string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return write(s).Wrap(next)
+	}
+}
+
+func TestFromConstructors(t *testing.T) {
+	wrappers := FromConstructors(writeConstructor("a"), writeConstructor("b"))
+	wrappers = append(wrappers, writeStop("c"))
+	rec, req := newTestRequest("GET", "/")
+	New(wrappers...).ServeHTTP(rec, req)
+	assertResponse(t, rec, "abc", 200)
+}
+
+func TestToConstructor(t *testing.T) {
+	c := ToConstructor(write("a"), write("b"))
+	rec, req := newTestRequest("GET", "/")
+	c(writeStop("c")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "abc", 200)
+}
+
+func TestConstructorsDebug(t *testing.T) {
+	var buf bytes.Buffer
+	old := CurrentDebugger()
+	NewLogDebugger(&buf, 0)
+	SetDebug()
+	defer DisableDebug()
+	wrappers := FromConstructors(writeConstructor("a"))
+	h := ToConstructor(wrappers...)(writeStop("b"))
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	SetDebugger(old)
+	assertResponse(t, rec, "ab", 200)
+
+	out := buf.String()
+	for _, exp := range []string{"GET / wrap.WrapperFunc as Wrapper", "GET / func(http.Handler) http.Handler as Constructor"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("debug output should contain %#v, but is %#v", exp, out)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+// peekPooled serves the next handler with a pooled Peek
+type peekPooled struct{}
+
+func (peekPooled) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	p := AcquirePeek(rw, nil)
+	next.ServeHTTP(p, req)
+	ReleasePeek(p)
+}
+
+// peekNew serves the next handler with a new Peek
+type peekNew struct{}
+
+func (peekNew) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	next.ServeHTTP(NewPeek(rw, nil), req)
+}
+
+// TestAllocs guards the allocations per request that are documented in the package documentation
+func TestAllocs(t *testing.T) {
+	if DEBUG || raceEnabled {
+		t.Skip("allocations are not guarded in debug mode and with the race detector")
+	}
+
+	tests := []struct {
+		name   string
+		h      http.Handler
+		allocs float64
+	}{
+		{"New", New(writeString(""), writeString(""), NextHandler(writeString(""))), 0},
+		{"New with NextHandlers", New(mkNextHandlers(10)...), 0},
+		{"Compile", Compile(mkNextHandlers(10)...), 0},
+		{"Stack", Stack(&context{}, mkNextHandlers(10)...), 1},
+		{"AcquirePeek", New(NextHandler(peekPooled{}), writeString("")), 0},
+		{"NewPeek", New(NextHandler(peekNew{}), writeString("")), 2},
+		{"HandleError", Stack(&context{}, HandleError{}, writeString("")), 2},
+	}
+
+	wr, req := mkRequestResponse()
+	for _, test := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			test.h.ServeHTTP(wr, req)
+		})
+		if allocs > test.allocs {
+			t.Errorf("%s should allocate at most %v times per request, but allocates %v times", test.name, test.allocs, allocs)
+		}
+	}
+}
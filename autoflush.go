@@ -0,0 +1,124 @@
+package wrap
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AutoFlush is a Wrapper that flushes the underlying ResponseWriter (see Flush) every Interval while the next
+// handlers run, if they have written to the body since the last flush. This lets progress output reach the client
+// and keeps proxies with idle timeouts from closing the connection, without the handlers calling Flush.
+//
+// The writes of the next handlers and the flushes are serialized by a mutex, so the flushes do not race with
+// concurrent writes. The flushing stops, when the next handlers have returned. Probe requests are passed
+// to the next handlers unchanged.
+type AutoFlush struct {
+	// Interval is the time between flushes. Defaults to one second.
+	Interval time.Duration
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = AutoFlush{}
+
+func (a AutoFlush) interval() time.Duration {
+	if a.Interval > 0 {
+		return a.Interval
+	}
+	return time.Second
+}
+
+// autoFlushWriter is the ResponseWriter passed to the next handlers by AutoFlush
+type autoFlushWriter struct {
+	http.ResponseWriter
+	mx sync.Mutex
+
+	// dirty is true, if the body has been written since the last flush
+	dirty bool
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &autoFlushWriter{}
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (a *autoFlushWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(a.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (a *autoFlushWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(a.ResponseWriter, ctxPtr)
+}
+
+// WriteHeader writes the status code
+func (a *autoFlushWriter) WriteHeader(code int) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes to the body
+func (a *autoFlushWriter) Write(b []byte) (int, error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.dirty = true
+	return a.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter
+func (a *autoFlushWriter) Flush() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.dirty = false
+	flushInner(a.ResponseWriter)
+}
+
+// flushDirty flushes the underlying ResponseWriter, if the body has been written since the last flush
+func (a *autoFlushWriter) flushDirty() {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if a.dirty {
+		a.dirty = false
+		flushInner(a.ResponseWriter)
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (a *autoFlushWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (a AutoFlush) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsProbe(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		w := &autoFlushWriter{ResponseWriter: rw}
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(a.interval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					w.flushDirty()
+				case <-stop:
+					return
+				}
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+		next.ServeHTTP(w, req)
+	}
+	return f
+}
@@ -0,0 +1,82 @@
+package wrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flushCounter is a ResponseWriter that counts its flushes
+type flushCounter struct {
+	mx sync.Mutex
+	*httptest.ResponseRecorder
+	flushes int32
+}
+
+func (f *flushCounter) Write(b []byte) (int, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.ResponseRecorder.Write(b)
+}
+
+func (f *flushCounter) Flush() {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	atomic.AddInt32(&f.flushes, 1)
+}
+
+func TestAutoFlush(t *testing.T) {
+	progress := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					rw.Write([]byte("."))
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+	rec := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	New(AutoFlush{Interval: 2 * time.Millisecond}, progress).ServeHTTP(rec, req)
+
+	flushes := atomic.LoadInt32(&rec.flushes)
+	if flushes == 0 {
+		t.Errorf("the response should be flushed periodically")
+	}
+	if rec.Body.Len() != 20 {
+		t.Errorf("expected 20 bytes, got %d", rec.Body.Len())
+	}
+
+	// no flushes after the handler returned
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt32(&rec.flushes); got != flushes {
+		t.Errorf("flushing should stop when the handler returns, got %d more flushes", got-flushes)
+	}
+}
+
+func TestAutoFlushIdle(t *testing.T) {
+	rec := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	New(AutoFlush{Interval: time.Millisecond}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	})).ServeHTTP(rec, req)
+
+	if rec.flushes != 0 {
+		t.Errorf("nothing written, nothing should be flushed, got %d flushes", rec.flushes)
+	}
+}
+
+func TestAutoFlushWriterConformance(t *testing.T) {
+	err := ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter { return &autoFlushWriter{ResponseWriter: rw} })
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package wrap
+
+import (
+	"net/http"
+	"time"
+)
+
+// WriteStats is the context type of the write metrics of a response, see Backpressure
+type WriteStats struct {
+	// Writes is the number of Write and Flush calls that returned
+	Writes int
+
+	// Bytes is the number of bytes written
+	Bytes int64
+
+	// Pending is the number of bytes of the Write in progress, 0 if there is none
+	Pending int64
+
+	// Total is the time spent in Write and Flush, Last the time of the last and Max the time of the slowest call
+	Total, Last, Max time.Duration
+
+	// Slow is true, if the last Write or Flush took at least the SlowWrite duration of Backpressure
+	Slow bool
+}
+
+// SlowClient returns if the client of the response is slow, i.e. the last Write or Flush took at least the
+// SlowWrite duration of a Backpressure (see WriteStats). It returns false, if there are no WriteStats.
+func SlowClient(rw http.ResponseWriter) bool {
+	var s WriteStats
+	found, err := TryContext(rw, &s)
+	return found && err == nil && s.Slow
+}
+
+// Backpressure is a ContextWrapper that measures the latency of each Write and Flush of the next handlers to the
+// underlying ResponseWriter and the pending bytes, to detect clients that can't keep up with the response.
+// If the ResponseWriter is a Contexter supporting the WriteStats type, the WriteStats are stored before and after
+// every Write (with the Pending bytes of the Write in progress) and Flush, so that the next handlers can shed work
+// or reduce the quality of the response for slow clients (see SlowClient).
+//
+// If Exporter is set, every Write and Flush is reported as observation of the wrapper "write" of the stack named
+// Stack with its latency and the status code of the response.
+type Backpressure struct {
+	// SlowWrite is the latency of a Write or Flush from which on the client is considered slow.
+	// Defaults to 100 milliseconds.
+	SlowWrite time.Duration
+
+	// Exporter receives the latency of every Write and Flush (optional)
+	Exporter Exporter
+
+	// Stack is the name of the stack reported to the Exporter
+	Stack string
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = Backpressure{}
+
+// ValidateContext makes sure that ctx supports the WriteStats type
+func (Backpressure) ValidateContext(ctx Contexter) {
+	var s WriteStats
+	ctx.SetContext(&s)
+	ctx.Context(&s)
+}
+
+func (b Backpressure) slowWrite() time.Duration {
+	if b.SlowWrite > 0 {
+		return b.SlowWrite
+	}
+	return 100 * time.Millisecond
+}
+
+// backpressureWriter is the ResponseWriter passed to the next handlers by Backpressure
+type backpressureWriter struct {
+	http.ResponseWriter
+	backpressure Backpressure
+	stats        WriteStats
+	status       int
+
+	// publish is true, if the underlying ResponseWriter supports WriteStats
+	publish bool
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &backpressureWriter{}
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (w *backpressureWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(w.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (w *backpressureWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(w.ResponseWriter, ctxPtr)
+}
+
+// store stores the WriteStats in the context, if it supports them
+func (w *backpressureWriter) store() {
+	if w.publish {
+		w.SetContext(&w.stats)
+	}
+}
+
+// measured records the latency of a Write or Flush that wrote n bytes
+func (w *backpressureWriter) measured(n int, d time.Duration) {
+	s := &w.stats
+	s.Writes++
+	s.Bytes += int64(n)
+	s.Pending = 0
+	s.Total += d
+	s.Last = d
+	if d > s.Max {
+		s.Max = d
+	}
+	s.Slow = d >= w.backpressure.slowWrite()
+	w.store()
+	if w.backpressure.Exporter != nil {
+		status := w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.backpressure.Exporter.Observe(w.backpressure.Stack, "write", d, status)
+	}
+}
+
+// WriteHeader writes the status code
+func (w *backpressureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes to the underlying ResponseWriter and measures the latency
+func (w *backpressureWriter) Write(b []byte) (int, error) {
+	w.stats.Pending = int64(len(b))
+	w.store()
+	start := time.Now()
+	n, err := w.ResponseWriter.Write(b)
+	w.measured(n, time.Since(start))
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter and measures the latency
+func (w *backpressureWriter) Flush() {
+	start := time.Now()
+	flushInner(w.ResponseWriter)
+	w.measured(0, time.Since(start))
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (w *backpressureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (b Backpressure) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsProbe(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		w := &backpressureWriter{ResponseWriter: rw, backpressure: b}
+		if _, ok := rw.(Contexter); ok {
+			w.publish = TrySetContext(rw, &w.stats) == nil
+		}
+		next.ServeHTTP(w, req)
+	}
+	return f
+}
@@ -0,0 +1,122 @@
+package wrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type writeStatsContext struct {
+	http.ResponseWriter
+	stats *WriteStats
+}
+
+func (c *writeStatsContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *WriteStats:
+		if c.stats == nil {
+			return false
+		}
+		*ty = *c.stats
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *writeStatsContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *WriteStats:
+		s := *ty
+		c.stats = &s
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c writeStatsContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&writeStatsContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// slowWriter is a ResponseWriter whose writes of more than one byte are slow
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	pending *int64
+	rw      *http.ResponseWriter
+}
+
+func (s *slowWriter) Write(b []byte) (int, error) {
+	var stats WriteStats
+	(*s.rw).(Contexter).Context(&stats)
+	*s.pending = stats.Pending
+	if len(b) > 1 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return s.ResponseRecorder.Write(b)
+}
+
+func TestBackpressure(t *testing.T) {
+	ValidateWrapperContexts(&writeStatsContext{}, Backpressure{})
+	var mx sync.Mutex
+	var observed []string
+	exporter := ExporterFunc(func(stack, wrapper string, d time.Duration, status int) {
+		mx.Lock()
+		observed = append(observed, stack+" "+wrapper+" "+http.StatusText(status))
+		mx.Unlock()
+	})
+
+	var pending int64
+	var inner http.ResponseWriter
+	var slow []bool
+	h := New(writeStatsContext{}, Backpressure{SlowWrite: 5 * time.Millisecond, Exporter: exporter, Stack: "s"},
+		HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			inner = rw
+			rw.WriteHeader(http.StatusAccepted)
+			rw.Write([]byte("a"))
+			slow = append(slow, SlowClient(rw))
+			rw.Write([]byte("bcd"))
+			slow = append(slow, SlowClient(rw))
+			rw.Write([]byte("e"))
+			slow = append(slow, SlowClient(rw))
+		}))
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(&slowWriter{ResponseRecorder: rec, pending: &pending, rw: &inner}, req)
+	assertResponse(t, rec, "abcde", 202)
+
+	if expected := []bool{false, true, false}; !reflect.DeepEqual(slow, expected) {
+		t.Errorf("expected slow signals %v, got %v", expected, slow)
+	}
+
+	var stats WriteStats
+	inner.(Contexter).Context(&stats)
+	if stats.Writes != 3 || stats.Bytes != 5 || stats.Pending != 0 || stats.Max < 5*time.Millisecond {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if pending != 1 {
+		t.Errorf("the pending bytes of the last write should be 1, got %d", pending)
+	}
+	if len(observed) != 3 || strings.Join(observed, ",") != "s write Accepted,s write Accepted,s write Accepted" {
+		t.Errorf("unexpected observations %v", observed)
+	}
+}
+
+func TestBackpressureWriterConformance(t *testing.T) {
+	err := ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter {
+		return &backpressureWriter{ResponseWriter: rw}
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package wrap
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -86,3 +91,241 @@ func BenchmarkServing2Wrappers(b *testing.B) {
 func BenchmarkServing2Simple(b *testing.B) {
 	benchmarkSimple(2, b)
 }
+
+// peekSink lets the Peeks of the benchmarks escape to the heap, like the Peeks of handlers do
+var peekSink *Peek
+
+func BenchmarkNewPeek(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := NewPeek(wr, nil)
+		p.Header().Set("X-Test", "1")
+		peekSink = p
+	}
+}
+
+func BenchmarkAcquirePeek(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := AcquirePeek(wr, nil)
+		p.Header().Set("X-Test", "1")
+		peekSink = p
+		ReleasePeek(p)
+	}
+}
+
+func BenchmarkServingHandleError(b *testing.B) {
+	b.StopTimer()
+	b.ReportAllocs()
+	h := Stack(&context{}, HandleError{}, writeString(""))
+	benchmark(h, b)
+}
+
+func mkNextHandlers(num int) []Wrapper {
+	wrappers := make([]Wrapper, num)
+	for i := 0; i < num; i++ {
+		wrappers[i] = NextHandler(writeString(""))
+	}
+	return wrappers
+}
+
+func BenchmarkServing100NextHandlersNew(b *testing.B) {
+	b.StopTimer()
+	benchmark(New(mkNextHandlers(100)...), b)
+}
+
+func BenchmarkServing100NextHandlersCompiled(b *testing.B) {
+	b.StopTimer()
+	benchmark(Compile(mkNextHandlers(100)...), b)
+}
+
+// realisticHeaders are the headers a typical response has
+var realisticHeaders = map[string]string{
+	"Content-Type":   "text/html; charset=utf-8",
+	"Cache-Control":  "no-cache",
+	"Etag":           `"abc"`,
+	"Vary":           "Accept-Encoding",
+	"X-Request-Id":   "123",
+	"Server-Timing":  "app;dur=1",
+	"Content-Length": "4",
+	"Last-Modified":  "Mon, 02 Jan 2006 15:04:05 GMT",
+}
+
+// bufferSink lets the Buffers of the benchmarks escape to the heap, like the Buffers of handlers do
+var bufferSink *Buffer
+
+func BenchmarkNewBufferHeaders(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := NewBuffer(wr)
+		for k, v := range realisticHeaders {
+			bf.Header().Set(k, v)
+		}
+		bf.Write([]byte("body"))
+		bufferSink = bf
+	}
+}
+
+func BenchmarkAcquireBufferHeaders(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bf := AcquireBuffer(wr)
+		for k, v := range realisticHeaders {
+			bf.Header().Set(k, v)
+		}
+		bf.Write([]byte("body"))
+		bufferSink = bf
+		ReleaseBuffer(bf)
+	}
+}
+
+func BenchmarkNewPeekHeaders(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := NewPeek(wr, nil)
+		for k, v := range realisticHeaders {
+			p.Header().Set(k, v)
+		}
+		peekSink = p
+	}
+}
+
+func BenchmarkAcquirePeekHeaders(b *testing.B) {
+	wr, _ := mkRequestResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := AcquirePeek(wr, nil)
+		for k, v := range realisticHeaders {
+			p.Header().Set(k, v)
+		}
+		peekSink = p
+		ReleasePeek(p)
+	}
+}
+
+// flushWriter is a http.Flusher that does nothing
+type flushWriter struct{ noHTTPWriter }
+
+func (flushWriter) Flush() {}
+
+// flushingContext returns a ResponseWriter that flushes through a Contexter and two statusRecorders
+func flushingContext() http.ResponseWriter {
+	var rw http.ResponseWriter = &context{ResponseWriter: flushWriter{}}
+	rw = newStatusRecorder(rw).responseWriter()
+	return newStatusRecorder(rw).responseWriter()
+}
+
+func BenchmarkFlushReclaim(b *testing.B) {
+	rw := flushingContext()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Flush(rw)
+	}
+}
+
+func BenchmarkFlushCached(b *testing.B) {
+	rw := flushingContext()
+	fl := rw.(http.Flusher)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fl.Flush()
+	}
+}
+
+// discardDebugger is a Debugger that discards everything
+type discardDebugger struct{}
+
+func (discardDebugger) Debug(req *http.Request, obj interface{}, role string) {}
+
+// discardInfoDebugger is an InfoDebugger that discards everything
+type discardInfoDebugger struct{ discardDebugger }
+
+func (discardInfoDebugger) DebugInfo(req *http.Request, info *DebugInfo) {}
+
+func benchmarkDebug(dbg Debugger, b *testing.B) {
+	b.StopTimer()
+	old := CurrentDebugger()
+	SetDebugger(dbg)
+	SetDebug()
+	h := New(mkNextHandlers(10)...)
+	b.ReportAllocs()
+	benchmark(h, b)
+	DisableDebug()
+	SetDebugger(old)
+}
+
+func BenchmarkDebugDiscard(b *testing.B) {
+	benchmarkDebug(discardDebugger{}, b)
+}
+
+func BenchmarkDebugInfoDiscard(b *testing.B) {
+	benchmarkDebug(discardInfoDebugger{}, b)
+}
+
+// benchmarkServeFile serves a file of 8MB via http.ServeFile through the given wrappers
+// from a real server, so that sendfile applies if the body reaches the connection via ReadFrom
+func benchmarkServeFile(b *testing.B, wrappers ...Wrapper) {
+	b.StopTimer()
+	file := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(file, bytes.Repeat([]byte("0123456789abcdef"), 1<<19), 0644); err != nil {
+		b.Fatal(err)
+	}
+	wrappers = append(wrappers, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.ServeFile(rw, req, file)
+	}))
+	srv := httptest.NewServer(New(wrappers...))
+	defer srv.Close()
+	// the client must not accept gzip, otherwise Compress has to encode the file
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	b.SetBytes(16 << 19)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+}
+
+// fileServingStack is a typical stack of wrappers that do not change the served file
+func fileServingStack() []Wrapper {
+	return []Wrapper{
+		AccessLog{Out: io.Discard},
+		Compress{},
+		Transform{Transformers: []BodyTransformer{stripSpaces}},
+		Include{},
+		RewriteHTML{},
+		Preload{},
+	}
+}
+
+// writeOnlyResponse hides the io.ReaderFrom of the ResponseWriter, so that the body is written via Write
+type writeOnlyResponse struct{ http.ResponseWriter }
+
+func hideReaderFrom(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(writeOnlyResponse{rw}, req)
+	}
+	return f
+}
+
+func BenchmarkServeFileDirect(b *testing.B) {
+	benchmarkServeFile(b)
+}
+
+func BenchmarkServeFileStack(b *testing.B) {
+	benchmarkServeFile(b, fileServingStack()...)
+}
+
+func BenchmarkServeFileStackWrite(b *testing.B) {
+	benchmarkServeFile(b, append(fileServingStack(), WrapperFunc(hideReaderFrom))...)
+}
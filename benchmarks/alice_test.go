@@ -0,0 +1,13 @@
+//go:build alice
+
+package benchmarks
+
+import (
+	"testing"
+
+	"github.com/justinas/alice"
+)
+
+func BenchmarkAlice(b *testing.B) {
+	benchmarkStack(b, alice.New(constructors()...).Then(Final))
+}
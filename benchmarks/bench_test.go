@@ -0,0 +1,131 @@
+package benchmarks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// headerWrapper is a wrap.Wrapper setting a header
+type headerWrapper string
+
+func (h headerWrapper) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set(string(h), "1")
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// headerNextHandler is a wrap.NextHandler setting a header
+type headerNextHandler string
+
+func (h headerNextHandler) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set(string(h), "1")
+	next.ServeHTTP(rw, req)
+}
+
+// contexter is a minimal Contexter for the Stack benchmark
+type contexter struct {
+	http.ResponseWriter
+}
+
+func (c *contexter) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	default:
+		panic(&wrap.ErrUnsupportedContextGetter{Type: ctxPtr})
+	}
+	return true
+}
+
+func (c *contexter) SetContext(ctxPtr interface{}) {
+	panic(&wrap.ErrUnsupportedContextSetter{Type: ctxPtr})
+}
+
+func (c *contexter) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&contexter{rw}, req)
+	}
+	return f
+}
+
+// benchmarkStack serves requests with h, checking the response once
+func benchmarkStack(b *testing.B, h http.Handler) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Body.String() != "ok" || rec.Header().Get(HeaderName(Depth-1)) != "1" {
+		b.Fatalf("stack does not respond as expected: %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rw := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := range rw.HeaderMap {
+			delete(rw.HeaderMap, k)
+		}
+		rw.Body.Reset()
+		h.ServeHTTP(rw, req)
+	}
+}
+
+func wrappers() []wrap.Wrapper {
+	ws := make([]wrap.Wrapper, 0, Depth+1)
+	for i := 0; i < Depth; i++ {
+		ws = append(ws, headerWrapper(HeaderName(i)))
+	}
+	return append(ws, wrap.Handler(Final))
+}
+
+func nextHandlers() []wrap.Wrapper {
+	ws := make([]wrap.Wrapper, 0, Depth+1)
+	for i := 0; i < Depth; i++ {
+		ws = append(ws, wrap.NextHandler(headerNextHandler(HeaderName(i))))
+	}
+	return append(ws, wrap.Handler(Final))
+}
+
+func constructors() []func(http.Handler) http.Handler {
+	cs := make([]func(http.Handler) http.Handler, Depth)
+	for i := range cs {
+		cs[i] = SetHeader(HeaderName(i))
+	}
+	return cs
+}
+
+func BenchmarkClosures(b *testing.B) {
+	var h http.Handler = Final
+	cs := constructors()
+	for i := len(cs) - 1; i >= 0; i-- {
+		h = cs[i](h)
+	}
+	benchmarkStack(b, h)
+}
+
+func BenchmarkWrapNew(b *testing.B) {
+	benchmarkStack(b, wrap.New(wrappers()...))
+}
+
+func BenchmarkWrapNewNextHandlers(b *testing.B) {
+	benchmarkStack(b, wrap.New(nextHandlers()...))
+}
+
+func BenchmarkWrapCompileNextHandlers(b *testing.B) {
+	benchmarkStack(b, wrap.Compile(nextHandlers()...))
+}
+
+func BenchmarkWrapStack(b *testing.B) {
+	benchmarkStack(b, wrap.Stack(&contexter{}, wrappers()...))
+}
+
+func BenchmarkWrapFromConstructors(b *testing.B) {
+	ws := append(wrap.FromConstructors(constructors()...), wrap.Handler(Final))
+	benchmarkStack(b, wrap.New(ws...))
+}
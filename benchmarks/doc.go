@@ -0,0 +1,49 @@
+// Package benchmarks compares the serving performance (ns/op and allocs/op) of equivalent middleware
+// stacks built with wrap (New, Compile, Stack and adapted constructors) and with plain nested http.Handler
+// closures, so that the performance claims of the documentation are reproducible:
+//
+//	go test -bench . -benchmem ./benchmarks
+//
+// Stacks built with alice and negroni are benchmarked behind the build tags alice and negroni, since
+// these libraries are no dependencies of wrap:
+//
+//	go get github.com/justinas/alice github.com/urfave/negroni
+//	go test -tags "alice negroni" -bench . -benchmem ./benchmarks
+//
+// Each stack consists of Depth middlewares setting a header and calling the next handler,
+// followed by a handler writing a short body.
+package benchmarks
+
+import "net/http"
+
+// Depth is the number of middlewares of the benchmarked stacks
+const Depth = 10
+
+// headerNames are the names of the headers set by the middlewares
+var headerNames = func() []string {
+	names := make([]string, Depth)
+	for i := range names {
+		names[i] = http.CanonicalHeaderKey("X-Middleware-" + string(rune('a'+i)))
+	}
+	return names
+}()
+
+// HeaderName returns the name of the header set by the i-th middleware
+func HeaderName(i int) string {
+	return headerNames[i]
+}
+
+// SetHeader returns an alice-style constructor of a middleware setting the given header
+func SetHeader(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set(name, "1")
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// Final is the handler at the end of the stacks
+var Final = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte("ok"))
+})
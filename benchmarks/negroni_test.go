@@ -0,0 +1,23 @@
+//go:build negroni
+
+package benchmarks
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/urfave/negroni"
+)
+
+func BenchmarkNegroni(b *testing.B) {
+	n := negroni.New()
+	for i := 0; i < Depth; i++ {
+		name := HeaderName(i)
+		n.UseFunc(func(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+			rw.Header().Set(name, "1")
+			next(rw, req)
+		})
+	}
+	n.UseHandler(Final)
+	benchmarkStack(b, n)
+}
@@ -0,0 +1,247 @@
+package wrap
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a Breaker
+type BreakerState int
+
+const (
+	// BreakerClosed is the normal state where requests are passed to the next handler
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen is the state where the fallback is served
+	BreakerOpen
+
+	// BreakerHalfOpen is the state after the cool-down where a single request is passed to
+	// the next handler to find out if it has recovered
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a Wrapper implementing a circuit breaker for the next handlers.
+//
+// While closed, it tracks the failure rate of the next handlers within a time window. A request
+// fails if the next handlers respond with a 5xx status code, take longer than Timeout or panic.
+// If the failure rate reaches Threshold (and at least MinRequests have been served within the window),
+// the breaker opens and serves the Fallback instead of the next handlers. After CoolDown the breaker
+// is half-open and passes a single request to the next handlers: if it succeeds, the breaker closes,
+// otherwise it opens again.
+//
+// A Breaker must be used as pointer and not be copied after first use. The zero value is ready to use
+// with the defaults described at the fields.
+type Breaker struct {
+	// Threshold is the failure rate (between 0 and 1) that opens the breaker. Defaults to 0.5.
+	Threshold float64
+
+	// MinRequests is the number of requests within the window that are needed before
+	// the breaker may open. Defaults to 10.
+	MinRequests int
+
+	// Window is the duration after which the counted requests and failures are reset. Defaults to 10s.
+	Window time.Duration
+
+	// CoolDown is the duration the breaker stays open before it is half-open. Defaults to 5s.
+	CoolDown time.Duration
+
+	// Timeout is the duration after which a request is counted as failure (it is not canceled).
+	// No timeout if 0.
+	Timeout time.Duration
+
+	// Fallback is served while the breaker is open. Defaults to a 503 Service Unavailable.
+	Fallback http.Handler
+
+	// OnStateChange is called after the state has changed (optional)
+	OnStateChange func(from, to BreakerState)
+
+	// OnReject is called when a request is served by the Fallback (optional)
+	OnReject func(req *http.Request, state BreakerState)
+
+	mx          sync.Mutex
+	state       BreakerState
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     bool
+
+	// now returns the current time; for testing
+	now func() time.Time
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = &Breaker{}
+
+func (b *Breaker) threshold() float64 {
+	if b.Threshold <= 0 {
+		return 0.5
+	}
+	return b.Threshold
+}
+
+func (b *Breaker) minRequests() int {
+	if b.MinRequests <= 0 {
+		return 10
+	}
+	return b.MinRequests
+}
+
+func (b *Breaker) window() time.Duration {
+	if b.Window <= 0 {
+		return 10 * time.Second
+	}
+	return b.Window
+}
+
+func (b *Breaker) coolDown() time.Duration {
+	if b.CoolDown <= 0 {
+		return 5 * time.Second
+	}
+	return b.CoolDown
+}
+
+func (b *Breaker) timeNow() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.state
+}
+
+// transition sets the state and resets the counters. mx must be locked.
+// It returns a function that calls OnStateChange and must be called after unlocking mx.
+func (b *Breaker) transition(to BreakerState, now time.Time) func() {
+	from := b.state
+	b.state = to
+	b.requests, b.failures = 0, 0
+	b.windowStart = now
+	if to == BreakerOpen {
+		b.openedAt = now
+	}
+	if from == to || b.OnStateChange == nil {
+		return func() {}
+	}
+	return func() { b.OnStateChange(from, to) }
+}
+
+// allow returns if the request may be passed to the next handler and if it is the probe of
+// a half-open breaker
+func (b *Breaker) allow() (allowed bool, probe bool) {
+	b.mx.Lock()
+	notify := func() {}
+	defer func() {
+		b.mx.Unlock()
+		notify()
+	}()
+
+	now := b.timeNow()
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.window() {
+			b.requests, b.failures = 0, 0
+			b.windowStart = now
+		}
+		return true, false
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.coolDown() {
+			return false, false
+		}
+		notify = b.transition(BreakerHalfOpen, now)
+	}
+
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// done records the result of a request that has been passed to the next handler
+func (b *Breaker) done(probe bool, failed bool) {
+	b.mx.Lock()
+	notify := func() {}
+	defer func() {
+		b.mx.Unlock()
+		notify()
+	}()
+
+	now := b.timeNow()
+	if probe {
+		b.probing = false
+		if failed {
+			notify = b.transition(BreakerOpen, now)
+			return
+		}
+		notify = b.transition(BreakerClosed, now)
+		return
+	}
+
+	if b.state != BreakerClosed {
+		return
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= b.minRequests() && float64(b.failures)/float64(b.requests) >= b.threshold() {
+		notify = b.transition(BreakerOpen, now)
+	}
+}
+
+// reject serves the fallback
+func (b *Breaker) reject(rw http.ResponseWriter, req *http.Request) {
+	if b.OnReject != nil {
+		b.OnReject(req, b.State())
+	}
+	if b.Fallback != nil {
+		b.Fallback.ServeHTTP(rw, req)
+		return
+	}
+	http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// Wrap implements the Wrapper interface
+func (b *Breaker) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		allowed, probe := b.allow()
+		if !allowed {
+			b.reject(rw, req)
+			return
+		}
+
+		rec := newStatusRecorder(rw)
+		start := b.timeNow()
+		failed := true
+		defer func() {
+			b.done(probe, failed)
+		}()
+		next.ServeHTTP(rec.responseWriter(), req)
+		failed = rec.Status() >= 500 || (b.Timeout > 0 && b.timeNow().Sub(start) > b.Timeout)
+	}
+	return f
+}
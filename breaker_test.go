@@ -0,0 +1,89 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusHandler writes its status code
+type statusHandler int
+
+func (s statusHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.WriteHeader(int(s))
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	var changes []string
+	b := &Breaker{
+		MinRequests: 2,
+		CoolDown:    time.Second,
+		Fallback:    writeStop("fallback"),
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, from.String()+">"+to.String())
+		},
+		now: func() time.Time { return now },
+	}
+
+	var status = statusHandler(500)
+	h := New(b, Handler(&status))
+
+	serve := func(body string, code int) {
+		t.Helper()
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, body, code)
+	}
+
+	serve("", 500)
+	if b.State() != BreakerClosed {
+		t.Fatalf("breaker should be closed after one failure")
+	}
+	serve("", 500)
+	if b.State() != BreakerOpen {
+		t.Fatalf("breaker should be open after two failures")
+	}
+	serve("fallback", 200)
+
+	now = now.Add(2 * time.Second)
+	status = 200
+	serve("", 200)
+	if b.State() != BreakerClosed {
+		t.Fatalf("breaker should be closed after successful probe, but is %s", b.State())
+	}
+
+	status = 503
+	serve("", 503)
+	serve("", 503)
+	now = now.Add(2 * time.Second)
+	serve("", 503)
+	if b.State() != BreakerOpen {
+		t.Fatalf("breaker should be open after failed probe, but is %s", b.State())
+	}
+
+	expected := "closed>open,open>half-open,half-open>closed,closed>open,open>half-open,half-open>open"
+	if got := strings.Join(changes, ","); got != expected {
+		t.Errorf("expected state changes %s, got %s", expected, got)
+	}
+}
+
+func TestBreakerTimeoutAndDefaults(t *testing.T) {
+	now := time.Now()
+	b := &Breaker{MinRequests: 1, Timeout: time.Second, now: func() time.Time { return now }}
+	h := New(b, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		now = now.Add(2 * time.Second)
+	}))
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	if b.State() != BreakerOpen {
+		t.Fatalf("slow request should open the breaker")
+	}
+
+	rec, req = newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "Service Unavailable", 503)
+}
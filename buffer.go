@@ -2,7 +2,9 @@ package wrap
 
 import (
 	"bytes"
+	"io"
 	"net/http"
+	"sync"
 )
 
 // Buffer is a ResponseWriter wrapper that may be used as buffer.
@@ -23,6 +25,22 @@ type Buffer struct {
 
 	// header is the cached header
 	header http.Header
+
+	// threshold is the size of the body above which the body is streamed (or passed through, if stream is nil),
+	// 0 if it is never streamed
+	threshold int
+
+	// passing is true, if the body exceeded the threshold of NewPassThroughBuffer and is passed through
+	passing bool
+
+	// stream consumes the streamed body
+	stream func(bf *Buffer, body io.Reader)
+
+	// pw is the writer of the pipe the body is streamed to, nil if the body is not streamed
+	pw *io.PipeWriter
+
+	// streamed is closed when stream has returned
+	streamed chan struct{}
 }
 
 // make sure to fulfill the Contexter interface
@@ -36,34 +54,170 @@ func NewBuffer(w http.ResponseWriter) (bf *Buffer) {
 	return
 }
 
-// Context gets the context of the underlying response writer. It panics if the underlying response writer
-// does no implement Contexter
+// maxPooledBuffer is the capacity above which a released Buffer is not pooled, so that single
+// large responses do not keep their memory
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds released Buffers for reuse
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &Buffer{header: make(http.Header)}
+	},
+}
+
+// AcquireBuffer is like NewBuffer but takes the Buffer from a pool, saving the allocation of the Buffer,
+// its header map and (mostly) its body buffer per request. The Buffer should be returned via ReleaseBuffer
+// when the request has been served.
+func AcquireBuffer(w http.ResponseWriter) *Buffer {
+	bf := bufferPool.Get().(*Buffer)
+	bf.ResponseWriter = w
+	return bf
+}
+
+// ReleaseBuffer resets the Buffer and returns it to the pool of AcquireBuffer. Neither the Buffer nor
+// its header map and body may be used afterwards. A streaming Buffer must be released after Done.
+func ReleaseBuffer(bf *Buffer) {
+	for k := range bf.header {
+		delete(bf.header, k)
+	}
+	bf.Buffer.Reset()
+	bf.ResponseWriter = nil
+	bf.Code = 0
+	bf.changed = false
+	bf.threshold, bf.stream, bf.pw, bf.streamed, bf.passing = 0, nil, nil, nil, false
+	if bf.Buffer.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(bf)
+}
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
 func (bf *Buffer) Context(ctxPtr interface{}) bool {
-	return bf.ResponseWriter.(Contexter).Context(ctxPtr)
+	return contextOf(bf.ResponseWriter, ctxPtr)
 }
 
 // SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
 // does no implement Contexter
 func (bf *Buffer) SetContext(ctxPtr interface{}) {
-	bf.ResponseWriter.(Contexter).SetContext(ctxPtr)
+	setContextOf(bf.ResponseWriter, ctxPtr)
 }
 
-// Header returns the cached http.Header and tracks this call as change
+// Header returns the cached http.Header and tracks this call as change.
+// If the body is passed through (see NewPassThroughBuffer), it returns the header of the underlying ResponseWriter.
 func (bf *Buffer) Header() http.Header {
 	bf.changed = true
+	if bf.passing {
+		return bf.ResponseWriter.Header()
+	}
 	return bf.header
 }
 
-// WriteHeader writes the cached status code and tracks this call as change
+// WriteHeader writes the cached status code and tracks this call as change.
+// It does nothing, if the body is passed through (see NewPassThroughBuffer).
 func (bf *Buffer) WriteHeader(i int) {
 	bf.changed = true
+	if bf.passing {
+		return
+	}
 	bf.Code = i
 }
 
-// Write writes to the underlying buffer and tracks this call as change
+// Write writes to the underlying buffer and tracks this call as change.
+// If the Buffer has been created by NewStreamBuffer and the body exceeds the threshold, it writes to
+// the stream instead. If it has been created by NewPassThroughBuffer and the body exceeds the threshold,
+// it writes to the underlying ResponseWriter.
 func (bf *Buffer) Write(b []byte) (int, error) {
 	bf.changed = true
-	return bf.Buffer.Write(b)
+	if bf.pw != nil {
+		return bf.pw.Write(b)
+	}
+	if bf.passing {
+		return bf.ResponseWriter.Write(b)
+	}
+	n, err := bf.Buffer.Write(b)
+	if bf.threshold > 0 && bf.Buffer.Len() > bf.threshold {
+		if bf.stream == nil {
+			return n, bf.startPassThrough()
+		}
+		bf.startStream()
+	}
+	return n, err
+}
+
+// NewPassThroughBuffer creates a Buffer that switches to passing the body through once it exceeds threshold
+// bytes: the cached headers and status code and the buffered bytes are then flushed to the underlying
+// ResponseWriter and the following writes go to it directly, so that wrappers that only need to inspect
+// small responses do not buffer large downloads. They must check IsPassingThrough before they modify
+// the response.
+//
+// After the switch, Header returns the header of the underlying ResponseWriter, WriteHeader does nothing,
+// the body of the Buffer is empty and FlushAll does nothing. If the body does not exceed the threshold,
+// the Buffer behaves like one created by NewBuffer.
+func NewPassThroughBuffer(w http.ResponseWriter, threshold int) *Buffer {
+	bf := NewBuffer(w)
+	bf.threshold = threshold
+	return bf
+}
+
+// startPassThrough flushes the headers, status code and buffered bytes and lets the following writes
+// pass through
+func (bf *Buffer) startPassThrough() error {
+	bf.FlushHeaders()
+	bf.FlushCode()
+	_, err := bf.ResponseWriter.Write(bf.Buffer.Bytes())
+	bf.Buffer.Reset()
+	bf.passing = true
+	return err
+}
+
+// IsPassingThrough returns if the body exceeded the threshold of NewPassThroughBuffer and is passed through
+func (bf *Buffer) IsPassingThrough() bool {
+	return bf.passing
+}
+
+// NewStreamBuffer creates a Buffer that switches to streaming once the body exceeds threshold bytes:
+// stream is then run in a new goroutine, receiving the buffered bytes followed by everything that is
+// written afterwards, while it is written. Write blocks until stream has read the data, so the body
+// is never held completely in memory. Stream may check the headers and status code (which must
+// therefore be set before the body exceeds the threshold) and write to the underlying ResponseWriter.
+// If stream returns before it has read everything, the following writes fail with io.ErrClosedPipe.
+//
+// If the body does not exceed the threshold, the Buffer behaves like one created by NewBuffer.
+// When the request has been served, Done must be called to end the stream.
+func NewStreamBuffer(w http.ResponseWriter, threshold int, stream func(bf *Buffer, body io.Reader)) *Buffer {
+	bf := NewBuffer(w)
+	bf.threshold = threshold
+	bf.stream = stream
+	return bf
+}
+
+// startStream runs the stream function with the buffered bytes and a pipe that receives the following writes
+func (bf *Buffer) startStream() {
+	pr, pw := io.Pipe()
+	bf.pw = pw
+	bf.streamed = make(chan struct{})
+	head := bytes.NewReader(bf.Buffer.Bytes())
+	go func() {
+		defer close(bf.streamed)
+		bf.stream(bf, io.MultiReader(head, pr))
+		pr.CloseWithError(io.ErrClosedPipe)
+	}()
+}
+
+// IsStreaming returns if the body exceeded the threshold of NewStreamBuffer and is streamed
+func (bf *Buffer) IsStreaming() bool {
+	return bf.pw != nil
+}
+
+// Done ends the stream of a streaming Buffer and waits for the stream function to return.
+// It does nothing if the Buffer is not streaming.
+func (bf *Buffer) Done() {
+	if bf.pw == nil {
+		return
+	}
+	bf.pw.Close()
+	<-bf.streamed
 }
 
 // Reset set the Buffer to the defaults
@@ -75,8 +229,9 @@ func (bf *Buffer) Reset() {
 }
 
 // FlushAll flushes headers, status code and body to the underlying ResponseWriter, if something changed
+// (and the body is not passed through, see NewPassThroughBuffer)
 func (bf *Buffer) FlushAll() {
-	if bf.HasChanged() {
+	if bf.HasChanged() && !bf.passing {
 		bf.FlushHeaders()
 		bf.FlushCode()
 		bf.ResponseWriter.Write(bf.Buffer.Bytes())
@@ -0,0 +1,68 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+)
+
+// DefaultMemLimit is the number of bytes of a body that BufferBody keeps in memory, if MemLimit is not set
+var DefaultMemLimit int64 = 1 << 20
+
+// BufferRequest reads the body of req completely and replaces it with the returned BufferedBody.
+// Bodies up to memLimit bytes are kept in memory, larger ones are spilled over to a temporary file
+// that is removed by Close. It also sets req.GetBody, so that the body can be read again, e.g. by
+// RetryTransport when the request is sent to another server.
+func BufferRequest(req *http.Request, memLimit int64) (*BufferedBody, error) {
+	bb, err := bufferBody(req.Body, memLimit)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = bb
+		req.GetBody = func() (io.ReadCloser, error) {
+			return bb.NewReader(), nil
+		}
+	}
+	req.ContentLength = bb.size
+	return bb, nil
+}
+
+// BufferBody is a Wrapper that buffers the request body (see BufferRequest), so that wrappers like
+// VerifySignature or an audit log may read it via req.GetBody without starving the next handlers.
+// The temporary file of a large body is removed after the next handler has been served.
+//
+// If reading the body fails, the request is rejected with 400 Bad Request or, if the body is
+// limited by a LimitBody that ran before, with 413 Request Entity Too Large (see HTTPError for how the
+// error is reported).
+type BufferBody struct {
+	// MemLimit is the number of bytes that are kept in memory, defaults to DefaultMemLimit
+	MemLimit int64
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = BufferBody{}
+
+// Wrap implements the Wrapper interface
+func (b BufferBody) Wrap(next http.Handler) http.Handler {
+	memLimit := b.MemLimit
+	if memLimit <= 0 {
+		memLimit = DefaultMemLimit
+	}
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		req = req.WithContext(req.Context())
+		bb, err := BufferRequest(req, memLimit)
+		if err != nil {
+			code := http.StatusBadRequest
+			if BodyTooLarge(err) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			reportError(rw, &HTTPError{Code: code, Err: err})
+			return
+		}
+		defer bb.Close()
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,57 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readGetBody writes the body read via GetBody followed by the body
+func readGetBody(rw http.ResponseWriter, req *http.Request) {
+	rc, _ := req.GetBody()
+	b, _ := io.ReadAll(rc)
+	rw.Write(b)
+	rw.Write([]byte("|"))
+	b, _ = io.ReadAll(req.Body)
+	rw.Write(b)
+}
+
+func TestBufferBody(t *testing.T) {
+	tests := []struct {
+		h    http.Handler
+		exp  string
+		code int
+	}{
+		{New(BufferBody{}, HandlerFunc(readGetBody)), "hello world|hello world", 200},
+		{New(BufferBody{MemLimit: 3}, HandlerFunc(readGetBody)), "hello world|hello world", 200},
+		{New(LimitBody{Max: 5}, BufferBody{}, HandlerFunc(readGetBody)), "Request Entity Too Large", 413},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		req, _ := http.NewRequest("POST", "/", strings.NewReader("hello world"))
+		req.ContentLength = -1
+		test.h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
+
+func TestBufferRequestSpillover(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("hello world"))
+	bb, err := BufferRequest(req, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bb.Bytes() != nil || bb.Size() != 11 || req.ContentLength != 11 {
+		t.Errorf("body should be spilled over with size 11, got %q and size %d", bb.Bytes(), bb.Size())
+	}
+
+	name := bb.file.Name()
+	bb.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("temporary file %s should be removed", name)
+	}
+}
@@ -0,0 +1,157 @@
+package wrap
+
+import (
+	stdcontext "context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BufferedTimeout is a Wrapper like http.TimeoutHandler, but native to this package: the next handlers run
+// in their own goroutine and write to a Buffer that keeps the Contexter of the ResponseWriter, so that context
+// assertions downstream keep working (http.TimeoutHandler passes its own ResponseWriter type).
+//
+// If the next handlers finish within Duration, the buffered response is written. Otherwise the buffered
+// response is discarded, the following writes of the next handlers fail with http.ErrHandlerTimeout and the
+// request is answered with 503 Service Unavailable through the error convention of this package
+// (see HTTPError for how the error is reported). If the client goes away before, nothing is written.
+// Panics of the next handlers are passed on to the goroutine that serves the request.
+//
+// The request context gets the deadline and if the ResponseWriter is a Contexter supporting the Deadline
+// type, the deadline is stored, like Timeout does. Since the response is buffered, BufferedTimeout is not
+// suitable for streaming responses, use Timeout instead.
+type BufferedTimeout struct {
+	Duration time.Duration
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = BufferedTimeout{}
+
+// timeoutWriter is the ResponseWriter passed to the next handlers by BufferedTimeout.
+// After the timeout, writes fail and the context of the underlying ResponseWriter is not accessed anymore,
+// since the error is reported through it.
+type timeoutWriter struct {
+	mx       sync.Mutex
+	bf       *Buffer
+	timedOut bool
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &timeoutWriter{}
+
+// Header returns the header of the buffer, or a new header after the timeout
+func (t *timeoutWriter) Header() http.Header {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.timedOut {
+		return http.Header{}
+	}
+	return t.bf.Header()
+}
+
+// WriteHeader writes the status code to the buffer, unless timed out
+func (t *timeoutWriter) WriteHeader(code int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if !t.timedOut {
+		t.bf.WriteHeader(code)
+	}
+}
+
+// Write writes to the buffer, after the timeout it fails with http.ErrHandlerTimeout
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return t.bf.Write(b)
+}
+
+// Context gets the context of the underlying response writer. After the timeout nothing is found.
+func (t *timeoutWriter) Context(ctxPtr interface{}) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.timedOut {
+		return false
+	}
+	return t.bf.Context(ctxPtr)
+}
+
+// SetContext sets the context of the underlying response writer. After the timeout it does nothing.
+func (t *timeoutWriter) SetContext(ctxPtr interface{}) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if !t.timedOut {
+		t.bf.SetContext(ctxPtr)
+	}
+}
+
+// timeOut marks the writer as timed out, so that the next handlers can't access the ResponseWriter anymore
+func (t *timeoutWriter) timeOut() {
+	t.mx.Lock()
+	t.timedOut = true
+	t.mx.Unlock()
+}
+
+// Wrap implements the Wrapper interface
+func (t BufferedTimeout) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		ctx, cancel := stdcontext.WithTimeout(req.Context(), t.Duration)
+		defer cancel()
+		at, _ := ctx.Deadline()
+
+		dl := Deadline{At: at, Cancel: cancel}
+		if _, ok := rw.(Contexter); ok {
+			TrySetContext(rw, &dl)
+		}
+
+		bf := NewBuffer(rw)
+		tw := &timeoutWriter{bf: bf}
+		req = req.WithContext(ctx)
+
+		// the context of probe requests is canceled, serve them synchronously
+		if IsProbe(req) {
+			next.ServeHTTP(tw, req)
+			bf.FlushAll()
+			return
+		}
+
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, req)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			bf.FlushAll()
+		case <-ctx.Done():
+			tw.timeOut()
+			// the next handlers may have finished at the same time
+			select {
+			case <-done:
+				bf.FlushAll()
+				return
+			default:
+			}
+			if ctx.Err() == stdcontext.DeadlineExceeded {
+				reportError(rw, &HTTPError{Code: http.StatusServiceUnavailable, Err: ctx.Err()})
+			}
+		}
+	}
+	return f
+}
@@ -0,0 +1,812 @@
+package wrap
+
+import (
+	"container/list"
+	stdcontext "context"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheableStatus are the status codes that are cacheable by default (RFC 7231, 6.1)
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// cacheEntry is a cached response
+type cacheEntry struct {
+	key string
+	url string
+
+	// base is the method and URL, the key of the cacheVary
+	base     string
+	snapshot *Snapshot
+	stored   time.Time
+	expires  time.Time
+
+	// size is the size of the entry in memory, diskSize the size of the body on disk
+	size     int
+	diskSize int64
+
+	// chunks is the body, if it is stored on disk (the body of the snapshot is nil then)
+	chunks *chunkStore
+
+	// staleWhileRevalidate is the point in time until the entry may be served stale while it is refreshed
+	staleWhileRevalidate time.Time
+
+	// staleIfError is the point in time until the entry may be served stale if the next handlers fail
+	staleIfError time.Time
+}
+
+// cacheVary are the names of the request headers that the cached responses for a method and URL vary on
+type cacheVary struct {
+	names []string
+
+	// entries is the number of cached responses for the method and URL
+	entries int
+
+	// size is the size of the method, URL and names in memory
+	size int
+}
+
+// newCacheVary returns the cacheVary for the given method and URL
+func newCacheVary(base string, names []string) *cacheVary {
+	size := len(base)
+	for _, n := range names {
+		size += len(n)
+	}
+	return &cacheVary{names: names, size: size}
+}
+
+// removable returns the point in time after which the entry can't be served anymore
+func (e *cacheEntry) removable() time.Time {
+	t := e.expires
+	if e.staleWhileRevalidate.After(t) {
+		t = e.staleWhileRevalidate
+	}
+	if e.staleIfError.After(t) {
+		t = e.staleIfError
+	}
+	return t
+}
+
+// Cache is a Wrapper that caches the responses of the next handlers in memory as Snapshot and serves
+// them without running the next handlers until they expire.
+//
+// Only GET and HEAD requests without Authorization header are cached, upgrade requests (see IsUpgrade)
+// bypass the cache. The cache key is made of the method, the URL (host and request URI, e.g.
+// "example.com/path?q=1") and the values of the request headers named by the Vary header of the response,
+// normalized by the Normalizers (so that e.g. all Accept-Encoding values preferring gzip share a cached
+// response). Requests with "Cache-Control: no-store" bypass the cache, requests with "Cache-Control: no-cache"
+// are served by the next handlers and refresh the cache.
+//
+// A response is cached, if its status code is cacheable by default (e.g. 200, 301, 404), it has no
+// Set-Cookie header, no "Vary: *" and its Cache-Control header has neither no-store, no-cache nor private.
+// It expires after the s-maxage or max-age of its Cache-Control header or after TTL. Cached responses
+// are served with an Age header.
+//
+// The response of the next handlers is written to a Buffer (which keeps the Contexter) and flushed after
+// it has been stored, so Cache is not suitable for streaming responses. Since cached responses are served
+// without running the next handlers, context values set by them are missing for wrappers before Cache.
+//
+// An expired response is served stale within the StaleWhileRevalidate duration (or the stale-while-revalidate
+// directive of its Cache-Control header), while a single background request refreshes it. The background
+// request runs the next handlers with a ResponseWriter that is a Contexter supporting only *http.ResponseWriter
+// and a request context without cancellation and deadline. Within the StaleIfError duration (or the
+// stale-if-error directive), an expired response is served instead of a 5xx response or a panic of the
+// next handlers. Concurrent requests for a missing or expired response wait for a single request to the
+// next handlers and share its response, if it is cacheable, to avoid thundering herds.
+//
+// Bodies larger than DiskThreshold are stored in chunk files of ChunkSize bytes inside Dir, if it is set,
+// so that the cache can hold large objects. They are written to the chunk files while the next handlers write
+// them, so that they are never held completely in memory. Range requests for a single byte range (with a matching If-Range,
+// if any) are served from cached responses with status 200 as 206 Partial Content, reading only the chunks
+// covering the range. A Range request for a missing response fetches and stores the complete response.
+// Cached responses with status 200 are served with "Accept-Ranges: bytes".
+//
+// If the cached responses exceed MaxBytes in memory or MaxDiskBytes on disk, the least recently used
+// responses are evicted.
+// Responses may be removed via Invalidate, InvalidatePrefix and Purge.
+//
+// A Cache must be used as pointer and not be copied after first use. The zero value is ready to use
+// with the defaults described at the fields.
+type Cache struct {
+	// TTL is the time to live of responses without max-age. Defaults to one minute.
+	TTL time.Duration
+
+	// MaxBytes is the maximum size of all cached responses in memory (bodies, headers, keys and the names of Vary headers). Defaults to 64 MiB.
+	MaxBytes int
+
+	// Dir is the directory where bodies larger than DiskThreshold are stored. If it is empty, all bodies
+	// are kept in memory.
+	Dir string
+
+	// DiskThreshold is the size above which bodies are stored in Dir. Defaults to 1 MiB.
+	DiskThreshold int
+
+	// ChunkSize is the size of the chunk files in Dir. Defaults to DefaultCacheChunkSize.
+	ChunkSize int
+
+	// MaxDiskBytes is the maximum size of all bodies in Dir. Defaults to 1 GiB.
+	MaxDiskBytes int64
+
+	// StaleWhileRevalidate is the duration after expiry in which a response is served stale while it is refreshed
+	// in the background, unless the response has a stale-while-revalidate directive
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError is the duration after expiry in which a response is served stale if the next handlers
+	// fail, unless the response has a stale-if-error directive
+	StaleIfError time.Duration
+
+	// Normalizers normalize the values of the request headers named by the Vary header for the cache key,
+	// by canonical header name. If it is nil, DefaultVaryNormalizers are used. Values of headers without
+	// normalizer are used as they are.
+	Normalizers map[string]VaryNormalizer
+
+	flight flightGroup
+
+	mx       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	size     int
+	diskSize int64
+
+	// vary are the names of the request headers that the responses vary on, by method and URL
+	vary map[string]*cacheVary
+
+	// now returns the current time, it is replaced by tests
+	now func() time.Time
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = &Cache{}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return time.Minute
+}
+
+func (c *Cache) maxBytes() int {
+	if c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return 64 << 20
+}
+
+func (c *Cache) diskThreshold() int {
+	if c.DiskThreshold > 0 {
+		return c.DiskThreshold
+	}
+	return 1 << 20
+}
+
+func (c *Cache) chunkSize() int {
+	if c.ChunkSize > 0 {
+		return c.ChunkSize
+	}
+	return DefaultCacheChunkSize
+}
+
+func (c *Cache) maxDiskBytes() int64 {
+	if c.MaxDiskBytes > 0 {
+		return c.MaxDiskBytes
+	}
+	return 1 << 30
+}
+
+func (c *Cache) currentTime() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// init initializes the maps, it must be called with the lock held
+func (c *Cache) init() {
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+		c.lru = list.New()
+		c.vary = map[string]*cacheVary{}
+	}
+}
+
+// cacheURL returns the URL part of the cache key of the request
+func cacheURL(req *http.Request) string {
+	return req.Host + req.URL.RequestURI()
+}
+
+// VaryNormalizer normalizes the value of a request header named by the Vary header of a response
+// for the cache key of Cache, so that equivalent values share a cached response
+type VaryNormalizer func(value string) string
+
+// DefaultVaryNormalizers are the VaryNormalizers of a Cache without Normalizers
+var DefaultVaryNormalizers = map[string]VaryNormalizer{
+	"Accept-Encoding": NormalizeAcceptEncoding,
+	"Accept-Language": NormalizeAcceptLanguage,
+}
+
+// NormalizeAcceptEncoding buckets an Accept-Encoding header value into "br", "gzip" or "identity",
+// the preferred of the encodings that are accepted
+func NormalizeAcceptEncoding(value string) string {
+	values := parseAccept([]string{value})
+	best, bestQ := "identity", 0.0
+	for _, enc := range []string{"br", "gzip"} {
+		q, explicit := acceptQuality(values, enc)
+		if enc == "gzip" && !explicit {
+			if xq, ok := acceptQuality(values, "x-gzip"); ok {
+				q = xq
+			}
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// NormalizeAcceptLanguage case-folds an Accept-Language header value and removes whitespace
+func NormalizeAcceptLanguage(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), ""))
+}
+
+// normalizer returns the VaryNormalizer for the canonical header name, nil if there is none
+func (c *Cache) normalizer(name string) VaryNormalizer {
+	if c.Normalizers != nil {
+		return c.Normalizers[name]
+	}
+	return DefaultVaryNormalizers[name]
+}
+
+// varyKey returns the cache key of the request for the given Vary header names
+func (c *Cache) varyKey(base string, names []string, req *http.Request) string {
+	if len(names) == 0 {
+		return base
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range names {
+		value := strings.Join(req.Header.Values(name), ",")
+		if n := c.normalizer(name); n != nil {
+			value = n(value)
+		}
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// varyNames returns the canonical, sorted header names of the Vary headers, nil and false for "Vary: *"
+func varyNames(header http.Header) (names []string, ok bool) {
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// cacheControl returns the directives of the Cache-Control headers by lowercase name
+func cacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(val, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// lifetime returns how long the response may be cached, 0 if it must not be cached
+func (c *Cache) lifetime(code int, header http.Header) time.Duration {
+	if !cacheableStatus[code] || header.Get("Set-Cookie") != "" {
+		return 0
+	}
+	cc := cacheControl(header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, has := cc[d]; has {
+			return 0
+		}
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, has := cc[d]; has {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs <= 0 {
+				return 0
+			}
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return c.ttl()
+}
+
+// staleDuration returns the duration of the given Cache-Control directive or the default
+func staleDuration(cc map[string]string, directive string, def time.Duration) time.Duration {
+	if v, has := cc[directive]; has {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return def
+}
+
+// lookup returns the cache key of the request and its entry, nil if there is none that can be served
+// (fresh or stale)
+func (c *Cache) lookup(req *http.Request) (string, *cacheEntry) {
+	base := req.Method + " " + cacheURL(req)
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.init()
+	var names []string
+	if v := c.vary[base]; v != nil {
+		names = v.names
+	}
+	key := c.varyKey(base, names, req)
+	el, has := c.entries[key]
+	if !has {
+		return key, nil
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.currentTime().Before(e.removable()) {
+		c.remove(el)
+		return key, nil
+	}
+	c.lru.MoveToFront(el)
+	return key, e
+}
+
+// store stores the response of the request and returns true, if it is cacheable. chunks is the body, if it
+// has been streamed to disk (the body of the snapshot is nil then), it is owned by the entry once it is stored.
+func (c *Cache) store(req *http.Request, s *Snapshot, chunks *chunkStore) bool {
+	code := s.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	ttl := c.lifetime(code, s.Header)
+	if ttl == 0 {
+		return false
+	}
+	names, ok := varyNames(s.Header)
+	if !ok {
+		return false
+	}
+
+	url := cacheURL(req)
+	base := req.Method + " " + url
+	key := c.varyKey(base, names, req)
+	size := len(key)
+	for k, vals := range s.Header {
+		size += len(k)
+		for _, v := range vals {
+			size += len(v)
+		}
+	}
+
+	now := c.currentTime()
+	e := &cacheEntry{key: key, url: url, base: base, snapshot: s, stored: now, expires: now.Add(ttl)}
+	if chunks != nil {
+		if chunks.size > c.maxDiskBytes() {
+			return false
+		}
+		e.chunks, e.diskSize = chunks, chunks.size
+	} else {
+		size += len(s.Body)
+	}
+	vary := newCacheVary(base, names)
+	if size+vary.size > c.maxBytes() {
+		return false
+	}
+	e.size = size
+
+	cc := cacheControl(s.Header)
+	e.staleWhileRevalidate = e.expires.Add(staleDuration(cc, "stale-while-revalidate", c.StaleWhileRevalidate))
+	e.staleIfError = e.expires.Add(staleDuration(cc, "stale-if-error", c.StaleIfError))
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.init()
+	if el, has := c.entries[key]; has {
+		c.remove(el)
+	}
+	if v := c.vary[base]; v != nil {
+		vary.entries = v.entries
+		c.size -= v.size
+	}
+	vary.entries++
+	c.vary[base] = vary
+	c.size += vary.size
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += size
+	c.diskSize += e.diskSize
+	for c.size > c.maxBytes() || c.diskSize > c.maxDiskBytes() {
+		c.remove(c.lru.Back())
+	}
+	return true
+}
+
+// remove removes the entry of the element, it must be called with the lock held
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	c.diskSize -= e.diskSize
+	if e.chunks != nil {
+		e.chunks.discard()
+	}
+	if v := c.vary[e.base]; v != nil {
+		v.entries--
+		if v.entries <= 0 {
+			delete(c.vary, e.base)
+			c.size -= v.size
+		}
+	}
+}
+
+// removeIf removes the entries matching the given function
+func (c *Cache) removeIf(match func(e *cacheEntry) bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.init()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*cacheEntry)) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Invalidate removes the cached responses of all methods and variants for the given URL,
+// i.e. host and request URI, e.g. "example.com/path?q=1"
+func (c *Cache) Invalidate(url string) {
+	c.removeIf(func(e *cacheEntry) bool { return e.url == url })
+}
+
+// InvalidatePrefix removes the cached responses of all URLs (see Invalidate) starting with the given prefix,
+// e.g. "example.com/products/"
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.removeIf(func(e *cacheEntry) bool { return strings.HasPrefix(e.url, prefix) })
+}
+
+// Purge removes all cached responses
+func (c *Cache) Purge() {
+	c.removeIf(func(*cacheEntry) bool { return true })
+}
+
+// Len returns the number of cached responses
+func (c *Cache) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.entries)
+}
+
+// Size returns the size of all cached responses in memory in bytes, see MaxBytes
+func (c *Cache) Size() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.size
+}
+
+// DiskSize returns the size of all cached bodies on disk in bytes, see MaxDiskBytes
+func (c *Cache) DiskSize() int64 {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.diskSize
+}
+
+// cacheable returns if the response to the request may be served from and stored in the cache
+func cacheable(req *http.Request) bool {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || req.Header.Get("Authorization") != "" || IsProbe(req) || IsUpgrade(req) {
+		return false
+	}
+	_, noStore := cacheControl(req.Header)["no-store"]
+	return !noStore
+}
+
+// serve serves the cached entry, adding the Age header, and serves Range requests. It returns false without
+// writing anything, if the body of the entry has been removed from disk in the meantime.
+func (e *cacheEntry) serve(rw http.ResponseWriter, req *http.Request, now time.Time) bool {
+	if e.chunks != nil {
+		if !e.chunks.acquire() {
+			return false
+		}
+		defer e.chunks.release()
+	}
+	header := rw.Header()
+	for k, v := range e.snapshot.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	code := e.snapshot.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	size := e.length()
+	start, end := int64(0), size
+	if code == http.StatusOK {
+		header.Set("Accept-Ranges", "bytes")
+		if r := req.Header.Get("Range"); r != "" && req.Method == http.MethodGet && ifRangeMatches(req, e.snapshot.Header) {
+			if s, en, ok, satisfiable := byteRange(r, size); ok {
+				if !satisfiable {
+					header.Del("Content-Length")
+					header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+					rw.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					return true
+				}
+				start, end, code = s, en, http.StatusPartialContent
+				header.Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end-1, 10)+"/"+strconv.FormatInt(size, 10))
+			}
+		}
+	}
+	if e.chunks != nil || code == http.StatusPartialContent {
+		header.Set("Content-Length", strconv.FormatInt(end-start, 10))
+	}
+	rw.WriteHeader(code)
+	if req.Method != http.MethodHead {
+		e.writeBody(rw, start, end)
+	}
+	return true
+}
+
+// cacheFetch is the result of a request to the next handlers shared by concurrent requests
+type cacheFetch struct {
+	snapshot *Snapshot
+
+	// chunks is the body, if it exceeded the DiskThreshold and has been streamed to Dir
+	chunks *chunkStore
+
+	// err is the error that prevented the body from being streamed to Dir
+	err error
+
+	// stored is true if the snapshot has been stored, so that it may be served to other requests
+	stored bool
+}
+
+// failed returns if the fetch failed (5xx status code, panic or a body that could not be written to Dir),
+// nil means that the next handlers panicked
+func (f *cacheFetch) failed() bool {
+	return f == nil || f.snapshot.Code >= 500 || f.err != nil
+}
+
+// release releases the chunk files of the body that fetch acquired, they are removed if they have not been
+// stored. It must be called once by the request that ran fetch.
+func (f *cacheFetch) release() {
+	if f == nil || f.chunks == nil {
+		return
+	}
+	if !f.stored {
+		f.chunks.discard()
+	}
+	f.chunks.release()
+}
+
+// fetch serves the request with the next handlers into a Buffer and stores the response.
+// The Buffer is returned to be flushed by the caller (see respond). The complete response is fetched
+// for Range requests. If Dir is set, a body that exceeds the DiskThreshold is streamed into chunk files
+// while it is written, instead of being buffered in memory.
+func (c *Cache) fetch(next http.Handler, rw http.ResponseWriter, req *http.Request) (*Buffer, *cacheFetch) {
+	f := &cacheFetch{}
+	bf := NewBuffer(rw)
+	if c.Dir != "" {
+		bf = NewStreamBuffer(rw, c.diskThreshold(), func(_ *Buffer, body io.Reader) {
+			f.chunks, f.err = readChunks(c.Dir, body, c.chunkSize())
+		})
+	}
+	fetchReq := req
+	if req.Header.Get("Range") != "" {
+		fetchReq = req.Clone(req.Context())
+		fetchReq.Header.Del("Range")
+		fetchReq.Header.Del("If-Range")
+	}
+	served := false
+	defer func() {
+		// a panic of the next handlers must not leave the stream or its chunk files behind
+		if !served {
+			bf.Done()
+			if f.chunks != nil {
+				f.chunks.discard()
+			}
+		}
+	}()
+	next.ServeHTTP(bf, fetchReq)
+	served = true
+	bf.Done()
+
+	f.snapshot = &Snapshot{Code: bf.Code, Header: bf.header.Clone()}
+	if f.snapshot.Code == 0 {
+		f.snapshot.Code = http.StatusOK
+	}
+	if f.chunks != nil {
+		// an eviction must not remove the files before the response has been written
+		f.chunks.acquire()
+	} else if !bf.IsStreaming() {
+		f.snapshot.Body = bf.Body()
+	}
+	if f.err == nil {
+		f.stored = c.store(req, f.snapshot, f.chunks)
+	}
+	return bf, f
+}
+
+// respond writes the fetched response, a Range request is served from the stored entry.
+// It releases the fetched chunk files.
+func (c *Cache) respond(bf *Buffer, fetched *cacheFetch, rw http.ResponseWriter, req *http.Request) {
+	defer fetched.release()
+	if fetched.stored && req.Header.Get("Range") != "" {
+		if _, e := c.lookup(req); e != nil && e.serve(rw, req, c.currentTime()) {
+			return
+		}
+	}
+	switch {
+	case fetched.err != nil:
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	case fetched.chunks != nil:
+		bf.FlushHeaders()
+		rw.Header().Set("Content-Length", strconv.FormatInt(fetched.chunks.size, 10))
+		bf.FlushCode()
+		if req.Method != http.MethodHead {
+			fetched.chunks.writeRange(rw, 0, fetched.chunks.size)
+		}
+	default:
+		bf.FlushAll()
+	}
+}
+
+// revalidate refreshes the entry of the key in the background, unless it is already refreshed
+func (c *Cache) revalidate(key string, next http.Handler, req *http.Request) {
+	req = req.Clone(stdcontext.Background())
+	go func() {
+		// a panic keeps the stale entry
+		defer func() { recover() }()
+		c.flight.do(key, func() interface{} {
+			_, f := c.fetch(next, discardWriter{}, req)
+			f.release()
+			return f
+		})
+	}()
+}
+
+// serveStale serves the entry, if it may be served stale at now in case of failed next handlers
+func (c *Cache) serveStale(e *cacheEntry, rw http.ResponseWriter, req *http.Request, now time.Time) bool {
+	if e == nil || !now.Before(e.staleIfError) {
+		return false
+	}
+	return e.serve(rw, req, now)
+}
+
+// Wrap implements the Wrapper interface
+func (c *Cache) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if !cacheable(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		_, noCache := cacheControl(req.Header)["no-cache"]
+		now := c.currentTime()
+		key, e := c.lookup(req)
+		if e != nil && !noCache {
+			switch {
+			case now.Before(e.expires) && e.serve(rw, req, now):
+				return
+			case now.Before(e.staleWhileRevalidate) && e.serve(rw, req, now):
+				c.revalidate(key, next, req)
+				return
+			}
+		}
+
+		if noCache {
+			bf, fetched := c.fetch(next, rw, req)
+			if fetched.failed() && c.serveStale(e, rw, req, now) {
+				fetched.release()
+				return
+			}
+			c.respond(bf, fetched, rw, req)
+			return
+		}
+
+		var bf *Buffer
+		val, leader := c.flight.do(key, func() interface{} {
+			defer func() {
+				// let the other requests run the next handlers themselves, the panic is passed on
+				if p := recover(); p != nil {
+					if c.serveStale(e, rw, req, now) {
+						return
+					}
+					panic(p)
+				}
+			}()
+			var fetched *cacheFetch
+			bf, fetched = c.fetch(next, rw, req)
+			return fetched
+		})
+		fetched, _ := val.(*cacheFetch)
+
+		if leader {
+			switch {
+			case bf == nil:
+				// the panic has been recovered by serving the stale entry
+			case fetched.failed() && c.serveStale(e, rw, req, now):
+				fetched.release()
+			default:
+				c.respond(bf, fetched, rw, req)
+			}
+			return
+		}
+
+		if fetched.failed() && c.serveStale(e, rw, req, now) {
+			return
+		}
+		// the stored response may vary on request headers that differ from the ones of this request
+		if fetched != nil && fetched.stored {
+			if _, e := c.lookup(req); e != nil && now.Before(e.expires) && e.serve(rw, req, c.currentTime()) {
+				return
+			}
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// flightGroup runs a function only once for concurrent calls with the same key
+type flightGroup struct {
+	mx    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+}
+
+// do runs fn, unless it is already running for the key, in which case it waits for it and returns its result.
+// leader is true for the call that ran fn. If fn panics, the waiting calls get nil and the panic is passed on.
+func (g *flightGroup) do(key string, fn func() interface{}) (val interface{}, leader bool) {
+	g.mx.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, has := g.calls[key]; has {
+		g.mx.Unlock()
+		<-call.done
+		return call.val, false
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mx.Unlock()
+
+	defer func() {
+		g.mx.Lock()
+		delete(g.calls, key)
+		g.mx.Unlock()
+		close(call.done)
+	}()
+	call.val = fn()
+	return call.val, true
+}
@@ -0,0 +1,601 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler writes the number of calls and the given headers
+type countingHandler struct {
+	mx     sync.Mutex
+	calls  int
+	code   int
+	header http.Header
+}
+
+func (c *countingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.mx.Lock()
+	c.calls++
+	n := c.calls
+	c.mx.Unlock()
+	for k, v := range c.header {
+		rw.Header()[k] = v
+	}
+	if c.code != 0 {
+		rw.WriteHeader(c.code)
+	}
+	fmt.Fprintf(rw, "%d", n)
+}
+
+// fakeClock is a settable clock for tests
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func cacheGet(h http.Handler, url string, header ...string) (string, http.Header) {
+	rec, req := newTestRequest("GET", url)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	h.ServeHTTP(rec, req)
+	return rec.Body.String(), rec.Header()
+}
+
+func TestCache(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	c := &Cache{TTL: time.Minute, now: clock.now}
+	h := New(c, Handler(&countingHandler{}))
+
+	if body, _ := cacheGet(h, "/a"); body != "1" {
+		t.Errorf("expected miss, got %q", body)
+	}
+
+	clock.t = clock.t.Add(30 * time.Second)
+	body, header := cacheGet(h, "/a")
+	if body != "1" || header.Get("Age") != "30" {
+		t.Errorf("expected hit with age 30, got %q, age %q", body, header.Get("Age"))
+	}
+
+	if body, _ := cacheGet(h, "/a?q"); body != "2" {
+		t.Errorf("other URL should be a miss, got %q", body)
+	}
+
+	if body, _ := cacheGet(h, "/a", "Cache-Control", "no-cache"); body != "3" {
+		t.Errorf("no-cache should refresh, got %q", body)
+	}
+
+	if body, _ := cacheGet(h, "/a"); body != "3" {
+		t.Errorf("expected refreshed response, got %q", body)
+	}
+
+	if body, _ := cacheGet(h, "/a", "Cache-Control", "no-store"); body != "4" {
+		t.Errorf("no-store should bypass, got %q", body)
+	}
+
+	if body, _ := cacheGet(h, "/a", "Authorization", "Basic x"); body != "5" {
+		t.Errorf("authorized requests should bypass, got %q", body)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if body, _ := cacheGet(h, "/a"); body != "6" {
+		t.Errorf("expired entry should be a miss, got %q", body)
+	}
+
+	rec, req := newTestRequest("POST", "/a")
+	h.ServeHTTP(rec, req)
+	if body, _ := cacheGet(h, "/a"); body != "6" {
+		t.Errorf("POST should not touch the cache, got %q", body)
+	}
+}
+
+func TestCacheResponseDirectives(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	tests := []struct {
+		code   int
+		header http.Header
+		cached time.Duration
+	}{
+		{0, nil, time.Minute},
+		{404, nil, time.Minute},
+		{500, nil, 0},
+		{0, http.Header{"Cache-Control": {"max-age=10"}}, 10 * time.Second},
+		{0, http.Header{"Cache-Control": {"max-age=10, s-maxage=20"}}, 20 * time.Second},
+		{0, http.Header{"Cache-Control": {"private, max-age=10"}}, 0},
+		{0, http.Header{"Cache-Control": {"no-store"}}, 0},
+		{0, http.Header{"Cache-Control": {"max-age=0"}}, 0},
+		{0, http.Header{"Set-Cookie": {"a=b"}}, 0},
+		{0, http.Header{"Vary": {"*"}}, 0},
+	}
+
+	for i, test := range tests {
+		c := &Cache{now: clock.now}
+		h := New(c, Handler(&countingHandler{code: test.code, header: test.header}))
+		cacheGet(h, "/")
+		start := clock.t
+		clock.t = start.Add(test.cached - time.Second)
+		body, _ := cacheGet(h, "/")
+		clock.t = start
+		if (body == "1") != (test.cached > 0) {
+			t.Errorf("%d: expected cached for %s, got %q", i, test.cached, body)
+		}
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	c := &Cache{}
+	h := New(c, Handler(&countingHandler{header: http.Header{"Vary": {"Accept-Language"}}}))
+
+	for i, test := range []struct{ lang, body string }{{"de", "1"}, {"en", "2"}, {"de", "1"}, {"en", "2"}} {
+		if body, _ := cacheGet(h, "/", "Accept-Language", test.lang); body != test.body {
+			t.Errorf("%d: expected %q for %s, got %q", i, test.body, test.lang, body)
+		}
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("expected 2 variants, got %d", c.Len())
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := &Cache{MaxBytes: 100}
+	h := New(c, Handler(&countingHandler{}))
+
+	for i := 0; i < 20; i++ {
+		cacheGet(h, fmt.Sprintf("/%d", i))
+	}
+	if c.Size() > 100 || c.Len() == 0 || c.Len() == 20 {
+		t.Errorf("expected eviction to at most 100 bytes, got %d entries with %d bytes", c.Len(), c.Size())
+	}
+
+	// the most recently used entry is kept
+	if body, _ := cacheGet(h, "/19"); body != "20" {
+		t.Errorf("most recent entry should be cached, got %q", body)
+	}
+	if body, _ := cacheGet(h, "/0"); body != "21" {
+		t.Errorf("least recent entry should be evicted, got %q", body)
+	}
+}
+
+func TestCacheVaryPruned(t *testing.T) {
+	c := &Cache{MaxBytes: 1000}
+	h := New(c, Handler(&countingHandler{header: http.Header{"Vary": {"Accept-Language"}}}))
+
+	for i := 0; i < 200; i++ {
+		cacheGet(h, fmt.Sprintf("/%d", i), "Accept-Language", "de")
+		cacheGet(h, fmt.Sprintf("/%d", i), "Accept-Language", "en")
+	}
+	c.mx.Lock()
+	vary := len(c.vary)
+	c.mx.Unlock()
+	if vary == 0 || vary > c.Len() || c.Size() > 1000 {
+		t.Errorf("the Vary names of evicted entries should be removed, got %d names for %d entries with %d bytes", vary, c.Len(), c.Size())
+	}
+
+	c.Purge()
+	if len(c.vary) != 0 || c.Size() != 0 {
+		t.Errorf("expected no Vary names after Purge, got %d names and %d bytes", len(c.vary), c.Size())
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	c := &Cache{}
+	h := New(c, Handler(&countingHandler{}))
+
+	for _, url := range []string{"/products/1", "/products/2", "/about"} {
+		cacheGet(h, url)
+	}
+	// the requests have no host
+	host := ""
+
+	c.Invalidate(host + "/about")
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries after Invalidate, got %d", c.Len())
+	}
+
+	c.InvalidatePrefix(host + "/products/")
+	if c.Len() != 0 || c.Size() != 0 {
+		t.Errorf("expected empty cache after InvalidatePrefix, got %d entries", c.Len())
+	}
+
+	cacheGet(h, "/about")
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected empty cache after Purge, got %d entries", c.Len())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := &Cache{MaxBytes: 500}
+	h := New(c, Handler(&countingHandler{header: http.Header{"Vary": {"X-V"}}}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if body, _ := cacheGet(h, fmt.Sprintf("/%d", j%10), "X-V", fmt.Sprint(i%2)); strings.TrimSpace(body) == "" {
+					t.Errorf("empty body")
+				}
+				if j%25 == 0 {
+					c.InvalidatePrefix("/1")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// failingHandler answers with 500 or panics while fail is set, otherwise it is a countingHandler
+type failingHandler struct {
+	countingHandler
+	fail  int32
+	panic bool
+}
+
+func (f *failingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&f.fail) == 1 {
+		if f.panic {
+			panic("failing")
+		}
+		rw.WriteHeader(500)
+		return
+	}
+	f.countingHandler.ServeHTTP(rw, req)
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	var mx sync.Mutex
+	now := func() time.Time {
+		mx.Lock()
+		defer mx.Unlock()
+		return clock.now()
+	}
+	c := &Cache{TTL: time.Minute, StaleWhileRevalidate: time.Minute, now: now}
+	ch := &countingHandler{}
+	h := New(c, Handler(ch))
+
+	cacheGet(h, "/")
+	mx.Lock()
+	clock.t = clock.t.Add(90 * time.Second)
+	mx.Unlock()
+
+	if body, _ := cacheGet(h, "/"); body != "1" {
+		t.Errorf("stale response should be served, got %q", body)
+	}
+
+	waitFor(t, func() bool {
+		_, req := newTestRequest("GET", "/")
+		_, e := c.lookup(req)
+		return e != nil && now().Before(e.expires)
+	}, "stale response should be refreshed in the background")
+
+	if body, _ := cacheGet(h, "/"); body != "2" {
+		t.Errorf("refreshed response should be served, got %q", body)
+	}
+
+	mx.Lock()
+	clock.t = clock.t.Add(3 * time.Minute)
+	mx.Unlock()
+
+	if body, _ := cacheGet(h, "/"); body != "3" {
+		t.Errorf("response after the stale window should be a miss, got %q", body)
+	}
+}
+
+func TestCacheStaleWhileRevalidateDirective(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	c := &Cache{now: clock.now}
+	e := func() *cacheEntry {
+		_, req := newTestRequest("GET", "/")
+		c.store(req, &Snapshot{Header: http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=20, stale-if-error=30"}}}, nil)
+		_, e := c.lookup(req)
+		return e
+	}()
+
+	if e.expires.Sub(clock.t) != 10*time.Second || e.staleWhileRevalidate.Sub(clock.t) != 30*time.Second || e.staleIfError.Sub(clock.t) != 40*time.Second {
+		t.Errorf("unexpected expiry %s, stale while revalidate %s, stale if error %s", e.expires, e.staleWhileRevalidate, e.staleIfError)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	for _, panics := range []bool{false, true} {
+		clock := &fakeClock{time.Now()}
+		c := &Cache{TTL: time.Minute, StaleIfError: time.Minute, now: clock.now}
+		fh := &failingHandler{panic: panics}
+		h := New(Recover(nil), c, Handler(fh))
+
+		cacheGet(h, "/")
+		atomic.StoreInt32(&fh.fail, 1)
+		clock.t = clock.t.Add(90 * time.Second)
+
+		if body, _ := cacheGet(h, "/"); body != "1" {
+			t.Errorf("panics %v: stale response should be served on error, got %q", panics, body)
+		}
+
+		clock.t = clock.t.Add(time.Minute)
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		if rec.Code != 500 {
+			t.Errorf("panics %v: error should be served after the stale window, got %d", panics, rec.Code)
+		}
+	}
+}
+
+// blockingHandler counts the calls and blocks until release is closed
+type blockingHandler struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (b *blockingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	rw.Write([]byte("shared"))
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	c := &Cache{}
+	bh := &blockingHandler{release: make(chan struct{})}
+	h := New(c, Handler(bh))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], _ = cacheGet(h, "/")
+		}(i)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&bh.calls) == 1 }, "the next handler should be called")
+	time.Sleep(10 * time.Millisecond)
+	close(bh.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&bh.calls); calls != 1 {
+		t.Errorf("concurrent requests should share one call of the next handler, got %d calls", calls)
+	}
+	for i, body := range bodies {
+		if body != "shared" {
+			t.Errorf("%d: expected shared response, got %q", i, body)
+		}
+	}
+}
+
+func TestNormalizeAcceptEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                       "identity",
+		"identity":               "identity",
+		"gzip, deflate":          "gzip",
+		"x-gzip":                 "gzip",
+		"gzip, deflate, br":      "br",
+		"br;q=0.5, gzip":         "gzip",
+		"br;q=0, gzip;q=0":       "identity",
+		"*":                      "br",
+		"*;q=0.5, br;q=0":        "gzip",
+		"GZIP;Q=0.8, deflate":    "gzip",
+		"deflate, compress, foo": "identity",
+	}
+	for value, expected := range tests {
+		if got := NormalizeAcceptEncoding(value); got != expected {
+			t.Errorf("%q: expected %q, got %q", value, expected, got)
+		}
+	}
+}
+
+func TestCacheVaryNormalized(t *testing.T) {
+	c := &Cache{}
+	h := New(c, Handler(&countingHandler{header: http.Header{"Vary": {"Accept-Encoding, accept-language"}}}))
+
+	tests := []struct{ encoding, lang, body string }{
+		{"gzip", "de-DE", "1"},
+		{"gzip, deflate", "de-de", "1"},
+		{"deflate, gzip;q=0.9", " DE-de ", "1"},
+		{"br, gzip", "de-DE", "2"},
+		{"", "de-DE", "3"},
+		{"identity", "de-DE", "3"},
+	}
+	for i, test := range tests {
+		if body, _ := cacheGet(h, "/", "Accept-Encoding", test.encoding, "Accept-Language", test.lang); body != test.body {
+			t.Errorf("%d: expected %q, got %q", i, test.body, body)
+		}
+	}
+}
+
+func TestCacheNormalizers(t *testing.T) {
+	// without normalizers, the values are used as they are
+	c := &Cache{Normalizers: map[string]VaryNormalizer{}}
+	h := New(c, Handler(&countingHandler{header: http.Header{"Vary": {"Accept-Encoding"}}}))
+	cacheGet(h, "/", "Accept-Encoding", "gzip")
+	if body, _ := cacheGet(h, "/", "Accept-Encoding", "gzip, deflate"); body != "2" {
+		t.Errorf("values should not be normalized, got %q", body)
+	}
+
+	c = &Cache{Normalizers: map[string]VaryNormalizer{"X-Device": func(v string) string {
+		if strings.Contains(v, "mobile") {
+			return "mobile"
+		}
+		return "desktop"
+	}}}
+	h = New(c, Handler(&countingHandler{header: http.Header{"Vary": {"X-Device"}}}))
+	cacheGet(h, "/", "X-Device", "mobile-ios")
+	if body, _ := cacheGet(h, "/", "X-Device", "mobile-android"); body != "1" {
+		t.Errorf("custom normalizer should bucket the values, got %q", body)
+	}
+}
+
+// largeHandler writes a body of the given size of the letters a-z and counts its calls
+type largeHandler struct {
+	size  int
+	calls int32
+}
+
+func (l *largeHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&l.calls, 1)
+	if req.Header.Get("Range") != "" {
+		rw.WriteHeader(http.StatusTeapot)
+		return
+	}
+	rw.Header().Set("ETag", `"v1"`)
+	rw.Write([]byte(largeBody(l.size)))
+}
+
+func largeBody(size int) string {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte('a' + i%26)
+	}
+	return string(b)
+}
+
+func cacheRange(h http.Handler, url string, header ...string) (*http.Response, string) {
+	rec, req := newTestRequest("GET", url)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	h.ServeHTTP(rec, req)
+	return rec.Result(), rec.Body.String()
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	c := &Cache{Dir: dir, DiskThreshold: 10, ChunkSize: 7}
+	l := &largeHandler{size: 30}
+	h := New(c, Handler(l))
+
+	cacheGet(h, "/large")
+	if body, header := cacheGet(h, "/large"); body != largeBody(30) || header.Get("Content-Length") != "30" || header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("unexpected cached response %q with headers %v", body, header)
+	}
+	if l.calls != 1 {
+		t.Errorf("expected 1 call of the handler, got %d", l.calls)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 5 || c.DiskSize() != 30 {
+		t.Errorf("expected 5 chunk files with 30 bytes, got %d files with %d bytes", len(files), c.DiskSize())
+	}
+	if c.Size() >= 30 {
+		t.Errorf("the body should not be counted in memory, got %d bytes", c.Size())
+	}
+
+	c.Purge()
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 || c.DiskSize() != 0 {
+		t.Errorf("expected no chunk files after Purge, got %d", len(files))
+	}
+}
+
+func TestCacheDiskEviction(t *testing.T) {
+	dir := t.TempDir()
+	c := &Cache{Dir: dir, DiskThreshold: 10, MaxDiskBytes: 50}
+	h := New(c, Handler(&largeHandler{size: 20}))
+
+	for i := 0; i < 5; i++ {
+		cacheGet(h, fmt.Sprintf("/%d", i))
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 2 || c.DiskSize() != 40 {
+		t.Errorf("expected 2 chunk files with 40 bytes, got %d files with %d bytes", len(files), c.DiskSize())
+	}
+}
+
+func TestCacheDiskStream(t *testing.T) {
+	for _, cc := range []string{"", "no-store"} {
+		dir := t.TempDir()
+		var streamed int
+		h := New(&Cache{Dir: dir, DiskThreshold: 10, ChunkSize: 7}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Cache-Control", cc)
+			body := largeBody(30)
+			for i := 0; i < len(body); i += 5 {
+				rw.Write([]byte(body[i : i+5]))
+			}
+			// the body is written to the chunk files while it is written, not after the handler returned
+			files, _ := filepath.Glob(filepath.Join(dir, "*"))
+			streamed = len(files)
+		}))
+
+		if body, header := cacheGet(h, "/large"); body != largeBody(30) || header.Get("Content-Length") != "30" {
+			t.Errorf("%q: unexpected response %q with headers %v", cc, body, header)
+		}
+		if streamed < 4 {
+			t.Errorf("%q: expected at least 4 chunk files while the body is written, got %d", cc, streamed)
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		if cc == "no-store" && len(files) != 0 {
+			t.Errorf("expected the chunk files of an uncacheable response to be removed, got %d", len(files))
+		}
+		if cc == "" && len(files) != 5 {
+			t.Errorf("expected 5 stored chunk files, got %d", len(files))
+		}
+	}
+}
+
+func TestCacheRange(t *testing.T) {
+	body := largeBody(30)
+	tests := []struct {
+		rangeHeader, ifRange string
+		code                 int
+		contentRange, body   string
+	}{
+		{"bytes=0-4", "", 206, "bytes 0-4/30", body[0:5]},
+		{"bytes=5-12", `"v1"`, 206, "bytes 5-12/30", body[5:13]},
+		{"bytes=25-", "", 206, "bytes 25-29/30", body[25:]},
+		{"bytes=-3", "", 206, "bytes 27-29/30", body[27:]},
+		{"bytes=20-100", "", 206, "bytes 20-29/30", body[20:]},
+		{"bytes=30-", "", 416, "bytes */30", ""},
+		{"bytes=0-1,3-4", "", 200, "", body},
+		{"bytes=0-4", `"v0"`, 200, "", body},
+		{"items=0-4", "", 200, "", body},
+	}
+
+	for _, dir := range []string{"", t.TempDir()} {
+		l := &largeHandler{size: 30}
+		h := New(&Cache{Dir: dir, DiskThreshold: 10, ChunkSize: 7}, Handler(l))
+
+		// a miss with Range fetches and stores the complete response
+		if res, got := cacheRange(h, "/r", "Range", "bytes=1-2"); res.StatusCode != 206 || got != body[1:3] {
+			t.Errorf("miss: expected 206 with %q, got %d with %q", body[1:3], res.StatusCode, got)
+		}
+
+		for _, test := range tests {
+			res, got := cacheRange(h, "/r", "Range", test.rangeHeader, "If-Range", test.ifRange)
+			if res.StatusCode != test.code || got != test.body || res.Header.Get("Content-Range") != test.contentRange {
+				t.Errorf("%s (dir %q): expected %d %q with %q, got %d %q with %q", test.rangeHeader, dir,
+					test.code, test.contentRange, test.body, res.StatusCode, res.Header.Get("Content-Range"), got)
+			}
+			if test.code == 206 && res.Header.Get("Content-Length") != fmt.Sprint(len(test.body)) {
+				t.Errorf("%s: unexpected Content-Length %q", test.rangeHeader, res.Header.Get("Content-Length"))
+			}
+		}
+		if l.calls != 1 {
+			t.Errorf("expected 1 call of the handler, got %d", l.calls)
+		}
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	tests := []struct {
+		value           string
+		start, end      int64
+		ok, satisfiable bool
+	}{
+		{"bytes=0-0", 0, 1, true, true},
+		{"bytes=-0", 0, 0, true, false},
+		{"bytes=5-3", 0, 0, false, false},
+		{"bytes=x-3", 0, 0, false, false},
+		{"bytes=3", 0, 0, false, false},
+	}
+	for _, test := range tests {
+		start, end, ok, satisfiable := byteRange(test.value, 10)
+		if start != test.start || end != test.end || ok != test.ok || satisfiable != test.satisfiable {
+			t.Errorf("%s: unexpected %d, %d, %v, %v", test.value, start, end, ok, satisfiable)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheChunkSize is the default size of the chunk files of bodies that a Cache stores on disk
+const DefaultCacheChunkSize = 1 << 20
+
+// chunkStore is a body that is stored in chunk files on disk. The files are removed when the store has been
+// discarded and the last reader has been released, so that evictions do not break running responses.
+type chunkStore struct {
+	files     []string
+	chunkSize int64
+	size      int64
+
+	mx        sync.Mutex
+	readers   int
+	discarded bool
+}
+
+// readChunks stores the body read from r in chunk files of chunkSize bytes inside dir, so that only one
+// chunk at a time is held in memory
+func readChunks(dir string, r io.Reader, chunkSize int) (*chunkStore, error) {
+	s := &chunkStore{chunkSize: int64(chunkSize)}
+	for {
+		f, err := os.CreateTemp(dir, "go-on-wrap-cache-")
+		if err != nil {
+			s.removeFiles()
+			return nil, err
+		}
+		n, err := io.CopyN(f, r, s.chunkSize)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		s.size += n
+		if n > 0 {
+			s.files = append(s.files, f.Name())
+		} else {
+			os.Remove(f.Name())
+		}
+		switch {
+		case err == io.EOF:
+			return s, nil
+		case err != nil:
+			s.removeFiles()
+			return nil, err
+		}
+	}
+}
+
+func (s *chunkStore) removeFiles() {
+	for _, f := range s.files {
+		os.Remove(f)
+	}
+}
+
+// acquire registers a reader, it returns false if the store has already been discarded
+func (s *chunkStore) acquire() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.discarded {
+		return false
+	}
+	s.readers++
+	return true
+}
+
+// release unregisters a reader
+func (s *chunkStore) release() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.readers--
+	if s.discarded && s.readers == 0 {
+		s.removeFiles()
+	}
+}
+
+// discard removes the files as soon as there are no readers
+func (s *chunkStore) discard() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.discarded = true
+	if s.readers == 0 {
+		s.removeFiles()
+	}
+}
+
+// writeRange writes the bytes from start to end (exclusive) to w, reading only the chunks covering them
+func (s *chunkStore) writeRange(w io.Writer, start, end int64) error {
+	for i := start / s.chunkSize; start < end; i++ {
+		chunkStart := i * s.chunkSize
+		chunkEnd := chunkStart + s.chunkSize
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		f, err := os.Open(s.files[i])
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, io.NewSectionReader(f, start-chunkStart, chunkEnd-start))
+		f.Close()
+		if err != nil {
+			return err
+		}
+		start = chunkEnd
+	}
+	return nil
+}
+
+// byteRange returns the single byte range of the Range header value for a body of the given size as start
+// and end (exclusive). ok is false, if the value is no single byte range, satisfiable is false, if the range
+// is outside of the body.
+func byteRange(value string, size int64) (start, end int64, ok, satisfiable bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, false
+	}
+	if first == "" {
+		// suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, true, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size
+	if last != "" {
+		l, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || l < start {
+			return 0, 0, false, false
+		}
+		if l+1 < end {
+			end = l + 1
+		}
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	return start, end, true, true
+}
+
+// ifRangeMatches returns if the If-Range header of the request (if any) matches the strong ETag or the
+// Last-Modified header of the response
+func ifRangeMatches(req *http.Request, header http.Header) bool {
+	ir := req.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") && ir == etag {
+		return true
+	}
+	return ir == header.Get("Last-Modified")
+}
+
+// length returns the size of the cached body
+func (e *cacheEntry) length() int64 {
+	if e.chunks != nil {
+		return e.chunks.size
+	}
+	return int64(len(e.snapshot.Body))
+}
+
+// writeBody writes the bytes from start to end (exclusive) of the cached body to w
+func (e *cacheEntry) writeBody(w io.Writer, start, end int64) error {
+	if e.chunks != nil {
+		return e.chunks.writeRange(w, start, end)
+	}
+	_, err := w.Write(e.snapshot.Body[start:end])
+	return err
+}
@@ -0,0 +1,166 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCanarySteps are the default percentages of the traffic a Canary sends to the new stack
+var DefaultCanarySteps = []int{1, 5, 25, 50, 100}
+
+// CanaryState is the state of a Canary
+type CanaryState struct {
+	// Percent is the current percentage of the traffic served by the canary
+	Percent int
+
+	// Step is the index of the current step
+	Step int
+
+	// RolledBack is true if the canary has been reverted
+	RolledBack bool
+
+	// Requests and Failures are the number of canary requests and failures in the current step
+	Requests, Failures int
+}
+
+// String returns a short description of the state
+func (c CanaryState) String() string {
+	if c.RolledBack {
+		return "rolled back"
+	}
+	return fmt.Sprintf("%d%% (step %d, %d requests, %d failures)", c.Percent, c.Step, c.Requests, c.Failures)
+}
+
+// Canary is a Wrapper that gradually shifts traffic from the Stable to the Canary wrapper (each wrapping
+// the next handler) using a Splitter. Whenever StepRequests have been served by the canary in a step,
+// the canary is evaluated: if its 5xx rate is above MaxErrorRate or its average latency is above MaxLatency
+// the canary is rolled back and receives no traffic anymore, otherwise the next step of Steps is taken.
+//
+// The requests are measured via a status recorder. A Canary is Inspectable, so its state is shown by
+// the InspectorHandler. It must be used as pointer.
+type Canary struct {
+	// Stable is the current stack, Canary the new one
+	Stable, Canary Wrapper
+
+	// Steps are the percentages of the traffic for the canary. Defaults to DefaultCanarySteps.
+	Steps []int
+
+	// StepRequests is the number of canary requests after which a step is evaluated. Defaults to 100.
+	StepRequests int
+
+	// MaxErrorRate is the maximal rate (between 0 and 1) of 5xx responses of the canary. Defaults to 0.05.
+	MaxErrorRate float64
+
+	// MaxLatency is the maximal average latency of the canary. No limit if 0.
+	MaxLatency time.Duration
+
+	// OnChange is called after the percentage changed or the canary was rolled back (optional)
+	OnChange func(CanaryState)
+
+	mx      sync.Mutex
+	state   CanaryState
+	latency time.Duration
+}
+
+// make sure to fulfill the Wrapper and Inspectable interfaces
+var _ Wrapper = &Canary{}
+var _ Inspectable = &Canary{}
+
+func (c *Canary) steps() []int {
+	if len(c.Steps) == 0 {
+		return DefaultCanarySteps
+	}
+	return c.Steps
+}
+
+func (c *Canary) stepRequests() int {
+	if c.StepRequests <= 0 {
+		return 100
+	}
+	return c.StepRequests
+}
+
+func (c *Canary) maxErrorRate() float64 {
+	if c.MaxErrorRate <= 0 {
+		return 0.05
+	}
+	return c.MaxErrorRate
+}
+
+// State returns the current state
+func (c *Canary) State() CanaryState {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.state
+}
+
+// InspectState implements the Inspectable interface
+func (c *Canary) InspectState() string {
+	return "canary " + c.State().String()
+}
+
+func (c *Canary) percent() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.state.Percent
+}
+
+// observe records a request served by the canary and evaluates the step
+func (c *Canary) observe(status int, d time.Duration) {
+	c.mx.Lock()
+	steps := c.steps()
+	if c.state.RolledBack || c.state.Step == len(steps)-1 {
+		c.mx.Unlock()
+		return
+	}
+
+	c.state.Requests++
+	if status >= 500 {
+		c.state.Failures++
+	}
+	c.latency += d
+
+	if c.state.Requests < c.stepRequests() {
+		c.mx.Unlock()
+		return
+	}
+
+	errRate := float64(c.state.Failures) / float64(c.state.Requests)
+	avgLatency := c.latency / time.Duration(c.state.Requests)
+	if errRate > c.maxErrorRate() || (c.MaxLatency > 0 && avgLatency > c.MaxLatency) {
+		c.state.RolledBack = true
+		c.state.Percent = 0
+	} else {
+		c.state.Step++
+		c.state.Percent = steps[c.state.Step]
+		c.state.Requests, c.state.Failures = 0, 0
+		c.latency = 0
+	}
+	state := c.state
+	c.mx.Unlock()
+
+	if c.OnChange != nil {
+		c.OnChange(state)
+	}
+}
+
+// Wrap implements the Wrapper interface
+func (c *Canary) Wrap(next http.Handler) http.Handler {
+	c.mx.Lock()
+	c.state.Percent = c.steps()[0]
+	c.mx.Unlock()
+
+	canary := c.Canary.Wrap(next)
+	var measured http.HandlerFunc
+	measured = func(rw http.ResponseWriter, req *http.Request) {
+		rec := newStatusRecorder(rw)
+		start := time.Now()
+		canary.ServeHTTP(rec.responseWriter(), req)
+		c.observe(rec.Status(), time.Since(start))
+	}
+
+	s := &Splitter{A: Handler(measured), B: c.Stable, percentA: c.percent}
+	return s.Wrap(next)
+}
@@ -0,0 +1,73 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanary(t *testing.T) {
+	var status = statusHandler(200)
+	var changes []string
+	c := &Canary{
+		Stable:       writeStop("stable"),
+		Canary:       Handler(&status),
+		Steps:        []int{50, 100},
+		StepRequests: 5,
+		OnChange:     func(s CanaryState) { changes = append(changes, s.String()) },
+	}
+	h := New(c)
+
+	for c.State().Percent != 100 {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+	}
+
+	if len(changes) != 1 || changes[0] != "100% (step 1, 0 requests, 0 failures)" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	// the last step is final
+	status = 500
+	for i := 0; i < 10; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "", 500)
+	}
+}
+
+func TestCanaryRollback(t *testing.T) {
+	c := &Canary{
+		Stable:       writeStop("stable"),
+		Canary:       Handler(statusHandler(503)),
+		Steps:        []int{100, 100},
+		StepRequests: 3,
+	}
+	h := New(c)
+
+	for i := 0; i < 3; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "", 503)
+	}
+
+	if !c.State().RolledBack {
+		t.Fatalf("canary should be rolled back")
+	}
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "stable", 200)
+}
+
+func TestCanaryInspector(t *testing.T) {
+	c := &Canary{Stable: writeStop("stable"), Canary: writeStop("canary")}
+	Named("canary-test", c)
+
+	rec, req := newTestRequest("GET", "/debug/wrap")
+	InspectorHandler().ServeHTTP(rec, req)
+
+	exp := "  0  *wrap.Canary [canary 1% (step 0, 0 requests, 0 failures)]"
+	if !strings.Contains(rec.Body.String(), exp) {
+		t.Errorf("inspector should show %#v, but shows:\n%s", exp, rec.Body.String())
+	}
+}
@@ -0,0 +1,120 @@
+package wrap
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ClientIP is the context type of the canonical IP address of the client (4 bytes for IPv4, 16 bytes
+// for IPv6), see SetClientIP
+type ClientIP net.IP
+
+// String returns the IP address as string
+func (c ClientIP) String() string {
+	return net.IP(c).String()
+}
+
+// canonicalIP parses addr (with or without port) and returns it as 4 bytes for IPv4 addresses,
+// nil if it is no IP address
+func canonicalIP(addr string) net.IP {
+	ip := net.ParseIP(hostOnly(addr))
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// ErrIPDenied is the error that is reported by SetClientIP for denied client IPs
+type ErrIPDenied struct {
+	IP ClientIP
+}
+
+// Error returns the error message
+func (e *ErrIPDenied) Error() string {
+	return fmt.Sprintf("client IP %s is not allowed", e.IP)
+}
+
+// SetClientIP is a ContextWrapper that determines the IP address of the client and optionally enforces
+// allow and deny lists before the next handlers run.
+//
+// The client IP is the remote address of the request or, if the remote address belongs to one of the
+// TrustedProxies, the rightmost address of the ForwardedHeader that is not a trusted proxy (like the Client
+// of the Forwarded context type of NormalizeHeaders). If the ResponseWriter is
+// a Contexter, the client IP is stored as ClientIP inside the context.
+//
+// If Deny contains the client IP or Allow is not empty and does not contain it, the request is answered
+// with 403 Forbidden (see HTTPError for how the error is reported) and the next handlers are not run.
+// Deny takes precedence over Allow. If Allow or Deny is set, requests whose client address is no IP
+// (e.g. "for=unknown" in a Forwarded header) are answered with 403 Forbidden as well.
+type SetClientIP struct {
+	// TrustedProxies are the IPs or CIDR ranges (e.g. "10.0.0.0/8") of the proxies in front of the server
+	TrustedProxies []string
+
+	// ForwardedHeader is the header that the TrustedProxies set: "X-Forwarded-For" (the default)
+	// or "Forwarded" (RFC 7239)
+	ForwardedHeader string
+
+	// Allow are the IPs or CIDR ranges that are allowed. If it is empty, every client IP not denied is allowed.
+	Allow []string
+
+	// Deny are the IPs or CIDR ranges that are denied
+	Deny []string
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = SetClientIP{}
+
+// ValidateContext makes sure that ctx supports the ClientIP type
+func (SetClientIP) ValidateContext(ctx Contexter) {
+	var ip ClientIP
+	ctx.SetContext(&ip)
+	ctx.Context(&ip)
+}
+
+// containsIP returns if ip is within one of the networks
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap implements the Wrapper interface. It panics if one of the TrustedProxies, the ForwardedHeader or
+// the Allow or Deny entries is invalid.
+func (s SetClientIP) Wrap(next http.Handler) http.Handler {
+	trusted := parseTrustedProxies(s.TrustedProxies)
+	header := parseForwardedHeader(s.ForwardedHeader)
+	allow := parseNetworks("allowed IP", s.Allow)
+	deny := parseNetworks("denied IP", s.Deny)
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ip := canonicalIP(forwardedOf(req, trusted, header).Client)
+
+		if (ip == nil && len(allow)+len(deny) > 0) || (ip != nil && (containsIP(deny, ip) || (len(allow) > 0 && !containsIP(allow, ip)))) {
+			reportError(rw, &HTTPError{Code: http.StatusForbidden, Err: &ErrIPDenied{ClientIP(ip)}})
+			return
+		}
+
+		if ctx, ok := rw.(Contexter); ok && ip != nil {
+			cip := ClientIP(ip)
+			ctx.SetContext(&cip)
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// ClientIPOf returns the IP address of the client: the ClientIP of the context, if rw is a Contexter
+// supporting it (see SetClientIP), otherwise the remote address of the request. It returns nil, if there
+// is none.
+func ClientIPOf(rw http.ResponseWriter, req *http.Request) ClientIP {
+	var ip ClientIP
+	if found, err := TryContext(rw, &ip); found && err == nil && ip != nil {
+		return ip
+	}
+	return ClientIP(canonicalIP(req.RemoteAddr))
+}
@@ -0,0 +1,160 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type clientIPContext struct {
+	http.ResponseWriter
+	ip  ClientIP
+	err error
+}
+
+func (c *clientIPContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *ClientIP:
+		if c.ip == nil {
+			return false
+		}
+		*ty = c.ip
+	case *error:
+		if c.err == nil {
+			return false
+		}
+		*ty = c.err
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *clientIPContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *ClientIP:
+		c.ip = *ty
+	case *error:
+		c.err = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c clientIPContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&clientIPContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// writeClientIP writes the client IP
+func writeClientIP(rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte(ClientIPOf(rw, req).String()))
+}
+
+func TestSetClientIP(t *testing.T) {
+	h := Stack(&clientIPContext{}, HandleError{}, SetClientIP{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Allow:          []string{"192.0.2.0/24", "2001:db8::/32"},
+		Deny:           []string{"192.0.2.66"},
+	}, HandlerFunc(writeClientIP))
+
+	tests := []struct {
+		remote string
+		header http.Header
+		body   string
+		code   int
+	}{
+		{"192.0.2.1:1234", nil, "192.0.2.1", 200},
+		{"[2001:db8::1]:1234", nil, "2001:db8::1", 200},
+		{"[::ffff:192.0.2.2]:1234", nil, "192.0.2.2", 200},
+		{"198.51.100.1:1234", nil, "Forbidden", 403},
+		{"192.0.2.66:1234", nil, "Forbidden", 403},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"192.0.2.3, 10.0.0.2"}}, "192.0.2.3", 200},
+		// the header is set by the client, if the remote address is no trusted proxy
+		{"192.0.2.4:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "192.0.2.4", 200},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"192.0.2.66"}}, "Forbidden", 403},
+		{"invalid", nil, "Forbidden", 403},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		req.RemoteAddr = test.remote
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, test.code)
+	}
+}
+
+func TestSetClientIPDenyBypass(t *testing.T) {
+	tests := []struct {
+		header string
+		fw     http.Header
+	}{
+		// a Forwarded header forged by the client behind a proxy that appends X-Forwarded-For
+		{"", http.Header{"X-Forwarded-For": {"6.6.6.6"}, "Forwarded": {"for=1.2.3.4"}}},
+		// a client address that is no IP
+		{"Forwarded", http.Header{"Forwarded": {"for=unknown"}}},
+		{"", http.Header{"X-Forwarded-For": {"unknown"}}},
+	}
+
+	for _, test := range tests {
+		h := New(SetClientIP{TrustedProxies: []string{"10.0.0.0/8"}, ForwardedHeader: test.header, Deny: []string{"6.6.6.6"}},
+			HandlerFunc(writeClientIP))
+		rec, req := newTestRequest("GET", "/")
+		req.RemoteAddr = "10.0.0.1:1234"
+		for k, v := range test.fw {
+			req.Header[k] = v
+		}
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "Forbidden", 403)
+	}
+}
+
+func TestSetClientIPWithoutContexter(t *testing.T) {
+	h := New(SetClientIP{Deny: []string{"192.0.2.66"}}, HandlerFunc(writeClientIP))
+
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "192.0.2.1", 200)
+
+	rec, req = newTestRequest("GET", "/")
+	req.RemoteAddr = "192.0.2.66:1234"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "Forbidden", 403)
+}
+
+func TestSetClientIPDeniedError(t *testing.T) {
+	var err error
+	getError := NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req)
+		err = GetError(rw)
+	})
+	h := New(&clientIPContext{}, getError, SetClientIP{Deny: []string{"192.0.2.0/24"}}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("next handler should not run")
+	}))
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(rec, req)
+
+	var denied *ErrIPDenied
+	if !errors.As(err, &denied) || denied.IP.String() != "192.0.2.1" {
+		t.Errorf("expected ErrIPDenied for 192.0.2.1, got %v", err)
+	}
+}
+
+func TestSetClientIPInvalid(t *testing.T) {
+	defer func() {
+		if p := recover(); p != "invalid allowed IP nonsense" {
+			t.Errorf("expected panic for invalid allowed IP, got %v", p)
+		}
+	}()
+	SetClientIP{Allow: []string{"nonsense"}}.Wrap(NoOp)
+}
@@ -0,0 +1,134 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+)
+
+// peekedBody replays the peeked bytes before reading the rest of the body
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// PeekResponse reads the first n bytes of the body of res (fewer if the body is shorter) and replaces
+// the body with one that returns them again before the rest. It is the client side counterpart of
+// Peek: a transport may inspect the status code and the beginning of the body to decide whether to
+// transform the response or to retry the request, without reading the whole body.
+func PeekResponse(res *http.Response, n int) ([]byte, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+	head := make([]byte, n)
+	read, err := io.ReadFull(res.Body, head)
+	head = head[:read]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	res.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(head), res.Body), Closer: res.Body}
+	return head, err
+}
+
+// BufferedBody is a response or request body that has been read completely into memory or, if it is larger than
+// a limit, into a temporary file. It can be read multiple times via Rewind.
+// Close removes the temporary file.
+type BufferedBody struct {
+	mem  []byte
+	file *os.File
+	size int64
+	r    io.ReadSeeker
+}
+
+// Read reads from the buffered body
+func (b *BufferedBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// Rewind lets the next Read start at the beginning of the body again
+func (b *BufferedBody) Rewind() error {
+	_, err := b.r.Seek(0, io.SeekStart)
+	return err
+}
+
+// Size returns the size of the body
+func (b *BufferedBody) Size() int64 {
+	return b.size
+}
+
+// Bytes returns the body if it is buffered in memory, nil if it has been spilled over to a file
+func (b *BufferedBody) Bytes() []byte {
+	return b.mem
+}
+
+// Close removes the temporary file, if there is one
+func (b *BufferedBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// BufferResponse reads the body of res completely and replaces it with a BufferedBody that is
+// returned. Bodies up to memLimit bytes are kept in memory, larger ones are spilled over to a
+// temporary file. It is the client side counterpart of Buffer.
+func BufferResponse(res *http.Response, memLimit int64) (*BufferedBody, error) {
+	bb, err := bufferBody(res.Body, memLimit)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = bb
+	res.ContentLength = bb.size
+	return bb, nil
+}
+
+// bufferBody reads body completely into a BufferedBody, spilling over to a temporary file if it is
+// larger than memLimit, and closes it
+func bufferBody(body io.ReadCloser, memLimit int64) (*BufferedBody, error) {
+	bb := &BufferedBody{}
+	if body == nil || body == http.NoBody {
+		bb.r = bytes.NewReader(nil)
+		return bb, nil
+	}
+	defer body.Close()
+
+	var mem bytes.Buffer
+	n, err := io.Copy(&mem, io.LimitReader(body, memLimit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= memLimit {
+		bb.mem = mem.Bytes()
+		bb.size = n
+		bb.r = bytes.NewReader(bb.mem)
+		return bb, nil
+	}
+
+	f, err := os.CreateTemp("", "go-on-wrap-body-")
+	if err != nil {
+		return nil, err
+	}
+	bb.file = f
+	size, err := io.Copy(f, io.MultiReader(&mem, body))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		bb.Close()
+		return nil, err
+	}
+	bb.size = size
+	bb.r = f
+	return bb, nil
+}
+
+// NewReader returns a new reader of the complete body that is independent of Read and Rewind
+func (b *BufferedBody) NewReader() io.ReadCloser {
+	if b.file == nil {
+		return io.NopCloser(bytes.NewReader(b.mem))
+	}
+	return io.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+}
@@ -0,0 +1,64 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newResponse(body string) *http.Response {
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestPeekResponse(t *testing.T) {
+	res := newResponse("<html>hello</html>")
+	head, err := PeekResponse(res, 6)
+	if err != nil || string(head) != "<html>" {
+		t.Errorf("unexpected head %#v, %v", string(head), err)
+	}
+
+	if got := readBody(t, res, nil); got != "<html>hello</html>" {
+		t.Errorf("body should be complete, but is %#v", got)
+	}
+
+	res = newResponse("ab")
+	head, err = PeekResponse(res, 6)
+	if err != nil || string(head) != "ab" {
+		t.Errorf("unexpected head %#v, %v", string(head), err)
+	}
+}
+
+func TestBufferResponse(t *testing.T) {
+	res := newResponse("small")
+	bb, err := BufferResponse(res, 10)
+	if err != nil || string(bb.Bytes()) != "small" || bb.Size() != 5 || res.ContentLength != 5 {
+		t.Errorf("small body should be kept in memory, got %#v, %v", string(bb.Bytes()), err)
+	}
+
+	large := strings.Repeat("x", 100)
+	res = newResponse(large)
+	bb, err = BufferResponse(res, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bb.Bytes() != nil || bb.file == nil || bb.Size() != 100 {
+		t.Errorf("large body should be spilled over to a file")
+	}
+
+	for i := 0; i < 2; i++ {
+		b, _ := io.ReadAll(res.Body)
+		if string(b) != large {
+			t.Errorf("body should be readable, got %d bytes", len(b))
+		}
+		bb.Rewind()
+	}
+
+	name := bb.file.Name()
+	res.Body.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("temporary file should be removed")
+	}
+}
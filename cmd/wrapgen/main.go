@@ -0,0 +1,195 @@
+// Command wrapgen generates a middleware stack with static dispatch from a stack definition.
+//
+// The stack definition is a JSON file like
+//
+//	{
+//	  "package": "main",
+//	  "name": "App",
+//	  "imports": ["example.com/mw"],
+//	  "wrappers": [
+//	    {"field": "Log", "type": "*mw.Logger", "kind": "next"},
+//	    {"field": "Auth", "type": "mw.Auth", "kind": "next"},
+//	    {"field": "Final", "type": "*handler", "kind": "handler"}
+//	  ]
+//	}
+//
+// Wrappers of kind "next" must have a ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request)
+// method (see wrap.NextHandler), a wrapper of kind "handler" must be a http.Handler and may only be the last one.
+//
+// wrapgen emits a struct type with a field per wrapper, whose ServeHTTP method runs the wrappers in order.
+// The next handlers passed to the wrappers are generated types calling the following wrapper directly,
+// so there are neither closures nor dynamic dispatch between the wrappers. Usage:
+//
+//	wrapgen -in stack.json -out stack_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"text/template"
+	"unicode"
+)
+
+// wrapperDef is the definition of a wrapper of the stack
+type wrapperDef struct {
+	Field string `json:"field"`
+	Type  string `json:"type"`
+	Kind  string `json:"kind"`
+}
+
+// stackDef is the definition of a stack
+type stackDef struct {
+	Package  string       `json:"package"`
+	Name     string       `json:"name"`
+	Imports  []string     `json:"imports"`
+	Wrappers []wrapperDef `json:"wrappers"`
+}
+
+// stage is a wrapper of the stack as used by the template
+type stage struct {
+	wrapperDef
+	// Next is the name of the type of the next handler
+	Next string
+}
+
+// prefix returns the prefix of the unexported generated types
+func (s *stackDef) prefix() string {
+	r := []rune(s.Name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// validate checks the definition
+func (s *stackDef) validate() error {
+	if s.Package == "" || s.Name == "" {
+		return fmt.Errorf("package and name are required")
+	}
+	if len(s.Wrappers) == 0 {
+		return fmt.Errorf("at least one wrapper is required")
+	}
+	for i, w := range s.Wrappers {
+		if w.Field == "" || w.Type == "" {
+			return fmt.Errorf("wrapper %d: field and type are required", i)
+		}
+		switch w.Kind {
+		case "next":
+		case "handler":
+			if i != len(s.Wrappers)-1 {
+				return fmt.Errorf("wrapper %d: a handler must be the last wrapper", i)
+			}
+		default:
+			return fmt.Errorf("wrapper %d: unknown kind %q", i, w.Kind)
+		}
+	}
+	return nil
+}
+
+var tmpl = template.Must(template.New("stack").Parse(`// Code generated by wrapgen. DO NOT EDIT.
+
+package {{.Def.Package}}
+
+import (
+	"net/http"
+{{range .Def.Imports}}	"{{.}}"
+{{end}})
+
+// {{.Def.Name}} is a middleware stack with static dispatch.
+type {{.Def.Name}} struct {
+{{range .Stages}}	{{.Field}} {{.Type}}
+{{end}}}
+
+// ServeHTTP runs the wrappers of the stack in order.
+func (s *{{.Def.Name}}) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	{{.Prefix}}0{s}.ServeHTTP(rw, req)
+}
+{{range $i, $st := .Stages}}
+// {{$.Prefix}}{{$i}} runs {{$st.Field}}.
+type {{$.Prefix}}{{$i}} struct{ s *{{$.Def.Name}} }
+
+func (n {{$.Prefix}}{{$i}}) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+{{if eq $st.Kind "handler"}}	n.s.{{$st.Field}}.ServeHTTP(rw, req)
+{{else}}	n.s.{{$st.Field}}.ServeHTTPNext({{$st.Next}}{n.s}, rw, req)
+{{end}}}
+{{end}}{{if .NeedsEnd}}
+// {{.Prefix}}End ends the stack.
+type {{.Prefix}}End struct{ s *{{.Def.Name}} }
+
+func ({{.Prefix}}End) ServeHTTP(http.ResponseWriter, *http.Request) {}
+{{end}}`))
+
+// generate writes the source of the stack to w
+func generate(def *stackDef, w io.Writer) error {
+	if err := def.validate(); err != nil {
+		return err
+	}
+	prefix := def.prefix()
+	stages := make([]stage, len(def.Wrappers))
+	for i, wd := range def.Wrappers {
+		stages[i] = stage{wrapperDef: wd, Next: fmt.Sprintf("%s%d", prefix, i+1)}
+	}
+	last := def.Wrappers[len(def.Wrappers)-1]
+	needsEnd := last.Kind != "handler"
+	if needsEnd {
+		stages[len(stages)-1].Next = prefix + "End"
+	}
+
+	var bf bytes.Buffer
+	err := tmpl.Execute(&bf, map[string]interface{}{
+		"Def":      def,
+		"Prefix":   prefix,
+		"Stages":   stages,
+		"NeedsEnd": needsEnd,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(bf.Bytes())
+	if err != nil {
+		return fmt.Errorf("invalid generated code (check the types): %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func run(in, out string) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	var def stackDef
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return fmt.Errorf("%s: %v", in, err)
+	}
+
+	var bf bytes.Buffer
+	if err := generate(&def, &bf); err != nil {
+		return fmt.Errorf("%s: %v", in, err)
+	}
+	if out == "" {
+		_, err = bf.WriteTo(os.Stdout)
+		return err
+	}
+	return os.WriteFile(out, bf.Bytes(), 0644)
+}
+
+func main() {
+	in := flag.String("in", "", "the stack definition (JSON)")
+	out := flag.String("out", "", "the generated file, stdout if empty")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*in, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "wrapgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// wrappersSrc defines the wrappers used by the generated stacks
+const wrappersSrc = `package app
+
+import "net/http"
+
+type logger struct{}
+
+func (*logger) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	next.ServeHTTP(rw, req)
+}
+
+type auth string
+
+func (auth) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	next.ServeHTTP(rw, req)
+}
+
+type handler struct{}
+
+func (handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {}
+`
+
+// typeCheck parses and type checks the generated source together with the wrappers
+func typeCheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, s := range map[string][]byte{"wrappers.go": []byte(wrappersSrc), "stack_gen.go": src} {
+		f, err := parser.ParseFile(fset, name, s, 0)
+		if err != nil {
+			t.Fatalf("%s does not parse: %v", name, err)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("app", fset, files, nil); err != nil {
+		t.Fatalf("generated code does not type check: %v\n%s", err, src)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	defs := []*stackDef{
+		{Package: "app", Name: "App", Wrappers: []wrapperDef{
+			{Field: "Log", Type: "*logger", Kind: "next"},
+			{Field: "Auth", Type: "auth", Kind: "next"},
+			{Field: "Final", Type: "handler", Kind: "handler"},
+		}},
+		{Package: "app", Name: "Partial", Wrappers: []wrapperDef{
+			{Field: "Log", Type: "*logger", Kind: "next"},
+		}},
+	}
+
+	for _, def := range defs {
+		var bf bytes.Buffer
+		if err := generate(def, &bf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(bf.String(), "// Code generated by wrapgen. DO NOT EDIT.") {
+			t.Errorf("generated code should start with the generated comment, got:\n%s", bf.String())
+		}
+		typeCheck(t, bf.Bytes())
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	defs := []*stackDef{
+		{Name: "App", Wrappers: []wrapperDef{{Field: "A", Type: "a", Kind: "next"}}},
+		{Package: "app", Name: "App"},
+		{Package: "app", Name: "App", Wrappers: []wrapperDef{{Field: "A", Type: "a", Kind: "other"}}},
+		{Package: "app", Name: "App", Wrappers: []wrapperDef{{Field: "A", Type: "a", Kind: "handler"}, {Field: "B", Type: "b", Kind: "next"}}},
+	}
+
+	for i, def := range defs {
+		if err := generate(def, &bytes.Buffer{}); err == nil {
+			t.Errorf("definition %d should be invalid", i)
+		}
+	}
+}
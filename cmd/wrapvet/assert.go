@@ -0,0 +1,114 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/go-on/wrap/internal/vetcheck"
+)
+
+// assertCheck flags unverified type assertions to wrap.Contexter
+var assertCheck = &vetcheck.Check{
+	Name: "contexterassert",
+	Doc: `check for unverified type assertions to wrap.Contexter
+
+A type assertion rw.(wrap.Contexter) without the comma-ok form panics if the stack has no Contexter.
+It is reported, unless the package builds a stack via wrap.Stack (which validates the ContextInjecter)
+or the assertion is inside a method of a type with a ValidateContext method (a ContextWrapper), that
+documents the required context types, so they can be validated via ValidateContext.
+The wrap package itself is not checked.`,
+	Run: runAssertCheck,
+}
+
+// isContexter returns if t is the wrap.Contexter type
+func isContexter(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Name() == "Contexter" && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == wrapPath
+}
+
+// callsStack returns if any of the files calls wrap.Stack
+func callsStack(pass *vetcheck.Pass) (calls bool) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return !calls
+			}
+			var id *ast.Ident
+			switch fn := call.Fun.(type) {
+			case *ast.Ident:
+				id = fn
+			case *ast.SelectorExpr:
+				id = fn.Sel
+			}
+			if id != nil {
+				if obj, ok := pass.TypesInfo.Uses[id].(*types.Func); ok && obj.Name() == "Stack" && obj.Pkg() != nil && obj.Pkg().Path() == wrapPath {
+					calls = true
+				}
+			}
+			return !calls
+		})
+	}
+	return
+}
+
+// hasValidateContext returns if the receiver type of the method has a ValidateContext method
+func hasValidateContext(pass *vetcheck.Pass, fn *ast.FuncDecl) bool {
+	if fn.Recv == nil {
+		return false
+	}
+	obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := obj.Type().(*types.Signature).Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	return types.NewMethodSet(types.NewPointer(recv)).Lookup(pass.Pkg, "ValidateContext") != nil
+}
+
+// contexterAssertions returns the type assertions to wrap.Contexter inside node that are not in the
+// comma-ok form, i.e. that panic if the ResponseWriter is no Contexter
+func contexterAssertions(info *types.Info, node ast.Node) (asserts []*ast.TypeAssertExpr) {
+	commaOk := map[*ast.TypeAssertExpr]bool{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.AssignStmt:
+			if len(s.Lhs) == 2 && len(s.Rhs) == 1 {
+				if ta, ok := s.Rhs[0].(*ast.TypeAssertExpr); ok {
+					commaOk[ta] = true
+				}
+			}
+		case *ast.ValueSpec:
+			if len(s.Names) == 2 && len(s.Values) == 1 {
+				if ta, ok := s.Values[0].(*ast.TypeAssertExpr); ok {
+					commaOk[ta] = true
+				}
+			}
+		case *ast.TypeAssertExpr:
+			if s.Type != nil && !commaOk[s] && isContexter(info.TypeOf(s.Type)) {
+				asserts = append(asserts, s)
+			}
+		}
+		return true
+	})
+	return
+}
+
+func runAssertCheck(pass *vetcheck.Pass) {
+	if pass.Pkg.Path() == wrapPath || callsStack(pass) {
+		return
+	}
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || hasValidateContext(pass, fn) {
+				continue
+			}
+			for _, ta := range contexterAssertions(pass.TypesInfo, fn.Body) {
+				pass.Reportf(ta.Pos(), "type assertion to wrap.Contexter panics without a Contexter in the stack: build the stack with wrap.Stack or add a ValidateContext method to the wrapper, so the required context types can be validated")
+			}
+		}
+	}
+}
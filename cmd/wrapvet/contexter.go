@@ -0,0 +1,187 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/go-on/wrap/internal/vetcheck"
+)
+
+const wrapPath = "github.com/go-on/wrap"
+
+// contexterCheck checks the Context and SetContext methods of Contexter implementations
+var contexterCheck = &vetcheck.Check{
+	Name: "contexter",
+	Doc: `check Contexter implementations
+
+Context and SetContext methods of types having both methods, that switch on the type of their argument,
+must panic with *wrap.ErrUnsupportedContextGetter / *wrap.ErrUnsupportedContextSetter in the default case.
+Context must support *http.ResponseWriter and SetContext must store the values the pointers point to,
+not the pointers. These are the mistakes ValidateContextInjecter catches at runtime.
+Methods that do not switch on the type (e.g. passing the argument to another Contexter) are not checked.`,
+	Run: runContexterCheck,
+}
+
+func runContexterCheck(pass *vetcheck.Pass) {
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil || (fn.Name.Name != "Context" && fn.Name.Name != "SetContext") {
+				continue
+			}
+			obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+			if !ok || !isContexterMethod(obj) {
+				continue
+			}
+			params := obj.Type().(*types.Signature).Params()
+			ts := typeSwitchOn(pass.TypesInfo, fn.Body, params.At(0))
+			if ts == nil {
+				continue
+			}
+			recv := recvName(obj)
+			if fn.Name.Name == "Context" {
+				checkContext(pass, recv, ts)
+			} else {
+				checkSetContext(pass, recv, ts)
+			}
+		}
+	}
+}
+
+// isContexterMethod returns if the method takes a single interface{} argument and its receiver
+// type has both the Context and the SetContext method
+func isContexterMethod(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 1 {
+		return false
+	}
+	if it, ok := sig.Params().At(0).Type().Underlying().(*types.Interface); !ok || it.NumMethods() != 0 {
+		return false
+	}
+	recv := sig.Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	ms := types.NewMethodSet(types.NewPointer(recv))
+	return ms.Lookup(fn.Pkg(), "Context") != nil && ms.Lookup(fn.Pkg(), "SetContext") != nil
+}
+
+// recvName returns the name of the receiver type of the method
+func recvName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	if n, ok := recv.(*types.Named); ok {
+		return n.Obj().Name()
+	}
+	return recv.String()
+}
+
+// typeSwitchOn returns the first type switch inside body on the given variable, nil if there is none
+func typeSwitchOn(info *types.Info, body *ast.BlockStmt, v *types.Var) (ts *ast.TypeSwitchStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if ts != nil {
+			return false
+		}
+		s, ok := n.(*ast.TypeSwitchStmt)
+		if !ok {
+			return true
+		}
+		var x ast.Expr
+		switch a := s.Assign.(type) {
+		case *ast.AssignStmt:
+			x = a.Rhs[0]
+		case *ast.ExprStmt:
+			x = a.X
+		}
+		if ta, ok := x.(*ast.TypeAssertExpr); ok {
+			if id, ok := ta.X.(*ast.Ident); ok && info.Uses[id] == v {
+				ts = s
+			}
+		}
+		return ts == nil
+	})
+	return
+}
+
+// isWrapError returns if t is a pointer to the error type of the given name of the wrap package
+func isWrapError(t types.Type, name string) bool {
+	p, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+	n, ok := p.Elem().(*types.Named)
+	return ok && n.Obj().Name() == name && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == wrapPath
+}
+
+// checkDefault reports a missing default case or a default case not panicking with the given error type
+func checkDefault(pass *vetcheck.Pass, recv, method, errName string, ts *ast.TypeSwitchStmt) {
+	for _, stmt := range ts.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		if cc.List != nil {
+			continue
+		}
+		var panics bool
+		ast.Inspect(cc, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" {
+				if _, isBuiltin := pass.TypesInfo.Uses[id].(*types.Builtin); isBuiltin && isWrapError(pass.TypesInfo.TypeOf(call.Args[0]), errName) {
+					panics = true
+				}
+			}
+			return !panics
+		})
+		if !panics {
+			pass.Reportf(cc.Pos(), "default case of %s.%s must panic with *wrap.%s", recv, method, errName)
+		}
+		return
+	}
+	pass.Reportf(ts.Pos(), "%s.%s has no default case, it must panic with *wrap.%s for unsupported types", recv, method, errName)
+}
+
+// checkContext checks the type switch of a Context method
+func checkContext(pass *vetcheck.Pass, recv string, ts *ast.TypeSwitchStmt) {
+	var rw bool
+	for _, stmt := range ts.Body.List {
+		for _, e := range stmt.(*ast.CaseClause).List {
+			if t := pass.TypesInfo.TypeOf(e); t != nil && t.String() == "*net/http.ResponseWriter" {
+				rw = true
+			}
+		}
+	}
+	if !rw {
+		pass.Reportf(ts.Pos(), "%s.Context must support *http.ResponseWriter", recv)
+	}
+	checkDefault(pass, recv, "Context", "ErrUnsupportedContextGetter", ts)
+}
+
+// checkSetContext checks the type switch of a SetContext method
+func checkSetContext(pass *vetcheck.Pass, recv string, ts *ast.TypeSwitchStmt) {
+	checkDefault(pass, recv, "SetContext", "ErrUnsupportedContextSetter", ts)
+	for _, stmt := range ts.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		v, ok := pass.TypesInfo.Implicits[cc].(*types.Var)
+		if !ok || len(cc.List) != 1 {
+			continue
+		}
+		if _, isPtr := v.Type().(*types.Pointer); !isPtr {
+			continue
+		}
+		ast.Inspect(cc, func(n ast.Node) bool {
+			as, ok := n.(*ast.AssignStmt)
+			if !ok {
+				return true
+			}
+			for _, rhs := range as.Rhs {
+				if id, ok := rhs.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == v {
+					pass.Reportf(rhs.Pos(), "%s.SetContext stores the pointer %s instead of the value, use *%s", recv, id.Name, id.Name)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Command wrapvet statically checks the usage of github.com/go-on/wrap. It runs as tool of go vet
+//
+//	go install github.com/go-on/wrap/cmd/wrapvet
+//	go vet -vettool=$(which wrapvet) ./...
+//
+// or standalone on package directories:
+//
+//	wrapvet ./handlers
+//
+// Run wrapvet -h for the list of checks.
+package main
+
+import "github.com/go-on/wrap/internal/vetcheck"
+
+// checks are the checks run by wrapvet
+var checks = []*vetcheck.Check{
+	contexterCheck,
+	assertCheck,
+	unreachableCheck,
+	validateContextCheck,
+}
+
+func main() {
+	vetcheck.Main(checks...)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-on/wrap/internal/vetcheck"
+)
+
+func TestContexterCheck(t *testing.T) {
+	vetcheck.RunTest(t, "testdata/contexter", contexterCheck)
+}
+
+func TestContexterAssertCheck(t *testing.T) {
+	vetcheck.RunTest(t, "testdata/contexterassert", assertCheck)
+	vetcheck.RunTest(t, "testdata/contexterassert/stack", assertCheck)
+}
+
+func TestUnreachableCheck(t *testing.T) {
+	vetcheck.RunTest(t, "testdata/unreachable", unreachableCheck)
+}
+
+func TestValidateContextCheck(t *testing.T) {
+	vetcheck.RunTest(t, "testdata/validatecontext", validateContextCheck)
+}
@@ -0,0 +1,70 @@
+package contexter
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+type userID string
+
+// good is a correct Contexter
+type good struct {
+	http.ResponseWriter
+	id userID
+}
+
+func (g *good) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = g.ResponseWriter
+	case *userID:
+		*ty = g.id
+	default:
+		panic(&wrap.ErrUnsupportedContextGetter{Type: ctxPtr})
+	}
+	return true
+}
+
+func (g *good) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *userID:
+		g.id = *ty
+	default:
+		panic(&wrap.ErrUnsupportedContextSetter{Type: ctxPtr})
+	}
+}
+
+// delegating passes everything to the underlying Contexter and is not checked
+type delegating struct {
+	wrap.Contexter
+}
+
+func (d *delegating) Context(ctxPtr interface{}) bool { return d.Contexter.Context(ctxPtr) }
+func (d *delegating) SetContext(ctxPtr interface{})   { d.Contexter.SetContext(ctxPtr) }
+
+// bad has all the mistakes
+type bad struct {
+	http.ResponseWriter
+	id  *userID
+	err error
+}
+
+func (b *bad) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) { // want "bad.Context must support \\*http.ResponseWriter"
+	case *userID:
+		*ty = *b.id
+	default: // want "default case of bad.Context must panic with \\*wrap.ErrUnsupportedContextGetter"
+		panic("unsupported")
+	}
+	return true
+}
+
+func (b *bad) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) { // want "bad.SetContext has no default case"
+	case *userID:
+		b.id = ty // want "bad.SetContext stores the pointer ty instead of the value, use \\*ty"
+	case *error:
+		b.err = *ty
+	}
+}
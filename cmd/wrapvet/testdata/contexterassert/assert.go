@@ -0,0 +1,59 @@
+package contexterassert
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+type userID string
+
+// hidden requires a Contexter without documenting it
+type hidden struct{}
+
+func (hidden) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		var id userID
+		rw.(wrap.Contexter).Context(&id) // want "type assertion to wrap.Contexter panics"
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// validated documents its requirement via ValidateContext
+type validated struct{}
+
+func (validated) ValidateContext(ctx wrap.Contexter) {
+	var id userID
+	ctx.SetContext(&id)
+	ctx.Context(&id)
+}
+
+func (validated) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		var id userID
+		rw.(wrap.Contexter).Context(&id)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// checked uses the comma-ok form
+func checked(rw http.ResponseWriter, req *http.Request) {
+	var id userID
+	if ctx, ok := rw.(wrap.Contexter); ok {
+		ctx.Context(&id)
+	}
+	var ctx, ok = rw.(wrap.Contexter)
+	if ok {
+		ctx.Context(&id)
+	}
+}
+
+func handle(rw http.ResponseWriter, req *http.Request) {
+	var id userID
+	ctx := rw.(wrap.Contexter) // want "build the stack with wrap.Stack"
+	ctx.Context(&id)
+}
@@ -0,0 +1,19 @@
+package stack
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+type userID string
+
+func handle(rw http.ResponseWriter, req *http.Request) {
+	var id userID
+	rw.(wrap.Contexter).Context(&id)
+}
+
+// the package builds its handler via wrap.Stack, which validates the ContextInjecter
+func handler(inject wrap.ContextInjecter) http.Handler {
+	return wrap.Stack(inject, wrap.HandlerFunc(handle))
+}
@@ -0,0 +1,31 @@
+package unreachable
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+func hello(rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte("hello"))
+}
+
+type header struct{}
+
+func (header) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Test", "1")
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+var (
+	ok         = wrap.New(header{}, wrap.HandlerFunc(hello))
+	unreached  = wrap.New(wrap.HandlerFunc(hello), header{})                                      // want "unreachable wrapper: wrap.HandlerFunc at position 0 of wrap.New"
+	compiled   = wrap.Compile(header{}, wrap.Handler(http.NotFoundHandler()), header{}, header{}) // want "wrap.Handler at position 1 of wrap.Compile"
+	named      = wrap.Named("app", wrap.Handler(http.NotFoundHandler()), header{})                // want "position 1 of wrap.Named"
+	middleware = wrap.Middleware(header{}, wrap.HandlerFunc(hello))                               // want "handler passed to the middleware of wrap.Middleware is unreachable"
+	spread     = wrap.New([]wrap.Wrapper{wrap.HandlerFunc(hello), header{}}...)
+)
@@ -0,0 +1,104 @@
+package validatecontext
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+type userID string
+
+type role string
+
+// noValidate requires a Contexter without a ValidateContext method
+type noValidate struct{}
+
+func (noValidate) Wrap(next http.Handler) http.Handler { // want "noValidate.Wrap type asserts to wrap.Contexter, but noValidate has no ValidateContext method"
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		var id userID
+		rw.(wrap.Contexter).Context(&id)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// optional uses the Contexter only if there is one
+type optional struct{}
+
+func (optional) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if ctx, ok := rw.(wrap.Contexter); ok {
+			var id userID
+			ctx.Context(&id)
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// complete validates all types it uses
+type complete struct{}
+
+func (complete) ValidateContext(ctx wrap.Contexter) {
+	var id userID
+	var r role
+	ctx.Context(&id)
+	ctx.SetContext(&r)
+}
+
+func (complete) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ctx := rw.(wrap.Contexter)
+		var id userID
+		ctx.Context(&id)
+		r := role("admin")
+		wrap.TrySetContext(rw, &r)
+		var w http.ResponseWriter
+		ctx.Context(&w)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// incomplete does not validate the role
+type incomplete struct{}
+
+func (*incomplete) ValidateContext(ctx wrap.Contexter) { // want "incomplete.ValidateContext does not validate the context types used in incomplete.Wrap: role$"
+	var id userID
+	ctx.Context(&id)
+}
+
+func (*incomplete) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ctx := rw.(wrap.Contexter)
+		var id userID
+		var r role
+		ctx.Context(&id)
+		ctx.SetContext(&r)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// delegating passes the Contexter to another validation, so the validated types are unknown
+type delegating struct {
+	complete
+}
+
+func (d delegating) ValidateContext(ctx wrap.Contexter) {
+	d.complete.ValidateContext(ctx)
+}
+
+func (delegating) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		var r role
+		rw.(wrap.Contexter).Context(&r)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
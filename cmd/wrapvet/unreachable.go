@@ -0,0 +1,112 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/go-on/wrap/internal/vetcheck"
+)
+
+// unreachableCheck reports wrappers after wrap.Handler and wrap.HandlerFunc adapters
+var unreachableCheck = &vetcheck.Check{
+	Name: "unreachable",
+	Doc: `check for unreachable wrappers
+
+The Wrappers returned by wrap.Handler and wrap.HandlerFunc never call the next handler, so the wrappers
+following them in calls of New, Compile, Stack and the other stack constructors never run.
+Inside Middleware and ToConstructor the handler passed to the middleware is unreachable too.
+Wrappers implementing wrap.Terminator can't be checked statically; New (if STRICT is set)
+and Stack report them at runtime. The wrap package itself is not checked.`,
+	Run: runUnreachableCheck,
+}
+
+// stackFunc describes a function of the wrap package building a stack from variadic wrappers
+type stackFunc struct {
+	// first is the index of the first wrapper parameter
+	first int
+
+	// tail is true if the stack is followed by a handler that is passed later
+	tail bool
+}
+
+var stackFuncs = map[string]stackFunc{
+	"New":           {0, false},
+	"Compile":       {0, false},
+	"Stack":         {1, false},
+	"Named":         {1, false},
+	"NamedStack":    {2, false},
+	"Instrument":    {2, false},
+	"NewCoverage":   {0, false},
+	"Middleware":    {0, true},
+	"ToConstructor": {0, true},
+}
+
+// wrapFunc returns the function of the wrap package that is called, nil if it is not a call of a function of the wrap package
+func wrapFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		id = fn
+	case *ast.SelectorExpr:
+		id = fn.Sel
+	}
+	if id == nil {
+		return nil
+	}
+	obj, ok := info.Uses[id].(*types.Func)
+	if !ok || obj.Pkg() == nil || obj.Pkg().Path() != wrapPath || obj.Type().(*types.Signature).Recv() != nil {
+		return nil
+	}
+	return obj
+}
+
+// isAdapter returns the name of the terminating adapter, if e is a call of wrap.Handler or wrap.HandlerFunc
+func isAdapter(info *types.Info, e ast.Expr) (string, bool) {
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return "", false
+	}
+	fn := wrapFunc(info, call)
+	if fn == nil || (fn.Name() != "Handler" && fn.Name() != "HandlerFunc") {
+		return "", false
+	}
+	return fn.Name(), true
+}
+
+func runUnreachableCheck(pass *vetcheck.Pass) {
+	if pass.Pkg.Path() == wrapPath {
+		return
+	}
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || call.Ellipsis.IsValid() {
+				return true
+			}
+			fn := wrapFunc(pass.TypesInfo, call)
+			if fn == nil {
+				return true
+			}
+			sf, ok := stackFuncs[fn.Name()]
+			if !ok || len(call.Args) <= sf.first {
+				return true
+			}
+			args := call.Args[sf.first:]
+			for i, arg := range args {
+				adapter, ok := isAdapter(pass.TypesInfo, arg)
+				if !ok {
+					continue
+				}
+				switch {
+				case i < len(args)-1:
+					pass.Reportf(args[i+1].Pos(), "unreachable wrapper: wrap.%s at position %d of wrap.%s never calls the next handler", adapter, sf.first+i, fn.Name())
+				case sf.tail:
+					pass.Reportf(arg.Pos(), "wrap.%s never calls the next handler, so the handler passed to the middleware of wrap.%s is unreachable", adapter, fn.Name())
+				}
+				// the first adapter makes the rest unreachable, further ones are not reported
+				return true
+			}
+			return true
+		})
+	}
+}
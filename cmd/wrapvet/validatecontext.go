@@ -0,0 +1,156 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/go-on/wrap/internal/vetcheck"
+)
+
+// validateContextCheck reports Wrappers using a Contexter without validating the context types
+var validateContextCheck = &vetcheck.Check{
+	Name: "validatecontext",
+	Doc: `check that Wrappers using a Contexter implement ValidateContext
+
+A Wrap method that type asserts the ResponseWriter to wrap.Contexter (not in the comma-ok form, which
+allows the Contexter to be optional) must belong to a type with a
+ValidateContext method (a wrap.ContextWrapper), so that ValidateWrapperContexts can uncover missing
+context types before serving. If the ValidateContext method is declared in the same package and only
+calls Context and SetContext, every context type passed to Context, SetContext, wrap.TryContext and
+wrap.TrySetContext inside the Wrap method must be passed inside ValidateContext too.`,
+	Run: runValidateContextCheck,
+}
+
+// contextTypes returns the types of the pointers that are passed inside node to the Context and SetContext
+// methods of wrap.Contexter values and to wrap.TryContext and wrap.TrySetContext, without *http.ResponseWriter
+func contextTypes(pkg *types.Package, info *types.Info, node ast.Node) map[string]bool {
+	found := map[string]bool{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var arg ast.Expr
+		switch fn := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			if (fn.Sel.Name == "Context" || fn.Sel.Name == "SetContext") && len(call.Args) == 1 && isContexter(info.TypeOf(fn.X)) {
+				arg = call.Args[0]
+			}
+		}
+		if f := wrapFunc(info, call); f != nil && (f.Name() == "TryContext" || f.Name() == "TrySetContext") && len(call.Args) == 2 {
+			arg = call.Args[1]
+		}
+		if arg == nil {
+			return true
+		}
+		if t := info.TypeOf(arg); t != nil {
+			if _, isPtr := t.(*types.Pointer); isPtr && t.String() != "*net/http.ResponseWriter" {
+				found[types.TypeString(t, qualifier(pkg))] = true
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// passesParam returns if the parameter is passed to a function or method (other than Context and SetContext
+// called on it), so that the validated types are unknown
+func passesParam(info *types.Info, body *ast.BlockStmt, param types.Object) (passes bool) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return !passes
+		}
+		for _, arg := range call.Args {
+			if id, ok := arg.(*ast.Ident); ok && info.Uses[id] == param {
+				passes = true
+			}
+		}
+		return !passes
+	})
+	return
+}
+
+// isWrapMethod returns if the method has the signature Wrap(http.Handler) http.Handler
+func isWrapMethod(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	return fn.Name() == "Wrap" && sig.Params().Len() == 1 && sig.Results().Len() == 1 &&
+		sig.Params().At(0).Type().String() == "net/http.Handler" && sig.Results().At(0).Type().String() == "net/http.Handler"
+}
+
+func runValidateContextCheck(pass *vetcheck.Pass) {
+	// the ValidateContext methods declared in the package by receiver type
+	validators := map[types.Type]*ast.FuncDecl{}
+	var wraps []*ast.FuncDecl
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil {
+				continue
+			}
+			obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			switch {
+			case fn.Name.Name == "ValidateContext":
+				validators[recvType(obj)] = fn
+			case isWrapMethod(obj):
+				wraps = append(wraps, fn)
+			}
+		}
+	}
+
+	for _, fn := range wraps {
+		if len(contexterAssertions(pass.TypesInfo, fn.Body)) == 0 {
+			continue
+		}
+		obj := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		recv := recvType(obj)
+		name := recvName(obj)
+		if types.NewMethodSet(types.NewPointer(recv)).Lookup(pass.Pkg, "ValidateContext") == nil {
+			pass.Reportf(fn.Name.Pos(), "%s.Wrap type asserts to wrap.Contexter, but %s has no ValidateContext method to validate the context types", name, name)
+			continue
+		}
+		validator, ok := validators[recv]
+		if !ok || len(validator.Type.Params.List) != 1 || len(validator.Type.Params.List[0].Names) != 1 {
+			continue
+		}
+		param := pass.TypesInfo.Defs[validator.Type.Params.List[0].Names[0]]
+		if param == nil || passesParam(pass.TypesInfo, validator.Body, param) {
+			continue
+		}
+		validated := contextTypes(pass.Pkg, pass.TypesInfo, validator.Body)
+		var missing []string
+		for t := range contextTypes(pass.Pkg, pass.TypesInfo, fn.Body) {
+			if !validated[t] {
+				missing = append(missing, strings.TrimPrefix(t, "*"))
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			pass.Reportf(validator.Name.Pos(), "%s.ValidateContext does not validate the context types used in %s.Wrap: %s", name, name, strings.Join(missing, ", "))
+		}
+	}
+}
+
+// qualifier qualifies types by their package name, omitting the name of the given package
+func qualifier(pkg *types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+}
+
+// recvType returns the receiver type of the method without pointer
+func recvType(fn *types.Func) types.Type {
+	recv := fn.Type().(*types.Signature).Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	return recv
+}
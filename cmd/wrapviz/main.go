@@ -0,0 +1,110 @@
+// Command wrapviz shows the middleware stacks a service is running: the wrappers of every stack in order,
+// the Contexter, the context types required by the wrappers and provided or missing in the Contexter,
+// the state of Inspectable wrappers and optionally the latency of each wrapper.
+//
+// The stacks are read as JSON manifest (see wrap.Manifest) from the wrap.InspectorHandler of the service,
+// from a manifest file (- for stdin) or from a file with the plain text output of the InspectorHandler.
+// Stacks mounted inside other stacks (like the routes of a wrap.Mux) are shown as separate stacks, named after
+// the stacks they are mounted in.
+//
+// The latency of the wrappers is read from the Prometheus endpoint of a wrap.MemMetrics that is
+// used with wrap.Instrument, if the -metrics flag is given. The metrics are matched by the stack name
+// and the type of the wrapper. Usage:
+//
+//	wrapviz [-metrics http://localhost:8080/debug/metrics] [-format text|dot|json] http://localhost:8080/debug/wrap
+//	wrapviz -format dot manifest.json | dot -Tsvg > stacks.svg
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// read returns the content of the source, which is either a http(s) URL, a file or - for stdin
+func read(src string) ([]byte, error) {
+	switch {
+	case src == "-":
+		return io.ReadAll(os.Stdin)
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		req, err := http.NewRequest("GET", src, nil)
+		if err != nil {
+			return nil, err
+		}
+		// the InspectorHandler serves the JSON manifest, the metrics handler ignores the header
+		req.Header.Set("Accept", "application/json, text/plain;q=0.9")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", src, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	default:
+		return os.ReadFile(src)
+	}
+}
+
+// load reads the stacks from the source, detecting JSON manifests by their first character
+func load(src string) ([]*stack, error) {
+	data, err := read(src)
+	if err != nil {
+		return nil, err
+	}
+	var stacks []*stack
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		stacks, err = parseManifest(data)
+	} else {
+		stacks, err = parseInspector(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	return stacks, nil
+}
+
+func run(src, metrics, format string, w io.Writer) error {
+	render, ok := renderers[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	stacks, err := load(src)
+	if err != nil {
+		return err
+	}
+	if metrics != "" {
+		data, err := read(metrics)
+		if err != nil {
+			return err
+		}
+		if err := addLatencies(stacks, data); err != nil {
+			return fmt.Errorf("%s: %v", metrics, err)
+		}
+	}
+	return render(stacks, w)
+}
+
+func main() {
+	metrics := flag.String("metrics", "", "the Prometheus endpoint (or file) of a wrap.MemMetrics to read the latencies from")
+	format := flag.String("format", "text", "the output format: text, dot (graphviz) or json (manifest)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: wrapviz [flags] inspector-url|manifest-file\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *metrics, *format, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "wrapviz:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+	"github.com/go-on/wrap/wraptest"
+)
+
+const inspectorOutput = `stack "app"
+  -  *app.context (Contexter)
+  0  app.setUserIP requires: app.userIP, error
+  1  *wrap.Breaker [closed]
+  2  app.handler requires: app.requestID
+  provided context types: app.userIP, error
+  missing context types: app.requestID
+
+stack "plain"
+  0  app.write
+  1  app.handler (Contexter) requires: app.userIP
+  no Contexter, missing context types: app.userIP
+`
+
+func TestParseInspector(t *testing.T) {
+	stacks, err := parseInspector([]byte(inspectorOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*stack{
+		{
+			Name:      "app",
+			Contexter: "*app.context",
+			Wrappers: []*wrapper{
+				{Type: "app.setUserIP", Requires: []string{"app.userIP", "error"}},
+				{Type: "*wrap.Breaker", State: "closed"},
+				{Type: "app.handler", Requires: []string{"app.requestID"}},
+			},
+			Provided: []string{"app.userIP", "error"},
+			Missing:  []string{"app.requestID"},
+		},
+		{
+			Name:      "plain",
+			Contexter: "app.handler",
+			Wrappers: []*wrapper{
+				{Type: "app.write"},
+				{Type: "app.handler", Roles: []string{"contexter"}, Requires: []string{"app.userIP"}},
+			},
+			Missing: []string{"app.userIP"},
+		},
+	}
+	if !reflect.DeepEqual(stacks, expected) {
+		var got, exp bytes.Buffer
+		renderJSON(stacks, &got)
+		renderJSON(expected, &exp)
+		t.Errorf("expected\n%s\ngot\n%s", exp.String(), got.String())
+	}
+
+	if _, err := parseInspector([]byte("  0  app.write\n")); err == nil {
+		t.Errorf("expected error for wrapper outside of stack")
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	stacks, _ := parseInspector([]byte(inspectorOutput))
+	var bf bytes.Buffer
+	if err := renderJSON(stacks, &bf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseManifest(bf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, stacks) {
+		t.Errorf("manifest does not round trip:\n%s", bf.String())
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels(`stack="a\"b",wrapper="x\\y\nz"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels["stack"] != `a"b` || labels["wrapper"] != "x\\y\nz" {
+		t.Errorf("unexpected labels %#v", labels)
+	}
+	if _, err := parseLabels(`stack="a`); err == nil {
+		t.Errorf("expected error for unterminated label")
+	}
+}
+
+type header struct{}
+
+func (header) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Test", "1")
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func TestRun(t *testing.T) {
+	metrics := wrap.NewMemMetrics()
+	wrappers := []wrap.Wrapper{header{}, wrap.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})}
+	wrap.NamedStack("wrapviz", wraptest.ContexterFor(wrappers...), wrappers...)
+	app := wrap.Instrument("wrapviz", metrics, wrappers...)
+	for i := 0; i < 3; i++ {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	routes := wrap.NewMux(wraptest.ContexterFor())
+	routes.Handle("/a", http.NotFoundHandler(), header{})
+	manifest, err := wrap.Manifest(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(file, manifest, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/wrap", wrap.InspectorHandler())
+	mux.Handle("/metrics", metrics)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for format, expected := range map[string][]string{
+		"text": {
+			`stack "wrapviz"`,
+			"contexter: *wraptest.Contexter",
+			"0  main.header  3 req, mean ",
+			"1  wrap.NextHandlerFunc  (terminator, adapter)  3 req, mean ",
+			`stack "mux > /a"`,
+			"0  main.header",
+		},
+		"dot":  {"digraph wrap {", `label="wrapviz"`, "s0_ctx -> s0_w0;"},
+		"json": {`"name": "wrapviz"`, `"requests": 3`},
+	} {
+		var bf bytes.Buffer
+		if err := run(srv.URL+"/debug/wrap", srv.URL+"/metrics", format, &bf); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if err := run(file, "", format, &bf); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for _, exp := range expected {
+			if !strings.Contains(bf.String(), exp) {
+				t.Errorf("%s: expected output to contain %q, got\n%s", format, exp, bf.String())
+			}
+		}
+	}
+
+	if err := run(srv.URL+"/debug/wrap", "", "svg", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if err := run(srv.URL+"/missing", "", "text", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error for missing endpoint")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseLabels parses the labels of a Prometheus sample, like stack="app",wrapper="*app.Auth"
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for s != "" {
+		eq := strings.Index(s, "=\"")
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid labels %q", s)
+		}
+		name := s[:eq]
+		s = s[eq+2:]
+		var val strings.Builder
+		i := 0
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					val.WriteByte('\n')
+					continue
+				}
+			}
+			val.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return nil, fmt.Errorf("unterminated label %s", name)
+		}
+		labels[name] = val.String()
+		s = strings.TrimPrefix(s[i+1:], ",")
+	}
+	return labels, nil
+}
+
+// latencyKey identifies the wrapper of a stack in the metrics
+type latencyKey struct {
+	stack, wrapper string
+}
+
+// parseMetrics parses the request counters and latency sums and counts of the Prometheus text
+// exposition format written by wrap.MemMetrics
+func parseMetrics(data []byte) (map[latencyKey]*latency, error) {
+	latencies := map[latencyKey]*latency{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		var metric string
+		for _, m := range []string{"wrap_requests_total{", "wrap_latency_seconds_sum{", "wrap_latency_seconds_count{"} {
+			if strings.HasPrefix(line, m) {
+				metric = m
+			}
+		}
+		if metric == "" {
+			continue
+		}
+		end := strings.LastIndex(line, "} ")
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: invalid sample %q", n, line)
+		}
+		labels, err := parseLabels(line[len(metric):end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		val, err := strconv.ParseFloat(line[end+2:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		k := latencyKey{labels["stack"], labels["wrapper"]}
+		l, has := latencies[k]
+		if !has {
+			l = &latency{}
+			latencies[k] = l
+		}
+		switch metric {
+		case "wrap_requests_total{":
+			l.Requests = uint64(val)
+		case "wrap_latency_seconds_sum{":
+			l.Sum = val
+		case "wrap_latency_seconds_count{":
+			l.Count = uint64(val)
+		}
+	}
+	return latencies, sc.Err()
+}
+
+// addLatencies sets the latencies of the wrappers of the stacks from the given metrics
+func addLatencies(stacks []*stack, metrics []byte) error {
+	latencies, err := parseMetrics(metrics)
+	if err != nil {
+		return err
+	}
+	for _, st := range stacks {
+		for _, w := range st.Wrappers {
+			if l, has := latencies[latencyKey{st.Name, w.Type}]; has {
+				w.Latency = l
+			}
+		}
+	}
+	return nil
+}
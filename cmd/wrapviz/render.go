@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// renderers are the output formats by name
+var renderers = map[string]func([]*stack, io.Writer) error{
+	"text": renderText,
+	"dot":  renderDot,
+	"json": renderJSON,
+}
+
+// String returns the number of requests and the mean latency
+func (l *latency) String() string {
+	if l.Count == 0 {
+		return fmt.Sprintf("%d req", l.Requests)
+	}
+	mean := time.Duration(l.Sum / float64(l.Count) * float64(time.Second))
+	return fmt.Sprintf("%d req, mean %s", l.Requests, mean)
+}
+
+// describe returns the details of the wrapper, separated by sep
+func (w *wrapper) describe(sep string) string {
+	var details []string
+	if len(w.Roles) > 0 {
+		details = append(details, "("+strings.Join(w.Roles, ", ")+")")
+	}
+	if len(w.Requires) > 0 {
+		details = append(details, "requires: "+strings.Join(w.Requires, ", "))
+	}
+	if w.State != "" {
+		details = append(details, "["+w.State+"]")
+	}
+	if w.Latency != nil {
+		details = append(details, w.Latency.String())
+	}
+	return strings.Join(details, sep)
+}
+
+func renderText(stacks []*stack, w io.Writer) error {
+	var bf strings.Builder
+	for i, st := range flatten(stacks) {
+		if i > 0 {
+			bf.WriteString("\n")
+		}
+		fmt.Fprintf(&bf, "stack %q\n", st.Name)
+		if st.Contexter != "" {
+			fmt.Fprintf(&bf, "  contexter: %s\n", st.Contexter)
+		}
+		for j, wr := range st.Wrappers {
+			fmt.Fprintf(&bf, "  %2d  %s", j, wr.Type)
+			if d := wr.describe("  "); d != "" {
+				fmt.Fprintf(&bf, "  %s", d)
+			}
+			bf.WriteString("\n")
+		}
+		if len(st.Provided) > 0 {
+			fmt.Fprintf(&bf, "  provided: %s\n", strings.Join(st.Provided, ", "))
+		}
+		if len(st.Missing) > 0 {
+			fmt.Fprintf(&bf, "  MISSING: %s\n", strings.Join(st.Missing, ", "))
+		}
+	}
+	_, err := io.WriteString(w, bf.String())
+	return err
+}
+
+// renderDot renders the stacks as graphviz digraph with a cluster per stack
+func renderDot(stacks []*stack, w io.Writer) error {
+	var bf strings.Builder
+	bf.WriteString("digraph wrap {\n  rankdir=TB;\n  node [shape=box];\n")
+	for i, st := range flatten(stacks) {
+		fmt.Fprintf(&bf, "  subgraph cluster_%d {\n    label=%s;\n", i, strconv.Quote(st.Name))
+		prev := ""
+		if st.Contexter != "" {
+			prev = fmt.Sprintf("s%d_ctx", i)
+			fmt.Fprintf(&bf, "    %s [label=%s, style=filled];\n", prev, strconv.Quote(st.Contexter+"\nContexter"))
+		}
+		for j, wr := range st.Wrappers {
+			id := fmt.Sprintf("s%d_w%d", i, j)
+			label := fmt.Sprintf("%d %s", j, wr.Type)
+			if d := wr.describe("\n"); d != "" {
+				label += "\n" + d
+			}
+			fmt.Fprintf(&bf, "    %s [label=%s];\n", id, strconv.Quote(label))
+			if prev != "" {
+				fmt.Fprintf(&bf, "    %s -> %s;\n", prev, id)
+			}
+			prev = id
+		}
+		if len(st.Missing) > 0 {
+			fmt.Fprintf(&bf, "    s%d_missing [label=%s, color=red];\n", i, strconv.Quote("missing: "+strings.Join(st.Missing, ", ")))
+		}
+		bf.WriteString("  }\n")
+	}
+	bf.WriteString("}\n")
+	_, err := io.WriteString(w, bf.String())
+	return err
+}
+
+// renderJSON renders the stacks as JSON manifest
+func renderJSON(stacks []*stack, w io.Writer) error {
+	data, err := json.MarshalIndent(manifest{Stacks: stacks}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
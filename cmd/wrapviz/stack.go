@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// stack is the description of a stack, as in the JSON manifest of wrap.Manifest
+type stack struct {
+	Name      string     `json:"name"`
+	Contexter string     `json:"contexter,omitempty"`
+	Wrappers  []*wrapper `json:"wrappers"`
+	Provided  []string   `json:"provided,omitempty"`
+	Missing   []string   `json:"missing,omitempty"`
+	Mounts    []*mount   `json:"mounts,omitempty"`
+}
+
+// mount is a stack mounted inside another stack, e.g. a route of a wrap.Mux
+type mount struct {
+	Pattern string `json:"pattern"`
+	Stack   *stack `json:"stack"`
+}
+
+// flatten returns the stacks followed by their mounted stacks, which are named after the path
+// of the stacks they are mounted in
+func flatten(stacks []*stack) []*stack {
+	var all []*stack
+	for _, st := range stacks {
+		all = append(all, st)
+		for _, m := range st.Mounts {
+			sub := *m.Stack
+			sub.Name = st.Name + " > " + sub.Name
+			all = append(all, flatten([]*stack{&sub})...)
+		}
+	}
+	return all
+}
+
+// wrapper is the description of a wrapper of a stack
+type wrapper struct {
+	Type string `json:"type"`
+
+	// Roles are the roles of the wrapper, like "contexter" for the Contexter of the stack (see wrap.Manifest)
+	Roles    []string `json:"roles,omitempty"`
+	Requires []string `json:"requires,omitempty"`
+	State    string   `json:"state,omitempty"`
+
+	// Latency is only set if metrics are read
+	Latency *latency `json:"latency,omitempty"`
+}
+
+// latency are the latency statistics of a wrapper
+type latency struct {
+	Requests uint64 `json:"requests"`
+	Count    uint64 `json:"count"`
+
+	// Sum is the sum of the latencies in seconds
+	Sum float64 `json:"sum"`
+}
+
+// hasRole returns if the wrapper has the given role
+func (w *wrapper) hasRole(role string) bool {
+	for _, r := range w.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// manifest is the JSON manifest
+type manifest struct {
+	Stacks []*stack `json:"stacks"`
+}
+
+// parseManifest parses a JSON manifest
+func parseManifest(data []byte) ([]*stack, error) {
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m.Stacks, nil
+}
+
+// splitTypes splits a comma separated list of types, as printed by the inspector
+func splitTypes(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ", ")
+}
+
+// parseWrapper parses the description of a wrapper after its index, like
+//
+//	wrap.setUserIP (Contexter) requires: wrap.userIP, error [state]
+func parseWrapper(s string) *wrapper {
+	w := &wrapper{}
+	if strings.HasSuffix(s, "]") {
+		if i := strings.LastIndex(s, " ["); i >= 0 {
+			w.State = s[i+2 : len(s)-1]
+			s = s[:i]
+		}
+	}
+	if i := strings.Index(s, " requires: "); i >= 0 {
+		w.Requires = splitTypes(s[i+len(" requires: "):])
+		s = s[:i]
+	}
+	if strings.HasSuffix(s, " (Contexter)") {
+		w.Roles = append(w.Roles, "contexter")
+		s = strings.TrimSuffix(s, " (Contexter)")
+	}
+	w.Type = s
+	return w
+}
+
+// parseInspector parses the output of the wrap.InspectorHandler
+func parseInspector(data []byte) ([]*stack, error) {
+	var stacks []*stack
+	var cur *stack
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(line, "stack "):
+			name, err := strconv.Unquote(strings.TrimPrefix(line, "stack "))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid stack name: %v", n, err)
+			}
+			cur = &stack{Name: name}
+			stacks = append(stacks, cur)
+		case cur == nil:
+			return nil, fmt.Errorf("line %d: expected stack, got %q", n, line)
+		case strings.HasPrefix(line, "  -  "):
+			cur.Contexter = strings.TrimSuffix(strings.TrimPrefix(line, "  -  "), " (Contexter)")
+		case strings.HasPrefix(line, "  provided context types: "):
+			cur.Provided = splitTypes(strings.TrimPrefix(line, "  provided context types: "))
+		case strings.HasPrefix(line, "  missing context types: "):
+			cur.Missing = splitTypes(strings.TrimPrefix(line, "  missing context types: "))
+		case strings.HasPrefix(line, "  no Contexter, missing context types: "):
+			cur.Missing = splitTypes(strings.TrimPrefix(line, "  no Contexter, missing context types: "))
+		default:
+			fields := strings.SplitN(strings.TrimSpace(line), "  ", 2)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: unexpected line %q", n, line)
+			}
+			if _, err := strconv.Atoi(fields[0]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid wrapper index: %q", n, fields[0])
+			}
+			w := parseWrapper(fields[1])
+			if w.hasRole("contexter") {
+				cur.Contexter = w.Type
+			}
+			cur.Wrappers = append(cur.Wrappers, w)
+		}
+	}
+	return stacks, sc.Err()
+}
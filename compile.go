@@ -0,0 +1,79 @@
+package wrap
+
+import "net/http"
+
+// chain runs a sequence of NextHandlerFuncs by walking a slice instead of nesting closures
+type chain struct {
+	fns   []NextHandlerFunc
+	steps []step
+	tail  http.Handler
+}
+
+// step is the http.Handler that runs the NextHandlerFunc at index i of the chain,
+// passing the following step as next handler
+type step struct {
+	c *chain
+	i int
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *step) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if s.i == len(s.c.fns) {
+		s.c.tail.ServeHTTP(rw, req)
+		return
+	}
+	s.c.fns[s.i](&s.c.steps[s.i+1], rw, req)
+}
+
+// newChain returns the first step of a chain of the given functions, followed by tail
+func newChain(fns []NextHandlerFunc, tail http.Handler) http.Handler {
+	c := &chain{fns: fns, steps: make([]step, len(fns)+1), tail: tail}
+	for i := range c.steps {
+		c.steps[i] = step{c, i}
+	}
+	return &c.steps[0]
+}
+
+// Compile is an alternative to New that creates the same handler, but runs consecutive NextHandlerFuncs
+// (including the Wrappers returned by Handler, HandlerFunc and NextHandler) by walking a slice instead
+// of nesting a closure per Wrapper. This saves the closures and a level of calls per NextHandlerFunc,
+// which matters for very deep stacks. Other Wrappers are wrapped around the rest of the stack as usual.
+//
+// If DEBUG is set, Compile is the same as New. Like New, Compile supports WithStrict.
+func Compile(wrapper ...Wrapper) http.Handler {
+	wrapper = applyStrict(wrapper)
+	if IsStrict() {
+		if err := findDuplicateContexter(wrapper); err != nil {
+			panic(err)
+		}
+	}
+	checkUnreachable(wrapper)
+	if IsDebug() {
+		return _debug(wrapper...)
+	}
+
+	var h http.Handler = NoOp
+	var run []NextHandlerFunc
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		fns := make([]NextHandlerFunc, len(run))
+		for i, fn := range run {
+			fns[len(run)-1-i] = fn
+		}
+		h = newChain(fns, h)
+		run = run[:0]
+	}
+
+	for i := len(wrapper) - 1; i >= 0; i-- {
+		if fn, ok := wrapper[i].(NextHandlerFunc); ok {
+			run = append(run, fn)
+			continue
+		}
+		flush()
+		h = wrapper[i].Wrap(h)
+	}
+	flush()
+	return h
+}
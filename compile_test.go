@@ -0,0 +1,37 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	stacks := [][]Wrapper{
+		{},
+		{NextHandler(write("a")), NextHandler(write("b")), HandlerFunc(writeCode)},
+		{write("a"), NextHandler(write("b")), NextHandler(write("c")), write("d"), Handler(writeStop("e")), write("f")},
+		{NextHandler(write("a")), Handler(writeStop("b")), NextHandler(write("c"))},
+	}
+
+	for i, st := range stacks {
+		recNew, req := newTestRequest("GET", "/")
+		New(st...).ServeHTTP(recNew, req)
+
+		rec, req := newTestRequest("GET", "/")
+		Compile(st...).ServeHTTP(rec, req)
+
+		if rec.Body.String() != recNew.Body.String() || rec.Code != recNew.Code {
+			t.Errorf("stack %d: Compile should respond like New (%d %q), got %d %q", i, recNew.Code, recNew.Body.String(), rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestCompileReentrant(t *testing.T) {
+	h := Compile(NextHandler(write("a")), NextHandler(write("b")))
+	inner := Compile(NextHandler(write("x")), Handler(h), NextHandler(write("y")))
+
+	rec, req := newTestRequest("GET", "/")
+	inner.ServeHTTP(rec, req)
+	inner.ServeHTTP(rec, req)
+	assertResponse(t, rec, "xabxab", http.StatusOK)
+}
@@ -0,0 +1,207 @@
+package wrap
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ContentEncoder is a content coding that Compress may apply to responses
+type ContentEncoder struct {
+	// Name is the token of the content coding in Accept-Encoding and Content-Encoding, e.g. "gzip" or "br"
+	Name string
+
+	// NewWriter returns a writer that encodes to w. If it has a Flush() error method, it is called
+	// when the response is flushed.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoder is the ContentEncoder for gzip
+var GzipEncoder = ContentEncoder{Name: "gzip", NewWriter: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }}
+
+// Compress is a Wrapper that negotiates the content coding of the response with the Accept-Encoding header
+// of the request (taking q-values into account) and compresses the body written by the next handlers with the
+// best of the Encoders. Between encodings of the same quality, the order of the Encoders decides.
+// If none is accepted, the body is sent unencoded (identity).
+//
+// Responses that already have a Content-Encoding, responses to HEAD requests, responses without body
+// (204, 304) and partial responses (206 or with Content-Range) are not encoded. Accept-Ranges is removed
+// from encoded responses. The Content-Type is detected from the unencoded body if it is not set, and the
+// Content-Length is removed. Vary: Accept-Encoding is always added, so a Cache before Compress caches the encoded
+// and the unencoded variants separately. Set NormalizeAcceptEncoding of Compress as normalizer of the Cache, so
+// that the variants are keyed by the negotiated encoding:
+//
+//	compress := wrap.Compress{}
+//	cache := &wrap.Cache{Normalizers: map[string]wrap.VaryNormalizer{"Accept-Encoding": compress.NormalizeAcceptEncoding}}
+//	wrap.New(cache, compress, ...)
+//
+// The ResponseWriter passed to the next handlers keeps the Contexter and http.Flusher.
+type Compress struct {
+	// Encoders are the available content codings. If it is empty, GzipEncoder is used.
+	// Brotli is not part of the standard library, it may be added as ContentEncoder{Name: "br", ...}
+	// with a brotli package of choice.
+	Encoders []ContentEncoder
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Compress{}
+
+func (c Compress) encoders() []ContentEncoder {
+	if len(c.Encoders) == 0 {
+		return []ContentEncoder{GzipEncoder}
+	}
+	return c.Encoders
+}
+
+// negotiate returns the best accepted encoder, nil for identity
+func (c Compress) negotiate(acceptEncoding []string) *ContentEncoder {
+	values := parseAccept(acceptEncoding)
+	encoders := c.encoders()
+	var best *ContentEncoder
+	var bestQ float64
+	for i := range encoders {
+		q, explicit := acceptQuality(values, encoders[i].Name)
+		if encoders[i].Name == "gzip" && !explicit {
+			if xq, ok := acceptQuality(values, "x-gzip"); ok {
+				q = xq
+			}
+		}
+		if q > bestQ {
+			best, bestQ = &encoders[i], q
+		}
+	}
+	return best
+}
+
+// NormalizeAcceptEncoding is a VaryNormalizer for a Cache that returns the content coding Compress negotiates
+// for the given Accept-Encoding value, "identity" if none
+func (c Compress) NormalizeAcceptEncoding(value string) string {
+	if enc := c.negotiate([]string{value}); enc != nil {
+		return enc.Name
+	}
+	return "identity"
+}
+
+// addVary adds the header name to the Vary header, unless it is already there
+func addVary(header http.Header, name string) {
+	if headerContainsToken(header, "Vary", name) || headerContainsToken(header, "Vary", "*") {
+		return
+	}
+	header.Add("Vary", name)
+}
+
+// compressWriter encodes the body written to it
+type compressWriter struct {
+	http.ResponseWriter
+	encoder *ContentEncoder
+	head    bool
+
+	// decided is true after the first Write or WriteHeader
+	decided bool
+	w       io.WriteCloser
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &compressWriter{}
+
+// decide starts the encoding, if the response should be encoded
+func (c *compressWriter) decide(code int, body []byte) {
+	if c.decided {
+		return
+	}
+	c.decided = true
+	header := c.ResponseWriter.Header()
+	if c.head || code == http.StatusNoContent || code == http.StatusNotModified || (code >= 100 && code < 200) ||
+		code == http.StatusPartialContent || header.Get("Content-Range") != "" || header.Get("Content-Encoding") != "" {
+		return
+	}
+	if _, has := header["Content-Type"]; !has && body != nil {
+		header.Set("Content-Type", http.DetectContentType(body))
+	}
+	header.Del("Content-Length")
+	// byte ranges refer to the unencoded body
+	header.Del("Accept-Ranges")
+	header.Set("Content-Encoding", c.encoder.Name)
+	c.w = c.encoder.NewWriter(c.ResponseWriter)
+}
+
+// WriteHeader decides about the encoding and writes the status code
+func (c *compressWriter) WriteHeader(code int) {
+	c.decide(code, nil)
+	c.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the encoded body
+func (c *compressWriter) Write(b []byte) (int, error) {
+	c.decide(http.StatusOK, b)
+	if c.w == nil {
+		return c.ResponseWriter.Write(b)
+	}
+	return c.w.Write(b)
+}
+
+// ReadFrom writes the content of r. If the response is not encoded, r is passed to the underlying
+// ResponseWriter (see Peek.ReadFrom), so that files may be served via sendfile.
+func (c *compressWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !c.decided && c.ResponseWriter.Header().Get("Content-Type") != "" {
+		c.decide(http.StatusOK, nil)
+	}
+	if c.decided && c.w == nil {
+		return readFrom(c.ResponseWriter, r)
+	}
+	return io.Copy(writerOnly{c}, r)
+}
+
+// Flush flushes the encoder and the underlying ResponseWriter
+func (c *compressWriter) Flush() {
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	flushInner(c.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (c *compressWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(c.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (c *compressWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(c.ResponseWriter, ctxPtr)
+}
+
+// close finishes the encoding
+func (c *compressWriter) close() {
+	if c.w != nil {
+		c.w.Close()
+	}
+}
+
+// Wrap implements the Wrapper interface
+func (c Compress) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		addVary(rw.Header(), "Accept-Encoding")
+		enc := c.negotiate(req.Header.Values("Accept-Encoding"))
+		if enc == nil {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: rw, encoder: enc, head: strings.EqualFold(req.Method, http.MethodHead)}
+		defer cw.close()
+		next.ServeHTTP(cw, req)
+	}
+	return f
+}
@@ -0,0 +1,174 @@
+package wrap
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upperEncoder is a ContentEncoder for tests that uppercases the body
+var upperEncoder = ContentEncoder{Name: "upper", NewWriter: func(w io.Writer) io.WriteCloser { return &upperWriter{w} }}
+
+type upperWriter struct {
+	io.Writer
+}
+
+func (u *upperWriter) Write(b []byte) (int, error) { return u.Writer.Write([]byte(strings.ToUpper(string(b)))) }
+func (u *upperWriter) Close() error                { return nil }
+
+func TestCompressGzip(t *testing.T) {
+	h := New(Compress{}, writeString("<html>hello</html>"))
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+	h.ServeHTTP(rec, req)
+
+	if ce := rec.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", ce)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("content type should be detected from the unencoded body, got %q", ct)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("expected Vary: Accept-Encoding, got %q", vary)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != "<html>hello</html>" {
+		t.Errorf("unexpected decoded body %q", body)
+	}
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	c := Compress{Encoders: []ContentEncoder{upperEncoder, GzipEncoder}}
+	tests := []struct {
+		accept, encoding string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"gzip, upper", "upper"},
+		{"gzip, upper;q=0.5", "gzip"},
+		{"*", "upper"},
+		{"*, upper;q=0", "gzip"},
+		{"br", ""},
+	}
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		req.Header.Set("Accept-Encoding", test.accept)
+		New(c, writeString("text")).ServeHTTP(rec, req)
+		if ce := rec.Header().Get("Content-Encoding"); ce != test.encoding {
+			t.Errorf("%q: expected encoding %q, got %q", test.accept, test.encoding, ce)
+		}
+		if test.encoding == "" && rec.Body.String() != "text" {
+			t.Errorf("%q: expected unencoded body, got %q", test.accept, rec.Body.String())
+		}
+		expected := test.encoding
+		if expected == "" {
+			expected = "identity"
+		}
+		if got := c.NormalizeAcceptEncoding(test.accept); got != expected {
+			t.Errorf("%q: expected normalized %q, got %q", test.accept, expected, got)
+		}
+	}
+}
+
+func TestCompressSkipped(t *testing.T) {
+	c := Compress{Encoders: []ContentEncoder{upperEncoder}}
+	encoded := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Encoding", "custom")
+		rw.Write([]byte("body"))
+	})
+	noContent := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(204)
+	})
+
+	for _, test := range []struct {
+		method   string
+		h        Wrapper
+		encoding string
+	}{
+		{"HEAD", writeString("body"), ""},
+		{"GET", encoded, "custom"},
+		{"GET", noContent, ""},
+	} {
+		rec, req := newTestRequest(test.method, "/")
+		req.Header.Set("Accept-Encoding", "upper")
+		New(c, test.h).ServeHTTP(rec, req)
+		if ce := rec.Header().Get("Content-Encoding"); ce != test.encoding {
+			t.Errorf("expected encoding %q, got %q", test.encoding, ce)
+		}
+	}
+}
+
+func TestCompressRanges(t *testing.T) {
+	h := New(Compress{Encoders: []ContentEncoder{upperEncoder}}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.ServeContent(rw, req, "a.txt", time.Time{}, strings.NewReader("body"))
+	}))
+
+	for _, test := range []struct {
+		rangeHeader  string
+		encoding     string
+		acceptRanges string
+		body         string
+	}{
+		{"", "upper", "", "BODY"},
+		{"bytes=1-2", "", "bytes", "od"},
+	} {
+		rec, req := newTestRequest("GET", "/")
+		req.Header.Set("Accept-Encoding", "upper")
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+		h.ServeHTTP(rec, req)
+		if ce := rec.Header().Get("Content-Encoding"); ce != test.encoding {
+			t.Errorf("%q: expected encoding %q, got %q", test.rangeHeader, test.encoding, ce)
+		}
+		if ar := rec.Header().Get("Accept-Ranges"); ar != test.acceptRanges {
+			t.Errorf("%q: expected Accept-Ranges %q, got %q", test.rangeHeader, test.acceptRanges, ar)
+		}
+		if body := rec.Body.String(); body != test.body {
+			t.Errorf("%q: expected body %q, got %q", test.rangeHeader, test.body, body)
+		}
+	}
+}
+
+func TestCompressVaryOnce(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(Compress{}, Compress{}, writeString("a")).ServeHTTP(rec, req)
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 {
+		t.Errorf("Vary should be added once, got %v", vary)
+	}
+}
+
+func TestCompressCache(t *testing.T) {
+	c := Compress{Encoders: []ContentEncoder{upperEncoder}}
+	cache := &Cache{Normalizers: map[string]VaryNormalizer{"Accept-Encoding": c.NormalizeAcceptEncoding}}
+	h := New(cache, c, Handler(&countingHandler{header: http.Header{"Content-Type": {"text/plain"}}}))
+
+	for i, test := range []struct{ accept, body string }{
+		{"upper", "1"}, {"", "2"}, {"gzip, upper", "1"}, {"br", "2"},
+	} {
+		if body, _ := cacheGet(h, "/", "Accept-Encoding", test.accept); body != test.body {
+			t.Errorf("%d: expected %q, got %q", i, test.body, body)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached variants, got %d", cache.Len())
+	}
+}
+
+func TestCompressWriterConformance(t *testing.T) {
+	err := ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter {
+		return &compressWriter{ResponseWriter: rw, encoder: &upperEncoder}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,370 @@
+package wrap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ConformanceError is a problem of a ResponseWriter wrapper found by ValidateResponseWriterWrapper
+type ConformanceError struct {
+	// Interface is the optional interface, e.g. "http.Flusher"
+	Interface string
+
+	// Inner describes the inner ResponseWriter the problem occurred with
+	Inner string
+
+	// Problem describes the problem
+	Problem string
+}
+
+// Error returns the error message
+func (c *ConformanceError) Error() string {
+	return fmt.Sprintf("%s (inner writer %s): %s", c.Interface, c.Inner, c.Problem)
+}
+
+// ConformanceErrors is the error returned by ValidateResponseWriterWrapper
+type ConformanceErrors []*ConformanceError
+
+// Error returns the error messages of all problems, one per line
+func (c ConformanceErrors) Error() string {
+	msgs := make([]string, len(c))
+	for i, err := range c {
+		msgs[i] = err.Error()
+	}
+	return "response writer wrapper does not conform:\n" + strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the problems
+func (c ConformanceErrors) Unwrap() []error {
+	errs := make([]error, len(c))
+	for i, err := range c {
+		errs[i] = err
+	}
+	return errs
+}
+
+// conformanceProbe is the context type that is passed to the Contexter of a wrapper
+type conformanceProbe struct{}
+
+// conformanceWriter is the inner ResponseWriter passed to the wrapper factory. It counts the calls
+// of the optional interfaces.
+type conformanceWriter struct {
+	header http.Header
+	calls  map[string]int
+	conns  []net.Conn
+}
+
+func newConformanceWriter() *conformanceWriter {
+	return &conformanceWriter{header: http.Header{}, calls: map[string]int{}}
+}
+
+func (c *conformanceWriter) Header() http.Header { return c.header }
+func (c *conformanceWriter) WriteHeader(int)     {}
+func (c *conformanceWriter) Write(b []byte) (int, error) {
+	c.calls["Write"]++
+	return len(b), nil
+}
+
+// close closes the connections of hijacks
+func (c *conformanceWriter) close() {
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// optionalWriter implements all optional interfaces except Contexter
+type optionalWriter struct {
+	*conformanceWriter
+}
+
+func (o *optionalWriter) Flush() { o.calls["Flush"]++ }
+
+func (o *optionalWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	o.calls["Hijack"]++
+	c1, c2 := net.Pipe()
+	o.conns = append(o.conns, c1, c2)
+	return c1, bufio.NewReadWriter(bufio.NewReader(c1), bufio.NewWriter(c1)), nil
+}
+
+func (o *optionalWriter) Push(target string, opts *http.PushOptions) error {
+	o.calls["Push"]++
+	return nil
+}
+
+func (o *optionalWriter) ReadFrom(r io.Reader) (int64, error) {
+	o.calls["ReadFrom"]++
+	return io.Copy(io.Discard, r)
+}
+
+// contexterWriter implements all optional interfaces including Contexter
+type contexterWriter struct {
+	*optionalWriter
+}
+
+func (c *contexterWriter) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c
+	case *conformanceProbe:
+		c.calls["Context"]++
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *contexterWriter) SetContext(ctxPtr interface{}) {
+	switch ctxPtr.(type) {
+	case *conformanceProbe:
+		c.calls["SetContext"]++
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+// optionalInterface is an optional interface of ResponseWriters that is checked
+type optionalInterface struct {
+	name string
+
+	// call calls the method of the interface, if rw implements it
+	call func(rw http.ResponseWriter) (implemented bool, err error)
+
+	// reached are the calls of the inner writer that show that the call reached it
+	reached []string
+}
+
+var optionalInterfaces = []optionalInterface{
+	{"http.Flusher", func(rw http.ResponseWriter) (bool, error) {
+		f, ok := rw.(http.Flusher)
+		if ok {
+			f.Flush()
+		}
+		return ok, nil
+	}, []string{"Flush"}},
+	{"http.Hijacker", func(rw http.ResponseWriter) (bool, error) {
+		h, ok := rw.(http.Hijacker)
+		if !ok {
+			return false, nil
+		}
+		c, _, err := h.Hijack()
+		if err == nil && c == nil {
+			err = errors.New("Hijack returned neither a connection nor an error")
+		}
+		return true, err
+	}, []string{"Hijack"}},
+	{"http.Pusher", func(rw http.ResponseWriter) (bool, error) {
+		p, ok := rw.(http.Pusher)
+		if !ok {
+			return false, nil
+		}
+		return true, p.Push("/conformance", nil)
+	}, []string{"Push"}},
+	{"io.ReaderFrom", func(rw http.ResponseWriter) (bool, error) {
+		r, ok := rw.(io.ReaderFrom)
+		if !ok {
+			return false, nil
+		}
+		_, err := r.ReadFrom(strings.NewReader("conformance"))
+		return true, err
+	}, []string{"ReadFrom", "Write"}},
+}
+
+// protect runs fn and returns the panic as error
+func protect(fn func()) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panics: %v", p)
+		}
+	}()
+	fn()
+	return
+}
+
+// underlying returns the ResponseWriters that middleware may reach from rw without type asserting
+// rw itself: via Unwrap methods (as http.ResponseController does) and via the *http.ResponseWriter
+// of a Contexter (as ReclaimResponseWriter does)
+func underlying(rw http.ResponseWriter) (ws []http.ResponseWriter) {
+	for w := rw; ; {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		if w = u.Unwrap(); w == nil {
+			break
+		}
+		ws = append(ws, w)
+	}
+	if _, ok := rw.(Contexter); ok {
+		var w http.ResponseWriter
+		if protect(func() { w = ReclaimResponseWriter(rw) }) == nil && w != nil && w != rw {
+			ws = append(ws, w)
+		}
+	}
+	return
+}
+
+// reachedInner returns if one of the given calls of the inner writer has been made since before
+func reachedInner(inner *conformanceWriter, calls []string, before map[string]int) bool {
+	for _, c := range calls {
+		if inner.calls[c] > before[c] {
+			return true
+		}
+	}
+	return false
+}
+
+func copyCalls(calls map[string]int) map[string]int {
+	c := make(map[string]int, len(calls))
+	for k, v := range calls {
+		c[k] = v
+	}
+	return c
+}
+
+// checkForwarding checks that the optional interfaces of the inner writer are reachable through
+// the wrapper and that calls reach the inner writer
+func checkForwarding(wrapper http.ResponseWriter, inner *conformanceWriter, innerName string, add func(iface, inner, problem string)) {
+	for _, oi := range optionalInterfaces {
+		before := copyCalls(inner.calls)
+		var implemented bool
+		var err error
+		if p := protect(func() { implemented, err = oi.call(wrapper) }); p != nil {
+			add(oi.name, innerName, p.Error())
+			continue
+		}
+		if implemented {
+			switch {
+			case err != nil:
+				add(oi.name, innerName, "returns error "+err.Error())
+			case !reachedInner(inner, oi.reached, before):
+				add(oi.name, innerName, "the call does not reach the inner writer")
+			}
+			continue
+		}
+		var reached bool
+		for _, u := range underlying(wrapper) {
+			if protect(func() { implemented, err = oi.call(u) }) == nil && implemented && err == nil && reachedInner(inner, oi.reached, before) {
+				reached = true
+				break
+			}
+		}
+		if !reached {
+			add(oi.name, innerName, "not forwarded: neither implemented, nor reachable via Unwrap or the *http.ResponseWriter of the Contexter")
+		}
+	}
+}
+
+// checkDegrading checks that the optional interfaces the wrapper implements do not panic and
+// report http.ErrNotSupported (or another error), if the inner writer does not implement them
+func checkDegrading(wrapper http.ResponseWriter, add func(iface, inner, problem string)) {
+	for _, oi := range optionalInterfaces {
+		var implemented bool
+		var err error
+		if p := protect(func() { implemented, err = oi.call(wrapper) }); p != nil {
+			add(oi.name, "without optional interfaces", p.Error())
+			continue
+		}
+		if implemented && err == nil && (oi.name == "http.Hijacker" || oi.name == "http.Pusher") {
+			add(oi.name, "without optional interfaces", "must return an error (e.g. http.ErrNotSupported), if the inner writer does not support it")
+		}
+	}
+}
+
+// checkContexter checks that the wrapper is a Contexter passing unknown types to the inner Contexter
+func checkContexter(wrapper http.ResponseWriter, inner *conformanceWriter, add func(iface, inner, problem string)) {
+	const innerName = "with Contexter"
+	ctx, ok := wrapper.(Contexter)
+	if !ok {
+		add("Contexter", innerName, "the wrapper hides the Contexter of the inner writer, it must be a Contexter too")
+		return
+	}
+	var rw http.ResponseWriter
+	var found bool
+	if p := protect(func() { found = ctx.Context(&rw) }); p != nil || !found || rw == nil {
+		add("Contexter", innerName, "Context does not support *http.ResponseWriter")
+	}
+	var probe conformanceProbe
+	if p := protect(func() { ctx.Context(&probe) }); p != nil || inner.calls["Context"] == 0 {
+		add("Contexter", innerName, "Context does not pass unknown types to the inner Contexter")
+	}
+	if p := protect(func() { ctx.SetContext(&probe) }); p != nil || inner.calls["SetContext"] == 0 {
+		add("Contexter", innerName, "SetContext does not pass unknown types to the inner Contexter")
+	}
+}
+
+// checkContexterDegrading checks the Contexter of the wrapper of an inner writer that is no Contexter
+func checkContexterDegrading(wrapper http.ResponseWriter, add func(iface, inner, problem string)) {
+	const innerName = "without Contexter"
+	ctx, ok := wrapper.(Contexter)
+	if !ok {
+		return
+	}
+	var rw http.ResponseWriter
+	var found bool
+	if p := protect(func() { found = ctx.Context(&rw) }); p != nil || !found || rw == nil {
+		add("Contexter", innerName, "Context does not support *http.ResponseWriter")
+	}
+	var probe conformanceProbe
+	if _, err := TryContext(ctx, &probe); !errors.Is(err, ErrUnsupportedContext) {
+		add("Contexter", innerName, "Context must panic with *ErrUnsupportedContextGetter for unsupported types")
+	}
+	if err := TrySetContext(ctx, &probe); !errors.Is(err, ErrUnsupportedContext) {
+		add("Contexter", innerName, "SetContext must panic with *ErrUnsupportedContextSetter for unsupported types")
+	}
+}
+
+// ValidateResponseWriterWrapper checks a ResponseWriter wrapper, i.e. a type wrapping the ResponseWriter
+// passed to a middleware, that is created by the given factory. It returns ConformanceErrors for all
+// problems, nil if there are none. The factory is called with different inner ResponseWriters:
+//
+//   - With an inner writer implementing http.Flusher, http.Hijacker, http.Pusher, io.ReaderFrom and Contexter
+//     the wrapper must be a Contexter, passing unknown context types to the inner writer. The optional
+//     interfaces must be forwarded, either by implementing them and calling the inner writer, or by
+//     making the inner writer reachable via an Unwrap() http.ResponseWriter method (see http.ResponseController)
+//     or via the *http.ResponseWriter of the Contexter (see ReclaimResponseWriter).
+//   - With the same inner writer without Contexter, the optional interfaces must be forwarded as well.
+//   - With an inner writer without optional interfaces, the implemented interfaces must not panic and Hijack
+//     and Push must return an error. If the wrapper is a Contexter, it must support *http.ResponseWriter and
+//     panic with the ErrUnsupportedContext errors for other types.
+//
+// It is the same kind of safety net for ResponseWriter wrappers that ValidateContextInjecter is for Contexters.
+func ValidateResponseWriterWrapper(factory func(http.ResponseWriter) http.ResponseWriter) error {
+	var errs ConformanceErrors
+	add := func(iface, inner, problem string) {
+		errs = append(errs, &ConformanceError{Interface: iface, Inner: inner, Problem: problem})
+	}
+	wrapper := func(inner http.ResponseWriter, name string) (w http.ResponseWriter) {
+		if p := protect(func() { w = factory(inner) }); p != nil {
+			add("http.ResponseWriter", name, "the factory "+p.Error())
+		}
+		return
+	}
+
+	full := newConformanceWriter()
+	defer full.close()
+	if w := wrapper(&contexterWriter{&optionalWriter{full}}, "with Contexter"); w != nil {
+		checkForwarding(w, full, "with Contexter", add)
+		checkContexter(w, full, add)
+	}
+
+	optional := newConformanceWriter()
+	defer optional.close()
+	if w := wrapper(&optionalWriter{optional}, "without Contexter"); w != nil {
+		checkForwarding(w, optional, "without Contexter", add)
+		checkContexterDegrading(w, add)
+	}
+
+	if w := wrapper(newConformanceWriter(), "without optional interfaces"); w != nil {
+		checkDegrading(w, add)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
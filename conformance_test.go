@@ -0,0 +1,80 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// hidingWriter wraps a ResponseWriter, hiding all its optional interfaces
+type hidingWriter struct {
+	http.ResponseWriter
+}
+
+// panickingFlusher panics when flushing an inner writer that is no http.Flusher
+type panickingFlusher struct {
+	http.ResponseWriter
+}
+
+func (p *panickingFlusher) Flush() { p.ResponseWriter.(http.Flusher).Flush() }
+
+func (p *panickingFlusher) Unwrap() http.ResponseWriter { return p.ResponseWriter }
+
+func TestValidateResponseWriterWrapper(t *testing.T) {
+	conforming := map[string]func(http.ResponseWriter) http.ResponseWriter{
+		"statusRecorder": func(rw http.ResponseWriter) http.ResponseWriter { return newStatusRecorder(rw).responseWriter() },
+		"EscapeHTML":     func(rw http.ResponseWriter) http.ResponseWriter { return &EscapeHTML{rw} },
+		"Peek":           func(rw http.ResponseWriter) http.ResponseWriter { return NewPeek(rw, nil) },
+	}
+
+	for name, factory := range conforming {
+		if err := ValidateResponseWriterWrapper(factory); err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateResponseWriterWrapperErrors(t *testing.T) {
+	err := ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter { return &hidingWriter{rw} })
+
+	var cerrs ConformanceErrors
+	if !errors.As(err, &cerrs) {
+		t.Fatalf("expected ConformanceErrors, got %T", err)
+	}
+
+	// 4 interfaces not forwarded with and without Contexter and the hidden Contexter
+	if len(cerrs) != 9 {
+		t.Fatalf("expected 9 problems, got %d: %v", len(cerrs), err)
+	}
+
+	if cerrs[0].Interface != "http.Flusher" || cerrs[0].Inner != "with Contexter" {
+		t.Errorf("expected first problem for http.Flusher with Contexter, got %v", cerrs[0])
+	}
+
+	if cerrs[4].Interface != "Contexter" {
+		t.Errorf("expected hidden Contexter, got %v", cerrs[4])
+	}
+
+	err = ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter { return &panickingFlusher{rw} })
+
+	if !errors.As(err, &cerrs) {
+		t.Fatalf("expected ConformanceErrors, got %T", err)
+	}
+
+	var found bool
+	for _, c := range cerrs {
+		if c.Interface == "http.Flusher" && c.Inner == "without optional interfaces" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected problem for panicking Flush, got %v", err)
+	}
+
+	err = ValidateResponseWriterWrapper(func(rw http.ResponseWriter) http.ResponseWriter { panic("broken") })
+
+	if !errors.As(err, &cerrs) || len(cerrs) != 3 || cerrs[0].Interface != "http.ResponseWriter" {
+		t.Errorf("expected 3 problems of the factory, got %v", err)
+	}
+}
@@ -52,6 +52,26 @@ func Flush(rw http.ResponseWriter) (ok bool) {
 	return false
 }
 
+// innerFlusher returns the http.Flusher for rw, the ResponseWriter wrapped by a ResponseWriter wrapper:
+// rw itself if it is one, otherwise the underlying ResponseWriter (see Flush), if it is one.
+// Unlike Flush, it does not skip the wrappers between rw and the underlying ResponseWriter, like the encoder of Compress.
+func innerFlusher(rw http.ResponseWriter) http.Flusher {
+	if fl, is := rw.(http.Flusher); is {
+		return fl
+	}
+	fl, _ := ReclaimResponseWriter(rw).(http.Flusher)
+	return fl
+}
+
+// flushInner is like Flush for rw, the ResponseWriter wrapped by a ResponseWriter wrapper, see innerFlusher
+func flushInner(rw http.ResponseWriter) (ok bool) {
+	if fl := innerFlusher(rw); fl != nil {
+		fl.Flush()
+		return true
+	}
+	return false
+}
+
 // CloseNotify is the same for http.CloseNotifier as Flush is for http.Flusher
 // ok tells if it was a CloseNotifier
 func CloseNotify(rw http.ResponseWriter) (ch <-chan bool, ok bool) {
@@ -64,14 +84,105 @@ func CloseNotify(rw http.ResponseWriter) (ch <-chan bool, ok bool) {
 	return
 }
 
+// Done returns a channel that is closed when the client has gone away, i.e. the context of the request is done
+// or the underlying ResponseWriter (see ReclaimResponseWriter) is a http.CloseNotifier that notifies the close
+// of the connection. The servers of the standard library cancel the context of the request at the latest when
+// the request has been served, which ends the goroutine that waits for the CloseNotifier.
+func Done(rw http.ResponseWriter, req *http.Request) <-chan struct{} {
+	ctxDone := req.Context().Done()
+	closed, ok := CloseNotify(rw)
+	if !ok {
+		return ctxDone
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctxDone:
+		case <-closed:
+		}
+		close(done)
+	}()
+	return done
+}
+
 // Hijack is the same for http.Hijacker as Flush is for http.Flusher
-// ok tells if it was a Hijacker
+// ok tells if it was a Hijacker. If the connection has been hijacked, an Upgrade in the context
+// of rw (see DetectUpgrade) is marked as Upgraded.
 func Hijack(rw http.ResponseWriter) (c net.Conn, brw *bufio.ReadWriter, err error, ok bool) {
 	w := ReclaimResponseWriter(rw)
 	if hj, is := w.(http.Hijacker); is {
 		c, brw, err = hj.Hijack()
 		ok = true
+		if err == nil {
+			markUpgraded(rw)
+		}
 		return
 	}
 	return
 }
+
+// capabilities caches the optional interfaces of the ResponseWriter underlying a ResponseWriter wrapper
+// (see ReclaimResponseWriter), so that they are resolved once per request and not on every call.
+// The Flusher is the wrapped ResponseWriter, if it is one (see innerFlusher).
+// The zero value is ready to use.
+type capabilities struct {
+	resolved bool
+	flusher  http.Flusher
+	hijacker http.Hijacker
+}
+
+// resolve resolves the capabilities of the ResponseWriter underlying rw, if not done yet
+func (c *capabilities) resolve(rw http.ResponseWriter) {
+	if c.resolved {
+		return
+	}
+	c.flusher = innerFlusher(rw)
+	c.hijacker, _ = ReclaimResponseWriter(rw).(http.Hijacker)
+	c.resolved = true
+}
+
+// flush is like Flush, but with cached capabilities
+func (c *capabilities) flush(rw http.ResponseWriter) bool {
+	c.resolve(rw)
+	if c.flusher == nil {
+		return false
+	}
+	c.flusher.Flush()
+	return true
+}
+
+// hijack is like Hijack, but with cached capabilities
+func (c *capabilities) hijack(rw http.ResponseWriter) (conn net.Conn, brw *bufio.ReadWriter, err error, ok bool) {
+	c.resolve(rw)
+	if c.hijacker == nil {
+		return
+	}
+	conn, brw, err = c.hijacker.Hijack()
+	if err == nil {
+		markUpgraded(rw)
+	}
+	return conn, brw, err, true
+}
+
+// contextOf is a helper for response writer wrappers that gets the context of the wrapped
+// response writer rw. If rw is no Contexter, only *http.ResponseWriter is supported and set to rw.
+func contextOf(rw http.ResponseWriter, ctxPtr interface{}) bool {
+	if ctx, ok := rw.(Contexter); ok {
+		return ctx.Context(ctxPtr)
+	}
+	if w, ok := ctxPtr.(*http.ResponseWriter); ok {
+		*w = rw
+		return true
+	}
+	panic(&ErrUnsupportedContextGetter{ctxPtr})
+}
+
+// setContextOf is a helper for response writer wrappers that sets the context of the wrapped
+// response writer rw. It panics if rw is no Contexter.
+func setContextOf(rw http.ResponseWriter, ctxPtr interface{}) {
+	if ctx, ok := rw.(Contexter); ok {
+		ctx.SetContext(ctxPtr)
+		return
+	}
+	panic(&ErrUnsupportedContextSetter{ctxPtr})
+}
@@ -0,0 +1,119 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Coverage is a middleware stack for tests that records which wrappers have been exercised
+// by the requests it served. It is meant to be shared by the tests of a test suite, so that
+// untouched middleware (blind spots of the tests) can be reported at the end, e.g. inside TestMain.
+//
+// For each wrapper Coverage counts how often it has been entered and how often it stopped the chain,
+// i.e. did not call the next handler. So a wrapper like an error handler, which normally calls the
+// next handler, shows up if no test triggered the error.
+type Coverage struct {
+	http.Handler
+	mx       sync.Mutex
+	wrappers []Wrapper
+	entered  []int
+	stopped  []int
+}
+
+// covRun tracks the wrappers that have been entered by a single request
+type covRun struct {
+	cov     *Coverage
+	mx      sync.Mutex
+	entered []bool
+}
+
+// covEntry is an internal type that tracks the entering of a wrapper
+type covEntry struct {
+	cov   *Coverage
+	index int
+	http.Handler
+}
+
+func (c *covEntry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	run, has := requestValue(req, coverageKey).(*covRun)
+	if !has || run.cov != c.cov {
+		c.cov.serveRun(c, rw, req)
+		return
+	}
+	run.mx.Lock()
+	run.entered[c.index] = true
+	run.mx.Unlock()
+	c.Handler.ServeHTTP(rw, req)
+}
+
+// serveRun serves a request that enters the stack of the coverage and records the wrappers it exercised
+func (c *Coverage) serveRun(entry *covEntry, rw http.ResponseWriter, req *http.Request) {
+	run := &covRun{cov: c, entered: make([]bool, len(c.wrappers)+1)}
+	run.entered[entry.index] = true
+	defer func() {
+		c.mx.Lock()
+		run.mx.Lock()
+		for i := range c.wrappers {
+			if !run.entered[i] {
+				continue
+			}
+			c.entered[i]++
+			if !run.entered[i+1] {
+				c.stopped[i]++
+			}
+		}
+		run.mx.Unlock()
+		c.mx.Unlock()
+	}()
+	entry.Handler.ServeHTTP(rw, withRequestValue(req, coverageKey, run))
+}
+
+// NewCoverage creates a Coverage for a stack of the given wrappers, that is build like New does.
+// A ContextInjecter should be passed as first wrapper.
+func NewCoverage(wrapper ...Wrapper) *Coverage {
+	c := &Coverage{
+		wrappers: wrapper,
+		entered:  make([]int, len(wrapper)),
+		stopped:  make([]int, len(wrapper)),
+	}
+	var h http.Handler = &covEntry{c, len(wrapper), NoOp}
+	for i := len(wrapper) - 1; i >= 0; i-- {
+		h = wrapper[i].Wrap(h)
+		if IsDebug() {
+			h = newDebug(wrapper[i], asWrapper, h)
+		}
+		h = &covEntry{c, i, h}
+	}
+	c.Handler = h
+	return c
+}
+
+// Untouched returns the wrappers that have never been entered
+func (c *Coverage) Untouched() (untouched []Wrapper) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for i, w := range c.wrappers {
+		if c.entered[i] == 0 {
+			untouched = append(untouched, w)
+		}
+	}
+	return
+}
+
+// Report returns a report that lists each wrapper with the number of requests that entered it
+// and the number of requests for which it stopped the chain, marking the untouched ones.
+func (c *Coverage) Report() string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	var bf bytes.Buffer
+	for i, w := range c.wrappers {
+		fmt.Fprintf(&bf, "%d  %T entered: %d stopped: %d", i, w, c.entered[i], c.stopped[i])
+		if c.entered[i] == 0 {
+			bf.WriteString(" UNTOUCHED")
+		}
+		bf.WriteString("\n")
+	}
+	return bf.String()
+}
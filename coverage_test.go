@@ -0,0 +1,40 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	cov := NewCoverage(&context{}, setUserIP{}, handleError{}, app{}, write("never"))
+
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "127.0.0.1:45643"
+	cov.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1\nDONE", 200)
+
+	untouched := cov.Untouched()
+
+	if len(untouched) != 1 || untouched[0] != write("never") {
+		t.Errorf("expected write(\"never\") to be untouched, got %v", untouched)
+	}
+
+	expected := `0  *wrap.context entered: 1 stopped: 0
+1  wrap.setUserIP entered: 1 stopped: 0
+2  wrap.handleError entered: 1 stopped: 0
+3  wrap.app entered: 1 stopped: 1
+4  wrap.write entered: 0 stopped: 0 UNTOUCHED
+`
+	if got := cov.Report(); got != expected {
+		t.Errorf("expected report\n%s\ngot\n%s", expected, got)
+	}
+
+	rec, req = newTestRequest("GET", "/")
+	req.RemoteAddr = "garbage"
+	cov.ServeHTTP(rec, req)
+
+	expected = "2  wrap.handleError entered: 2 stopped: 1\n"
+	if got := cov.Report(); !strings.Contains(got, expected) {
+		t.Errorf("expected report to contain %#v, got\n%s", expected, got)
+	}
+}
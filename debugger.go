@@ -1,10 +1,14 @@
 package wrap
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -13,20 +17,68 @@ var (
 	asNextHandler     = "NextHandler"
 	asNextHandlerFunc = "NextHandlerFunc"
 	asWrapper         = "Wrapper"
+	asEvent           = "Event"
 )
 
 type logDebugger struct {
 	*log.Logger
 }
 
+// printf logs the formatted line, prefixed with the request id of the X-Request-ID header, if any
+func (l *logDebugger) printf(req *http.Request, format string, v ...interface{}) {
+	if id := req.Header.Get(RequestIDHeader); id != "" {
+		format = "[%s] " + format
+		v = append([]interface{}{id}, v...)
+	}
+	l.Printf(format, v...)
+}
+
 func (l *logDebugger) Debug(req *http.Request, obj interface{}, role string) {
-	l.Printf("%s %s %T as %s", req.Method, req.URL.Path, obj, role)
+	l.DebugInfo(req, newDebugInfo(obj, role))
+}
+
+// DebugInfo logs the preformatted label of the debugged object
+func (l *logDebugger) DebugInfo(req *http.Request, info *DebugInfo) {
+	l.printf(req, "%s %s %s", req.Method, req.URL.Path, info.Label)
+}
+
+// DebugPanic logs the panic and the stack trace
+func (l *logDebugger) DebugPanic(req *http.Request, obj interface{}, role string, recovered interface{}, stack []byte) {
+	l.printf(req, "%s %s %T as %s panicked: %v\n%s", req.Method, req.URL.Path, obj, role, recovered, stack)
+}
+
+// DebugWriteError logs the write error
+func (l *logDebugger) DebugWriteError(req *http.Request, obj interface{}, role string, err error) {
+	l.printf(req, "%s %s %T as %s write error: %v", req.Method, req.URL.Path, obj, role, err)
+}
+
+// DebugTransport logs the method and URL of an outgoing request
+func (l *logDebugger) DebugTransport(req *http.Request, obj interface{}, role string) {
+	l.printf(req, "client %s %s %T as %s", req.Method, req.URL, obj, role)
+}
+
+// DebugTransportDone logs the status code or error and the latency of an outgoing request
+func (l *logDebugger) DebugTransportDone(req *http.Request, obj interface{}, role string, status int, err error, d time.Duration) {
+	if err != nil {
+		l.printf(req, "client %s %s %T as %s failed after %s: %v", req.Method, req.URL, obj, role, d, err)
+		return
+	}
+	l.printf(req, "client %s %s %T as %s returned %d after %s", req.Method, req.URL, obj, role, status, d)
+}
+
+// DebugEvent logs the event
+func (l *logDebugger) DebugEvent(req *http.Request, e Event) {
+	l.printf(req, "%s %s event %q from %T %v", req.Method, req.URL.Path, e.Name, e.Source, e.Data)
 }
 
-// NewLogDebugger sets the DEBUGGER  to a logger that logs to the given io.Writer.
+// NewLogDebugger sets the Debugger (see SetDebugger) to a logger that logs to the given io.Writer.
 // Flag is a flag from the log standard library that is passed to log.New
+// If the request has a X-Request-ID header (see SetRequestID), the request id
+// is part of every logged line.
+// The logging debugger is a PanicDebugger that logs panics with their stack trace,
+// a WriteErrorDebugger that logs write errors and a TransportDebugger that logs outgoing requests.
 func NewLogDebugger(out io.Writer, flag int) {
-	DEBUGGER = &logDebugger{log.New(out, "[go-on/wrap debugger]", flag)}
+	SetDebugger(&logDebugger{log.New(out, "[go-on/wrap debugger]", flag)})
 }
 
 // Debugger has a Debug method to debug middleware stacks
@@ -37,21 +89,141 @@ type Debugger interface {
 	Debug(req *http.Request, obj interface{}, role string)
 }
 
+// DebugInfo describes a debugged object. It is computed once when the stack is built.
+type DebugInfo struct {
+	// Object is the debugged object
+	Object interface{}
+
+	// Role is the role in which the object acts (see Debugger)
+	Role string
+
+	// Type is the type of the object, formatted with %T
+	Type string
+
+	// Label is the type and role, formatted like "wrap.write as Wrapper"
+	Label string
+}
+
+// newDebugInfo returns the DebugInfo for the given object and role
+func newDebugInfo(obj interface{}, role string) *DebugInfo {
+	ty := fmt.Sprintf("%T", obj)
+	return &DebugInfo{Object: obj, Role: role, Type: ty, Label: ty + " as " + role}
+}
+
+// InfoDebugger is a Debugger that receives the DebugInfo that has been computed when the stack was built.
+// If the DEBUGGER is an InfoDebugger, DebugInfo is called instead of Debug, so that the type and role
+// of the object do not have to be formatted per request.
+type InfoDebugger interface {
+	Debugger
+
+	// DebugInfo receives the current request and the description of the debugged object
+	DebugInfo(req *http.Request, info *DebugInfo)
+}
+
+// PanicDebugger is a Debugger that is also informed about panics.
+// If the DEBUGGER is a PanicDebugger, the debug wrappers recover panics, call DebugPanic
+// and panic again with the recovered value. DebugPanic is only called by the innermost
+// debugged object, i.e. the one that panicked (or whose non debugged next handler panicked).
+// Panics with http.ErrAbortHandler or *ErrClientGone (see Disconnect) are not reported.
+type PanicDebugger interface {
+	Debugger
+
+	// DebugPanic receives the current request, the object and role (see Debugger),
+	// the recovered value and the stack trace of the panicking goroutine.
+	DebugPanic(req *http.Request, obj interface{}, role string, recovered interface{}, stack []byte)
+}
+
+// ExitDebugger is a Debugger that is also informed when a debugged object has finished
+// serving the request (also if it panicked).
+type ExitDebugger interface {
+	Debugger
+
+	// DebugExit receives the current request, the object and role (see Debugger),
+	// the status code that has been written through the object (0 if nothing has been written)
+	// and the duration of serving the request, including the next handlers.
+	DebugExit(req *http.Request, obj interface{}, role string, status int, d time.Duration)
+}
+
+// WriteErrorDebugger is a Debugger that is also informed about failing writes, like writes to
+// a Peek that does not proceed (io.EOF) or writes to a client that has gone away.
+// If the DEBUGGER is a WriteErrorDebugger, the debug wrappers pass a ResponseWriter to the debugged
+// object that reports every error returned by Write. DebugWriteError is only called by the innermost
+// debugged object, i.e. the one that wrote.
+type WriteErrorDebugger interface {
+	Debugger
+
+	// DebugWriteError receives the current request, the object and role (see Debugger)
+	// and the error returned by Write.
+	DebugWriteError(req *http.Request, obj interface{}, role string, err error)
+}
+
 // DEBUGGER is the Debugger used for debugging middleware stacks.
-// It defaults to a logging debugger that logs to os.Stdout
+// It defaults to a logging debugger that logs to os.Stdout.
+//
+// Deprecated: assigning DEBUGGER while requests are served is a data race. Use SetDebugger instead,
+// which takes precedence over DEBUGGER.
 var DEBUGGER = Debugger(&logDebugger{log.New(os.Stdout, "[go-on/wrap debugger]", log.LstdFlags)})
 
 // DEBUG indicates if any stack should be debugged. Set it before any call to New.
+// To debug only some of the requests, see SetDebugSampler.
+//
+// Deprecated: assigning DEBUG while requests are served is a data race. Use SetDebug and DisableDebug
+// instead. DEBUG is still honored, i.e. debugging is enabled if DEBUG is set or SetDebug has been called,
+// but it is never changed by this package.
 var DEBUG = false
 
-// SetDebug provides a way to set DEBUG=true in a var declaration, like
+var (
+	// debugOn is 1 if debugging has been enabled via SetDebug
+	debugOn int32
+
+	// debuggerValue holds the debuggerBox set by SetDebugger
+	debuggerValue atomic.Value
+)
+
+// debuggerBox lets an atomic.Value hold Debuggers of different types
+type debuggerBox struct {
+	Debugger
+}
+
+// SetDebug enables debugging. It is safe to call while requests are served. It provides a way to
+// enable debugging in a var declaration, like
 //
 //   var _ = wrap.SetDebug()
 //
-// This is an easy way to ensure DEBUG is set to true before the init functions run
+// This is an easy way to ensure debugging is enabled before the init functions run.
+//
+// Stacks are only debugged if debugging is enabled when they are built (see New). The debug wrappers of
+// such stacks check per request if debugging is still enabled, so debugging may be toggled at runtime
+// via SetDebug and DisableDebug without rebuilding the stacks.
 func SetDebug() bool {
-	DEBUG = true
-	return DEBUG
+	atomic.StoreInt32(&debugOn, 1)
+	return true
+}
+
+// DisableDebug disables debugging that has been enabled via SetDebug. It is safe to call while requests
+// are served. It does not change the deprecated DEBUG variable.
+func DisableDebug() {
+	atomic.StoreInt32(&debugOn, 0)
+}
+
+// IsDebug returns if debugging is enabled via SetDebug or DEBUG
+func IsDebug() bool {
+	return atomic.LoadInt32(&debugOn) == 1 || DEBUG
+}
+
+// SetDebugger sets the Debugger that is used for debugging middleware stacks, taking precedence
+// over DEBUGGER. It is safe to call while requests are served. A nil Debugger lets DEBUGGER be used again.
+func SetDebugger(d Debugger) {
+	debuggerValue.Store(debuggerBox{d})
+}
+
+// CurrentDebugger returns the Debugger set by SetDebugger or DEBUGGER. It is safe to call while requests
+// are served.
+func CurrentDebugger() Debugger {
+	if b, ok := debuggerValue.Load().(debuggerBox); ok && b.Debugger != nil {
+		return b.Debugger
+	}
+	return DEBUGGER
 }
 
 // debug is an internal type
@@ -59,11 +231,184 @@ type debug struct {
 	Object interface{}
 	Role   string
 	http.Handler
+	info *DebugInfo
+}
+
+// newDebug returns a debug for the given object, role and handler. The handler may be nil,
+// if the debug is only used via serve.
+func newDebug(obj interface{}, role string, h http.Handler) *debug {
+	return &debug{Object: obj, Role: role, Handler: h, info: newDebugInfo(obj, role)}
+}
+
+// call passes the debugged object to the Debugger
+func (d *debug) call(dbg Debugger, req *http.Request) {
+	if id, ok := dbg.(InfoDebugger); ok {
+		id.DebugInfo(req, d.info)
+		return
+	}
+	dbg.Debug(req, d.Object, d.Role)
 }
 
 func (d *debug) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	DEBUGGER.Debug(req, d.Object, d.Role)
-	d.Handler.ServeHTTP(rw, req)
+	d.serve(d.Handler, rw, req)
+}
+
+// serve serves the request with h, debugging the object of d
+func (d *debug) serve(h http.Handler, rw http.ResponseWriter, req *http.Request) {
+	if !IsDebug() {
+		h.ServeHTTP(rw, req)
+		return
+	}
+	req, sampled := sampleRequest(req)
+	if !sampled {
+		h.ServeHTTP(rw, req)
+		return
+	}
+	dbg := CurrentDebugger()
+	if req == nil {
+		d.call(dbg, req)
+		h.ServeHTTP(rw, req)
+		return
+	}
+	req, state := withDebugState(req)
+	state.traversal = append(state.traversal, d)
+	d.call(dbg, req)
+
+	if ed, ok := dbg.(ExitDebugger); ok {
+		rec := newStatusRecorder(rw)
+		rw = rec.responseWriter()
+		start := time.Now()
+		defer func() {
+			ed.DebugExit(req, d.Object, d.Role, rec.Status(), time.Since(start))
+		}()
+	}
+
+	if wd, ok := dbg.(WriteErrorDebugger); ok {
+		rw = newWriteErrorWriter(rw, state, func(err error) {
+			wd.DebugWriteError(req, d.Object, d.Role, err)
+		})
+	}
+
+	if pd, ok := dbg.(PanicDebugger); ok {
+		d.serveRecovering(pd, state, h, rw, req)
+		return
+	}
+	h.ServeHTTP(rw, req)
+}
+
+// debugState is shared by the debug wrappers of a request
+type debugState struct {
+	// reported tracks if a panic has already been reported
+	reported bool
+
+	// trace is per request data of the DEBUGGER
+	trace interface{}
+
+	// traversal are the debug wrappers the request passed so far
+	traversal []*debug
+
+	// writes is the number of nested writeErrorWriter.Write calls
+	writes int
+
+	// writeReported tracks if the error of the current write has already been reported
+	writeReported bool
+}
+
+// writeErrorWriter reports the errors returned by Write
+type writeErrorWriter struct {
+	*statusRecorder
+	state  *debugState
+	report func(error)
+}
+
+// writeErrorContexter is a writeErrorWriter for an underlying Contexter
+type writeErrorContexter struct {
+	*writeErrorWriter
+}
+
+// Context gets the Context of the underlying response writer.
+func (w *writeErrorContexter) Context(ctxPtr interface{}) bool {
+	return contextOf(w.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer.
+func (w *writeErrorContexter) SetContext(ctxPtr interface{}) {
+	setContextOf(w.ResponseWriter, ctxPtr)
+}
+
+// newWriteErrorWriter returns a ResponseWriter that passes the errors returned by Write to report.
+// It is a Contexter if (and only if) rw is one.
+func newWriteErrorWriter(rw http.ResponseWriter, state *debugState, report func(error)) http.ResponseWriter {
+	w := &writeErrorWriter{statusRecorder: newStatusRecorder(rw), state: state, report: report}
+	if _, ok := rw.(Contexter); ok {
+		return &writeErrorContexter{w}
+	}
+	return w
+}
+
+// Write reports the error of the underlying Write, unless it has already been
+// reported by a nested writeErrorWriter
+func (w *writeErrorWriter) Write(b []byte) (int, error) {
+	w.state.writes++
+	n, err := w.statusRecorder.Write(b)
+	w.done(err)
+	return n, err
+}
+
+// ReadFrom is like Write for the content of r, see statusRecorder.ReadFrom
+func (w *writeErrorWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.state.writes++
+	n, err := w.statusRecorder.ReadFrom(r)
+	w.done(err)
+	return n, err
+}
+
+// done reports the error of a finished write, see Write
+func (w *writeErrorWriter) done(err error) {
+	w.state.writes--
+	if err != nil && !w.state.writeReported {
+		w.state.writeReported = true
+		w.report(err)
+	}
+	if w.state.writes == 0 {
+		w.state.writeReported = false
+	}
+}
+
+// withDebugState returns the debugState of the request, creating it if there is none.
+func withDebugState(req *http.Request) (*http.Request, *debugState) {
+	if state, has := requestValue(req, debugKey).(*debugState); has {
+		return req, state
+	}
+	state := &debugState{}
+	return withRequestValue(req, debugKey, state), state
+}
+
+// serveRecovering serves the request and reports a panic to the given PanicDebugger before
+// panicking again.
+func (d *debug) serveRecovering(pd PanicDebugger, state *debugState, h http.Handler, rw http.ResponseWriter, req *http.Request) {
+	defer func() {
+		if p := recover(); p != nil {
+			if !state.reported && !isAbort(p) {
+				state.reported = true
+				pd.DebugPanic(req, d.Object, d.Role, p, stackTrace())
+			}
+			panic(p)
+		}
+	}()
+	h.ServeHTTP(rw, req)
+}
+
+// stackTrace returns the stack trace of the current goroutine
+func stackTrace() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
 }
 
 // _debug is like New() but wraps each http.Handler with a debug struct that calls DEBUGGER.Debug before
@@ -71,7 +416,7 @@ func (d *debug) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 func _debug(wrapper ...Wrapper) (h http.Handler) {
 	h = NoOp
 	for i := len(wrapper) - 1; i >= 0; i-- {
-		h = &debug{wrapper[i], asWrapper, wrapper[i].Wrap(h)}
+		h = newDebug(wrapper[i], asWrapper, wrapper[i].Wrap(h))
 	}
 	return
 }
@@ -2,6 +2,7 @@ package wrap
 
 import (
 	"bytes"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -23,7 +24,7 @@ func TestDebug(t *testing.T) {
 		writeStop("two"),
 	).ServeHTTP(rec, req)
 
-	DEBUG = false
+	DisableDebug()
 
 	splitted := strings.Split(strings.TrimSpace(buf.String()), "\n")
 
@@ -215,3 +216,245 @@ func TestDebug2(t *testing.T) {
 		t.Errorf("%#v should end with %#v but does not", splitted[3], suffix)
 	}
 }
+
+type panicDebugger struct {
+	obj       interface{}
+	role      string
+	recovered interface{}
+	stack     []byte
+	calls     int
+}
+
+func (p *panicDebugger) Debug(req *http.Request, obj interface{}, role string) {}
+
+func (p *panicDebugger) DebugPanic(req *http.Request, obj interface{}, role string, recovered interface{}, stack []byte) {
+	p.obj, p.role, p.recovered, p.stack = obj, role, recovered, stack
+	p.calls++
+}
+
+type panicker string
+
+func (p panicker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	panic(string(p))
+}
+
+func TestDebugPanic(t *testing.T) {
+	old := CurrentDebugger()
+	pd := &panicDebugger{}
+	SetDebugger(pd)
+	SetDebug()
+	defer DisableDebug()
+
+	h := New(
+		write("one"),
+		Handler(panicker("boom")),
+	)
+
+	rec, req := newTestRequest("GET", "/")
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("panic should be re-raised with %#v, but is %#v", "boom", p)
+			}
+		}()
+		h.ServeHTTP(rec, req)
+	}()
+
+	SetDebugger(old)
+
+	if pd.calls != 1 {
+		t.Errorf("DebugPanic should be called once, but was called %d times", pd.calls)
+	}
+
+	if _, ok := pd.obj.(panicker); !ok || pd.role != asHandler {
+		t.Errorf("panicking object should be wrap.panicker as http.Handler, but is %T as %s", pd.obj, pd.role)
+	}
+
+	if pd.recovered != "boom" {
+		t.Errorf("recovered should be %#v, but is %#v", "boom", pd.recovered)
+	}
+
+	if !strings.Contains(string(pd.stack), "panicker.ServeHTTP") {
+		t.Errorf("stack should contain the panicking method, but is:\n%s", pd.stack)
+	}
+}
+
+func TestLogDebugPanic(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	_, req := newTestRequest("GET", "/")
+	CurrentDebugger().(PanicDebugger).DebugPanic(req, panicker("x"), asHandler, "x", []byte("the stack"))
+
+	if out := buf.String(); !strings.Contains(out, "GET / wrap.panicker as http.Handler panicked: x\nthe stack") {
+		t.Errorf("unexpected output: %#v", out)
+	}
+}
+
+type writeErrorDebugger struct {
+	objs []interface{}
+	errs []error
+}
+
+func (w *writeErrorDebugger) Debug(req *http.Request, obj interface{}, role string) {}
+
+func (w *writeErrorDebugger) DebugWriteError(req *http.Request, obj interface{}, role string, err error) {
+	w.objs = append(w.objs, obj)
+	w.errs = append(w.errs, err)
+}
+
+// peekStop serves the next handler with a Peek that never proceeds
+type peekStop struct{}
+
+func (peekStop) ServeHTTPNext(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	next.ServeHTTP(NewPeek(rw, func(*Peek) bool { return false }), req)
+}
+
+func TestDebugWriteError(t *testing.T) {
+	old := CurrentDebugger()
+	wd := &writeErrorDebugger{}
+	SetDebugger(wd)
+	SetDebug()
+	defer DisableDebug()
+
+	h := New(
+		NextHandler(peekStop{}),
+		write("one"),
+		write("two"),
+	)
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	SetDebugger(old)
+
+	assertResponse(t, rec, "", 200)
+
+	if len(wd.errs) != 2 {
+		t.Fatalf("DebugWriteError should be called twice, but was called %d times", len(wd.errs))
+	}
+
+	for i, err := range wd.errs {
+		if err != io.EOF {
+			t.Errorf("error should be io.EOF, but is %v", err)
+		}
+		if _, ok := wd.objs[i].(write); !ok {
+			t.Errorf("object should be wrap.write, but is %T", wd.objs[i])
+		}
+	}
+}
+
+func TestLogDebugWriteError(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	_, req := newTestRequest("GET", "/")
+	CurrentDebugger().(WriteErrorDebugger).DebugWriteError(req, write("x"), asWrapper, io.EOF)
+
+	if out := buf.String(); !strings.Contains(out, "GET / wrap.write as Wrapper write error: EOF") {
+		t.Errorf("unexpected output: %#v", out)
+	}
+}
+
+type infoDebugger struct {
+	infos []*DebugInfo
+}
+
+func (i *infoDebugger) Debug(req *http.Request, obj interface{}, role string) {}
+
+func (i *infoDebugger) DebugInfo(req *http.Request, info *DebugInfo) {
+	i.infos = append(i.infos, info)
+}
+
+func TestInfoDebugger(t *testing.T) {
+	old := CurrentDebugger()
+	id := &infoDebugger{}
+	SetDebugger(id)
+	SetDebug()
+	defer DisableDebug()
+
+	h := New(
+		write("one"),
+		HandlerFunc(x),
+	)
+
+	for i := 0; i < 2; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+	}
+	SetDebugger(old)
+
+	if len(id.infos) != 8 {
+		t.Fatalf("DebugInfo should be called 8 times, but was called %d times", len(id.infos))
+	}
+
+	if id.infos[0].Label != "wrap.write as Wrapper" || id.infos[0].Type != "wrap.write" || id.infos[0].Role != asWrapper {
+		t.Errorf("unexpected info %#v", id.infos[0])
+	}
+
+	for i := 0; i < 4; i++ {
+		if id.infos[i] != id.infos[i+4] {
+			t.Errorf("info %d should be computed once, not per request", i)
+		}
+	}
+}
+
+func TestDebugToggle(t *testing.T) {
+	id := &infoDebugger{}
+	SetDebugger(id)
+	defer SetDebugger(nil)
+	SetDebug()
+
+	h := New(write("one"), HandlerFunc(x))
+
+	serve := func() {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "onex", 200)
+	}
+
+	serve()
+	DisableDebug()
+	serve()
+	SetDebug()
+	serve()
+	DisableDebug()
+
+	if len(id.infos) != 8 {
+		t.Errorf("only the requests served while debugging should be debugged, got %d calls", len(id.infos))
+	}
+}
+
+func TestSetDebugger(t *testing.T) {
+	id := &infoDebugger{}
+	SetDebugger(id)
+
+	if CurrentDebugger() != Debugger(id) {
+		t.Errorf("debugger set by SetDebugger should take precedence over DEBUGGER")
+	}
+
+	SetDebugger(nil)
+
+	if CurrentDebugger() != DEBUGGER {
+		t.Errorf("SetDebugger(nil) should let DEBUGGER be used again")
+	}
+}
+
+func TestDebugRace(t *testing.T) {
+	SetDebugger(discardDebugger{})
+	defer SetDebugger(nil)
+	SetDebug()
+	h := New(write("one"), HandlerFunc(x))
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			DisableDebug()
+			SetDebug()
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+	}
+	<-done
+	DisableDebug()
+}
@@ -0,0 +1,156 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ErrClientGone is the error returned by the writers of a Disconnect wrapper after the client
+// has gone away. It is also the value of the panic that aborts the chain if Disconnect.Abort is set.
+type ErrClientGone struct {
+	// Err is the error of the request context (e.g. context.Canceled) or the error
+	// returned by the underlying Write
+	Err error
+}
+
+// Error returns the error message
+func (e *ErrClientGone) Error() string {
+	return fmt.Sprintf("client gone: %v", e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ErrClientGone) Unwrap() error {
+	return e.Err
+}
+
+// isAbort returns if the recovered value aborts the handling of a request
+// and therefor should be neither recovered nor reported
+func isAbort(recovered interface{}) bool {
+	if recovered == http.ErrAbortHandler {
+		return true
+	}
+	_, ok := recovered.(*ErrClientGone)
+	return ok
+}
+
+// Disconnect is a Wrapper that stops writing the response when the client has gone away,
+// i.e. when the context of the request is done or a Write to the underlying ResponseWriter fails.
+// Afterwards every Write of the next handlers returns an *ErrClientGone without writing and
+// WriteHeader and Flush do nothing, so that no CPU is burned for a response nobody will receive.
+//
+// If Abort is set, the remaining chain is aborted by panicking with the *ErrClientGone, which is
+// recovered by Disconnect. Recover and the debug wrappers pass this panic through.
+type Disconnect struct {
+	// Abort aborts the next handlers as soon as the client is detected to be gone
+	Abort bool
+
+	// OnDisconnect is called once, when the client is detected to be gone (optional)
+	OnDisconnect func(req *http.Request, err *ErrClientGone)
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Disconnect{}
+
+// Wrap implements the Wrapper interface
+func (d Disconnect) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		w := &disconnectWriter{ResponseWriter: rw, req: req, d: d}
+		if d.Abort {
+			defer func() {
+				if p := recover(); p != nil && p != w.err {
+					panic(p)
+				}
+			}()
+		}
+		next.ServeHTTP(w.responseWriter(), req)
+	}
+	return f
+}
+
+// disconnectWriter is the ResponseWriter of Disconnect
+type disconnectWriter struct {
+	http.ResponseWriter
+	req  *http.Request
+	d    Disconnect
+	err  *ErrClientGone
+	caps capabilities
+}
+
+// responseWriter returns a Contexter if (and only if) the underlying ResponseWriter is one
+func (w *disconnectWriter) responseWriter() http.ResponseWriter {
+	if _, ok := w.ResponseWriter.(Contexter); ok {
+		return &disconnectContexter{w}
+	}
+	return w
+}
+
+// gone marks the client as gone and aborts if needed
+func (w *disconnectWriter) gone(err error) {
+	w.err = &ErrClientGone{err}
+	if w.d.OnDisconnect != nil {
+		w.d.OnDisconnect(w.req, w.err)
+	}
+	if w.d.Abort {
+		panic(w.err)
+	}
+}
+
+// check returns if the client is gone
+func (w *disconnectWriter) check() bool {
+	if w.err != nil {
+		return true
+	}
+	if w.req == nil {
+		return false
+	}
+	if err := w.req.Context().Err(); err != nil {
+		w.gone(err)
+		return true
+	}
+	return false
+}
+
+// WriteHeader passes the code to the underlying ResponseWriter unless the client is gone
+func (w *disconnectWriter) WriteHeader(code int) {
+	if w.check() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write passes b to the underlying ResponseWriter unless the client is gone
+func (w *disconnectWriter) Write(b []byte) (int, error) {
+	if w.check() {
+		return 0, w.err
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		w.gone(err)
+		return n, w.err
+	}
+	return n, nil
+}
+
+// Flush flushes the underlying ResponseWriter unless the client is gone
+func (w *disconnectWriter) Flush() {
+	if w.check() {
+		return
+	}
+	w.caps.flush(w.ResponseWriter)
+}
+
+// disconnectContexter is a disconnectWriter for an underlying Contexter
+type disconnectContexter struct {
+	*disconnectWriter
+}
+
+// Context gets the Context of the underlying response writer.
+func (w *disconnectContexter) Context(ctxPtr interface{}) bool {
+	return contextOf(w.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer.
+func (w *disconnectContexter) SetContext(ctxPtr interface{}) {
+	setContextOf(w.ResponseWriter, ctxPtr)
+}
@@ -0,0 +1,98 @@
+package wrap
+
+import (
+	"bytes"
+	"compress/gzip"
+	stdcontext "context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// failWriter is a ResponseWriter whose Write always fails
+type failWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (f *failWriter) Write(b []byte) (int, error) {
+	f.writes++
+	return 0, io.ErrClosedPipe
+}
+
+func TestDisconnectWriteError(t *testing.T) {
+	var gone *ErrClientGone
+	d := Disconnect{OnDisconnect: func(req *http.Request, err *ErrClientGone) { gone = err }}
+	fw := &failWriter{ResponseRecorder: httptest.NewRecorder()}
+	_, req := newTestRequest("GET", "/")
+
+	var errs []error
+	New(d, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for i := 0; i < 3; i++ {
+			_, err := rw.Write([]byte("a"))
+			errs = append(errs, err)
+		}
+	})).ServeHTTP(fw, req)
+
+	if fw.writes != 1 {
+		t.Errorf("underlying Write should be called once, but was called %d times", fw.writes)
+	}
+
+	if gone == nil || !errors.Is(gone, io.ErrClosedPipe) {
+		t.Errorf("OnDisconnect should receive the write error, got %v", gone)
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("Write should return the write error, got %v", err)
+		}
+	}
+}
+
+func TestDisconnectAbort(t *testing.T) {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	rec, req := newTestRequest("GET", "/")
+	req = req.WithContext(ctx)
+
+	var reached bool
+	h := Stack(&context{},
+		Disconnect{Abort: true},
+		Recover(nil),
+		write("a"),
+		HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { reached = true }),
+	)
+	// Stack has probed the wrappers
+	reached = false
+	h.ServeHTTP(rec, req)
+
+	assertResponse(t, rec, "", 200)
+
+	if reached {
+		t.Errorf("the chain should have been aborted")
+	}
+}
+
+func TestDisconnectFlushCompress(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	var flushed string
+	New(Compress{}, Disconnect{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("hello"))
+		rw.(http.Flusher).Flush()
+		gz, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 5)
+		io.ReadFull(gz, b)
+		flushed = string(b)
+	})).ServeHTTP(rec, req)
+
+	if flushed != "hello" {
+		t.Errorf("Flush should flush the encoder of Compress, got %#v", flushed)
+	}
+}
@@ -40,6 +40,15 @@ Benchmarks (Go 1.3)
   BenchmarkServing100Simple   50000   52074   ns/op   1,00x
   BenchmarkServing100Wrappers 50000   53450   ns/op   1,03x
 
+Allocations
+
+The allocations per request of the core paths are guarded by tests (see alloc_test.go):
+
+  - stacks created by New or Compile: 0 allocs/request (apart from the wrappers themselves)
+  - stacks created by Stack: 1 alloc/request for the Contexter of the ContextInjecter
+  - a Peek taken from AcquirePeek: 0 allocs/request (NewPeek: 2 allocs/request)
+  - HandleError: 1 alloc/request for the error lookup
+
 Credits
 
 Initial inspiration came from Christian Neukirchen's rack for ruby some years ago.
@@ -50,8 +59,9 @@ Content of the package
 The core of this package is the New function that constructs a stack of middlewares that implement
 the Wrapper interface.
 
-If the global DEBUG flag is set before calling New then each middleware call will result in
-calling the Debug method of the global DEBUGGER (defaults to a logger).
+If debugging is enabled via SetDebug before calling New then each middleware call will result in
+calling the Debug method of the Debugger set via SetDebugger (defaults to a logger). Debugging
+may be disabled and enabled again via DisableDebug and SetDebug while the stack is serving.
 
 To help constructing middleware there are some adapters like WrapperFunc, Handler, HandlerFunc,
 NextHandler and NextHandlerFunc each of them adapting to the Wrapper interface.
@@ -87,6 +97,10 @@ forget to implement the Contexter interface).
 Finally EscapeHTML provides a response writer wrapper that allows on the fly
 html escaping of the bytes written to the wrapped response writer.
 
+For error handling middleware should agree on storing errors as context type error
+(see SetError and GetError), preferably as HTTPError that carries the status code and the
+message for the client. The HandleError wrapper renders them. Recover renders panics.
+
 
 How to write a middleware
 
@@ -306,7 +320,7 @@ anymore. And you should run tests. Then the assertion will blow up and that is c
 there is no reasonable way to handle such an error. It means that either you have no context in your
 stack or you inject the context to late or the context does not handle the kind of type the middleware
 expects. In each case you should fix it early and the panic forces you to do.
-Use the DEBUG flag to see what's going on.
+Use SetDebug to see what's going on.
 
 4. What happens if my context is wrapped inside another context or response writer?
 
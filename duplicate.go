@@ -0,0 +1,125 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+)
+
+// STRICT indicates if New and Stack should probe the wrappers for duplicate Contexters and check for
+// unreachable wrappers (see Terminator). Set it before any call to New.
+//
+// Deprecated: assigning STRICT while stacks are built is a data race. Use SetStrict and DisableStrict
+// instead. STRICT is still honored, i.e. the checks run if STRICT is set or SetStrict has been called,
+// but it is never changed by this package.
+var STRICT = false
+
+// strictOn is 1 if the checks have been enabled via SetStrict
+var strictOn int32
+
+// SetStrict enables the checks of STRICT. It is safe to call while stacks are built and provides a way
+// to enable them in a var declaration, like
+//
+//	var _ = wrap.SetStrict()
+func SetStrict() bool {
+	atomic.StoreInt32(&strictOn, 1)
+	return true
+}
+
+// DisableStrict disables the checks that have been enabled via SetStrict. It does not change the
+// deprecated STRICT variable.
+func DisableStrict() {
+	atomic.StoreInt32(&strictOn, 0)
+}
+
+// IsStrict returns if the checks are enabled via SetStrict or STRICT
+func IsStrict() bool {
+	return atomic.LoadInt32(&strictOn) == 1 || STRICT
+}
+
+// ErrDuplicateContexter is the error returned if more than one wrapper of a stack injects a Contexter.
+// This is considered a bug, since the context set before the second Contexter is not visible after it.
+type ErrDuplicateContexter struct {
+	// First is the first wrapper that injects a Contexter and FirstIndex its position
+	First      Wrapper
+	FirstIndex int
+
+	// Second is the second wrapper that injects a Contexter and SecondIndex its position
+	Second      Wrapper
+	SecondIndex int
+}
+
+// Error returns the error message
+func (e *ErrDuplicateContexter) Error() string {
+	return fmt.Sprintf("duplicate Contexter in stack: %T at %d and %T at %d", e.First, e.FirstIndex, e.Second, e.SecondIndex)
+}
+
+// probeMarker is the context type that is only supported by the markerContexter
+type probeMarker struct{}
+
+// markerContexter is the Contexter that is passed to a wrapper to find out if it injects a Contexter.
+// Contexters that pass unknown types to the wrapped ResponseWriter find the probeMarker,
+// injected Contexters do not.
+type markerContexter struct {
+	http.ResponseWriter
+}
+
+func (m *markerContexter) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = m.ResponseWriter
+	case *probeMarker:
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (m *markerContexter) SetContext(ctxPtr interface{}) {
+	panic(&ErrUnsupportedContextSetter{ctxPtr})
+}
+
+// injectsContexter returns if w passes a new Contexter to the next handler.
+// It is probed by serving a synthetic request (see IsProbe). Terminators that terminate are not
+// served, since they never call the next handler.
+func injectsContexter(w Wrapper) (injects bool) {
+	if terminates(w) {
+		return false
+	}
+	var probe http.HandlerFunc
+	probe = func(rw http.ResponseWriter, req *http.Request) {
+		ctx, ok := rw.(Contexter)
+		if !ok {
+			return
+		}
+		defer func() {
+			if recover() != nil {
+				injects = true
+			}
+		}()
+		var m probeMarker
+		injects = !ctx.Context(&m)
+	}
+	// wrappers that need context types not supported by the markerContexter panic,
+	// but they don't inject a Contexter either
+	defer func() { recover() }()
+	w.Wrap(probe).ServeHTTP(&markerContexter{httptest.NewRecorder()}, newProbeRequest())
+	return
+}
+
+// findDuplicateContexter returns an *ErrDuplicateContexter if more than one of the
+// given wrappers injects a Contexter, nil otherwise
+func findDuplicateContexter(wrapper []Wrapper) error {
+	first := -1
+	for i, w := range wrapper {
+		if w == nil || !injectsContexter(w) {
+			continue
+		}
+		if first >= 0 {
+			return &ErrDuplicateContexter{First: wrapper[first], FirstIndex: first, Second: w, SecondIndex: i}
+		}
+		first = i
+	}
+	return nil
+}
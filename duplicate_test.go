@@ -0,0 +1,73 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestInjectsContexter(t *testing.T) {
+	tests := []struct {
+		w       Wrapper
+		injects bool
+	}{
+		{&context{}, true},
+		{requestIDContext{}, true},
+		{write("a"), false},
+		{setUserIP{}, false},
+		{EventCollector{}, false},
+		{Recover(nil), false},
+		{HandleError{}, false},
+	}
+
+	for _, test := range tests {
+		if got := injectsContexter(test.w); got != test.injects {
+			t.Errorf("injectsContexter(%T) = %v, expected %v", test.w, got, test.injects)
+		}
+	}
+}
+
+func TestStackDuplicateContexter(t *testing.T) {
+	SetStrict()
+	defer func() {
+		DisableStrict()
+		var dup *ErrDuplicateContexter
+		err, _ := recover().(error)
+		if !errors.As(err, &dup) {
+			t.Fatalf("expected *ErrDuplicateContexter, got %v", err)
+		}
+		if dup.FirstIndex != 0 || dup.SecondIndex != 2 {
+			t.Errorf("expected duplicates at 0 and 2, got %d and %d", dup.FirstIndex, dup.SecondIndex)
+		}
+		if got, exp := dup.Error(), "duplicate Contexter in stack: *wrap.context at 0 and wrap.requestIDContext at 2"; got != exp {
+			t.Errorf("expected error message %#v, got %#v", exp, got)
+		}
+	}()
+	Stack(&context{}, EventCollector{}, requestIDContext{}, write("a"))
+}
+
+func TestStackNoProbe(t *testing.T) {
+	var calls int
+	Stack(&context{}, requestIDContext{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { calls++ }))
+
+	SetStrict()
+	Stack(&context{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) { calls++ }))
+	DisableStrict()
+
+	if calls != 0 {
+		t.Errorf("the handler should not be called while building the stack, but was called %d times", calls)
+	}
+}
+
+func TestNewStrict(t *testing.T) {
+	New(&context{}, requestIDContext{})
+
+	SetStrict()
+	defer func() {
+		DisableStrict()
+		if _, ok := recover().(*ErrDuplicateContexter); !ok {
+			t.Errorf("New should panic with *ErrDuplicateContexter in strict mode")
+		}
+	}()
+	New(&context{}, requestIDContext{})
+}
@@ -1,7 +1,23 @@
 package wrap
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrFlushOrder is the sentinel error that is wrapped by the errors reporting that headers, status code
+	// and body have been flushed in the wrong order, i.e. ErrBodyFlushedBeforeCode and ErrCodeFlushedBeforeHeaders.
+	ErrFlushOrder = errors.New("flushed in wrong order")
+
+	// ErrUnsupportedContext is the sentinel error that is wrapped by the errors reporting that a context type
+	// is not supported, i.e. ErrUnsupportedContextGetter and ErrUnsupportedContextSetter.
+	ErrUnsupportedContext = errors.New("context type is not supported")
+
+	// ErrNoContexter is returned by TryContext and TrySetContext if the ResponseWriter is no Contexter.
+	// It wraps ErrUnsupportedContext.
+	ErrNoContexter = fmt.Errorf("ResponseWriter is no Contexter: %w", ErrUnsupportedContext)
 )
 
 // ErrBodyFlushedBeforeCode is the error returned if a body flushed to an underlying response writer
@@ -14,6 +30,11 @@ func (e ErrBodyFlushedBeforeCode) Error() string {
 	return "body flushed before code"
 }
 
+// Unwrap returns ErrFlushOrder
+func (e ErrBodyFlushedBeforeCode) Unwrap() error {
+	return ErrFlushOrder
+}
+
 // ErrCodeFlushedBeforeHeaders is the error returned if a status code flushed to an underlying response writer
 // before the headers have been flushed. It should help to sort out errors in middleware that uses
 // responsewriter wrappers from this package.
@@ -24,6 +45,11 @@ func (e ErrCodeFlushedBeforeHeaders) Error() string {
 	return "code flushed before headers"
 }
 
+// Unwrap returns ErrFlushOrder
+func (e ErrCodeFlushedBeforeHeaders) Unwrap() error {
+	return ErrFlushOrder
+}
+
 // ErrUnsupportedContextSetter is the error returned if the context type is not supported by the SetContext()
 // method of a Contexter
 type ErrUnsupportedContextSetter struct {
@@ -34,6 +60,11 @@ func (e *ErrUnsupportedContextSetter) Error() string {
 	return fmt.Sprintf("setting the context type %T is not supported by the Contexter", e.Type)
 }
 
+// Unwrap returns ErrUnsupportedContext
+func (e *ErrUnsupportedContextSetter) Unwrap() error {
+	return ErrUnsupportedContext
+}
+
 // ErrUnsupportedContextGetter is the error returned if the context type is not supported by the Context()
 // method of a Contexter
 type ErrUnsupportedContextGetter struct {
@@ -43,3 +74,43 @@ type ErrUnsupportedContextGetter struct {
 func (e *ErrUnsupportedContextGetter) Error() string {
 	return fmt.Sprintf("getting the context type %T is not supported by the Contexter", e.Type)
 }
+
+// Unwrap returns ErrUnsupportedContext
+func (e *ErrUnsupportedContextGetter) Unwrap() error {
+	return ErrUnsupportedContext
+}
+
+// catchError recovers a panic with an error that wraps the given sentinel and stores it in err.
+// Other panics are passed through.
+func catchError(sentinel error, err *error) {
+	if p := recover(); p != nil {
+		if e, ok := p.(error); ok && errors.Is(e, sentinel) {
+			*err = e
+			return
+		}
+		panic(p)
+	}
+}
+
+// TryContext is like calling Context on rw as Contexter, but returns an error instead of panicking
+// if rw is no Contexter (ErrNoContexter) or the context type is not supported (*ErrUnsupportedContextGetter).
+func TryContext(rw http.ResponseWriter, ctxPtr interface{}) (found bool, err error) {
+	ctx, ok := rw.(Contexter)
+	if !ok {
+		return false, ErrNoContexter
+	}
+	defer catchError(ErrUnsupportedContext, &err)
+	return ctx.Context(ctxPtr), nil
+}
+
+// TrySetContext is like calling SetContext on rw as Contexter, but returns an error instead of panicking
+// if rw is no Contexter (ErrNoContexter) or the context type is not supported (*ErrUnsupportedContextSetter).
+func TrySetContext(rw http.ResponseWriter, ctxPtr interface{}) (err error) {
+	ctx, ok := rw.(Contexter)
+	if !ok {
+		return ErrNoContexter
+	}
+	defer catchError(ErrUnsupportedContext, &err)
+	ctx.SetContext(ctxPtr)
+	return nil
+}
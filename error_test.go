@@ -1,6 +1,7 @@
 package wrap
 
 import (
+	"errors"
 	"fmt"
 	"net/http/httptest"
 	"reflect"
@@ -103,3 +104,84 @@ func TestCodeFlushedBeforeHeaders(t *testing.T) {
 	ckA.FlushHeaders()
 
 }
+
+func TestErrorHierarchy(t *testing.T) {
+	errs := map[error]error{
+		ErrBodyFlushedBeforeCode{}:                      ErrFlushOrder,
+		ErrCodeFlushedBeforeHeaders{}:                   ErrFlushOrder,
+		&ErrUnsupportedContextGetter{Type: 1}:           ErrUnsupportedContext,
+		&ErrUnsupportedContextSetter{Type: 1}:           ErrUnsupportedContext,
+		ErrNoContexter:                                  ErrUnsupportedContext,
+		fmt.Errorf("x: %w", ErrBodyFlushedBeforeCode{}): ErrFlushOrder,
+	}
+
+	for err, sentinel := range errs {
+		if !errors.Is(err, sentinel) {
+			t.Errorf("%T should wrap %v", err, sentinel)
+		}
+	}
+
+	var getter *ErrUnsupportedContextGetter
+	if !errors.As(fmt.Errorf("x: %w", &ErrUnsupportedContextGetter{Type: 1}), &getter) || getter.Type != 1 {
+		t.Errorf("errors.As should find *ErrUnsupportedContextGetter")
+	}
+}
+
+func TestTryFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ck := NewPeek(rec, nil)
+	write("hu").ServeHTTP(ck, nil)
+	writeCode(ck, nil)
+
+	if err := ck.TryFlushCode(); !errors.Is(err, ErrBodyFlushedBeforeCode{}) {
+		t.Errorf("expected ErrBodyFlushedBeforeCode, got %v", err)
+	}
+
+	if err := ck.TryFlushHeaders(); !errors.Is(err, ErrFlushOrder) {
+		t.Errorf("expected ErrFlushOrder, got %v", err)
+	}
+
+	ck = NewPeek(rec, nil)
+	writeCode(ck, nil)
+
+	if err := ck.TryFlushHeaders(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err := ck.TryFlushCode(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestTryContext(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	if _, err := TryContext(rec, new(error)); err != ErrNoContexter {
+		t.Errorf("expected ErrNoContexter, got %v", err)
+	}
+
+	if err := TrySetContext(rec, new(error)); err != ErrNoContexter {
+		t.Errorf("expected ErrNoContexter, got %v", err)
+	}
+
+	ctx := &context{ResponseWriter: rec}
+
+	if _, err := TryContext(ctx, new(int)); !errors.Is(err, ErrUnsupportedContext) {
+		t.Errorf("expected ErrUnsupportedContext, got %v", err)
+	}
+
+	var setter *ErrUnsupportedContextSetter
+	if err := TrySetContext(ctx, new(int)); !errors.As(err, &setter) {
+		t.Errorf("expected *ErrUnsupportedContextSetter, got %v", err)
+	}
+
+	e := errors.New("x")
+	if err := TrySetContext(ctx, &e); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	var got error
+	if found, err := TryContext(ctx, &got); !found || err != nil || got != e {
+		t.Errorf("expected to find the error, got %v, %v, %v", found, err, got)
+	}
+}
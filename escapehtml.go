@@ -32,16 +32,16 @@ type EscapeHTML struct {
 // make sure to fulfill the Contexter interface
 var _ Contexter = &EscapeHTML{}
 
-// Context gets the Context of the underlying response writer. It panics if the underlying response writer
-// does no implement Contexter
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
 func (e *EscapeHTML) Context(ctxPtr interface{}) bool {
-	return e.ResponseWriter.(Contexter).Context(ctxPtr)
+	return contextOf(e.ResponseWriter, ctxPtr)
 }
 
 // SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
 // does no implement Contexter
 func (e *EscapeHTML) SetContext(ctxPtr interface{}) {
-	e.ResponseWriter.(Contexter).SetContext(ctxPtr)
+	setContextOf(e.ResponseWriter, ctxPtr)
 }
 
 // Write writes to the inner *http.ResponseWriter escaping html special chars on the fly
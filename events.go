@@ -0,0 +1,144 @@
+package wrap
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event is a structured event that a middleware publishes during a request via Emit
+type Event struct {
+	// Source is the middleware that emitted the event
+	Source interface{}
+
+	// Name is the name of the event
+	Name string
+
+	// Data is optional structured data of the event
+	Data map[string]interface{}
+
+	// Time is the time of the event. Emit sets it, if it is zero.
+	Time time.Time
+}
+
+// EmitFunc is the context type that is provided by the EventCollector to emit events
+type EmitFunc func(Event)
+
+// Emit publishes the given event for the current request, so that any middleware may signal
+// something without inventing ad-hoc context types. The event is delivered by the EventCollector
+// of the stack at the end of the request.
+// Emit returns false if there is no EventCollector in the stack before the given ResponseWriter.
+func Emit(rw http.ResponseWriter, e Event) bool {
+	var emit EmitFunc
+	if found, err := TryContext(rw, &emit); err != nil || !found || emit == nil {
+		return false
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	emit(e)
+	return true
+}
+
+// EventCollector is a Wrapper that collects the events emitted during a request and delivers them
+// at the end of the request (also if it panics).
+//
+// EventCollector provides the EmitFunc context type by wrapping the ResponseWriter, so the Contexter
+// of the stack does not need to support it. Other context types are passed to the wrapped ResponseWriter.
+type EventCollector struct {
+	// Deliver receives the request and the collected events. It is not called if there are no events.
+	// See EventsToChannel and EventsToDebugger for predefined functions.
+	Deliver func(req *http.Request, events []Event)
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = EventCollector{}
+
+// Wrap implements the Wrapper interface
+func (c EventCollector) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ew := &eventWriter{ResponseWriter: rw}
+		ew.emit = func(e Event) {
+			ew.mx.Lock()
+			ew.events = append(ew.events, e)
+			ew.mx.Unlock()
+		}
+		defer func() {
+			ew.mx.Lock()
+			events := ew.events
+			ew.mx.Unlock()
+			if len(events) > 0 && c.Deliver != nil {
+				c.Deliver(req, events)
+			}
+		}()
+		next.ServeHTTP(ew, req)
+	}
+	return f
+}
+
+// eventWriter provides the EmitFunc context type
+type eventWriter struct {
+	http.ResponseWriter
+	mx     sync.Mutex
+	events []Event
+	emit   EmitFunc
+	caps   capabilities
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &eventWriter{}
+
+// Context supports *EmitFunc and passes other types to the underlying response writer.
+func (e *eventWriter) Context(ctxPtr interface{}) bool {
+	if emit, ok := ctxPtr.(*EmitFunc); ok {
+		*emit = e.emit
+		return true
+	}
+	return contextOf(e.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer.
+func (e *eventWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(e.ResponseWriter, ctxPtr)
+}
+
+// Flush flushes the underlying ResponseWriter if it is a http.Flusher
+func (e *eventWriter) Flush() {
+	e.caps.flush(e.ResponseWriter)
+}
+
+// RequestEvents are the events of a request
+type RequestEvents struct {
+	Request *http.Request
+	Events  []Event
+}
+
+// EventsToChannel returns a function for EventCollector.Deliver that sends the events to the given channel
+func EventsToChannel(ch chan<- RequestEvents) func(*http.Request, []Event) {
+	return func(req *http.Request, events []Event) {
+		ch <- RequestEvents{req, events}
+	}
+}
+
+// EventDebugger is a Debugger that may receive events (see EventsToDebugger)
+type EventDebugger interface {
+	Debugger
+
+	// DebugEvent receives the current request and an event that has been emitted
+	DebugEvent(req *http.Request, e Event)
+}
+
+// EventsToDebugger is a function for EventCollector.Deliver that passes the events to the DEBUGGER.
+// If the DEBUGGER is an EventDebugger, DebugEvent is called, otherwise Debug with
+// the event as object and "Event" as role.
+func EventsToDebugger(req *http.Request, events []Event) {
+	dbg := CurrentDebugger()
+	for _, e := range events {
+		if ed, ok := dbg.(EventDebugger); ok {
+			ed.DebugEvent(req, e)
+			continue
+		}
+		dbg.Debug(req, e, asEvent)
+	}
+}
@@ -0,0 +1,74 @@
+package wrap
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type emitter string
+
+func (e emitter) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		Emit(rw, Event{Source: e, Name: string(e), Data: map[string]interface{}{"path": req.URL.Path}})
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func TestEventCollector(t *testing.T) {
+	ch := make(chan RequestEvents, 1)
+	h := Stack(&context{}, EventCollector{Deliver: EventsToChannel(ch)}, emitter("a"), setUserIP{}, emitter("b"), app{})
+	rec, req := newTestRequest("GET", "/x")
+	req.RemoteAddr = "127.0.0.1:45643"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1\nDONE", 200)
+
+	re := <-ch
+
+	if re.Request != req {
+		t.Errorf("events should be delivered with the request")
+	}
+
+	if len(re.Events) != 2 || re.Events[0].Name != "a" || re.Events[1].Name != "b" {
+		t.Fatalf("expected events a and b, got %v", re.Events)
+	}
+
+	if re.Events[0].Time.IsZero() || re.Events[0].Data["path"] != "/x" {
+		t.Errorf("event should have time and data, got %v", re.Events[0])
+	}
+}
+
+func TestEmitWithoutCollector(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+
+	if Emit(rec, Event{Name: "x"}) {
+		t.Errorf("Emit should return false for a plain ResponseWriter")
+	}
+
+	var emitted bool
+	Stack(&context{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		emitted = Emit(rw, Event{Name: "x"})
+	})).ServeHTTP(rec, req)
+
+	if emitted {
+		t.Errorf("Emit should return false if there is no EventCollector")
+	}
+}
+
+func TestEventsToDebugger(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	h := New(EventCollector{Deliver: EventsToDebugger}, emitter("hello"))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	exp := `GET / event "hello" from wrap.emitter map[path:/]`
+	if !strings.Contains(buf.String(), exp) {
+		t.Errorf("expected %#v in %#v", exp, buf.String())
+	}
+}
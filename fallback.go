@@ -0,0 +1,55 @@
+package wrap
+
+import (
+	"net/http"
+)
+
+// fallback is the Wrapper returned by Fallback
+type fallback struct {
+	primary  Wrapper
+	fallback http.Handler
+}
+
+// Fallback returns a Wrapper that runs primary (wrapping the next handler) behind a Peek.
+// If primary responds with a 5xx status code or writes nothing at all, its headers and body are discarded
+// and the fallback handler is served instead, e.g. to serve a stale cache on errors.
+func Fallback(primary Wrapper, fallbackHandler http.Handler) Wrapper {
+	return &fallback{primary: primary, fallback: fallbackHandler}
+}
+
+// isServerError returns if the response cached by the peek is a failure
+func isServerError(p *Peek) bool {
+	return p.Code >= 500
+}
+
+// proceedUnlessServerError is a proceed function for a Peek that flushes the cached headers and
+// status code unless the status code is a server error
+func proceedUnlessServerError(p *Peek) bool {
+	if isServerError(p) {
+		return false
+	}
+	p.FlushMissing()
+	return true
+}
+
+// Wrap implements the Wrapper interface
+func (f *fallback) Wrap(next http.Handler) http.Handler {
+	primary := f.primary.Wrap(next)
+	var fn http.HandlerFunc
+	fn = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			primary.ServeHTTP(rw, req)
+			return
+		}
+		p := AcquirePeek(rw, proceedUnlessServerError)
+		defer ReleasePeek(p)
+		primary.ServeHTTP(p, req)
+
+		if p.bodyWritten || (p.Code != 0 && !isServerError(p)) {
+			p.FlushMissing()
+			return
+		}
+		f.fallback.ServeHTTP(rw, req)
+	}
+	return fn
+}
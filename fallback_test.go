@@ -0,0 +1,45 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	failing := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Primary", "yes")
+		rw.WriteHeader(502)
+		rw.Write([]byte("bad gateway"))
+	})
+
+	tests := []struct {
+		primary Wrapper
+		body    string
+		code    int
+		header  string
+	}{
+		{failing, "fallback", 200, ""},
+		{HandlerFunc(func(http.ResponseWriter, *http.Request) {}), "fallback", 200, ""},
+		{Handler(writeStop("primary")), "primary", 200, ""},
+		{Handler(statusHandler(404)), "", 404, ""},
+		{HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Primary", "yes")
+			rw.WriteHeader(201)
+		}), "", 201, "yes"},
+	}
+
+	for i, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		New(Fallback(test.primary, writeStop("fallback"))).ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, test.code)
+		if got := rec.Header().Get("X-Primary"); got != test.header {
+			t.Errorf("[%d] X-Primary header should be %#v, but is %#v", i, test.header, got)
+		}
+	}
+}
+
+func TestFallbackNext(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(Fallback(write("a"), writeStop("fallback")), writeStop("b")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "ab", 200)
+}
@@ -0,0 +1,45 @@
+package wrap
+
+import "net/http"
+
+// ContextValue returns the context value of type T of the Contexter rw and if it has been found.
+// Like TryContext it returns ErrNoContexter if rw is no Contexter and *ErrUnsupportedContextGetter
+// if the Contexter does not support T.
+func ContextValue[T any](rw http.ResponseWriter) (v T, found bool, err error) {
+	found, err = TryContext(rw, &v)
+	return
+}
+
+// SetContextValue sets the context value of type T of the Contexter rw.
+// Like TrySetContext it returns ErrNoContexter if rw is no Contexter and *ErrUnsupportedContextSetter
+// if the Contexter does not support T.
+func SetContextValue[T any](rw http.ResponseWriter, v T) error {
+	return TrySetContext(rw, &v)
+}
+
+// ContextHandlerFunc is a ContextWrapper for a function that gets the context value of type T
+// (the zero value if it has not been set) in addition to the arguments of a NextHandlerFunc.
+// Its ValidateContext method validates that the Contexter supports T, so that Stack and
+// ValidateWrapperContexts uncover a missing support before serving.
+type ContextHandlerFunc[T any] func(v T, next http.Handler, rw http.ResponseWriter, req *http.Request)
+
+// ValidateContext gets and sets T, so that it panics if ctx does not support T
+func (f ContextHandlerFunc[T]) ValidateContext(ctx Contexter) {
+	var v T
+	ctx.SetContext(&v)
+	ctx.Context(&v)
+}
+
+// Wrap implements the Wrapper interface. The returned handler panics if the ResponseWriter is no
+// Contexter supporting T.
+func (f ContextHandlerFunc[T]) Wrap(next http.Handler) http.Handler {
+	var fn http.HandlerFunc
+	fn = func(rw http.ResponseWriter, req *http.Request) {
+		v, _, err := ContextValue[T](rw)
+		if err != nil {
+			panic(err)
+		}
+		f(v, next, rw, req)
+	}
+	return fn
+}
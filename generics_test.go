@@ -0,0 +1,47 @@
+package wrap
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestContextValue(t *testing.T) {
+	ctx := &context{ResponseWriter: noHTTPWriter{}}
+	if _, found, err := ContextValue[userIP](ctx); found || err != nil {
+		t.Errorf("expected no userIP, got found: %v, err: %v", found, err)
+	}
+	if err := SetContextValue(ctx, userIP(net.ParseIP("1.2.3.4"))); err != nil {
+		t.Fatal(err)
+	}
+	if ip, found, err := ContextValue[userIP](ctx); !found || err != nil || net.IP(ip).String() != "1.2.3.4" {
+		t.Errorf("expected userIP 1.2.3.4, got %v (found: %v, err: %v)", net.IP(ip), found, err)
+	}
+
+	if _, _, err := ContextValue[RequestID](ctx); !errors.Is(err, ErrUnsupportedContext) {
+		t.Errorf("expected ErrUnsupportedContext, got %v", err)
+	}
+	if err := SetContextValue(noHTTPWriter{}, userIP(nil)); !errors.Is(err, ErrNoContexter) {
+		t.Errorf("expected ErrNoContexter, got %v", err)
+	}
+}
+
+func TestContextHandlerFunc(t *testing.T) {
+	var showIP ContextHandlerFunc[userIP]
+	showIP = func(ip userIP, next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(net.IP(ip).String()))
+		next.ServeHTTP(rw, req)
+	}
+
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "5.6.7.8:1234"
+	Stack(&context{}, setUserIP{}, showIP, write("!")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "5.6.7.8!", http.StatusOK)
+
+	var showID ContextHandlerFunc[RequestID]
+	showID = func(RequestID, http.Handler, http.ResponseWriter, *http.Request) {}
+	if err := ValidateWrapperContextsAll(&context{}, showIP, showID); err == nil {
+		t.Errorf("expected validation error for RequestID")
+	}
+}
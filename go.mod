@@ -1,3 +1,5 @@
 module github.com/go-on/wrap
 
+go 1.23
+
 require github.com/go-on/wrap-contrib v2.7.1+incompatible
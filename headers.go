@@ -0,0 +1,295 @@
+package wrap
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are the headers that are meaningful only for a single connection (RFC 7230, 6.1)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// forwardingHeaders are the headers that are parsed into the Forwarded context type
+var forwardingHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Proto",
+	"X-Forwarded-Host",
+}
+
+// Forwarded is the context type of the forwarding information of a request, see NormalizeHeaders
+type Forwarded struct {
+	// For is the chain of client addresses, starting with the originating client and ending with
+	// the remote address of the connection
+	For []string
+
+	// Client is the address of the client, i.e. the rightmost address of For that is not a trusted proxy
+	Client string
+
+	// Proto is the protocol that the client used, "http" or "https"
+	Proto string
+
+	// Host is the host that the client requested
+	Host string
+}
+
+// NormalizeHeaders is a ContextWrapper that cleans up the headers of the request, so that the next
+// handlers may rely on them:
+//
+//   - hop-by-hop headers (and those named in the Connection header) are removed, unless the request
+//     is an upgrade and AllowUpgrade is set
+//   - values are trimmed and values containing control characters are removed
+//   - headers with several values are folded into one (cookies separated by "; ", others by ", ")
+//   - the Forwarded and X-Forwarded-* headers are parsed into the Forwarded context type and removed
+//
+// The forwarding headers are only taken into account if the remote address of the request belongs to
+// one of the TrustedProxies, since they are set by the client otherwise. Only the ForwardedHeader is
+// parsed, since a proxy that sets one of the headers passes the other one on as sent by the client.
+type NormalizeHeaders struct {
+	// TrustedProxies are the IPs or CIDR ranges (e.g. "10.0.0.0/8") of the proxies in front of the server
+	TrustedProxies []string
+
+	// ForwardedHeader is the header that the TrustedProxies set: "X-Forwarded-For" (the default, together
+	// with X-Forwarded-Proto and X-Forwarded-Host) or "Forwarded" (RFC 7239)
+	ForwardedHeader string
+
+	// AllowUpgrade keeps the Connection and Upgrade headers of upgrade requests, e.g. for websockets
+	AllowUpgrade bool
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = NormalizeHeaders{}
+
+// ValidateContext makes sure that ctx supports the Forwarded type
+func (NormalizeHeaders) ValidateContext(ctx Contexter) {
+	var f Forwarded
+	ctx.SetContext(&f)
+	ctx.Context(&f)
+}
+
+// Wrap implements the Wrapper interface. It panics if one of the TrustedProxies or the ForwardedHeader
+// is invalid.
+func (n NormalizeHeaders) Wrap(next http.Handler) http.Handler {
+	trusted := parseTrustedProxies(n.TrustedProxies)
+	header := parseForwardedHeader(n.ForwardedHeader)
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		req = req.Clone(req.Context())
+		fw := forwardedOf(req, trusted, header)
+		for _, h := range forwardingHeaders {
+			req.Header.Del(h)
+		}
+		n.stripHopByHop(req.Header)
+		cleanHeader(req.Header)
+		rw.(Contexter).SetContext(&fw)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// stripHopByHop removes the hop-by-hop headers
+func (n NormalizeHeaders) stripHopByHop(header http.Header) {
+	if n.AllowUpgrade && header.Get("Upgrade") != "" && headerContainsToken(header, "Connection", "upgrade") {
+		return
+	}
+	for _, v := range header["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, h := range hopByHopHeaders {
+		header.Del(h)
+	}
+}
+
+// headerContainsToken returns if the comma separated values of the header contain the token
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanHeader trims the values, removes values with control characters and folds multiple values
+func cleanHeader(header http.Header) {
+	for name, values := range header {
+		clean := values[:0]
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v != "" && !hasControlChar(v) {
+				clean = append(clean, v)
+			}
+		}
+		switch {
+		case len(clean) == 0:
+			delete(header, name)
+		case name == "Cookie":
+			header[name] = []string{strings.Join(clean, "; ")}
+		default:
+			header[name] = []string{strings.Join(clean, ", ")}
+		}
+	}
+}
+
+// hasControlChar returns if s contains an ASCII control character other than horizontal tab
+func hasControlChar(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < 0x20 && c != '\t') || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses IPs and CIDR ranges, panicking on invalid ones
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	return parseNetworks("trusted proxy", proxies)
+}
+
+// parseForwardedHeader returns the canonical name of the forwarding header that the trusted proxies set:
+// "X-Forwarded-For" (the default, together with X-Forwarded-Proto and X-Forwarded-Host) or "Forwarded"
+// (RFC 7239). It panics on other headers.
+func parseForwardedHeader(header string) string {
+	switch header = http.CanonicalHeaderKey(header); header {
+	case "":
+		return "X-Forwarded-For"
+	case "X-Forwarded-For", "Forwarded":
+		return header
+	}
+	panic("invalid forwarded header " + header)
+}
+
+// parseNetworks parses IPs and CIDR ranges, panicking on invalid ones with a message naming the kind of entries
+func parseNetworks(kind string, entries []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, p := range entries {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				panic("invalid " + kind + " " + p)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			panic("invalid " + kind + " " + p)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isTrusted returns if addr is an IP within one of the trusted networks
+func isTrusted(trusted []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOnly strips the port and the brackets of an IPv6 address from addr
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// forwardedOf returns the forwarding information of the request, taking the given forwarding header (see
+// parseForwardedHeader) into account if the remote address is trusted
+func forwardedOf(req *http.Request, trusted []*net.IPNet, header string) Forwarded {
+	var fw Forwarded
+	remote := hostOnly(req.RemoteAddr)
+
+	if isTrusted(trusted, remote) {
+		if header == "Forwarded" {
+			fw = parseForwarded(req.Header.Values("Forwarded"))
+		} else {
+			for _, v := range req.Header.Values("X-Forwarded-For") {
+				for _, addr := range strings.Split(v, ",") {
+					if addr = strings.TrimSpace(addr); addr != "" {
+						fw.For = append(fw.For, hostOnly(addr))
+					}
+				}
+			}
+			fw.Proto = strings.ToLower(strings.TrimSpace(req.Header.Get("X-Forwarded-Proto")))
+			fw.Host = strings.TrimSpace(req.Header.Get("X-Forwarded-Host"))
+		}
+	}
+
+	fw.For = append(fw.For, remote)
+	fw.Client = fw.For[0]
+	for i := len(fw.For) - 1; i >= 0; i-- {
+		if !isTrusted(trusted, fw.For[i]) {
+			fw.Client = fw.For[i]
+			break
+		}
+	}
+
+	if fw.Proto == "" {
+		fw.Proto = "http"
+		if req.TLS != nil {
+			fw.Proto = "https"
+		}
+	}
+	if fw.Host == "" {
+		fw.Host = req.Host
+	}
+	return fw
+}
+
+// parseForwarded parses the Forwarded headers (RFC 7239). Proto and host are taken from the first element.
+func parseForwarded(values []string) (fw Forwarded) {
+	for _, v := range values {
+		for i, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					fw.For = append(fw.For, hostOnly(val))
+				case "proto":
+					if i == 0 && fw.Proto == "" {
+						fw.Proto = strings.ToLower(val)
+					}
+				case "host":
+					if i == 0 && fw.Host == "" {
+						fw.Host = val
+					}
+				}
+			}
+		}
+	}
+	return
+}
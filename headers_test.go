@@ -0,0 +1,164 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type forwardedContext struct {
+	http.ResponseWriter
+	forwarded Forwarded
+}
+
+func (c *forwardedContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *Forwarded:
+		*ty = c.forwarded
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *forwardedContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *Forwarded:
+		c.forwarded = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c forwardedContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&forwardedContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// writeForwarded writes the Forwarded context
+func writeForwarded(rw http.ResponseWriter, req *http.Request) {
+	var fw Forwarded
+	rw.(Contexter).Context(&fw)
+	fmt.Fprintf(rw, "%s %s %s %s", strings.Join(fw.For, ","), fw.Client, fw.Proto, fw.Host)
+}
+
+func TestNormalizeHeadersForwarded(t *testing.T) {
+	ValidateWrapperContexts(&forwardedContext{}, NormalizeHeaders{})
+
+	h := New(forwardedContext{}, NormalizeHeaders{TrustedProxies: []string{"10.0.0.0/8", "::1"}}, HandlerFunc(writeForwarded))
+
+	tests := []struct {
+		remote string
+		header http.Header
+		exp    string
+	}{
+		{"1.2.3.4:1234", http.Header{"X-Forwarded-For": {"6.6.6.6"}}, "1.2.3.4 1.2.3.4 http example.com"},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"5.5.5.5, 10.0.0.2"}, "X-Forwarded-Proto": {"HTTPS"}, "X-Forwarded-Host": {"example.org"}},
+			"5.5.5.5,10.0.0.2,10.0.0.1 5.5.5.5 https example.org"},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 5.5.5.5"}}, "6.6.6.6,5.5.5.5,10.0.0.1 5.5.5.5 http example.com"},
+		// a Forwarded header is passed on as sent by the client by proxies that set X-Forwarded-For
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6"}, "Forwarded": {"for=1.2.3.4"}},
+			"6.6.6.6,10.0.0.1 6.6.6.6 http example.com"},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		req.Host = "example.com"
+		req.RemoteAddr = test.remote
+		req.Header = test.header
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, 200)
+	}
+}
+
+func TestNormalizeHeadersForwardedHeader(t *testing.T) {
+	h := New(forwardedContext{}, NormalizeHeaders{TrustedProxies: []string{"10.0.0.0/8", "::1"}, ForwardedHeader: "forwarded"}, HandlerFunc(writeForwarded))
+
+	tests := []struct {
+		remote string
+		header http.Header
+		exp    string
+	}{
+		{"[::1]:1234", http.Header{"Forwarded": {`for="[2001:db8::1]:4711";proto=https;host=example.net, for=10.1.1.1`}},
+			"2001:db8::1,10.1.1.1,::1 2001:db8::1 https example.net"},
+		// X-Forwarded-For is passed on as sent by the client by proxies that set Forwarded
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}, "Forwarded": {"for=6.6.6.6"}},
+			"6.6.6.6,10.0.0.1 6.6.6.6 http example.com"},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		req.Host = "example.com"
+		req.RemoteAddr = test.remote
+		req.Header = test.header
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, 200)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("an invalid ForwardedHeader should panic")
+		}
+	}()
+	New(NormalizeHeaders{ForwardedHeader: "X-Real-IP"})
+}
+
+func TestNormalizeHeadersClean(t *testing.T) {
+	var got http.Header
+	h := New(forwardedContext{}, NormalizeHeaders{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req.Header
+	}))
+
+	rec, req := newTestRequest("GET", "/")
+	req.Header = http.Header{
+		"Connection":      {"keep-alive, X-Secret"},
+		"Keep-Alive":      {"timeout=5"},
+		"X-Secret":        {"hop"},
+		"Upgrade":         {"websocket"},
+		"Accept":          {" text/html ", "application/json"},
+		"Cookie":          {"a=1", "b=2"},
+		"X-Injected":      {"bad\r\nvalue"},
+		"X-Forwarded-For": {"6.6.6.6"},
+	}
+	h.ServeHTTP(rec, req)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "X-Secret", "Upgrade", "X-Injected", "X-Forwarded-For"} {
+		if _, has := got[name]; has {
+			t.Errorf("header %s should be removed", name)
+		}
+	}
+
+	if v := got["Accept"]; len(v) != 1 || v[0] != "text/html, application/json" {
+		t.Errorf("Accept should be folded, got %#v", v)
+	}
+
+	if v := got["Cookie"]; len(v) != 1 || v[0] != "a=1; b=2" {
+		t.Errorf("Cookie should be folded, got %#v", v)
+	}
+
+	if len(req.Header["Keep-Alive"]) != 1 {
+		t.Errorf("headers of the original request should not be changed")
+	}
+}
+
+func TestNormalizeHeadersUpgrade(t *testing.T) {
+	var got http.Header
+	h := New(forwardedContext{}, NormalizeHeaders{AllowUpgrade: true}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req.Header
+	}))
+
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	h.ServeHTTP(rec, req)
+
+	if got.Get("Upgrade") != "websocket" || got.Get("Connection") != "Upgrade" {
+		t.Errorf("upgrade headers should be kept, got %#v", got)
+	}
+}
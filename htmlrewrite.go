@@ -0,0 +1,511 @@
+package wrap
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTMLTokenType is the type of an HTMLToken
+type HTMLTokenType int
+
+const (
+	// HTMLText is text (including the content of script and style elements), Data is the raw text
+	HTMLText HTMLTokenType = iota
+
+	// HTMLStartTag is a start tag like <a href="x">, Data is the lowercased tag name
+	HTMLStartTag
+
+	// HTMLEndTag is an end tag like </a>, Data is the lowercased tag name
+	HTMLEndTag
+
+	// HTMLSelfClosingTag is a tag like <br/>, Data is the lowercased tag name
+	HTMLSelfClosingTag
+
+	// HTMLComment is a comment, a doctype or a processing instruction, Data is the raw markup
+	HTMLComment
+)
+
+// HTMLAttr is an attribute of a tag with the unescaped value
+type HTMLAttr struct {
+	Key, Val string
+}
+
+// HTMLToken is a token of an HTML body, visited by HTMLVisitors. Tokens that have not been changed
+// are written as they have been read, changed tags are written with double quoted attribute values.
+type HTMLToken struct {
+	Type HTMLTokenType
+	Data string
+	Attr []HTMLAttr
+
+	// Prepend and Append are written (unescaped) before and after the token
+	Prepend, Append string
+
+	// Drop removes the token from the body (Prepend and Append are still written)
+	Drop bool
+
+	raw      string
+	origData string
+	origAttr []HTMLAttr
+}
+
+// IsTag returns if the token is a start or self-closing tag with the given lowercase name
+func (t *HTMLToken) IsTag(name string) bool {
+	return (t.Type == HTMLStartTag || t.Type == HTMLSelfClosingTag) && t.Data == name
+}
+
+// AttrValue returns the value of the attribute with the given key and if it exists
+func (t *HTMLToken) AttrValue(key string) (string, bool) {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// SetAttr sets the value of the attribute with the given key, adding the attribute if it does not exist
+func (t *HTMLToken) SetAttr(key, val string) {
+	for i := range t.Attr {
+		if t.Attr[i].Key == key {
+			t.Attr[i].Val = val
+			return
+		}
+	}
+	t.Attr = append(t.Attr, HTMLAttr{Key: key, Val: val})
+}
+
+// changed returns if Data or Attr have been changed
+func (t *HTMLToken) changed() bool {
+	if t.Data != t.origData || len(t.Attr) != len(t.origAttr) {
+		return true
+	}
+	for i := range t.Attr {
+		if t.Attr[i] != t.origAttr[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTo writes the token to w
+func (t *HTMLToken) writeTo(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(t.Prepend)
+	switch {
+	case t.Drop:
+	case !t.changed():
+		b.WriteString(t.raw)
+	case t.Type == HTMLText || t.Type == HTMLComment:
+		b.WriteString(t.Data)
+	default:
+		b.WriteByte('<')
+		if t.Type == HTMLEndTag {
+			b.WriteByte('/')
+		}
+		b.WriteString(t.Data)
+		for _, a := range t.Attr {
+			b.WriteByte(' ')
+			b.WriteString(a.Key)
+			if a.Val != "" {
+				b.WriteString(`="`)
+				b.WriteString(html.EscapeString(a.Val))
+				b.WriteByte('"')
+			}
+		}
+		if t.Type == HTMLSelfClosingTag {
+			b.WriteByte('/')
+		}
+		b.WriteByte('>')
+	}
+	b.WriteString(t.Append)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HTMLVisitor visits the tokens of an HTML body and may change them
+type HTMLVisitor func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken)
+
+// InjectBeforeBodyEnd returns an HTMLVisitor that inserts the given markup before </body>
+func InjectBeforeBodyEnd(markup string) HTMLVisitor {
+	return func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken) {
+		if tok.Type == HTMLEndTag && tok.Data == "body" {
+			tok.Prepend += markup
+		}
+	}
+}
+
+// RewriteURLs returns an HTMLVisitor that replaces the values of the href and src attributes by the
+// result of rewrite, e.g. to point asset URLs to a CDN
+func RewriteURLs(rewrite func(url string) string) HTMLVisitor {
+	return func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken) {
+		if tok.Type != HTMLStartTag && tok.Type != HTMLSelfClosingTag {
+			return
+		}
+		for i, a := range tok.Attr {
+			if a.Key == "href" || a.Key == "src" {
+				tok.Attr[i].Val = rewrite(a.Val)
+			}
+		}
+	}
+}
+
+// AddNonce returns an HTMLVisitor that sets the nonce attribute of script and style tags to the nonce of
+// the request (e.g. the one of the Content-Security-Policy header). Tags are not changed, if nonce returns
+// an empty string.
+func AddNonce(nonce func(rw http.ResponseWriter, req *http.Request) string) HTMLVisitor {
+	return func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken) {
+		if tok.IsTag("script") || tok.IsTag("style") {
+			if n := nonce(rw, req); n != "" {
+				tok.SetAttr("nonce", n)
+			}
+		}
+	}
+}
+
+// maxHTMLToken is the size of an incomplete tag or comment above which it is treated as text, so that
+// broken markup does not hold back the rest of the body
+const maxHTMLToken = 64 * 1024
+
+// htmlTokenizer splits a streamed HTML body into tokens
+type htmlTokenizer struct {
+	buf []byte
+
+	// rawTag is the name of the element with raw text content (script, style, textarea, title),
+	// whose start tag has been read last
+	rawTag string
+}
+
+// next returns the next complete token of the buffer. If final is false, incomplete tokens at the
+// end of the buffer are held back.
+func (z *htmlTokenizer) next(final bool) (tok HTMLToken, ok bool) {
+	if len(z.buf) == 0 {
+		return
+	}
+	var n int
+	if z.rawTag != "" {
+		n = rawTextEnd(z.buf, z.rawTag)
+		switch {
+		case n >= 0 || final:
+			if n < 0 {
+				n = len(z.buf)
+			}
+			z.rawTag = ""
+			if n == 0 {
+				return z.next(final)
+			}
+		default:
+			// hold back a possible partial end tag with the byte following the tag name
+			n = len(z.buf) - len(z.rawTag) - 3
+			if n <= 0 {
+				return
+			}
+		}
+		tok = HTMLToken{Type: HTMLText}
+	} else if z.buf[0] != '<' {
+		n = bytes.IndexByte(z.buf, '<')
+		if n < 0 {
+			n = len(z.buf)
+		}
+		tok = HTMLToken{Type: HTMLText}
+	} else {
+		var complete bool
+		tok, n, complete = parseHTMLMarkup(z.buf)
+		if !complete {
+			if !final && len(z.buf) < maxHTMLToken {
+				return
+			}
+			tok, n = HTMLToken{Type: HTMLText}, len(z.buf)
+		}
+	}
+
+	tok.raw = string(z.buf[:n])
+	if tok.Type == HTMLText || tok.Type == HTMLComment {
+		tok.Data = tok.raw
+	}
+	tok.origData = tok.Data
+	tok.origAttr = append([]HTMLAttr(nil), tok.Attr...)
+	if tok.Type == HTMLStartTag {
+		switch tok.Data {
+		case "script", "style", "textarea", "title":
+			z.rawTag = tok.Data
+		}
+	}
+	z.buf = z.buf[n:]
+	return tok, true
+}
+
+// rawTextEnd returns the index of the end tag of the given element inside buf or -1
+func rawTextEnd(buf []byte, tag string) int {
+	end := []byte("</" + tag)
+	lower := bytes.ToLower(buf)
+	for off := 0; ; {
+		i := bytes.Index(lower[off:], end)
+		if i < 0 {
+			return -1
+		}
+		i += off
+		j := i + len(end)
+		if j < len(buf) && (buf[j] == '>' || buf[j] == '/' || isHTMLSpace(buf[j])) {
+			return i
+		}
+		off = j
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseHTMLMarkup parses the markup starting with '<' at the beginning of buf. It returns the token,
+// its length and if it is complete. '<' that does not start markup is returned as text.
+func parseHTMLMarkup(buf []byte) (tok HTMLToken, n int, complete bool) {
+	if len(buf) < 2 {
+		return tok, 0, false
+	}
+	switch c := buf[1]; {
+	case c == '!' || c == '?':
+		if c == '!' && (len(buf) < 4 && bytes.HasPrefix([]byte("<!--"), buf)) {
+			return tok, 0, false
+		}
+		if bytes.HasPrefix(buf, []byte("<!--")) {
+			i := bytes.Index(buf[4:], []byte("-->"))
+			if i < 0 {
+				return tok, 0, false
+			}
+			return HTMLToken{Type: HTMLComment}, i + 7, true
+		}
+		i := bytes.IndexByte(buf, '>')
+		if i < 0 {
+			return tok, 0, false
+		}
+		return HTMLToken{Type: HTMLComment}, i + 1, true
+	case c == '/':
+		if len(buf) < 3 {
+			return tok, 0, false
+		}
+		if !isASCIILetter(buf[2]) {
+			return HTMLToken{Type: HTMLText}, 1, true
+		}
+		tok.Type = HTMLEndTag
+	case isASCIILetter(c):
+		tok.Type = HTMLStartTag
+	default:
+		return HTMLToken{Type: HTMLText}, 1, true
+	}
+
+	// find the end of the tag, skipping quoted attribute values
+	end := -1
+	var quote byte
+	for i := 1; i < len(buf) && end < 0; i++ {
+		switch c := buf[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			end = i
+		}
+	}
+	if end < 0 {
+		return tok, 0, false
+	}
+
+	inner := buf[1:end]
+	if tok.Type == HTMLEndTag {
+		inner = inner[1:]
+	}
+	if len(inner) > 0 && inner[len(inner)-1] == '/' && tok.Type == HTMLStartTag {
+		tok.Type = HTMLSelfClosingTag
+		inner = inner[:len(inner)-1]
+	}
+	i := 0
+	for i < len(inner) && !isHTMLSpace(inner[i]) && inner[i] != '/' {
+		i++
+	}
+	tok.Data = strings.ToLower(string(inner[:i]))
+	tok.Attr = parseHTMLAttrs(inner[i:])
+	return tok, end + 1, true
+}
+
+// parseHTMLAttrs parses the attributes of a tag
+func parseHTMLAttrs(b []byte) (attrs []HTMLAttr) {
+	i := 0
+	for {
+		for i < len(b) && (isHTMLSpace(b[i]) || b[i] == '/') {
+			i++
+		}
+		if i >= len(b) {
+			return
+		}
+		start := i
+		for i < len(b) && !isHTMLSpace(b[i]) && b[i] != '=' && b[i] != '/' {
+			i++
+		}
+		a := HTMLAttr{Key: strings.ToLower(string(b[start:i]))}
+		for i < len(b) && isHTMLSpace(b[i]) {
+			i++
+		}
+		if i < len(b) && b[i] == '=' {
+			i++
+			for i < len(b) && isHTMLSpace(b[i]) {
+				i++
+			}
+			if i < len(b) && (b[i] == '"' || b[i] == '\'') {
+				q := b[i]
+				i++
+				start = i
+				for i < len(b) && b[i] != q {
+					i++
+				}
+				a.Val = html.UnescapeString(string(b[start:i]))
+				i++
+			} else {
+				start = i
+				for i < len(b) && !isHTMLSpace(b[i]) {
+					i++
+				}
+				a.Val = html.UnescapeString(string(b[start:i]))
+			}
+		}
+		attrs = append(attrs, a)
+	}
+}
+
+// RewriteHTML is a Wrapper that passes the tokens of HTML bodies written by the next handlers through the
+// Visitors (in order), while they are written. Only incomplete tags and comments at the end of a write
+// are held back, so pages are not buffered completely. Visitors may e.g. inject scripts before </body>
+// (InjectBeforeBodyEnd), rewrite asset URLs (RewriteURLs) or add nonce attributes (AddNonce).
+//
+// Like EscapeHTML, RewriteHTML works on the bytes of the body, but it tokenizes them: the content of
+// script, style, textarea and title elements is treated as text and markup is not validated.
+// Only responses with a Content-Type of text/html (set or detected) are rewritten and the Content-Length
+// header of them is removed. Responses to HEAD requests and responses with a Content-Encoding are not
+// rewritten, so RewriteHTML should come after Compress in the stack.
+type RewriteHTML struct {
+	Visitors []HTMLVisitor
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = RewriteHTML{}
+
+// htmlRewriteWriter is the ResponseWriter passed to the next handlers by RewriteHTML
+type htmlRewriteWriter struct {
+	*Peek
+	visitors  []HTMLVisitor
+	req       *http.Request
+	head      bool
+	started   bool
+	rewriting bool
+	tokenizer htmlTokenizer
+}
+
+// start decides if the response is rewritten
+func (h *htmlRewriteWriter) start(b []byte) {
+	h.started = true
+	header := h.Peek.Header()
+	if h.head || header.Get("Content-Encoding") != "" {
+		return
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(b)
+		header.Set("Content-Type", ct)
+	}
+	mediaType, _, _ := strings.Cut(ct, ";")
+	if strings.ToLower(strings.TrimSpace(mediaType)) == "text/html" {
+		header.Del("Content-Length")
+		h.rewriting = true
+	}
+}
+
+// flushTokens visits and writes the complete tokens
+func (h *htmlRewriteWriter) flushTokens(final bool) error {
+	for {
+		tok, ok := h.tokenizer.next(final)
+		if !ok {
+			break
+		}
+		for _, v := range h.visitors {
+			v(h.Peek, h.req, &tok)
+		}
+		if err := tok.writeTo(h.Peek); err != nil {
+			return err
+		}
+	}
+	// do not keep a large backing array for the held back bytes
+	h.tokenizer.buf = append([]byte(nil), h.tokenizer.buf...)
+	return nil
+}
+
+// Write writes the rewritten body
+func (h *htmlRewriteWriter) Write(b []byte) (int, error) {
+	if !h.started {
+		h.start(b)
+	}
+	if !h.rewriting {
+		return h.Peek.Write(b)
+	}
+	h.tokenizer.buf = append(h.tokenizer.buf, b...)
+	if err := h.flushTokens(false); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// ReadFrom writes the content of r. If the response is not rewritten, r is passed to the
+// underlying ResponseWriter (see Peek.ReadFrom), so that files may be served via sendfile.
+func (h *htmlRewriteWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !h.started && h.Peek.Header().Get("Content-Type") != "" {
+		h.start(nil)
+	}
+	if h.started && !h.rewriting {
+		return h.Peek.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{h}, r)
+}
+
+// Flush flushes the underlying ResponseWriter. Held back incomplete tokens are not flushed.
+func (h *htmlRewriteWriter) Flush() {
+	h.Peek.FlushMissing()
+	flushInner(h.Peek.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (h *htmlRewriteWriter) Unwrap() http.ResponseWriter {
+	return h.Peek.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (r RewriteHTML) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		hw := &htmlRewriteWriter{
+			Peek: NewPeek(rw, func(p *Peek) bool {
+				p.FlushMissing()
+				return true
+			}),
+			visitors: r.Visitors,
+			req:      req,
+			head:     req.Method == http.MethodHead,
+		}
+		next.ServeHTTP(hw, req)
+		if hw.rewriting {
+			hw.flushTokens(true)
+		}
+		hw.Peek.FlushMissing()
+	}
+	return f
+}
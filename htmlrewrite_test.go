@@ -0,0 +1,100 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html><html><head><title>a < b</title>` +
+	`<script src='app.js'>if (a<b) { document.write("</p>") }</script>` +
+	`<link rel=stylesheet href="/style.css"></head>` +
+	`<body class="x"><!-- <a href="/no"> --><p>1 < 2 & <br/><img src="/i.png" alt='"q"'></p></body></html>`
+
+func writeSplitted(body string, size int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		for len(body) > 0 {
+			n := size
+			if n > len(body) {
+				n = len(body)
+			}
+			rw.Write([]byte(body[:n]))
+			body = body[n:]
+		}
+	})
+}
+
+func TestRewriteHTMLUnchanged(t *testing.T) {
+	var tags []string
+	visitor := func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken) {
+		if tok.Type == HTMLStartTag {
+			tags = append(tags, tok.Data)
+		}
+	}
+	for _, size := range []int{1, 3, 7, len(testPage)} {
+		tags = nil
+		h := New(RewriteHTML{Visitors: []HTMLVisitor{visitor}}, Handler(writeSplitted(testPage, size)))
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, testPage, 200)
+
+		if got := strings.Join(tags, ","); got != "html,head,title,script,link,body,p,img" {
+			t.Errorf("unexpected start tags with writes of %d bytes: %s", size, got)
+		}
+	}
+}
+
+func TestRewriteHTMLVisitors(t *testing.T) {
+	r := RewriteHTML{Visitors: []HTMLVisitor{
+		InjectBeforeBodyEnd(`<script src="/live.js"></script>`),
+		RewriteURLs(func(url string) string {
+			if strings.HasPrefix(url, "/") {
+				return "https://cdn.example.com" + url
+			}
+			return url
+		}),
+		AddNonce(func(rw http.ResponseWriter, req *http.Request) string { return "n0" }),
+	}}
+	for _, size := range []int{1, len(testPage)} {
+		h := New(r, Handler(writeSplitted(testPage, size)))
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+
+		expected := `<!DOCTYPE html><html><head><title>a < b</title>` +
+			`<script src="app.js" nonce="n0">if (a<b) { document.write("</p>") }</script>` +
+			`<link rel="stylesheet" href="https://cdn.example.com/style.css"></head>` +
+			`<body class="x"><!-- <a href="/no"> --><p>1 < 2 & <br/><img src="https://cdn.example.com/i.png" alt="&#34;q&#34;"></p>` +
+			`<script src="/live.js"></script></body></html>`
+		assertResponse(t, rec, expected, 200)
+	}
+}
+
+func TestRewriteHTMLDrop(t *testing.T) {
+	drop := func(rw http.ResponseWriter, req *http.Request, tok *HTMLToken) {
+		if tok.Type == HTMLComment {
+			tok.Drop = true
+		}
+	}
+	h := New(RewriteHTML{Visitors: []HTMLVisitor{drop}}, Handler(writeSplitted("<p>a<!-- b -->c</p>", 2)))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "<p>ac</p>", 200)
+}
+
+func TestRewriteHTMLSkip(t *testing.T) {
+	h := New(RewriteHTML{Visitors: []HTMLVisitor{InjectBeforeBodyEnd("x")}}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte("</body>"))
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "</body>", 200)
+}
+
+func TestRewriteHTMLIncomplete(t *testing.T) {
+	h := New(RewriteHTML{Visitors: []HTMLVisitor{InjectBeforeBodyEnd("x")}}, Handler(writeSplitted(`<p>a</p><a href="`, 4)))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, `<p>a</p><a href="`, 200)
+}
@@ -0,0 +1,153 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is an error that carries the status code and the message that should be sent to the client.
+// Err is the underlying error which is not shown to the client (unless HandleError is in Dev mode).
+//
+// By convention middleware stores errors as context type error (see SetError) and
+// HandleError renders them. Wrappers that reject a request store a HTTPError, if the ResponseWriter
+// is a Contexter supporting the error type, and also write the response directly, unless a HandleError
+// before them renders it.
+type HTTPError struct {
+	// Code is the status code of the response
+	Code int
+
+	// Msg is the message that is sent to the client. If it is empty, the status text of Code is used.
+	Msg string
+
+	// Err is the underlying error
+	Err error
+}
+
+// message returns the message for the client
+func (e *HTTPError) message() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	return http.StatusText(e.Code)
+}
+
+// Error returns the error message
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d %s", e.Code, e.message())
+	}
+	return fmt.Sprintf("%d %s: %s", e.Code, e.message(), e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// SetError stores the given error as context type error inside the Contexter.
+// It is the conventional way for middleware to report an error that should be rendered by HandleError.
+func SetError(rw http.ResponseWriter, err error) {
+	rw.(Contexter).SetContext(&err)
+}
+
+// GetError returns the error stored as context type error inside the Contexter, nil if there is none
+func GetError(rw http.ResponseWriter) (err error) {
+	rw.(Contexter).Context(&err)
+	return
+}
+
+// HandleError is a ContextWrapper that renders an error stored in the context type error (see SetError).
+//
+// If there is an error before the next handler is run, it is rendered and the next handler is not run.
+// Otherwise the next handler is run with a Peek, and if afterwards there is an error and nothing has been
+// written to the response, the error is rendered.
+//
+// The status code is taken from a HTTPError inside the error chain (see errors.As), otherwise it is 500.
+// The body is the message of the HTTPError or the status text. In Dev mode the body also shows the
+// complete error.
+type HandleError struct {
+	// Dev shows the details of the error in the response. Never set it in production.
+	Dev bool
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = HandleError{}
+
+// ValidateContext makes sure that ctx supports the error type
+func (HandleError) ValidateContext(ctx Contexter) {
+	var err error
+	ctx.Context(&err)
+	ctx.SetContext(&err)
+}
+
+// render writes the error to rw
+func (h HandleError) render(rw http.ResponseWriter, err error) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		he = &HTTPError{Code: http.StatusInternalServerError, Err: err}
+	}
+	msg := he.message()
+	if h.Dev {
+		msg = fmt.Sprintf("%s\n\n%s", msg, err.Error())
+	}
+	http.Error(rw, msg, he.Code)
+}
+
+// flushMissing is a proceed function for a Peek that flushes the cached headers and status code
+func flushMissing(p *Peek) bool {
+	p.FlushMissing()
+	return true
+}
+
+// Wrap implements the Wrapper interface
+func (h HandleError) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if err := GetError(rw); err != nil {
+			h.render(rw, err)
+			return
+		}
+		p := AcquirePeek(rw, flushMissing)
+		defer ReleasePeek(p)
+		p.rendersErrors = true
+		next.ServeHTTP(p, req)
+		if !p.bodyWritten && p.Code == 0 {
+			if err := GetError(rw); err != nil {
+				h.render(rw, err)
+				return
+			}
+		}
+		p.FlushMissing()
+	}
+	return f
+}
+
+// reportError stores err as context type error if rw is a Contexter supporting it. Unless a HandleError
+// renders it (see rendersErrors), the status text of the code is written directly.
+func reportError(rw http.ResponseWriter, err *HTTPError) {
+	var e error = err
+	if _, ok := rw.(Contexter); ok && TrySetContext(rw, &e) == nil && rendersErrors(rw) {
+		return
+	}
+	http.Error(rw, err.message(), err.Code)
+}
+
+// rendersErrors returns if rw has been passed by a HandleError to the next handlers, possibly wrapped by
+// ResponseWriter wrappers with an Unwrap method. If a wrapper in between has no Unwrap method,
+// it returns false, so that errors are rather written directly than not at all.
+func rendersErrors(rw http.ResponseWriter) bool {
+	for {
+		switch w := rw.(type) {
+		case *Peek:
+			if w.rendersErrors {
+				return true
+			}
+			rw = w.ResponseWriter
+		case interface{ Unwrap() http.ResponseWriter }:
+			rw = w.Unwrap()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	inner := errors.New("db down")
+	err := fmt.Errorf("loading: %w", &HTTPError{Code: 503, Err: inner})
+
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != 503 {
+		t.Errorf("errors.As should find the HTTPError")
+	}
+
+	if !errors.Is(err, inner) {
+		t.Errorf("errors.Is should find the underlying error")
+	}
+
+	if got := he.Error(); got != "503 Service Unavailable: db down" {
+		t.Errorf("unexpected error message %#v", got)
+	}
+
+	if got := (&HTTPError{Code: 404, Msg: "no such page"}).Error(); got != "404 no such page" {
+		t.Errorf("unexpected error message %#v", got)
+	}
+}
+
+func setErr(err error) Wrapper {
+	return NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		SetError(rw, err)
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func TestHandleError(t *testing.T) {
+	ValidateWrapperContexts(&context{}, HandleError{})
+
+	tests := []struct {
+		h    http.Handler
+		body string
+		code int
+	}{
+		{Stack(&context{}, setErr(&HTTPError{Code: 404, Msg: "gone"}), HandleError{}, Handler(write("never"))), "gone", 404},
+		{Stack(&context{}, setErr(errors.New("secret")), HandleError{}, Handler(write("never"))), "Internal Server Error", 500},
+		{Stack(&context{}, setErr(errors.New("secret")), HandleError{Dev: true}), "Internal Server Error\n\nsecret", 500},
+		{Stack(&context{}, HandleError{}, setErr(&HTTPError{Code: 400})), "Bad Request", 400},
+		{Stack(&context{}, HandleError{}, Handler(write("ok"))), "ok", 200},
+		{Stack(&context{}, HandleError{}, write("written"), setErr(&HTTPError{Code: 400})), "written", 200},
+	}
+
+	for i, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		test.h.ServeHTTP(rec, req)
+		if rec.Body.String() != test.body+"\n" && rec.Body.String() != test.body {
+			t.Errorf("[%d] body should be %#v, but is %#v", i, test.body, rec.Body.String())
+		}
+		if rec.Code != test.code {
+			t.Errorf("[%d] code should be %d, but is %d", i, test.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Default delimiters of include directives, matching the syntax of server side includes, e.g.
+//
+//	<!--#include virtual="footer" -->
+const (
+	DefaultIncludeOpen  = `<!--#include virtual="`
+	DefaultIncludeClose = `" -->`
+)
+
+// maxIncludeDirective is the maximal length of the name of an include directive. Longer "directives"
+// are written unchanged, so that an unclosed opening delimiter does not hold back the rest of the body.
+const maxIncludeDirective = 1024
+
+// Include is a Wrapper that substitutes include directives in the bodies written by the next handlers
+// by the bodies of named handlers, allowing edge side include style composition of pages inside a stack.
+// A directive is the name of a handler between the Open and the Close delimiter.
+//
+// The body is scanned while it is written, only a possible partial directive at the end of a write is held
+// back. For each directive, the named handler is served with a clone of the request (without body) and a
+// Buffer (so it shares the context of the response). Its body is written in place of the directive, if its
+// status code is 2xx, otherwise the directive is removed. Directives with unknown names are written unchanged.
+// The bodies of the included handlers are not scanned for directives, to include nested directives the
+// included handlers may be wrapped with an Include themselves.
+//
+// Only responses with a matching Content-Type are scanned (see BodyTransformer for the format of the
+// ContentTypes). The Content-Length header of scanned responses is removed. Responses to HEAD requests
+// and responses with a Content-Encoding are not scanned.
+type Include struct {
+	// Handlers are the handlers that can be included, by name
+	Handlers map[string]http.Handler
+
+	// Open and Close are the delimiters of the directives, DefaultIncludeOpen and DefaultIncludeClose if empty
+	Open, Close string
+
+	// ContentTypes are the media types of responses that are scanned, "text/html" if empty
+	ContentTypes []string
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Include{}
+
+// includeWriter is the ResponseWriter passed to the next handlers by Include
+type includeWriter struct {
+	*Peek
+	include     *Include
+	req         *http.Request
+	open, close []byte
+	head        bool
+	started     bool
+	scan        bool
+
+	// inDirective is true, if the opening delimiter has been written and the closing delimiter is missing
+	inDirective bool
+
+	// pending are the held back bytes (after the opening delimiter, if inDirective)
+	pending []byte
+}
+
+// start decides if the response is scanned
+func (i *includeWriter) start(b []byte) {
+	i.started = true
+	header := i.Peek.Header()
+	if i.head || header.Get("Content-Encoding") != "" {
+		return
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(b)
+		header.Set("Content-Type", ct)
+	}
+	mediaType, _, _ := strings.Cut(ct, ";")
+	types := i.include.ContentTypes
+	if len(types) == 0 {
+		types = []string{"text/html"}
+	}
+	bt := BodyTransformer{ContentTypes: types}
+	if bt.matches(strings.ToLower(strings.TrimSpace(mediaType))) {
+		header.Del("Content-Length")
+		i.scan = true
+	}
+}
+
+// Write writes the body, substituting the directives
+func (i *includeWriter) Write(b []byte) (int, error) {
+	if !i.started {
+		i.start(b)
+	}
+	if !i.scan {
+		return i.Peek.Write(b)
+	}
+	data := b
+	if len(i.pending) > 0 {
+		data = append(i.pending, b...)
+		i.pending = nil
+	}
+
+	for {
+		if !i.inDirective {
+			idx := bytes.Index(data, i.open)
+			if idx < 0 {
+				keep := partialSuffix(data, i.open)
+				if _, err := i.Peek.Write(data[:len(data)-keep]); err != nil {
+					return 0, err
+				}
+				i.pending = append([]byte(nil), data[len(data)-keep:]...)
+				return len(b), nil
+			}
+			if _, err := i.Peek.Write(data[:idx]); err != nil {
+				return 0, err
+			}
+			data = data[idx+len(i.open):]
+			i.inDirective = true
+		}
+
+		idx := bytes.Index(data, i.close)
+		if idx < 0 {
+			if len(data) > maxIncludeDirective+len(i.close) {
+				// no directive, write it unchanged
+				i.inDirective = false
+				if _, err := i.Peek.Write(i.open); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			i.pending = append([]byte(nil), data...)
+			return len(b), nil
+		}
+		if err := i.substitute(data[:idx]); err != nil {
+			return 0, err
+		}
+		data = data[idx+len(i.close):]
+		i.inDirective = false
+	}
+}
+
+// substitute writes the body of the handler of the directive with the given name
+func (i *includeWriter) substitute(name []byte) error {
+	h, ok := i.include.Handlers[strings.TrimSpace(string(name))]
+	if !ok {
+		_, err := i.Peek.Write(bytes.Join([][]byte{i.open, name, i.close}, nil))
+		return err
+	}
+	req := i.req.Clone(i.req.Context())
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	buf := NewBuffer(i.Peek)
+	h.ServeHTTP(buf, req)
+	if !buf.IsOk() {
+		return nil
+	}
+	_, err := i.Peek.Write(buf.Body())
+	return err
+}
+
+// partialSuffix returns the length of the longest suffix of data that is a prefix of delim
+func partialSuffix(data, delim []byte) int {
+	n := len(delim) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if bytes.HasSuffix(data, delim[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// finish writes the held back bytes
+func (i *includeWriter) finish() {
+	if i.inDirective {
+		i.Peek.Write(i.open)
+	}
+	if len(i.pending) > 0 {
+		i.Peek.Write(i.pending)
+	}
+	i.Peek.FlushMissing()
+}
+
+// ReadFrom writes the content of r. If the response is not scanned, r is passed to the
+// underlying ResponseWriter (see Peek.ReadFrom), so that files may be served via sendfile.
+func (i *includeWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !i.started && i.Peek.Header().Get("Content-Type") != "" {
+		i.start(nil)
+	}
+	if i.started && !i.scan {
+		return i.Peek.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{i}, r)
+}
+
+// Flush flushes the underlying ResponseWriter. Held back bytes of a possible directive are not flushed.
+func (i *includeWriter) Flush() {
+	i.Peek.FlushMissing()
+	flushInner(i.Peek.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (i *includeWriter) Unwrap() http.ResponseWriter {
+	return i.Peek.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (in Include) Wrap(next http.Handler) http.Handler {
+	open, close := in.Open, in.Close
+	if open == "" {
+		open = DefaultIncludeOpen
+	}
+	if close == "" {
+		close = DefaultIncludeClose
+	}
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		iw := &includeWriter{
+			Peek: NewPeek(rw, func(p *Peek) bool {
+				p.FlushMissing()
+				return true
+			}),
+			include: &in,
+			req:     req,
+			open:    []byte(open),
+			close:   []byte(close),
+			head:    req.Method == http.MethodHead,
+		}
+		next.ServeHTTP(iw, req)
+		iw.finish()
+	}
+	return f
+}
@@ -0,0 +1,62 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func writeChunks(chunks ...string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html")
+		for _, c := range chunks {
+			rw.Write([]byte(c))
+		}
+	})
+}
+
+var includeHandlers = map[string]http.Handler{
+	"header": writeString("HEAD"),
+	"path": http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Method + " " + req.URL.Path))
+	}),
+	"missing": http.NotFoundHandler(),
+}
+
+func TestInclude(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		body   string
+	}{
+		{[]string{`a<!--#include virtual="header" -->b`}, "aHEADb"},
+		{[]string{`a<!--#incl`, `ude virtual="hea`, `der" `, `-->b`}, "aHEADb"},
+		{[]string{`<!--#include virtual="path" --><!--#include virtual=" header " -->`}, "GET /pageHEAD"},
+		{[]string{`a<!--#include virtual="missing" -->b`}, "ab"},
+		{[]string{`a<!--#include virtual="unknown" -->b`}, `a<!--#include virtual="unknown" -->b`},
+		{[]string{`a<!--#include virtual="x`}, `a<!--#include virtual="x`},
+		{[]string{`a<!--`}, `a<!--`},
+	}
+
+	for _, test := range tests {
+		h := New(Include{Handlers: includeHandlers}, Handler(writeChunks(test.chunks...)))
+		rec, req := newTestRequest("POST", "/page")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, 200)
+	}
+}
+
+func TestIncludeDelimiters(t *testing.T) {
+	h := New(Include{Handlers: includeHandlers, Open: "{{", Close: "}}", ContentTypes: []string{"text/*"}}, writeString("a{{header}}b"))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "aHEADb", 200)
+}
+
+func TestIncludeSkip(t *testing.T) {
+	h := New(Include{Handlers: includeHandlers}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`<!--#include virtual="header" -->`))
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, `<!--#include virtual="header" -->`, 200)
+}
@@ -0,0 +1,140 @@
+package wrap
+
+// This file contains an adapter that uses reflection. It is meant to ease the migration of
+// martini/inject style handlers and is slower than the other adapters.
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+var (
+	responseWriterType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+	requestType        = reflect.TypeOf((*http.Request)(nil))
+	handlerType        = reflect.TypeOf((*http.Handler)(nil)).Elem()
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	stringType         = reflect.TypeOf("")
+)
+
+// injector is the ContextWrapper returned by Inject
+type injector struct {
+	fn      reflect.Value
+	hasNext bool
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = &injector{}
+
+// Inject returns a ContextWrapper for a function with an arbitrary parameter list. It uses reflection.
+//
+// The parameters are resolved by their type: http.ResponseWriter, *http.Request and http.Handler
+// (the next handler) are passed directly, every other type is taken from the Contexter of the stack
+// (the zero value if the Contexter has none).
+//
+// The function may return nothing, a string that is written to the response, an error or a string and an error.
+// A non nil error is stored as context type error (see SetError and HandleError), or written as
+// 500 Internal Server Error if the ResponseWriter is no Contexter.
+//
+// If the function has no http.Handler parameter, the next handler is run after it (unless it returned an error).
+//
+// Inject panics if fn is no function or has unsupported return types. ValidateContext checks that the
+// Contexter supports all the other parameter types.
+func Inject(fn interface{}) ContextWrapper {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("wrap.Inject: %T is no function", fn))
+	}
+	t := v.Type()
+	switch {
+	case t.NumOut() == 0:
+	case t.NumOut() == 1 && (t.Out(0) == stringType || t.Out(0) == errorType):
+	case t.NumOut() == 2 && t.Out(0) == stringType && t.Out(1) == errorType:
+	default:
+		panic(fmt.Sprintf("wrap.Inject: unsupported return types of %T", fn))
+	}
+	inj := &injector{fn: v}
+	for i := 0; i < t.NumIn(); i++ {
+		if t.In(i) == handlerType {
+			inj.hasNext = true
+		}
+	}
+	return inj
+}
+
+// contextTypes returns the parameter types that are resolved by the Contexter
+func (inj *injector) contextTypes() (types []reflect.Type) {
+	t := inj.fn.Type()
+	for i := 0; i < t.NumIn(); i++ {
+		switch in := t.In(i); in {
+		case responseWriterType, requestType, handlerType:
+		default:
+			types = append(types, in)
+		}
+	}
+	return
+}
+
+// ValidateContext makes sure that ctx supports the types of the parameters
+func (inj *injector) ValidateContext(ctx Contexter) {
+	for _, ty := range inj.contextTypes() {
+		ctx.Context(reflect.New(ty).Interface())
+	}
+}
+
+// call calls the function and returns the string and error results
+func (inj *injector) call(next http.Handler, rw http.ResponseWriter, req *http.Request) (body string, err error) {
+	t := inj.fn.Type()
+	args := make([]reflect.Value, t.NumIn())
+	for i := range args {
+		switch in := t.In(i); in {
+		case responseWriterType:
+			args[i] = reflect.ValueOf(&rw).Elem()
+		case requestType:
+			args[i] = reflect.ValueOf(req)
+		case handlerType:
+			args[i] = reflect.ValueOf(&next).Elem()
+		default:
+			ptr := reflect.New(in)
+			if ctx, ok := rw.(Contexter); ok {
+				ctx.Context(ptr.Interface())
+			}
+			args[i] = ptr.Elem()
+		}
+	}
+
+	for _, out := range inj.fn.Call(args) {
+		switch out.Type() {
+		case stringType:
+			body = out.String()
+		case errorType:
+			if !out.IsNil() {
+				err = out.Interface().(error)
+			}
+		}
+	}
+	return
+}
+
+// Wrap implements the Wrapper interface
+func (inj *injector) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		body, err := inj.call(next, rw, req)
+		if body != "" {
+			rw.Write([]byte(body))
+		}
+		if err != nil {
+			if _, ok := rw.(Contexter); ok {
+				SetError(rw, err)
+				return
+			}
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !inj.hasNext {
+			next.ServeHTTP(rw, req)
+		}
+	}
+	return f
+}
@@ -0,0 +1,62 @@
+package wrap
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestInject(t *testing.T) {
+	ipOf := Inject(func(ip userIP) string {
+		return net.IP(ip).String()
+	})
+	ValidateWrapperContexts(&context{}, ipOf)
+
+	h := Stack(&context{}, setUserIP{}, ipOf, Inject(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(" " + req.URL.Path))
+	}))
+	rec, req := newTestRequest("GET", "/path")
+	req.RemoteAddr = "127.0.0.1:45643"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1 /path", 200)
+}
+
+func TestInjectNextAndError(t *testing.T) {
+	stop := Inject(func(next http.Handler) (string, error) {
+		return "", &HTTPError{Code: 403}
+	})
+	h := Stack(&context{}, HandleError{}, stop, writeStop("never"))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "Forbidden", 403)
+
+	rec, req = newTestRequest("GET", "/")
+	New(Inject(func() error { return errors.New("x") }), writeStop("never")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "Internal Server Error", 500)
+
+	rec, req = newTestRequest("GET", "/")
+	New(Inject(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("a"))
+		next.ServeHTTP(rw, req)
+	}), writeStop("b")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "ab", 200)
+}
+
+func TestInjectValidation(t *testing.T) {
+	err := ValidateWrapperContextsAll(&requestIDContext{}, Inject(func(ip userIP, id RequestID) {}))
+	if !errors.Is(err, ErrUnsupportedContext) {
+		t.Errorf("userIP should not be supported, got %v", err)
+	}
+
+	for _, fn := range []interface{}{42, func() int { return 1 }} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Inject(%T) should panic", fn)
+				}
+			}()
+			Inject(fn)
+		}()
+	}
+}
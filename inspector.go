@@ -0,0 +1,168 @@
+package wrap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+)
+
+// Inspectable is a Wrapper with a runtime state that is shown by the InspectorHandler
+type Inspectable interface {
+	// InspectState returns a short one line description of the current state
+	InspectState() string
+}
+
+// typeRecorder is a Contexter that records the types that are passed to it
+type typeRecorder struct {
+	http.ResponseWriter
+	types []reflect.Type
+}
+
+func (t *typeRecorder) record(ctxPtr interface{}) {
+	ty := reflect.TypeOf(ctxPtr)
+	if ty == nil {
+		return
+	}
+	if ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	for _, known := range t.types {
+		if known == ty {
+			return
+		}
+	}
+	t.types = append(t.types, ty)
+}
+
+// Context records the type and returns false
+func (t *typeRecorder) Context(ctxPtr interface{}) bool {
+	t.record(ctxPtr)
+	return false
+}
+
+// SetContext records the type
+func (t *typeRecorder) SetContext(ctxPtr interface{}) {
+	t.record(ctxPtr)
+}
+
+// contextRequirements returns the context types required by the given wrapper, if it
+// is a ContextWrapper
+func contextRequirements(w Wrapper) (types []reflect.Type) {
+	cw, ok := w.(ContextWrapper)
+	if !ok {
+		return nil
+	}
+	rec := &typeRecorder{}
+	defer func() {
+		recover()
+		types = rec.types
+	}()
+	cw.ValidateContext(rec)
+	return
+}
+
+// contextSupports returns if the Contexter injected by inject supports getting and setting
+// the given type. It is probed by serving a synthetic request.
+func contextSupports(inject ContextInjecter, ty reflect.Type) (supported bool) {
+	var probe http.HandlerFunc
+	probe = func(rw http.ResponseWriter, req *http.Request) {
+		ctx, ok := rw.(Contexter)
+		if !ok {
+			return
+		}
+		defer func() {
+			if recover() != nil {
+				supported = false
+			}
+		}()
+		ctx.Context(reflect.New(ty).Interface())
+		ctx.SetContext(reflect.New(ty).Interface())
+		supported = true
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	inject.Wrap(probe).ServeHTTP(httptest.NewRecorder(), req)
+	return
+}
+
+// inspect writes the topology of the named stack to bf
+func (ns *namedStack) inspect(bf *bytes.Buffer) {
+	ms := describeStack(ns.name, ns.inject, ns.wrappers)
+	fmt.Fprintf(bf, "stack %q\n", ms.Name)
+	if ns.inject != nil {
+		fmt.Fprintf(bf, "  -  %s (Contexter)\n", ms.Contexter)
+	}
+
+	for i, w := range ms.Wrappers {
+		fmt.Fprintf(bf, "  %d  %s", i, w.Type)
+		if w.hasRole(roleContexter) {
+			bf.WriteString(" (Contexter)")
+		}
+		if len(w.Requires) > 0 {
+			fmt.Fprintf(bf, " requires: %s", strings.Join(w.Requires, ", "))
+		}
+		if w.hasRole(roleInspectable) {
+			fmt.Fprintf(bf, " [%s]", w.State)
+		}
+		bf.WriteString("\n")
+	}
+
+	if ms.Contexter == "" {
+		if len(ms.Missing) > 0 {
+			fmt.Fprintf(bf, "  no Contexter, missing context types: %s\n", strings.Join(ms.Missing, ", "))
+		}
+		return
+	}
+	fmt.Fprintf(bf, "  provided context types: %s\n", strings.Join(ms.Provided, ", "))
+	if len(ms.Missing) > 0 {
+		fmt.Fprintf(bf, "  missing context types: %s\n", strings.Join(ms.Missing, ", "))
+	}
+}
+
+func containsType(types []reflect.Type, ty reflect.Type) bool {
+	for _, t := range types {
+		if t == ty {
+			return true
+		}
+	}
+	return false
+}
+
+// InspectorHandler returns a http.Handler that renders the topology of every stack that has been
+// registered with Named or NamedStack: the order of the wrappers, the Contexter of the stack and
+// the context types that are required by the wrappers (via ValidateContext) and provided or missing
+// in the Contexter. The state of Inspectable wrappers is shown as well. Like the expvar and pprof handlers it is meant to be mounted on a
+// path like /debug/wrap that is not public.
+//
+// If the request has the query parameter format=json or accepts application/json, the stacks are served
+// as JSON manifest (see Manifest).
+func InspectorHandler() http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+			m := manifest{Stacks: []*manifestStack{}}
+			for _, ns := range registered() {
+				m.Stacks = append(m.Stacks, describeStack(ns.name, ns.inject, ns.wrappers))
+			}
+			data, err := json.MarshalIndent(m, "", "  ")
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write(data)
+			return
+		}
+		var bf bytes.Buffer
+		for _, ns := range registered() {
+			ns.inspect(&bf)
+			bf.WriteString("\n")
+		}
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		bf.WriteTo(rw)
+	}
+	return f
+}
@@ -0,0 +1,34 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspectorHandler(t *testing.T) {
+	NamedStack("test-inspect", &context{}, setUserIP{}, handleError{}, app{}, SetRequestID{})
+	Named("test-inspect-plain", write("a"), app{})
+
+	rec, req := newTestRequest("GET", "/debug/wrap")
+	InspectorHandler().ServeHTTP(rec, req)
+
+	expected := []string{`stack "test-inspect"
+  -  *wrap.context (Contexter)
+  0  wrap.setUserIP requires: wrap.userIP, error
+  1  wrap.handleError requires: error
+  2  wrap.app requires: wrap.userIP
+  3  wrap.SetRequestID requires: wrap.RequestID
+  provided context types: wrap.userIP, error
+  missing context types: wrap.RequestID
+`, `stack "test-inspect-plain"
+  0  wrap.write
+  1  wrap.app requires: wrap.userIP
+  no Contexter, missing context types: wrap.userIP
+`}
+
+	for _, exp := range expected {
+		if !strings.Contains(rec.Body.String(), exp) {
+			t.Errorf("expected output to contain\n%s\ngot\n%s", exp, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package vetcheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// wantComment matches the expectation comments of RunTest
+var wantComment = regexp.MustCompile(`//\s*want\s+(".*")\s*$`)
+
+// RunTest runs the checks on the package inside dir and compares the diagnostics with the
+// expectations inside the source files: a comment like
+//
+//	// want "regular expression"
+//
+// at the end of a line expects a diagnostic at this line with a message matching the regular expression.
+// Diagnostics without expectation and expectations without diagnostic fail the test.
+func RunTest(t *testing.T, dir string, checks ...*Check) {
+	t.Helper()
+	diagnostics, err := RunDir(dir, checks...)
+	if err != nil {
+		t.Fatalf("can't run checks on %s: %s", dir, err)
+	}
+
+	wants, err := expectations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range diagnostics {
+		key := fmt.Sprintf("%s:%d", filepath.Base(d.Pos.Filename), d.Pos.Line)
+		re, ok := wants[key]
+		if !ok {
+			t.Errorf("unexpected diagnostic %s", d)
+			continue
+		}
+		if !re.MatchString(d.Message) {
+			t.Errorf("diagnostic %s does not match %q", d, re)
+		}
+		delete(wants, key)
+	}
+
+	for key, re := range wants {
+		t.Errorf("%s: missing diagnostic matching %q", key, re)
+	}
+}
+
+// expectations returns the regular expressions of the want comments of the Go files in dir by file:line
+func expectations(dir string) (map[string]*regexp.Regexp, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	wants := map[string]*regexp.Regexp{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			m := wantComment.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			expr, err := strconv.Unquote(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid want comment: %s", file, i+1, err)
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid want comment: %s", file, i+1, err)
+			}
+			wants[fmt.Sprintf("%s:%d", filepath.Base(file), i+1)] = re
+		}
+	}
+	return wants, nil
+}
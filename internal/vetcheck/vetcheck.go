@@ -0,0 +1,324 @@
+// Package vetcheck runs static checks on type-checked packages, either as a tool of
+//
+//	go vet -vettool=$(which wrapvet) ./...
+//
+// or standalone on package directories. It implements the minimal subset of the protocol of
+// golang.org/x/tools/go/analysis/unitchecker that "go vet" requires, with the standard library only,
+// so that the checks of this module do not add dependencies. The API of the checks is modelled after
+// the go/analysis API (Check ≈ Analyzer, Pass ≈ Pass), to ease a later migration.
+package vetcheck
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Check is a static check of a package
+type Check struct {
+	// Name is the name of the check, used as prefix of its diagnostics
+	Name string
+
+	// Doc describes the check
+	Doc string
+
+	// Run runs the check on the package of the pass and reports the diagnostics via the pass
+	Run func(*Pass)
+}
+
+// Pass is the package a Check runs on
+type Pass struct {
+	Check     *Check
+	Fset      *token.FileSet
+	Files     []*ast.File
+	Pkg       *types.Package
+	TypesInfo *types.Info
+
+	diagnostics *[]Diagnostic
+}
+
+// Reportf reports a diagnostic at the given position
+func (p *Pass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Pos:     p.Fset.Position(pos),
+		Check:   p.Check.Name,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Diagnostic is a problem found by a Check
+type Diagnostic struct {
+	Pos     token.Position
+	Check   string
+	Message string
+}
+
+// String returns the diagnostic in the format of go vet
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Check, d.Message)
+}
+
+// config is the subset of the configuration passed by go vet that is needed to type check a package
+type config struct {
+	ID          string
+	ImportPath  string
+	GoVersion   string
+	Compiler    string
+	GoFiles     []string
+	ImportMap   map[string]string
+	PackageFile map[string]string
+	VetxOnly    bool
+	VetxOutput  string
+	Stdout      string
+}
+
+// readConfig reads the configuration file of go vet
+func readConfig(file string) (*config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode JSON config file %s: %v", file, err)
+	}
+	return cfg, nil
+}
+
+// run parses and type checks the files of a package and runs the checks on it
+func run(path, goVersion string, fset *token.FileSet, filenames []string, imp types.Importer, checks []*Check) ([]Diagnostic, error) {
+	var files []*ast.File
+	for _, name := range filenames {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	tc := &types.Config{Importer: imp, GoVersion: goVersion}
+	pkg, err := tc.Check(path, fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []Diagnostic
+	for _, c := range checks {
+		c.Run(&Pass{Check: c, Fset: fset, Files: files, Pkg: pkg, TypesInfo: info, diagnostics: &diagnostics})
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Pos, diagnostics[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return diagnostics, nil
+}
+
+// RunConfig runs the checks on the package described by the given configuration file of go vet
+func RunConfig(file string, checks ...*Check) ([]Diagnostic, error) {
+	cfg, err := readConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	// no facts are exported, but go vet expects the file
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0666); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.VetxOnly || len(cfg.GoFiles) == 0 {
+		return nil, nil
+	}
+	fset := token.NewFileSet()
+	compilerImporter := importer.ForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
+		file, ok := cfg.PackageFile[path]
+		if !ok {
+			return nil, fmt.Errorf("no package file for %q", path)
+		}
+		return os.Open(file)
+	})
+	imp := importerFunc(func(importPath string) (*types.Package, error) {
+		path, ok := cfg.ImportMap[importPath]
+		if !ok {
+			return nil, fmt.Errorf("can't resolve import %q", importPath)
+		}
+		return compilerImporter.Import(path)
+	})
+	return run(cfg.ImportPath, cfg.GoVersion, fset, cfg.GoFiles, imp, checks)
+}
+
+// RunDir runs the checks on the package inside the given directory (without test files),
+// type checking the imported packages from source.
+func RunDir(dir string, checks ...*Check) ([]Diagnostic, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var filenames []string
+	for _, name := range bp.GoFiles {
+		filenames = append(filenames, filepath.Join(dir, name))
+	}
+	fset := token.NewFileSet()
+	return run(bp.ImportPath, "", fset, filenames, importer.ForCompiler(fset, "source", nil), checks)
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// Main is the main function of a vet tool running the given checks. It supports the flags
+// -V=full, -flags and -json that go vet requires and runs the checks either on the configuration file
+// passed by go vet or on the package directories passed as arguments. It prints the diagnostics
+// to stderr (or as JSON to stdout) and exits with 1 if there are any.
+func Main(checks ...*Check) {
+	progname := filepath.Base(os.Args[0])
+	log.SetFlags(0)
+	log.SetPrefix(progname + ": ")
+
+	flag.Var(versionFlag{}, "V", "print version and exit")
+	printFlags := flag.Bool("flags", false, "print flags in JSON")
+	jsonOut := flag.Bool("json", false, "emit JSON output")
+	// flags of go vet that have no effect
+	flag.Int("c", -1, "no effect")
+	flag.Bool("fix", false, "no effect, there are no suggested fixes")
+	flag.Bool("diff", false, "no effect, there are no suggested fixes")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s checks the usage of github.com/go-on/wrap.\n\nUsage:\n", progname)
+		fmt.Fprintf(os.Stderr, "\tgo vet -vettool=$(which %s) packages\n\t%s directories\n\nChecks:\n", progname, progname)
+		for _, c := range checks {
+			fmt.Fprintf(os.Stderr, "\t%s: %s\n", c.Name, strings.SplitN(c.Doc, "\n", 2)[0])
+		}
+		os.Exit(2)
+	}
+	flag.Parse()
+
+	if *printFlags {
+		// the checks have no flags
+		os.Stdout.Write([]byte("[]"))
+		os.Exit(0)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+	}
+
+	// diagnostics by package id
+	diagnostics := map[string][]Diagnostic{}
+	var ids []string
+	for _, arg := range args {
+		id := arg
+		var ds []Diagnostic
+		var err error
+		if strings.HasSuffix(arg, ".cfg") {
+			var cfg *config
+			if cfg, err = readConfig(arg); err == nil {
+				id = cfg.ID
+				if cfg.Stdout != "" {
+					if os.Stdout, err = os.Create(cfg.Stdout); err != nil {
+						log.Fatal(err)
+					}
+				}
+				ds, err = RunConfig(arg, checks...)
+			}
+		} else {
+			ds, err = RunDir(arg, checks...)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(ds) > 0 {
+			ids = append(ids, id)
+			diagnostics[id] = ds
+		}
+	}
+
+	if *jsonOut {
+		printJSON(diagnostics)
+		return
+	}
+	for _, id := range ids {
+		for _, d := range diagnostics[id] {
+			fmt.Fprintln(os.Stderr, d)
+		}
+	}
+	if len(ids) > 0 {
+		os.Exit(1)
+	}
+}
+
+// jsonDiagnostic is the JSON schema of a diagnostic expected by go vet
+type jsonDiagnostic struct {
+	Posn    string `json:"posn"`
+	End     string `json:"end"`
+	Message string `json:"message"`
+}
+
+// printJSON prints the diagnostics to stdout as map of package id to check name to diagnostics,
+// like the unitchecker of golang.org/x/tools does
+func printJSON(diagnostics map[string][]Diagnostic) {
+	if len(diagnostics) == 0 {
+		return
+	}
+	tree := map[string]map[string][]jsonDiagnostic{}
+	for id, ds := range diagnostics {
+		tree[id] = map[string][]jsonDiagnostic{}
+		for _, d := range ds {
+			tree[id][d.Check] = append(tree[id][d.Check], jsonDiagnostic{Posn: d.Pos.String(), End: d.Pos.String(), Message: d.Check + ": " + d.Message})
+		}
+	}
+	data, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(data)
+}
+
+// versionFlag implements the -V=full protocol of go vet, which prints a version
+// that changes whenever the executable changes
+type versionFlag struct{}
+
+func (versionFlag) IsBoolFlag() bool { return true }
+func (versionFlag) String() string   { return "" }
+func (versionFlag) Set(s string) error {
+	if s != "full" {
+		return fmt.Errorf("unsupported flag value: -V=%s (use -V=full)", s)
+	}
+	progname, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(progname)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	f.Close()
+	fmt.Printf("%s version devel comments-go-here buildID=%02x\n", progname, string(h.Sum(nil)))
+	os.Exit(0)
+	return nil
+}
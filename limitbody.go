@@ -0,0 +1,103 @@
+package wrap
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// LimitBody is a Wrapper that limits the size of the request body via http.MaxBytesReader.
+//
+// If the body is larger than Max, reading it fails with a *http.MaxBytesError (see BodyTooLarge): if the
+// Content-Length exceeds Max, already the first read fails without reading anything, otherwise the read
+// that crosses the limit fails. The next handler decides how to respond. If it has not written anything,
+// a HandleError before LimitBody renders a 413 Request Entity Too Large, since LimitBody then stores a
+// HTTPError, if the ResponseWriter is a Contexter supporting the error type (see SetError).
+//
+// A LimitBody overrides the limit of a LimitBody that ran before, so a route specific limit (see Mux)
+// may be larger than the limit of the base stack. The limit is shared by the LimitBody wrappers of a
+// request and applies to the body that the first of them has limited, so that bodies installed in
+// between (e.g. by BufferBody) are kept.
+type LimitBody struct {
+	// Max is the maximal number of bytes of the body
+	Max int64
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = LimitBody{}
+
+// BodyTooLarge returns if the error was caused by reading a body limited by LimitBody beyond its limit
+func BodyTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
+
+// bodyLimit is the limit that the LimitBody wrappers of a request share, it is stored inside the request
+type bodyLimit struct {
+	max int64
+
+	// exceeded is true if a read failed because of the limit
+	exceeded bool
+}
+
+// limitedBody is a request body limited by a bodyLimit. The http.MaxBytesReader doing the work is created
+// by the first read and recreated with the remaining bytes, if the limit changes afterwards.
+type limitedBody struct {
+	io.ReadCloser
+	rw            http.ResponseWriter
+	limit         *bodyLimit
+	contentLength int64
+
+	// reader is the http.MaxBytesReader for the limit max, read is the number of bytes read so far
+	reader io.Reader
+	max    int64
+	read   int64
+}
+
+// Read reads from the body, failing with a *http.MaxBytesError beyond the limit
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.reader == nil || b.max != b.limit.max {
+		b.max = b.limit.max
+		if b.contentLength > b.max {
+			return 0, b.exceeded()
+		}
+		b.reader = http.MaxBytesReader(b.rw, b.ReadCloser, b.max-b.read)
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if BodyTooLarge(err) {
+		err = b.exceeded()
+	}
+	return n, err
+}
+
+// exceeded marks the limit as exceeded and returns the error for it
+func (b *limitedBody) exceeded() error {
+	b.limit.exceeded = true
+	return &http.MaxBytesError{Limit: b.max}
+}
+
+// Wrap implements the Wrapper interface
+func (l LimitBody) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if req.Body == nil || req.Body == http.NoBody {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		if limit, has := requestValue(req, bodyLimitKey).(*bodyLimit); has {
+			limit.max = l.Max
+			next.ServeHTTP(rw, req)
+			return
+		}
+		limit := &bodyLimit{max: l.Max}
+		req = withRequestValue(req, bodyLimitKey, limit)
+		req.Body = &limitedBody{ReadCloser: req.Body, rw: rw, limit: limit, contentLength: req.ContentLength}
+		next.ServeHTTP(rw, req)
+		if limit.exceeded {
+			var err error = &HTTPError{Code: http.StatusRequestEntityTooLarge, Err: &http.MaxBytesError{Limit: limit.max}}
+			TrySetContext(rw, &err)
+		}
+	}
+	return f
+}
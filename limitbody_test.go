@@ -0,0 +1,82 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(rw http.ResponseWriter, req *http.Request) {
+	b, err := io.ReadAll(req.Body)
+	if BodyTooLarge(err) {
+		http.Error(rw, "too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	rw.Write(b)
+}
+
+// readOnly reads the body without writing anything
+func readOnly(rw http.ResponseWriter, req *http.Request) {
+	io.ReadAll(req.Body)
+}
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		h    http.Handler
+		body string
+		size int64
+		exp  string
+		code int
+	}{
+		{New(LimitBody{Max: 5}, HandlerFunc(readAll)), "12345", 5, "12345", 200},
+		{New(LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", 6, "too large", 413},
+		{New(LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", -1, "too large", 413},
+		{Stack(&context{}, HandleError{}, LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", 6, "too large", 413},
+		{Stack(&context{}, HandleError{}, LimitBody{Max: 5}, HandlerFunc(readOnly)), "123456", 6, "Request Entity Too Large", 413},
+		{Stack(&context{}, HandleError{}, LimitBody{Max: 5}, HandlerFunc(readOnly)), "123456", -1, "Request Entity Too Large", 413},
+		{Stack(&context{}, HandleError{Dev: true}, Timeout{Duration: time.Second}, LimitBody{Max: 5}, HandlerFunc(readOnly)), "123456", 6, "Request Entity Too Large\n\n413 Request Entity Too Large: http: request body too large", 413},
+		{Stack(&context{}, LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", 6, "too large", 413},
+		{New(LimitBody{Max: 5}, LimitBody{Max: 10}, HandlerFunc(readAll)), "123456", -1, "123456", 200},
+		{New(LimitBody{Max: 5}, LimitBody{Max: 10}, HandlerFunc(readAll)), "123456", 6, "123456", 200},
+		{New(LimitBody{Max: 10}, LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", -1, "too large", 413},
+		{New(LimitBody{Max: 10}, LimitBody{Max: 5}, HandlerFunc(readAll)), "123456", 6, "too large", 413},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.ContentLength = test.size
+		test.h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
+
+func TestLimitBodyKeepsInstalledBody(t *testing.T) {
+	// a body installed between two LimitBody wrappers is limited by the inner one
+	sniff := NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		head := make([]byte, 2)
+		n, _ := io.ReadFull(req.Body, head)
+		req.Body = &peekedBody{Reader: io.MultiReader(strings.NewReader(strings.ToUpper(string(head[:n]))), req.Body), Closer: req.Body}
+		next.ServeHTTP(rw, req)
+	})
+
+	tests := []struct {
+		inner int64
+		exp   string
+		code  int
+	}{
+		{10, "AB3456", 200},
+		{4, "too large", 413},
+	}
+
+	for _, test := range tests {
+		h := New(LimitBody{Max: 5}, sniff, LimitBody{Max: test.inner}, HandlerFunc(readAll))
+		rec, _ := newTestRequest("POST", "/")
+		req, _ := http.NewRequest("POST", "/", strings.NewReader("ab3456"))
+		req.ContentLength = -1
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
@@ -0,0 +1,85 @@
+package wrap
+
+import (
+	"net/http"
+	"time"
+)
+
+// LongPoll is a Wrapper that holds requests until there is something to respond (long polling).
+//
+// It subscribes to the events of the request via Subscribe and runs the next handlers with a Buffer
+// (which keeps the Contexter). If they respond with nothing or with 204 No Content, the request is held
+// until an event fires, then the next handlers are run again (with a new Buffer). This is repeated until
+// they respond with something else, which is flushed, or until MaxHold has elapsed since the request came in,
+// in which case the last empty response is flushed (204 No Content if nothing has been written). If the client
+// goes away while the request is held (see Done), nothing is written. Subscribing before the next handlers
+// are run for the first time ensures that events between their check and the hold are not missed.
+//
+// The response of the next handlers is buffered, so LongPoll is not suitable for streaming responses.
+// Upgrade requests (see IsUpgrade) are passed to the next handlers unchanged.
+type LongPoll struct {
+	// Subscribe returns a channel that receives a value (or is closed) when an event for the request fires,
+	// and a function that ends the subscription, which is called when the request has been answered.
+	Subscribe func(req *http.Request) (events <-chan struct{}, unsubscribe func())
+
+	// MaxHold is the maximum time a request is held. Defaults to 30 seconds.
+	MaxHold time.Duration
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = LongPoll{}
+
+func (l LongPoll) maxHold() time.Duration {
+	if l.MaxHold > 0 {
+		return l.MaxHold
+	}
+	return 30 * time.Second
+}
+
+// isEmptyPoll returns if the buffered response has nothing to respond
+func isEmptyPoll(bf *Buffer) bool {
+	return bf.Code == http.StatusNoContent || (bf.Code == 0 && bf.Buffer.Len() == 0)
+}
+
+// Wrap implements the Wrapper interface
+func (l LongPoll) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsProbe(req) || IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		events, unsubscribe := l.Subscribe(req)
+		defer unsubscribe()
+
+		hold := time.NewTimer(l.maxHold())
+		defer hold.Stop()
+		done := Done(rw, req)
+
+		for {
+			bf := NewBuffer(rw)
+			next.ServeHTTP(bf, req)
+			if !isEmptyPoll(bf) {
+				bf.FlushAll()
+				return
+			}
+
+			select {
+			case _, open := <-events:
+				if !open {
+					// the subscription ended, after this run only MaxHold or the client end the hold
+					events = nil
+				}
+			case <-hold.C:
+				if bf.Code == 0 {
+					bf.WriteHeader(http.StatusNoContent)
+				}
+				bf.FlushAll()
+				return
+			case <-done:
+				return
+			}
+		}
+	}
+	return f
+}
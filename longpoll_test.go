@@ -0,0 +1,112 @@
+package wrap
+
+import (
+	stdcontext "context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pollHandler writes the message, if there is one
+type pollHandler struct {
+	msg atomic.Value
+}
+
+func (p *pollHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if msg, _ := p.msg.Load().(string); msg != "" {
+		rw.Write([]byte(msg))
+	}
+}
+
+func TestLongPollEvent(t *testing.T) {
+	events := make(chan struct{})
+	unsubscribed := make(chan struct{})
+	p := &pollHandler{}
+	h := New(LongPoll{Subscribe: func(req *http.Request) (<-chan struct{}, func()) {
+		return events, func() { close(unsubscribed) }
+	}}, Handler(p))
+
+	go func() {
+		events <- struct{}{}
+		p.msg.Store("news")
+		events <- struct{}{}
+	}()
+
+	rec, req := newTestRequest("GET", "/poll")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "news", 200)
+
+	select {
+	case <-unsubscribed:
+	default:
+		t.Errorf("the subscription should be ended")
+	}
+}
+
+func TestLongPollMaxHold(t *testing.T) {
+	h := New(LongPoll{MaxHold: 10 * time.Millisecond, Subscribe: func(req *http.Request) (<-chan struct{}, func()) {
+		return make(chan struct{}), func() {}
+	}}, Handler(&pollHandler{}))
+
+	rec, req := newTestRequest("GET", "/poll")
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "", 204)
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("request should be held for MaxHold")
+	}
+}
+
+func TestLongPollImmediate(t *testing.T) {
+	p := &pollHandler{}
+	p.msg.Store("now")
+	h := New(LongPoll{Subscribe: func(req *http.Request) (<-chan struct{}, func()) {
+		return nil, func() {}
+	}}, Handler(p))
+
+	rec, req := newTestRequest("GET", "/poll")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "now", 200)
+}
+
+func TestLongPollClientGone(t *testing.T) {
+	h := New(LongPoll{Subscribe: func(req *http.Request) (<-chan struct{}, func()) {
+		return nil, func() {}
+	}}, Handler(&pollHandler{}))
+
+	rec, req := newTestRequest("GET", "/poll")
+	ctx, cancel := stdcontext.WithCancel(req.Context())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	h.ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("nothing should be written for a gone client, got %q", rec.Body.String())
+	}
+}
+
+type closeNotifyRecorder struct {
+	http.ResponseWriter
+	closed chan bool
+}
+
+func (c closeNotifyRecorder) CloseNotify() <-chan bool { return c.closed }
+
+func TestDone(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	ctx, cancel := stdcontext.WithCancel(req.Context())
+	defer cancel()
+	cn := closeNotifyRecorder{ResponseWriter: rec, closed: make(chan bool, 1)}
+	done := Done(cn, req.WithContext(ctx))
+
+	select {
+	case <-done:
+		t.Fatalf("done should not be closed before the connection is closed")
+	default:
+	}
+	cn.closed <- true
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("done should be closed after the connection is closed")
+	}
+}
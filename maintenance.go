@@ -0,0 +1,154 @@
+package wrap
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Maintenance is a Wrapper that serves a maintenance response instead of the next handlers while it is
+// enabled. It is toggled at runtime, without rebuilding the stack, via Enable and Disable or by watching
+// a file (WatchFile) or an environment variable (WatchEnv).
+//
+// Requests for one of the BypassPaths or from one of the BypassIPs are passed to the next handlers anyway,
+// e.g. health checks or administrators checking the site. The client IP is taken from ClientIPOf, so
+// SetClientIP must run before Maintenance if the server is behind proxies.
+//
+// A Maintenance must be used as pointer and not be copied after first use. The zero value is ready to use
+// and disabled.
+type Maintenance struct {
+	// Response is served while enabled. Defaults to 503 Service Unavailable with a Retry-After header
+	// if RetryAfter is set.
+	Response http.Handler
+
+	// RetryAfter is sent as Retry-After header (in seconds) by the default Response, if it is not 0
+	RetryAfter time.Duration
+
+	// BypassPaths are path prefixes that are served by the next handlers while enabled
+	BypassPaths []string
+
+	// BypassIPs are IPs or CIDR ranges of clients that are served by the next handlers while enabled
+	BypassIPs []string
+
+	enabled   int32
+	parseOnce sync.Once
+	bypassIPs []*net.IPNet
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = &Maintenance{}
+
+// Enable enables the maintenance mode
+func (m *Maintenance) Enable() {
+	atomic.StoreInt32(&m.enabled, 1)
+}
+
+// Disable disables the maintenance mode
+func (m *Maintenance) Disable() {
+	atomic.StoreInt32(&m.enabled, 0)
+}
+
+// Set enables or disables the maintenance mode
+func (m *Maintenance) Set(enabled bool) {
+	if enabled {
+		m.Enable()
+		return
+	}
+	m.Disable()
+}
+
+// Enabled returns if the maintenance mode is enabled
+func (m *Maintenance) Enabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// watch calls enabled every interval and sets the maintenance mode accordingly, until stop is called
+func (m *Maintenance) watch(interval time.Duration, enabled func() bool) (stop func()) {
+	m.Set(enabled())
+	t := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				m.Set(enabled())
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.Stop()
+			close(done)
+		})
+	}
+}
+
+// WatchFile enables the maintenance mode while the given file exists, checking every interval,
+// so that it can be toggled by touching or removing the file. The watching ends when stop is called.
+func (m *Maintenance) WatchFile(file string, interval time.Duration) (stop func()) {
+	return m.watch(interval, func() bool {
+		_, err := os.Stat(file)
+		return err == nil
+	})
+}
+
+// WatchEnv enables the maintenance mode while the given environment variable is set to a true value
+// (as accepted by strconv.ParseBool, e.g. "1" or "true"), checking every interval.
+// The watching ends when stop is called.
+func (m *Maintenance) WatchEnv(name string, interval time.Duration) (stop func()) {
+	return m.watch(interval, func() bool {
+		on, _ := strconv.ParseBool(os.Getenv(name))
+		return on
+	})
+}
+
+// bypass returns if the request is passed to the next handlers while enabled
+func (m *Maintenance) bypass(rw http.ResponseWriter, req *http.Request) bool {
+	for _, p := range m.BypassPaths {
+		if strings.HasPrefix(req.URL.Path, p) {
+			return true
+		}
+	}
+	if len(m.bypassIPs) == 0 {
+		return false
+	}
+	ip := ClientIPOf(rw, req)
+	return ip != nil && containsIP(m.bypassIPs, net.IP(ip))
+}
+
+// serveMaintenance is the default Response
+func (m *Maintenance) serveMaintenance(rw http.ResponseWriter, req *http.Request) {
+	if m.RetryAfter > 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(int((m.RetryAfter+time.Second-1)/time.Second)))
+	}
+	http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// Wrap implements the Wrapper interface. It panics if one of the BypassIPs is invalid.
+func (m *Maintenance) Wrap(next http.Handler) http.Handler {
+	m.parseOnce.Do(func() {
+		m.bypassIPs = parseNetworks("bypass IP", m.BypassIPs)
+	})
+	response := m.Response
+	if response == nil {
+		response = http.HandlerFunc(m.serveMaintenance)
+	}
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if !m.Enabled() || m.bypass(rw, req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		response.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,96 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	m := &Maintenance{RetryAfter: 90 * time.Second, BypassPaths: []string{"/health"}, BypassIPs: []string{"192.0.2.0/24"}}
+	h := New(m, write("ok"))
+
+	serve := func(path, remote string) (string, int, string) {
+		rec, req := newTestRequest("GET", path)
+		req.RemoteAddr = remote
+		h.ServeHTTP(rec, req)
+		return rec.Body.String(), rec.Code, rec.Header().Get("Retry-After")
+	}
+
+	if body, code, _ := serve("/", "198.51.100.1:1234"); body != "ok" || code != 200 {
+		t.Errorf("disabled maintenance should serve the stack, got %d %q", code, body)
+	}
+
+	m.Enable()
+
+	if _, code, retry := serve("/", "198.51.100.1:1234"); code != 503 || retry != "90" {
+		t.Errorf("enabled maintenance should answer 503 with Retry-After 90, got %d %q", code, retry)
+	}
+
+	if body, _, _ := serve("/health/live", "198.51.100.1:1234"); body != "ok" {
+		t.Errorf("bypass path should serve the stack, got %q", body)
+	}
+
+	if body, _, _ := serve("/", "192.0.2.1:1234"); body != "ok" {
+		t.Errorf("bypass IP should serve the stack, got %q", body)
+	}
+
+	m.Disable()
+
+	if body, _, _ := serve("/", "198.51.100.1:1234"); body != "ok" {
+		t.Errorf("disabled maintenance should serve the stack, got %q", body)
+	}
+}
+
+func TestMaintenanceResponse(t *testing.T) {
+	m := &Maintenance{Response: writeStop("down")}
+	m.Set(true)
+	rec, req := newTestRequest("GET", "/")
+	New(m, write("ok")).ServeHTTP(rec, req)
+	assertResponse(t, rec, "down", 200)
+}
+
+// waitFor waits until cond returns true or fails after a second
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+	}
+}
+
+func TestMaintenanceWatchFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "maintenance")
+	m := &Maintenance{}
+	stop := m.WatchFile(file, time.Millisecond)
+	defer stop()
+
+	if m.Enabled() {
+		t.Fatal("maintenance should be disabled without file")
+	}
+
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m.Enabled, "maintenance should be enabled by the file")
+
+	os.Remove(file)
+	waitFor(t, func() bool { return !m.Enabled() }, "maintenance should be disabled after removing the file")
+}
+
+func TestMaintenanceWatchEnv(t *testing.T) {
+	t.Setenv("WRAP_MAINTENANCE", "true")
+	m := &Maintenance{}
+	stop := m.WatchEnv("WRAP_MAINTENANCE", time.Millisecond)
+
+	if !m.Enabled() {
+		t.Fatal("maintenance should be enabled by the environment variable")
+	}
+
+	os.Setenv("WRAP_MAINTENANCE", "0")
+	waitFor(t, func() bool { return !m.Enabled() }, "maintenance should be disabled by the environment variable")
+	stop()
+	stop()
+}
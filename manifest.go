@@ -0,0 +1,182 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// roles of the wrappers inside a manifest
+const (
+	roleContexter      = "contexter"
+	roleContextWrapper = "context-wrapper"
+	roleTerminator     = "terminator"
+	roleInspectable    = "inspectable"
+	roleAdapter        = "adapter"
+)
+
+// manifestWrapper describes a wrapper of a stack
+type manifestWrapper struct {
+	Type     string   `json:"type"`
+	Roles    []string `json:"roles,omitempty"`
+	Requires []string `json:"requires,omitempty"`
+	State    string   `json:"state,omitempty"`
+}
+
+// hasRole returns if the wrapper has the given role
+func (m *manifestWrapper) hasRole(role string) bool {
+	for _, r := range m.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestMount is a stack that is mounted inside another one, e.g. a route of a Mux
+type manifestMount struct {
+	Pattern string         `json:"pattern"`
+	Stack   *manifestStack `json:"stack"`
+}
+
+// manifestStack describes a stack
+type manifestStack struct {
+	Name      string            `json:"name"`
+	Contexter string            `json:"contexter,omitempty"`
+	Wrappers  []manifestWrapper `json:"wrappers"`
+	Provided  []string          `json:"provided,omitempty"`
+	Missing   []string          `json:"missing,omitempty"`
+	Mounts    []manifestMount   `json:"mounts,omitempty"`
+}
+
+// manifest is the JSON document returned by Manifest
+type manifest struct {
+	Stacks []*manifestStack `json:"stacks"`
+}
+
+func typeStrings(types []reflect.Type) []string {
+	if len(types) == 0 {
+		return nil
+	}
+	s := make([]string, len(types))
+	for i, ty := range types {
+		s[i] = ty.String()
+	}
+	return s
+}
+
+// describeStack returns the description of the stack of the given wrappers. inject is the ContextInjecter
+// passed separately (as to Stack) or nil. The mounted stacks of the wrappers are described too.
+func describeStack(name string, inject ContextInjecter, wrappers []Wrapper) *manifestStack {
+	ms := &manifestStack{Name: name, Wrappers: []manifestWrapper{}}
+	injectIdx := -1
+	if inject != nil {
+		ms.Contexter = fmt.Sprintf("%T", inject)
+	} else {
+		for i, w := range wrappers {
+			if ci, ok := w.(ContextInjecter); ok {
+				inject, injectIdx = ci, i
+				ms.Contexter = fmt.Sprintf("%T", ci)
+				break
+			}
+		}
+	}
+
+	var required []reflect.Type
+	for i, w := range wrappers {
+		mw := manifestWrapper{Type: fmt.Sprintf("%T", w)}
+		if i == injectIdx {
+			mw.Roles = append(mw.Roles, roleContexter)
+		}
+		if _, ok := w.(ContextWrapper); ok {
+			mw.Roles = append(mw.Roles, roleContextWrapper)
+		}
+		if terminates(w) {
+			mw.Roles = append(mw.Roles, roleTerminator)
+		}
+		if ins, ok := w.(Inspectable); ok {
+			mw.Roles = append(mw.Roles, roleInspectable)
+			mw.State = ins.InspectState()
+		}
+		if _, ok := w.(NextHandlerFunc); ok {
+			mw.Roles = append(mw.Roles, roleAdapter)
+		}
+		req := contextRequirements(w)
+		mw.Requires = typeStrings(req)
+		for _, ty := range req {
+			if !containsType(required, ty) {
+				required = append(required, ty)
+			}
+		}
+		ms.Wrappers = append(ms.Wrappers, mw)
+	}
+
+	if inject == nil {
+		ms.Missing = typeStrings(required)
+		return ms
+	}
+	var provided, missing []reflect.Type
+	for _, ty := range required {
+		if contextSupports(inject, ty) {
+			provided = append(provided, ty)
+		} else {
+			missing = append(missing, ty)
+		}
+	}
+	ms.Provided, ms.Missing = typeStrings(provided), typeStrings(missing)
+	return ms
+}
+
+// describe returns the description of the handler, nil if it is no stack that can be described
+func describe(h http.Handler) *manifestStack {
+	switch st := h.(type) {
+	case *counting:
+		ns := lookupRegistered(st.name)
+		if ns == nil {
+			return nil
+		}
+		return describeStack(ns.name, ns.inject, ns.wrappers)
+	case *Mux:
+		return st.describe()
+	}
+	return nil
+}
+
+// Manifest returns a machine-readable JSON description of the stack h, for deployment tooling,
+// diffing the stacks between releases and the wrapviz command. The stack must have been created
+// by Named, NamedStack or NewMux, otherwise an error is returned, since the wrappers of other handlers
+// are not known. The JSON document has the form
+//
+//	{
+//	  "stacks": [
+//	    {
+//	      "name": "app",
+//	      "contexter": "*app.Context",
+//	      "wrappers": [
+//	        {"type": "*app.Auth", "roles": ["context-wrapper"], "requires": ["app.User"]},
+//	        {"type": "*wrap.Breaker", "roles": ["inspectable"], "state": "closed"},
+//	        {"type": "wrap.NextHandlerFunc", "roles": ["adapter"]}
+//	      ],
+//	      "provided": ["app.User"],
+//	      "missing": [],
+//	      "mounts": [
+//	        {"pattern": "GET /users/{id}", "stack": {"name": "GET /users/{id}", "wrappers": []}}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// The roles of a wrapper are "contexter" (it injects the Contexter), "context-wrapper" (it implements
+// ContextWrapper), "terminator" (a Terminator that terminates or a Wrapper returned by Handler or
+// HandlerFunc), "inspectable" (an Inspectable, whose state is given) and "adapter" (a NextHandlerFunc,
+// like the Wrappers returned by Handler and HandlerFunc).
+// The routes of a Mux are given as mounted stacks. Their context types are checked against the Contexter
+// of the Mux. A stack that is mounted as handler is described as well, if it can be.
+func Manifest(h http.Handler) ([]byte, error) {
+	ms := describe(h)
+	if ms == nil {
+		return nil, fmt.Errorf("no manifest for %T: only stacks created by Named, NamedStack and NewMux can be described", h)
+	}
+	return json.MarshalIndent(manifest{Stacks: []*manifestStack{ms}}, "", "  ")
+}
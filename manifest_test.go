@@ -0,0 +1,114 @@
+package wrap
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeManifest(t *testing.T, h http.Handler) *manifestStack {
+	t.Helper()
+	data, err := Manifest(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid manifest: %v\n%s", err, data)
+	}
+	if len(m.Stacks) != 1 {
+		t.Fatalf("expected 1 stack, got %d", len(m.Stacks))
+	}
+	return m.Stacks[0]
+}
+
+func TestManifestNamedStack(t *testing.T) {
+	h := NamedStack("test-manifest", &context{}, setUserIP{}, SetRequestID{}, terminator(true))
+
+	expected := &manifestStack{
+		Name:      "test-manifest",
+		Contexter: "*wrap.context",
+		Wrappers: []manifestWrapper{
+			{Type: "wrap.setUserIP", Roles: []string{roleContextWrapper}, Requires: []string{"wrap.userIP", "error"}},
+			{Type: "wrap.SetRequestID", Roles: []string{roleContextWrapper}, Requires: []string{"wrap.RequestID"}},
+			{Type: "wrap.terminator", Roles: []string{roleTerminator}},
+		},
+		Provided: []string{"wrap.userIP", "error"},
+		Missing:  []string{"wrap.RequestID"},
+	}
+	if got := decodeManifest(t, h); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func TestManifestNamed(t *testing.T) {
+	h := Named("test-manifest-plain", &context{}, HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	ms := decodeManifest(t, h)
+	if ms.Contexter != "*wrap.context" || len(ms.Wrappers) != 2 {
+		t.Fatalf("unexpected manifest %#v", ms)
+	}
+	if !ms.Wrappers[0].hasRole(roleContexter) || !ms.Wrappers[1].hasRole(roleAdapter) {
+		t.Errorf("unexpected roles %v and %v", ms.Wrappers[0].Roles, ms.Wrappers[1].Roles)
+	}
+}
+
+func TestManifestMux(t *testing.T) {
+	m := NewMux(&context{}, setUserIP{})
+	m.Handle("/a", Named("test-manifest-mounted", write("a")), app{})
+	m.Handle("/b", writeString("b"))
+
+	ms := decodeManifest(t, m)
+	if ms.Name != "mux" || len(ms.Wrappers) != 1 || len(ms.Mounts) != 2 {
+		t.Fatalf("unexpected manifest %#v", ms)
+	}
+	a := ms.Mounts[0]
+	if a.Pattern != "/a" || a.Stack.Wrappers[0].Type != "wrap.app" || !reflect.DeepEqual(a.Stack.Provided, []string{"wrap.userIP"}) {
+		t.Errorf("unexpected route %#v", a.Stack)
+	}
+	if len(a.Stack.Mounts) != 1 || a.Stack.Mounts[0].Stack.Name != "test-manifest-mounted" {
+		t.Errorf("expected the mounted named stack, got %#v", a.Stack.Mounts)
+	}
+	if b := ms.Mounts[1]; b.Pattern != "/b" || len(b.Stack.Wrappers) != 0 || len(b.Stack.Mounts) != 0 {
+		t.Errorf("unexpected route %#v", b.Stack)
+	}
+}
+
+func TestManifestUnknown(t *testing.T) {
+	if _, err := Manifest(New(write("a"))); err == nil {
+		t.Errorf("expected error for stack created by New")
+	}
+}
+
+func TestInspectorHandlerJSON(t *testing.T) {
+	Named("test-inspect-json", write("a"))
+
+	rec, req := newTestRequest("GET", "/debug/wrap?format=json")
+	InspectorHandler().ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON, got %q", ct)
+	}
+	var m manifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, ms := range m.Stacks {
+		if ms.Name == "test-inspect-json" && len(ms.Wrappers) == 1 && ms.Wrappers[0].Type == "wrap.write" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("stack test-inspect-json not found in\n%s", rec.Body.String())
+	}
+
+	rec, req = newTestRequest("GET", "/debug/wrap")
+	req.Header.Set("Accept", "application/json")
+	InspectorHandler().ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Body.String(), "{") {
+		t.Errorf("expected JSON for Accept: application/json, got\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,81 @@
+package wrap
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MethodOverrideHeader is the header that carries the overriding method
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// MethodOverrideField is the form field that carries the overriding method
+const MethodOverrideField = "_method"
+
+// DefaultOverrideMethods are the methods a POST request may be overridden with, if no Methods are set
+var DefaultOverrideMethods = []string{"PUT", "PATCH", "DELETE"}
+
+// OriginalMethod is the context type of the method of the request before it was overridden by MethodOverride
+type OriginalMethod string
+
+// MethodOverride is a ContextWrapper that lets POST requests act as requests with another method, so
+// that HTML forms can be used with handlers dispatching on the method. The method is taken from the
+// MethodOverrideHeader or, for urlencoded forms, from the MethodOverrideField (which means that the
+// form is parsed, see http.Request.ParseForm). Methods that are not allowed are ignored.
+//
+// The method of the request before the override is stored as OriginalMethod.
+// Since WrapMux determines the pattern before its wrappers run, MethodOverride must run in front of
+// the mux for the overridden method to be matched by method specific patterns like "DELETE /items/{id}".
+type MethodOverride struct {
+	// Methods are the allowed methods, defaults to DefaultOverrideMethods
+	Methods []string
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = MethodOverride{}
+
+// ValidateContext makes sure that ctx supports the OriginalMethod type
+func (MethodOverride) ValidateContext(ctx Contexter) {
+	var m OriginalMethod
+	ctx.SetContext(&m)
+	ctx.Context(&m)
+}
+
+// override returns the overriding method of the request, "" if there is none
+func override(req *http.Request) string {
+	if m := req.Header.Get(MethodOverrideHeader); m != "" {
+		return m
+	}
+	ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if ct != "application/x-www-form-urlencoded" {
+		return ""
+	}
+	req.ParseForm()
+	return req.PostForm.Get(MethodOverrideField)
+}
+
+// Wrap implements the Wrapper interface
+func (m MethodOverride) Wrap(next http.Handler) http.Handler {
+	methods := m.Methods
+	if len(methods) == 0 {
+		methods = DefaultOverrideMethods
+	}
+	allowed := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		allowed[strings.ToUpper(method)] = true
+	}
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		orig := OriginalMethod(req.Method)
+		rw.(Contexter).SetContext(&orig)
+		if req.Method == "POST" {
+			req = req.WithContext(req.Context())
+			if method := strings.ToUpper(strings.TrimSpace(override(req))); allowed[method] {
+				req.Method = method
+			}
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
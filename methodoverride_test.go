@@ -0,0 +1,79 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type methodContext struct {
+	http.ResponseWriter
+	orig OriginalMethod
+}
+
+func (c *methodContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *OriginalMethod:
+		*ty = c.orig
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *methodContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *OriginalMethod:
+		c.orig = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c methodContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&methodContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func TestMethodOverride(t *testing.T) {
+	ValidateWrapperContexts(&methodContext{}, MethodOverride{})
+
+	h := New(methodContext{}, MethodOverride{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var orig OriginalMethod
+		rw.(Contexter).Context(&orig)
+		fmt.Fprintf(rw, "%s %s %s", orig, req.Method, req.FormValue("name"))
+	}))
+
+	tests := []struct {
+		method string
+		header string
+		form   string
+		exp    string
+	}{
+		{"POST", "DELETE", "", "POST DELETE"},
+		{"POST", "patch", "", "POST PATCH"},
+		{"POST", "CONNECT", "", "POST POST"},
+		{"GET", "DELETE", "", "GET GET"},
+		{"POST", "", "_method=PUT&name=x", "POST PUT x"},
+		{"POST", "", "name=x", "POST POST x"},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		req, _ := http.NewRequest(test.method, "/", strings.NewReader(test.form))
+		if test.header != "" {
+			req.Header.Set(MethodOverrideHeader, test.header)
+		}
+		if test.form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, 200)
+	}
+}
@@ -0,0 +1,283 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter receives the timings of the wrappers of stacks created by Instrument, so that they
+// can be pushed to statsd, OTLP, Prometheus and the like without this package depending on them.
+// Implementations must be safe for concurrent use.
+type Exporter interface {
+	// Observe is called after the wrapper of the stack with the given name served a request.
+	// d is the time the wrapper (including the wrappers after it) took and status is the status
+	// code as seen by the wrapper (http.StatusOK if nothing has been written).
+	Observe(stack, wrapper string, d time.Duration, status int)
+}
+
+// ExporterFunc is an adapter for a function that acts as Exporter
+type ExporterFunc func(stack, wrapper string, d time.Duration, status int)
+
+// Observe makes the ExporterFunc fulfill the Exporter interface by calling itself.
+func (ef ExporterFunc) Observe(stack, wrapper string, d time.Duration, status int) {
+	ef(stack, wrapper, d, status)
+}
+
+// Metrics is the interface for metric backends that keep counters and histograms.
+// Every measurement is labeled by the name of the stack and the name of the wrapper.
+// A Metrics may be used with Instrument via MetricsExporter.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// IncRequests increments the number of requests that reached the wrapper
+	IncRequests(stack, wrapper string)
+
+	// ObserveLatency records the time the wrapper (including the wrappers after it)
+	// took to serve a request
+	ObserveLatency(stack, wrapper string, d time.Duration)
+
+	// IncStatus increments the number of responses with the given status class (e.g. "2xx")
+	// as seen by the wrapper
+	IncStatus(stack, wrapper, class string)
+}
+
+type metricsExporter struct {
+	Metrics
+}
+
+func (m metricsExporter) Observe(stack, wrapper string, d time.Duration, status int) {
+	m.IncRequests(stack, wrapper)
+	m.ObserveLatency(stack, wrapper, d)
+	m.IncStatus(stack, wrapper, statusClass(status))
+}
+
+// MetricsExporter returns an Exporter that reports each observation to the given Metrics
+// as request, latency and status class.
+func MetricsExporter(m Metrics) Exporter {
+	return metricsExporter{m}
+}
+
+// instrument is an internal type that measures a wrapper in a stack created by Instrument
+type instrument struct {
+	stack    string
+	name     string
+	exporter Exporter
+	http.Handler
+}
+
+func (i *instrument) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rec := newStatusRecorder(rw)
+	start := time.Now()
+	i.Handler.ServeHTTP(rec.responseWriter(), req)
+	status := rec.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	i.exporter.Observe(i.stack, i.name, time.Since(start), status)
+}
+
+// Instrument is like New but reports the timings and status codes of every wrapper to the
+// given Exporter, labeled with the given stack name and the type of the wrapper (as printed by %T).
+// If a type occurs more than once in the stack, its position is appended (e.g. "wrap.write#2"), so that
+// the wrappers do not share a series.
+// MemMetrics is an in-memory Exporter, other Metrics may be used via MetricsExporter.
+//
+// Instrument does not depend on DEBUG, so it may be used in production. If DEBUG is set,
+// the wrappers are debugged as well.
+func Instrument(stack string, e Exporter, wrapper ...Wrapper) (h http.Handler) {
+	names := make([]string, len(wrapper))
+	count := map[string]int{}
+	for i, w := range wrapper {
+		names[i] = fmt.Sprintf("%T", w)
+		count[names[i]]++
+	}
+
+	h = NoOp
+	for i := len(wrapper) - 1; i >= 0; i-- {
+		h = wrapper[i].Wrap(h)
+		if IsDebug() {
+			h = newDebug(wrapper[i], asWrapper, h)
+		}
+		name := names[i]
+		if count[name] > 1 {
+			name += "#" + strconv.Itoa(i)
+		}
+		h = &instrument{stack: stack, name: name, exporter: e, Handler: h}
+	}
+	return
+}
+
+// DefaultBuckets are the default upper bounds (in seconds) of the latency histogram buckets of MemMetrics.
+// They are the same as the default buckets of the Prometheus client libraries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricKey struct {
+	stack   string
+	wrapper string
+}
+
+type metricSeries struct {
+	requests uint64
+	status   map[string]uint64
+	buckets  []uint64
+	sum      float64
+	count    uint64
+}
+
+// MemMetrics is the default in-memory Exporter and Metrics implementation that keeps
+// Prometheus-style counters and latency histograms. It is a http.Handler that serves them in the
+// Prometheus text exposition format.
+type MemMetrics struct {
+	buckets []float64
+	mx      sync.Mutex
+	series  map[metricKey]*metricSeries
+}
+
+// make sure to fulfill the Metrics and Exporter interfaces
+var _ Metrics = &MemMetrics{}
+var _ Exporter = &MemMetrics{}
+
+// NewMemMetrics creates a new MemMetrics with the given upper bounds (in seconds)
+// of the latency histogram buckets. The bounds must be sorted in increasing order.
+// If no bounds are given, DefaultBuckets are used.
+func NewMemMetrics(buckets ...float64) *MemMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &MemMetrics{buckets: buckets, series: map[metricKey]*metricSeries{}}
+}
+
+// get returns the series for the given labels. mx must be locked.
+func (m *MemMetrics) get(stack, wrapper string) *metricSeries {
+	k := metricKey{stack, wrapper}
+	s, has := m.series[k]
+	if !has {
+		s = &metricSeries{status: map[string]uint64{}, buckets: make([]uint64, len(m.buckets))}
+		m.series[k] = s
+	}
+	return s
+}
+
+// Observe counts the request and its status class and adds the duration to the latency histogram of the wrapper
+func (m *MemMetrics) Observe(stack, wrapper string, d time.Duration, status int) {
+	MetricsExporter(m).Observe(stack, wrapper, d, status)
+}
+
+// IncRequests increments the request counter of the wrapper
+func (m *MemMetrics) IncRequests(stack, wrapper string) {
+	m.mx.Lock()
+	m.get(stack, wrapper).requests++
+	m.mx.Unlock()
+}
+
+// ObserveLatency adds the duration to the latency histogram of the wrapper
+func (m *MemMetrics) ObserveLatency(stack, wrapper string, d time.Duration) {
+	secs := d.Seconds()
+	m.mx.Lock()
+	s := m.get(stack, wrapper)
+	for i, le := range m.buckets {
+		if secs <= le {
+			s.buckets[i]++
+		}
+	}
+	s.sum += secs
+	s.count++
+	m.mx.Unlock()
+}
+
+// IncStatus increments the counter of the status class of the wrapper
+func (m *MemMetrics) IncStatus(stack, wrapper, class string) {
+	m.mx.Lock()
+	m.get(stack, wrapper).status[class]++
+	m.mx.Unlock()
+}
+
+// Requests returns the number of requests that reached the wrapper
+func (m *MemMetrics) Requests(stack, wrapper string) uint64 {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.get(stack, wrapper).requests
+}
+
+// Status returns the number of responses of the given status class (e.g. "2xx") seen by the wrapper
+func (m *MemMetrics) Status(stack, wrapper, class string) uint64 {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.get(stack, wrapper).status[class]
+}
+
+// Latency returns the number of observed latencies and their sum
+func (m *MemMetrics) Latency(stack, wrapper string) (count uint64, sum time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	s := m.get(stack, wrapper)
+	return s.count, time.Duration(s.sum * float64(time.Second))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labels(k metricKey) string {
+	return fmt.Sprintf(`stack="%s",wrapper="%s"`, labelEscaper.Replace(k.stack), labelEscaper.Replace(k.wrapper))
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format to w
+func (m *MemMetrics) WriteTo(w io.Writer) (n int64, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	keys := make([]metricKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].stack != keys[b].stack {
+			return keys[a].stack < keys[b].stack
+		}
+		return keys[a].wrapper < keys[b].wrapper
+	})
+
+	var bf strings.Builder
+
+	bf.WriteString("# TYPE wrap_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&bf, "wrap_requests_total{%s} %d\n", labels(k), m.series[k].requests)
+	}
+
+	bf.WriteString("# TYPE wrap_responses_total counter\n")
+	for _, k := range keys {
+		s := m.series[k]
+		classes := make([]string, 0, len(s.status))
+		for c := range s.status {
+			classes = append(classes, c)
+		}
+		sort.Strings(classes)
+		for _, c := range classes {
+			fmt.Fprintf(&bf, "wrap_responses_total{%s,class=\"%s\"} %d\n", labels(k), c, s.status[c])
+		}
+	}
+
+	bf.WriteString("# TYPE wrap_latency_seconds histogram\n")
+	for _, k := range keys {
+		s := m.series[k]
+		for i, le := range m.buckets {
+			fmt.Fprintf(&bf, "wrap_latency_seconds_bucket{%s,le=\"%g\"} %d\n", labels(k), le, s.buckets[i])
+		}
+		fmt.Fprintf(&bf, "wrap_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels(k), s.count)
+		fmt.Fprintf(&bf, "wrap_latency_seconds_sum{%s} %g\n", labels(k), s.sum)
+		fmt.Fprintf(&bf, "wrap_latency_seconds_count{%s} %d\n", labels(k), s.count)
+	}
+
+	i, err := io.WriteString(w, bf.String())
+	return int64(i), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (m *MemMetrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(rw)
+}
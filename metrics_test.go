@@ -0,0 +1,149 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrument(t *testing.T) {
+	m := NewMemMetrics()
+	h := Instrument("main", m,
+		write("a"),
+		HandlerFunc(writeCode),
+	)
+
+	for i := 0; i < 3; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "a", 200)
+	}
+
+	if got := m.Requests("main", "wrap.write"); got != 3 {
+		t.Errorf("requests of wrap.write should be 3, but is %d", got)
+	}
+
+	if got := m.Status("main", "wrap.write", "2xx"); got != 3 {
+		t.Errorf("2xx responses of wrap.write should be 3, but is %d", got)
+	}
+
+	// writeCode sets 407 after the body has been written by wrap.write, so it is not sent
+	if got := m.Status("main", "wrap.NextHandlerFunc", "4xx"); got != 3 {
+		t.Errorf("4xx responses of wrap.NextHandlerFunc should be 3, but is %d", got)
+	}
+
+	if count, _ := m.Latency("main", "wrap.write"); count != 3 {
+		t.Errorf("latency count of wrap.write should be 3, but is %d", count)
+	}
+}
+
+func TestInstrumentSameType(t *testing.T) {
+	m := NewMemMetrics()
+	h := Instrument("same", m,
+		write("a"),
+		NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			next.ServeHTTP(rw, req)
+		}),
+		write("b"),
+		HandlerFunc(writeCode),
+	)
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "ab", 200)
+
+	if got := m.Requests("same", "wrap.write"); got != 0 {
+		t.Errorf("wrappers of the same type should not share a series, got %d requests", got)
+	}
+	for _, name := range []string{"wrap.write#0", "wrap.write#2", "wrap.NextHandlerFunc#1", "wrap.NextHandlerFunc#3"} {
+		if got := m.Requests("same", name); got != 1 {
+			t.Errorf("requests of %s should be 1, but is %d", name, got)
+		}
+	}
+
+	// only the first write includes the sleeping wrapper
+	_, first := m.Latency("same", "wrap.write#0")
+	_, second := m.Latency("same", "wrap.write#2")
+	if first < 10*time.Millisecond || second >= 10*time.Millisecond {
+		t.Errorf("the latencies should be kept apart, got %v and %v", first, second)
+	}
+}
+
+func TestInstrumentContext(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMemMetrics()
+	h := Instrument("ctx", m, &context{}, setUserIP{}, handleError{}, app{})
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "127.0.0.1:45643"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1\nDONE", 200)
+
+	m.WriteTo(&buf)
+	out := buf.String()
+
+	expected := []string{
+		`wrap_requests_total{stack="ctx",wrapper="wrap.app"} 1`,
+		`wrap_responses_total{stack="ctx",wrapper="*wrap.context",class="2xx"} 1`,
+		`wrap_latency_seconds_bucket{stack="ctx",wrapper="wrap.app",le="+Inf"} 1`,
+		`wrap_latency_seconds_count{stack="ctx",wrapper="wrap.setUserIP"} 1`,
+	}
+
+	for _, exp := range expected {
+		if !strings.Contains(out, exp) {
+			t.Errorf("metrics should contain %#v but do not:\n%s", exp, out)
+		}
+	}
+}
+
+func TestMemMetricsServeHTTP(t *testing.T) {
+	m := NewMemMetrics(1)
+	m.IncRequests("a\"b", "c")
+	rec, req := newTestRequest("GET", "/metrics")
+	m.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type should be text/plain, but is %#v", ct)
+	}
+
+	exp := `wrap_requests_total{stack="a\"b",wrapper="c"} 1`
+	if !strings.Contains(rec.Body.String(), exp) {
+		t.Errorf("body should contain %#v, but is %#v", exp, rec.Body.String())
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status code should be 200, but is %d", rec.Code)
+	}
+}
+
+func TestInstrumentExporter(t *testing.T) {
+	var observed []string
+	e := ExporterFunc(func(stack, wrapper string, d time.Duration, status int) {
+		observed = append(observed, fmt.Sprintf("%s %s %d", stack, wrapper, status))
+	})
+
+	h := Instrument("exp", e, write("a"), HandlerFunc(writeCode))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	expected := []string{"exp wrap.NextHandlerFunc 407", "exp wrap.write 200"}
+
+	if strings.Join(observed, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected observations %v, got %v", expected, observed)
+	}
+}
+
+func TestMetricsExporter(t *testing.T) {
+	m := NewMemMetrics()
+	MetricsExporter(m).Observe("s", "w", time.Millisecond, 503)
+
+	if m.Requests("s", "w") != 1 || m.Status("s", "w", "5xx") != 1 {
+		t.Errorf("request and status class should be counted")
+	}
+
+	if count, sum := m.Latency("s", "w"); count != 1 || sum != time.Millisecond {
+		t.Errorf("latency should be observed once with 1ms, got %d and %s", count, sum)
+	}
+}
@@ -0,0 +1,22 @@
+package wrap
+
+import "net/http"
+
+// Middleware returns the stack of the given wrappers as conventional middleware, so that it can be mounted
+// inside routers that accept the func(http.Handler) http.Handler signature (like chi, gorilla/mux or
+// a wrapper around http.ServeMux). The handler passed to the middleware runs after the last wrapper.
+//
+// Like Stack, Middleware validates the first wrapper if it is a ContextInjecter and panics with
+// *ErrDuplicateContexter if more than one wrapper injects a Contexter. The handler passed to the
+// middleware then receives the Contexter as ResponseWriter.
+func Middleware(wrapper ...Wrapper) func(http.Handler) http.Handler {
+	if len(wrapper) > 0 {
+		if inject, ok := wrapper[0].(ContextInjecter); ok {
+			ValidateContextInjecter(inject)
+		}
+	}
+	if err := findDuplicateContexter(wrapper); err != nil {
+		panic(err)
+	}
+	return ToConstructor(wrapper...)
+}
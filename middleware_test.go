@@ -0,0 +1,37 @@
+package wrap
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	mw := Middleware(&context{}, setUserIP{}, handleError{})
+
+	mux := http.NewServeMux()
+	mux.Handle("/ip", mw(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var uIP userIP
+		rw.(Contexter).Context(&uIP)
+		rw.Write([]byte(net.IP(uIP).String()))
+	})))
+
+	rec, req := newTestRequest("GET", "/ip")
+	req.RemoteAddr = "127.0.0.1:45643"
+	mux.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1", 200)
+
+	rec, req = newTestRequest("GET", "/ip")
+	req.RemoteAddr = "invalid"
+	mux.ServeHTTP(rec, req)
+	assertResponse(t, rec, `userip: "invalid" is not IP:port`, 500)
+}
+
+func TestMiddlewareDuplicateContexter(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(*ErrDuplicateContexter); !ok {
+			t.Errorf("Middleware should panic with *ErrDuplicateContexter")
+		}
+	}()
+	Middleware(&context{}, requestIDContext{})
+}
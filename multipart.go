@@ -0,0 +1,289 @@
+package wrap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// FilePart is a file of a multipart form that has been stored by a PartStorage
+type FilePart struct {
+	// Field is the name of the form field
+	Field string
+
+	// Filename is the file name sent by the client
+	Filename string
+
+	// ContentType is the content type sent by the client
+	ContentType string
+
+	// Size is the number of bytes of the file
+	Size int64
+
+	// Location is returned by the PartStorage and identifies the stored file
+	Location string
+}
+
+// FormParts is the context type of the parts of a multipart form parsed by ParseMultipart
+type FormParts struct {
+	// Values are the non file fields
+	Values url.Values
+
+	// Files are the stored files in the order of the form
+	Files []*FilePart
+}
+
+// PartStorage stores the file parts of a multipart form, see ParseMultipart
+type PartStorage interface {
+	// Store reads the file from r and returns the location of the stored file. The Location
+	// and Size of the FilePart are not set yet. If reading r fails, Store must return the error.
+	Store(file *FilePart, r io.Reader) (location string, err error)
+
+	// Remove removes the file stored at the given location
+	Remove(location string) error
+}
+
+// TempStorage is a PartStorage that stores files as temporary files within Dir (os.TempDir() if empty).
+// The location is the path of the file.
+type TempStorage struct {
+	Dir string
+}
+
+// make sure to fulfill the PartStorage interface
+var _ PartStorage = TempStorage{}
+
+// Store implements the PartStorage interface
+func (t TempStorage) Store(file *FilePart, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(t.Dir, "go-on-wrap-part-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Remove implements the PartStorage interface
+func (TempStorage) Remove(location string) error {
+	return os.Remove(location)
+}
+
+// limitedPart returns a *http.MaxBytesError when reading more than n bytes
+type limitedPart struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedPart) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		return int(l.n), &http.MaxBytesError{Limit: l.limit}
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// ParseMultipart is a ContextWrapper that parses multipart/form-data requests while reading the body,
+// stores the file parts through the Storage and stores the parts as FormParts context type.
+// The values are also set as Form, PostForm and MultipartForm of the request, so that
+// http.Request.FormValue works as usual. Other requests are passed to the next handler unchanged.
+//
+// If a part or all values together exceed their limit, the request is rejected with
+// 413 Request Entity Too Large, if the form is malformed with 400 Bad Request and if the Storage
+// fails with 500 Internal Server Error (see HTTPError for how the error is reported).
+// Files that have already been stored are removed then.
+type ParseMultipart struct {
+	// MaxValueSize is the maximal size of a non file part, defaults to 1 MB
+	MaxValueSize int64
+
+	// MaxMemory is the maximal size of all non file parts together, defaults to 10 MB
+	MaxMemory int64
+
+	// MaxFileSize is the maximal size of a file part, defaults to 32 MB
+	MaxFileSize int64
+
+	// MaxParts is the maximal number of parts, defaults to 1000
+	MaxParts int
+
+	// Storage stores the files, defaults to TempStorage{}
+	Storage PartStorage
+
+	// Cleanup removes the stored files after the next handler has been served
+	Cleanup bool
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = ParseMultipart{}
+
+// errTooManyParts is returned if there are more than MaxParts parts
+var errTooManyParts = errors.New("too many parts")
+
+// storageError is returned if the Storage fails to store a file
+type storageError struct {
+	err error
+}
+
+func (s *storageError) Error() string { return "storing file part: " + s.err.Error() }
+
+func (s *storageError) Unwrap() error { return s.err }
+
+// ValidateContext makes sure that ctx supports the FormParts type
+func (ParseMultipart) ValidateContext(ctx Contexter) {
+	var fp FormParts
+	ctx.SetContext(&fp)
+	ctx.Context(&fp)
+}
+
+func (p ParseMultipart) defaults() ParseMultipart {
+	if p.MaxValueSize <= 0 {
+		p.MaxValueSize = 1 << 20
+	}
+	if p.MaxMemory <= 0 {
+		p.MaxMemory = 10 << 20
+	}
+	if p.MaxFileSize <= 0 {
+		p.MaxFileSize = 32 << 20
+	}
+	if p.MaxParts <= 0 {
+		p.MaxParts = 1000
+	}
+	if p.Storage == nil {
+		p.Storage = TempStorage{}
+	}
+	return p
+}
+
+// remove removes the stored files
+func (p ParseMultipart) remove(fp *FormParts) {
+	for _, f := range fp.Files {
+		p.Storage.Remove(f.Location)
+	}
+}
+
+// parse reads the parts of mr
+func (p ParseMultipart) parse(mr *multipart.Reader) (*FormParts, error) {
+	fp := &FormParts{Values: url.Values{}}
+	memory := p.MaxMemory
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return fp, nil
+		}
+		if err != nil {
+			return fp, err
+		}
+		if i >= p.MaxParts {
+			return fp, errTooManyParts
+		}
+
+		field := part.FormName()
+		if part.FileName() == "" {
+			limit := p.MaxValueSize
+			if memory < limit {
+				limit = p.MaxMemory
+			}
+			var bf bytes.Buffer
+			n, err := io.Copy(&bf, &limitedPart{r: part, n: min(memory, p.MaxValueSize), limit: limit})
+			if err != nil {
+				return fp, err
+			}
+			memory -= n
+			fp.Values.Add(field, bf.String())
+			continue
+		}
+
+		file := &FilePart{Field: field, Filename: part.FileName(), ContentType: part.Header.Get("Content-Type")}
+		counter := &countingReader{r: &limitedPart{r: part, n: p.MaxFileSize, limit: p.MaxFileSize}}
+		file.Location, err = p.Storage.Store(file, counter)
+		if counter.err != nil && counter.err != io.EOF {
+			return fp, counter.err
+		}
+		if err != nil {
+			return fp, &storageError{err}
+		}
+		file.Size = counter.n
+		fp.Files = append(fp.Files, file)
+	}
+}
+
+// countingReader counts the bytes read and keeps the last error, so that errors
+// of the request body can be told apart from errors of the Storage
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// Wrap implements the Wrapper interface
+func (p ParseMultipart) Wrap(next http.Handler) http.Handler {
+	p = p.defaults()
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if ct != "multipart/form-data" || req.MultipartForm != nil {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		req = req.WithContext(req.Context())
+		mr, err := req.MultipartReader()
+		if err != nil {
+			reportError(rw, &HTTPError{Code: http.StatusBadRequest, Err: err})
+			return
+		}
+
+		fp, err := p.parse(mr)
+		if err != nil {
+			p.remove(fp)
+			code := http.StatusBadRequest
+			var se *storageError
+			switch {
+			case BodyTooLarge(err) || err == errTooManyParts:
+				code = http.StatusRequestEntityTooLarge
+			case errors.As(err, &se):
+				code = http.StatusInternalServerError
+			}
+			reportError(rw, &HTTPError{Code: code, Err: err})
+			return
+		}
+		if p.Cleanup {
+			defer p.remove(fp)
+		}
+
+		req.PostForm = fp.Values
+		req.Form = url.Values{}
+		for k, v := range req.URL.Query() {
+			req.Form[k] = v
+		}
+		for k, v := range fp.Values {
+			req.Form[k] = append(req.Form[k], v...)
+		}
+		req.MultipartForm = &multipart.Form{Value: fp.Values}
+
+		rw.(Contexter).SetContext(fp)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,190 @@
+package wrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type formPartsContext struct {
+	http.ResponseWriter
+	parts FormParts
+}
+
+func (c *formPartsContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *FormParts:
+		*ty = c.parts
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *formPartsContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *FormParts:
+		c.parts = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c formPartsContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&formPartsContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// memStorage is a PartStorage that keeps the files in memory
+type memStorage map[string]string
+
+func (m memStorage) Store(file *FilePart, r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	loc := strconv.Itoa(len(m))
+	m[loc] = string(b)
+	return loc, nil
+}
+
+func (m memStorage) Remove(location string) error {
+	delete(m, location)
+	return nil
+}
+
+// newMultipartRequest returns a request with the given values and files (field => content)
+func newMultipartRequest(values, files map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range values {
+		mw.WriteField(k, v)
+	}
+	for k, v := range files {
+		w, _ := mw.CreateFormFile(k, k+".txt")
+		w.Write([]byte(v))
+	}
+	mw.Close()
+	req, _ := http.NewRequest("POST", "/?q=1", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// writeFormParts writes the values and the stored files
+func writeFormParts(storage memStorage) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		var fp FormParts
+		rw.(Contexter).Context(&fp)
+		fmt.Fprintf(rw, "%s %s", req.FormValue("name"), req.FormValue("q"))
+		for _, f := range fp.Files {
+			fmt.Fprintf(rw, " %s:%s:%d:%s", f.Field, f.Filename, f.Size, storage[f.Location])
+		}
+	}
+}
+
+func TestParseMultipart(t *testing.T) {
+	ValidateWrapperContexts(&formPartsContext{}, ParseMultipart{})
+
+	storage := memStorage{}
+	h := New(formPartsContext{}, ParseMultipart{Storage: storage, MaxValueSize: 5, MaxFileSize: 10}, HandlerFunc(writeFormParts(storage)))
+
+	tests := []struct {
+		values, files map[string]string
+		exp           string
+		code          int
+	}{
+		{map[string]string{"name": "peter"}, map[string]string{"doc": "content"}, "peter 1 doc:doc.txt:7:content", 200},
+		{map[string]string{"name": "too long"}, nil, "Request Entity Too Large", 413},
+		{nil, map[string]string{"doc": "much too long content"}, "Request Entity Too Large", 413},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		h.ServeHTTP(rec, newMultipartRequest(test.values, test.files))
+		assertResponse(t, rec, test.exp, test.code)
+	}
+
+	if len(storage) != 1 {
+		t.Errorf("only the file of the valid request should be stored, got %d files", len(storage))
+	}
+
+	rec, _ := newTestRequest("POST", "/")
+	req, _ := http.NewRequest("POST", "/?q=2", strings.NewReader("name=paul"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "paul 2", 200)
+}
+
+func TestParseMultipartCleanup(t *testing.T) {
+	var location string
+	h := New(formPartsContext{}, ParseMultipart{Cleanup: true}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var fp FormParts
+		rw.(Contexter).Context(&fp)
+		location = fp.Files[0].Location
+		b, _ := os.ReadFile(location)
+		rw.Write(b)
+	}))
+
+	rec, _ := newTestRequest("POST", "/")
+	h.ServeHTTP(rec, newMultipartRequest(nil, map[string]string{"doc": "content"}))
+	assertResponse(t, rec, "content", 200)
+
+	if _, err := os.Stat(location); !os.IsNotExist(err) {
+		t.Errorf("temporary file %s should be removed", location)
+	}
+}
+
+func TestParseMultipartMaxMemory(t *testing.T) {
+	storage := memStorage{}
+	h := New(formPartsContext{}, ParseMultipart{Storage: storage, MaxValueSize: 5, MaxMemory: 8}, HandlerFunc(writeFormParts(storage)))
+
+	tests := []struct {
+		values map[string]string
+		exp    string
+		code   int
+	}{
+		{map[string]string{"name": "peter", "a": "abc"}, "peter 1", 200},
+		{map[string]string{"name": "peter", "a": "abcd"}, "Request Entity Too Large", 413},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		h.ServeHTTP(rec, newMultipartRequest(test.values, nil))
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
+
+// failingStorage is a PartStorage that fails to store the files
+type failingStorage struct{}
+
+func (failingStorage) Store(file *FilePart, r io.Reader) (string, error) {
+	io.Copy(io.Discard, r)
+	return "", errors.New("disk full")
+}
+
+func (failingStorage) Remove(location string) error { return nil }
+
+func TestParseMultipartStorageError(t *testing.T) {
+	h := New(formPartsContext{}, ParseMultipart{Storage: failingStorage{}}, HandlerFunc(writeFormParts(nil)))
+
+	rec, _ := newTestRequest("POST", "/")
+	h.ServeHTTP(rec, newMultipartRequest(nil, map[string]string{"doc": "content"}))
+	assertResponse(t, rec, "Internal Server Error", 500)
+
+	h = New(formPartsContext{}, ParseMultipart{Storage: failingStorage{}, MaxFileSize: 3}, HandlerFunc(writeFormParts(nil)))
+	rec, _ = newTestRequest("POST", "/")
+	h.ServeHTTP(rec, newMultipartRequest(nil, map[string]string{"doc": "content"}))
+	assertResponse(t, rec, "Request Entity Too Large", 413)
+}
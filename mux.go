@@ -0,0 +1,93 @@
+package wrap
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Mux is a http.Handler that routes requests with a http.ServeMux (supporting the method and
+// wildcard patterns of Go 1.22). The Contexter is injected once before the routing and shared by all routes.
+// The base wrappers run for every request before the routing, while each route may attach additional
+// wrappers that run before its handler.
+//
+// The context requirements of the base wrappers are validated against the Contexter by NewMux and
+// those of the wrappers of a route when the route is registered.
+type Mux struct {
+	inject  ContextInjecter
+	base    []Wrapper
+	mux     *http.ServeMux
+	handler http.Handler
+
+	// routes are the registered routes, for Manifest
+	mx     sync.Mutex
+	routes []muxRoute
+}
+
+// muxRoute is a route registered with Handle
+type muxRoute struct {
+	pattern  string
+	wrappers []Wrapper
+	handler  http.Handler
+}
+
+// make sure to fulfill the http.Handler interface
+var _ http.Handler = &Mux{}
+
+// NewMux creates a new Mux with the given ContextInjecter and base wrappers. Like Stack, it panics
+// if inject is not valid or another wrapper injects a Contexter.
+func NewMux(inject ContextInjecter, base ...Wrapper) *Mux {
+	m := &Mux{inject: inject, base: base, mux: http.NewServeMux()}
+	if err := ValidateWrapperContextsAll(inject, base...); err != nil {
+		panic(err)
+	}
+	st := make([]Wrapper, 0, len(base)+1)
+	st = append(st, base...)
+	st = append(st, Handler(m.mux))
+	m.handler = Stack(inject, st...)
+	return m
+}
+
+// Handle registers the handler for the given pattern (see http.ServeMux). The given wrappers run
+// before the handler. Handle panics if the Contexter does not support a context type that is required by
+// the wrappers (see ValidateWrapperContextsAll) or if one of the wrappers injects another Contexter.
+func (m *Mux) Handle(pattern string, handler http.Handler, wrapper ...Wrapper) {
+	if err := ValidateWrapperContextsAll(m.inject, wrapper...); err != nil {
+		panic(err)
+	}
+	if err := findDuplicateContexter(append([]Wrapper{m.inject}, wrapper...)); err != nil {
+		panic(err)
+	}
+	st := make([]Wrapper, 0, len(wrapper)+1)
+	st = append(st, wrapper...)
+	st = append(st, Handler(handler))
+	m.mux.Handle(pattern, New(st...))
+	m.mx.Lock()
+	m.routes = append(m.routes, muxRoute{pattern, wrapper, handler})
+	m.mx.Unlock()
+}
+
+// HandleFunc is like Handle but for a function with the type signature of http.HandlerFunc
+func (m *Mux) HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request), wrapper ...Wrapper) {
+	m.Handle(pattern, http.HandlerFunc(fn), wrapper...)
+}
+
+// ServeHTTP serves the request through the base wrappers and the matching route
+func (m *Mux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	m.handler.ServeHTTP(rw, req)
+}
+
+// describe returns the description of the base wrappers with the routes as mounted stacks
+func (m *Mux) describe() *manifestStack {
+	ms := describeStack("mux", m.inject, m.base)
+	m.mx.Lock()
+	routes := append([]muxRoute(nil), m.routes...)
+	m.mx.Unlock()
+	for _, r := range routes {
+		rs := describeStack(r.pattern, m.inject, r.wrappers)
+		if sub := describe(r.handler); sub != nil {
+			rs.Mounts = append(rs.Mounts, manifestMount{Pattern: r.pattern, Stack: sub})
+		}
+		ms.Mounts = append(ms.Mounts, manifestMount{Pattern: r.pattern, Stack: rs})
+	}
+	return ms
+}
@@ -0,0 +1,43 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	m := NewMux(&context{}, setUserIP{})
+	m.HandleFunc("GET /items/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("item " + req.PathValue("id")))
+	})
+	m.Handle("GET /ip", app{}.Wrap(NoOp), handleError{})
+	m.HandleFunc("/", writeStop("index").ServeHTTP, write("pre "))
+
+	tests := []struct {
+		method, path, remote string
+		body                 string
+		code                 int
+	}{
+		{"GET", "/items/42", "127.0.0.1:1", "item 42", 200},
+		{"GET", "/ip", "127.0.0.1:1", "127.0.0.1\nDONE", 200},
+		{"GET", "/ip", "invalid", `userip: "invalid" is not IP:port`, 500},
+		{"GET", "/other", "127.0.0.1:1", "pre index", 200},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest(test.method, test.path)
+		req.RemoteAddr = test.remote
+		m.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, test.code)
+	}
+}
+
+func TestMuxValidation(t *testing.T) {
+	m := NewMux(&requestIDContext{})
+	defer func() {
+		if _, ok := recover().(ValidationErrors); !ok {
+			t.Errorf("Handle should panic with ValidationErrors")
+		}
+	}()
+	m.Handle("/", NoOp, app{})
+}
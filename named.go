@@ -0,0 +1,125 @@
+package wrap
+
+import (
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ExpvarName is the name of the expvar.Map under which the counters of the named stacks are published.
+const ExpvarName = "go-on/wrap"
+
+// stackVars are the counters of a named stack
+type stackVars struct {
+	requests expvar.Int
+	inFlight expvar.Int
+	panics   expvar.Int
+	bytes    expvar.Int
+	m        expvar.Map
+}
+
+func newStackVars() *stackVars {
+	v := &stackVars{}
+	v.m.Init()
+	v.m.Set("requests", &v.requests)
+	v.m.Set("in_flight", &v.inFlight)
+	v.m.Set("panics", &v.panics)
+	v.m.Set("bytes_written", &v.bytes)
+	return v
+}
+
+// namedStack is a stack that has been registered by Named or NamedStack
+type namedStack struct {
+	name     string
+	inject   ContextInjecter
+	wrappers []Wrapper
+	vars     *stackVars
+}
+
+var registry = struct {
+	sync.Mutex
+	stacks  map[string]*namedStack
+	expvars *expvar.Map
+}{stacks: map[string]*namedStack{}}
+
+// register registers the stack under the given name and returns the counters for the stack.
+// If there is already a stack with the same name, it is replaced, but the counters are kept.
+func register(name string, inject ContextInjecter, wrapper []Wrapper) *stackVars {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.expvars == nil {
+		registry.expvars = expvar.NewMap(ExpvarName)
+	}
+	ns, has := registry.stacks[name]
+	if !has {
+		ns = &namedStack{name: name, vars: newStackVars()}
+		registry.stacks[name] = ns
+		registry.expvars.Set(name, &ns.vars.m)
+	}
+	ns.inject = inject
+	ns.wrappers = wrapper
+	return ns.vars
+}
+
+// registered returns copies of the registered stacks, sorted by name
+func registered() []*namedStack {
+	registry.Lock()
+	defer registry.Unlock()
+	stacks := make([]*namedStack, 0, len(registry.stacks))
+	for _, ns := range registry.stacks {
+		c := *ns
+		stacks = append(stacks, &c)
+	}
+	sort.Slice(stacks, func(a, b int) bool { return stacks[a].name < stacks[b].name })
+	return stacks
+}
+
+// lookupRegistered returns a copy of the stack registered under the given name, nil if there is none
+func lookupRegistered(name string) *namedStack {
+	registry.Lock()
+	defer registry.Unlock()
+	ns, has := registry.stacks[name]
+	if !has {
+		return nil
+	}
+	c := *ns
+	return &c
+}
+
+// counting is an internal type that counts the requests of a named stack
+type counting struct {
+	vars *stackVars
+	name string
+	http.Handler
+}
+
+func (c *counting) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.vars.requests.Add(1)
+	c.vars.inFlight.Add(1)
+	rec := newStatusRecorder(rw)
+	defer func() {
+		c.vars.inFlight.Add(-1)
+		c.vars.bytes.Add(int64(rec.bytes))
+		if p := recover(); p != nil {
+			c.vars.panics.Add(1)
+			panic(p)
+		}
+	}()
+	c.Handler.ServeHTTP(rec.responseWriter(), req)
+}
+
+// Named is like New but registers the stack under the given name and publishes the
+// counters of the stack (requests served, requests in flight, panics and bytes written)
+// via expvar inside the map named by ExpvarName.
+//
+// If a stack with the same name has already been registered, it is replaced
+// but the counters are shared.
+func Named(name string, wrapper ...Wrapper) http.Handler {
+	return &counting{register(name, nil, wrapper), name, New(wrapper...)}
+}
+
+// NamedStack is like Stack but registers the stack under the given name, like Named does.
+func NamedStack(name string, inject ContextInjecter, wrapper ...Wrapper) http.Handler {
+	return &counting{register(name, inject, wrapper), name, Stack(inject, wrapper...)}
+}
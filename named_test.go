@@ -0,0 +1,64 @@
+package wrap
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	h := Named("test-named", write("a"), Handler(write("b")))
+
+	for i := 0; i < 2; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "ab", 200)
+	}
+
+	h = Named("test-named", Handler(panicker("boom")))
+	func() {
+		defer func() { recover() }()
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+	}()
+
+	vars := expvar.Get(ExpvarName).(*expvar.Map).Get("test-named")
+	if vars == nil {
+		t.Fatal("counters of the stack should be published")
+	}
+
+	var counters map[string]int
+	if err := json.Unmarshal([]byte(vars.String()), &counters); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"requests": 3, "in_flight": 0, "panics": 1, "bytes_written": 4}
+
+	for k, v := range expected {
+		if counters[k] != v {
+			t.Errorf("%s should be %d, but is %d", k, v, counters[k])
+		}
+	}
+}
+
+func TestNamedStack(t *testing.T) {
+	h := NamedStack("test-named-stack", &context{}, setUserIP{}, handleError{}, app{})
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "127.0.0.1:45643"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "127.0.0.1\nDONE", 200)
+
+	var found bool
+	for _, ns := range registered() {
+		if ns.name == "test-named-stack" {
+			found = true
+			if ns.inject == nil || len(ns.wrappers) != 3 {
+				t.Errorf("stack should be registered with its ContextInjecter and 3 wrappers")
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("stack should be registered")
+	}
+}
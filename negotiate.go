@@ -0,0 +1,128 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MediaType is the context type of the media type negotiated by Negotiate, e.g. "application/json"
+type MediaType string
+
+// Offer is a media type that Negotiate offers
+type Offer struct {
+	// Type is the media type, e.g. "application/json"
+	Type string
+
+	// Handler serves the requests that negotiated Type instead of the next handlers.
+	// If it is nil, the next handlers are run.
+	Handler http.Handler
+}
+
+// Negotiate is a ContextWrapper that negotiates the media type of the response with the Accept header of the
+// request (taking q-values and the specificity of media ranges into account) among the Offers. Between media
+// types of the same quality, the order of the Offers decides. Requests without Accept header get the first offer.
+//
+// If the ResponseWriter is a Contexter, the negotiated media type is stored as MediaType inside the context.
+// The request is then served by the Handler of the offer or by the next handlers. If no offer is acceptable,
+// the request is answered with 406 Not Acceptable listing the offered types (see HTTPError for how the error
+// is reported). Vary: Accept is added to the response.
+type Negotiate struct {
+	Offers []Offer
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = Negotiate{}
+
+// ValidateContext makes sure that ctx supports the MediaType type
+func (Negotiate) ValidateContext(ctx Contexter) {
+	var mt MediaType
+	ctx.SetContext(&mt)
+	ctx.Context(&mt)
+}
+
+// make sure to fulfill the Terminator interface
+var _ Terminator = Negotiate{}
+
+// Terminates returns true if all offers have a Handler, so that the next handlers are never run
+func (n Negotiate) Terminates() bool {
+	for _, o := range n.Offers {
+		if o.Handler == nil {
+			return false
+		}
+	}
+	return len(n.Offers) > 0
+}
+
+// mediaTypeQuality returns the quality of the media type for the parsed Accept header: the quality
+// of the most specific matching media range, -1 if none matches
+func mediaTypeQuality(values []acceptValue, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := -1.0, -1
+	for _, v := range values {
+		rng, _, _ := strings.Cut(v.value, ";")
+		var s int
+		switch rng {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*", "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = v.q, s
+		}
+	}
+	return q
+}
+
+// negotiate returns the best acceptable offer, nil if none is acceptable
+func (n Negotiate) negotiate(accept []string) *Offer {
+	if len(n.Offers) == 0 {
+		return nil
+	}
+	if len(accept) == 0 {
+		return &n.Offers[0]
+	}
+	values := parseAccept(accept)
+	var best *Offer
+	var bestQ float64
+	for i := range n.Offers {
+		if q := mediaTypeQuality(values, n.Offers[i].Type); q > bestQ {
+			best, bestQ = &n.Offers[i], q
+		}
+	}
+	return best
+}
+
+// Wrap implements the Wrapper interface
+func (n Negotiate) Wrap(next http.Handler) http.Handler {
+	types := make([]string, len(n.Offers))
+	for i, o := range n.Offers {
+		types[i] = o.Type
+	}
+	notAcceptable := http.StatusText(http.StatusNotAcceptable) + ", supported media types: " + strings.Join(types, ", ")
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		addVary(rw.Header(), "Accept")
+		offer := n.negotiate(req.Header.Values("Accept"))
+		if offer == nil {
+			reportError(rw, &HTTPError{Code: http.StatusNotAcceptable, Msg: notAcceptable})
+			return
+		}
+		if ctx, ok := rw.(Contexter); ok {
+			mt := MediaType(offer.Type)
+			ctx.SetContext(&mt)
+		}
+		if offer.Handler != nil {
+			offer.Handler.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,113 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+type mediaTypeContext struct {
+	http.ResponseWriter
+	mediaType MediaType
+	err       error
+}
+
+func (c *mediaTypeContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *MediaType:
+		*ty = c.mediaType
+	case *error:
+		if c.err == nil {
+			return false
+		}
+		*ty = c.err
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *mediaTypeContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *MediaType:
+		c.mediaType = *ty
+	case *error:
+		c.err = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c mediaTypeContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&mediaTypeContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// writeMediaType writes the negotiated MediaType
+func writeMediaType(rw http.ResponseWriter, req *http.Request) {
+	var mt MediaType
+	rw.(Contexter).Context(&mt)
+	rw.Write([]byte("next " + mt))
+}
+
+func TestNegotiate(t *testing.T) {
+	h := Stack(&mediaTypeContext{}, HandleError{}, Negotiate{Offers: []Offer{
+		{Type: "application/json"},
+		{Type: "text/html", Handler: writeString("html")},
+		{Type: "text/plain"},
+	}}, HandlerFunc(writeMediaType))
+
+	tests := []struct {
+		accept string
+		body   string
+		code   int
+	}{
+		{"", "next application/json", 200},
+		{"*/*", "next application/json", 200},
+		{"text/html", "html", 200},
+		{"text/*", "html", 200},
+		{"text/*, text/html;q=0.5", "next text/plain", 200},
+		{"text/html;level=1;q=0.2, application/json;q=0.1", "html", 200},
+		{"*/*;q=0.5, application/json;q=0", "html", 200},
+		{"TEXT/PLAIN", "next text/plain", 200},
+		{"image/png", "Not Acceptable, supported media types: application/json, text/html, text/plain", 406},
+		{"application/json;q=0", "Not Acceptable, supported media types: application/json, text/html, text/plain", 406},
+	}
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, test.code)
+		if vary := rec.Header().Get("Vary"); test.code == 200 && vary != "Accept" {
+			t.Errorf("%q: expected Vary: Accept, got %q", test.accept, vary)
+		}
+	}
+}
+
+func TestNegotiateWithoutContexter(t *testing.T) {
+	h := New(Negotiate{Offers: []Offer{{Type: "text/plain", Handler: writeString("plain")}}})
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "plain", 200)
+
+	rec, req = newTestRequest("GET", "/")
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "Not Acceptable, supported media types: text/plain", 406)
+}
+
+func TestNegotiateTerminates(t *testing.T) {
+	if !(Negotiate{Offers: []Offer{{Type: "a/b", Handler: NoOp}}}).Terminates() {
+		t.Errorf("Negotiate with handlers for all offers should terminate")
+	}
+	if (Negotiate{Offers: []Offer{{Type: "a/b", Handler: NoOp}, {Type: "c/d"}}}).Terminates() {
+		t.Errorf("Negotiate with an offer without handler should not terminate")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package wrap
+
+// raceEnabled reports if the race detector is enabled, which changes the allocations
+const raceEnabled = false
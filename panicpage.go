@@ -0,0 +1,174 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// contextValues returns the values of the given context types (pointers by name) that are set in rw.
+// Unsupported types and ResponseWriters that are no Contexter are skipped.
+func contextValues(rw http.ResponseWriter, types map[string]interface{}) map[string]interface{} {
+	if _, ok := rw.(Contexter); !ok || len(types) == 0 {
+		return nil
+	}
+	values := map[string]interface{}{}
+	for name, ptr := range types {
+		t := reflect.TypeOf(ptr)
+		if t == nil || t.Kind() != reflect.Ptr {
+			continue
+		}
+		v := reflect.New(t.Elem())
+		if found, err := TryContext(rw, v.Interface()); found && err == nil {
+			values[name] = v.Elem().Interface()
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// pruneStack removes the frames of the recovery (up to and including the call of panic) and the frames
+// of the runtime from a stack trace as returned by runtime.Stack
+func pruneStack(stack []byte) []byte {
+	lines := strings.Split(strings.TrimRight(string(stack), "\n"), "\n")
+	if len(lines) == 0 {
+		return stack
+	}
+	var out []string
+	var panicked bool
+	// the first line is the goroutine header, the frames are pairs of function and file line
+	for i := 1; i+1 < len(lines); i += 2 {
+		fn := lines[i]
+		if !panicked {
+			panicked = strings.HasPrefix(fn, "panic(")
+			continue
+		}
+		if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "created by ") {
+			continue
+		}
+		out = append(out, fn, lines[i+1])
+	}
+	if !panicked {
+		return stack
+	}
+	return []byte(lines[0] + "\n" + strings.Join(out, "\n") + "\n")
+}
+
+// traversalOf returns the wrappers and handlers the request passed until now as type and role.
+// It requires DEBUG, otherwise nothing is recorded.
+func traversalOf(req *http.Request) (traversal []string) {
+	if req == nil {
+		return nil
+	}
+	state, has := requestValue(req, debugKey).(*debugState)
+	if !has {
+		return nil
+	}
+	for _, d := range state.traversal {
+		traversal = append(traversal, fmt.Sprintf("%T (%s)", d.Object, d.Role))
+	}
+	return
+}
+
+// panicPage is the data of the template of DevPanicPage
+type panicPage struct {
+	Method, URL, RequestID string
+	Recovered              string
+	Stack                  string
+	Traversal              []string
+	Context                [][2]string
+}
+
+var panicPageTemplate = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>panic: {{.Recovered}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+h1 { color: #b00; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+td { padding: 0.2em 1em 0.2em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>panic: {{.Recovered}}</h1>
+<p>{{.Method}} {{.URL}}{{if .RequestID}} (request id {{.RequestID}}){{end}}</p>
+<h2>Stack trace</h2>
+<pre>{{.Stack}}</pre>
+<h2>Wrappers</h2>
+{{if .Traversal}}<ol>{{range .Traversal}}
+<li>{{.}}</li>{{end}}
+</ol>{{else}}<p>Not recorded, set DEBUG to record the wrappers the request passed.</p>{{end}}
+<h2>Context</h2>
+{{if .Context}}<table>{{range .Context}}
+<tr><td>{{index . 0}}</td><td><pre>{{index . 1}}</pre></td></tr>{{end}}
+</table>{{else}}<p>No context values.</p>{{end}}
+</body>
+</html>
+`))
+
+// DevPanicPage returns a handler for Recover meant for local development. It renders a HTML page with
+// the recovered value, the stack trace without the frames of the recovery and the runtime, the wrappers
+// the request passed until the panic (recorded if DEBUG is set) and the values of the given context types
+// (pointers by name, e.g. map[string]interface{}{"user": new(User)}), if the ResponseWriter is a Contexter.
+//
+// Never use it in production, since it exposes internals. See PanicPage for switching by an option.
+func DevPanicPage(context map[string]interface{}) func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {
+	return func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {
+		page := panicPage{
+			Recovered: fmt.Sprint(recovered),
+			Stack:     string(pruneStack(stack)),
+			Traversal: traversalOf(req),
+		}
+		if req != nil {
+			page.Method, page.URL, page.RequestID = req.Method, req.URL.String(), RequestIDOf(rw, req)
+		}
+		values := contextValues(rw, context)
+		for name, v := range values {
+			page.Context = append(page.Context, [2]string{name, fmt.Sprintf("%#v", v)})
+		}
+		sort.Slice(page.Context, func(a, b int) bool { return page.Context[a][0] < page.Context[b][0] })
+
+		var bf bytes.Buffer
+		if err := panicPageTemplate.Execute(&bf, page); err != nil {
+			ProductionPanicPage(rw, req, recovered, stack)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Header().Set("X-Content-Type-Options", "nosniff")
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write(bf.Bytes())
+	}
+}
+
+// ProductionPanicPage is a handler for Recover that writes a terse 500 Internal Server Error,
+// mentioning the request id (see RequestIDOf), if there is one, so that the panic can be found in the logs.
+// Nothing about the panic is exposed.
+func ProductionPanicPage(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {
+	msg := http.StatusText(http.StatusInternalServerError)
+	if req != nil {
+		if id := RequestIDOf(rw, req); id != "" {
+			msg += " (request id " + id + ")"
+		}
+	}
+	http.Error(rw, msg, http.StatusInternalServerError)
+}
+
+// PanicPage returns the handler for Recover that renders recovered panics: DevPanicPage with the given
+// context types if dev is true, ProductionPanicPage otherwise. dev is usually set by a flag or an environment
+// variable, e.g.
+//
+//	wrap.Recover(wrap.PanicPage(os.Getenv("ENV") == "dev", nil))
+func PanicPage(dev bool, context map[string]interface{}) func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {
+	if dev {
+		return DevPanicPage(context)
+	}
+	return ProductionPanicPage
+}
@@ -0,0 +1,87 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDevPanicPage(t *testing.T) {
+	old := CurrentDebugger()
+	SetDebugger(&panicDebugger{})
+	SetDebug()
+
+	h := New(
+		&context{},
+		setUserIP{},
+		Recover(PanicPage(true, map[string]interface{}{"ip": new(userIP), "err": new(error)})),
+		Handler(panicker("<boom>")),
+	)
+	rec, req := newTestRequest("GET", "/path")
+	req.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(rec, req)
+
+	DisableDebug()
+	SetDebugger(old)
+
+	if rec.Code != 500 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected 500 HTML page, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"panic: &lt;boom&gt;",
+		"GET /path",
+		"wrap.panicker.ServeHTTP",
+		"<li>*wrap.context (" + asWrapper + ")</li>",
+		"<li>wrap.panicker (" + asHandler + ")</li>",
+		"<td>ip</td><td><pre>wrap.userIP{",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("page should contain %#v, got\n%s", expected, body)
+		}
+	}
+
+	for _, unexpected := range []string{"<boom>", "stackTrace", "<td>err</td>"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("page should not contain %#v", unexpected)
+		}
+	}
+}
+
+func TestDevPanicPageWithoutDebug(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(Recover(DevPanicPage(nil)), Handler(panicker("boom"))).ServeHTTP(rec, req)
+
+	if body := rec.Body.String(); !strings.Contains(body, "Not recorded") || !strings.Contains(body, "No context values") {
+		t.Errorf("page should report missing traversal and context, got\n%s", body)
+	}
+}
+
+func TestProductionPanicPage(t *testing.T) {
+	h := New(
+		SetRequestID{Generate: func() string { return "xyz" }},
+		Recover(PanicPage(false, nil)),
+		Handler(panicker("secret")),
+	)
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	assertResponse(t, rec, "Internal Server Error (request id xyz)", 500)
+}
+
+func TestPruneStack(t *testing.T) {
+	stack := []byte(`goroutine 1 [running]:
+github.com/go-on/wrap.stackTrace()
+	/wrap/debugger.go:385 +0x3f
+panic({0x6b1e40?, 0xc000012345?})
+	/go/src/runtime/panic.go:770 +0x132
+main.handler(...)
+	/app/main.go:10 +0x20
+runtime.goexit()
+	/go/src/runtime/asm_amd64.s:1695 +0x1
+`)
+	expected := "goroutine 1 [running]:\nmain.handler(...)\n\t/app/main.go:10 +0x20\n"
+	if got := string(pruneStack(stack)); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -3,6 +3,7 @@ package wrap
 import (
 	"io"
 	"net/http"
+	"sync"
 )
 
 // Peek is a ResponseWriter wrapper that intercepts the writing of the body, allowing to check headers and
@@ -24,6 +25,11 @@ type Peek struct {
 	codeWritten    bool
 	headersWritten bool
 	bodyWritten    bool
+
+	// rendersErrors is set by HandleError, which renders the errors stored by the handlers it runs
+	// with the Peek (see reportError)
+	rendersErrors bool
+
 	// proceed should return true if the data should be written to the inner ResponseWriter
 	// otherwise false
 	// Proceed may check the Code and headers that have been set and to the Peek
@@ -62,6 +68,34 @@ func NewPeek(rw http.ResponseWriter, proceed func(*Peek) bool) *Peek {
 	return &Peek{ResponseWriter: rw, proceed: proceed, header: make(http.Header)}
 }
 
+// peekPool holds released Peeks for reuse
+var peekPool = sync.Pool{
+	New: func() interface{} {
+		return &Peek{header: make(http.Header)}
+	},
+}
+
+// AcquirePeek is like NewPeek but takes the Peek from a pool, saving the allocation of the Peek and its
+// header map per request. The Peek should be returned via ReleasePeek when the request has been served.
+func AcquirePeek(rw http.ResponseWriter, proceed func(*Peek) bool) *Peek {
+	p := peekPool.Get().(*Peek)
+	p.ResponseWriter = rw
+	p.proceed = proceed
+	return p
+}
+
+// ReleasePeek resets the Peek and returns it to the pool of AcquirePeek. Neither the Peek nor its
+// header map may be used afterwards, so it must not be released while a handler that received it might
+// still use it (e.g. from a goroutine).
+func ReleasePeek(p *Peek) {
+	for k := range p.header {
+		delete(p.header, k)
+	}
+	header := p.header
+	*p = Peek{header: header}
+	peekPool.Put(p)
+}
+
 // FlushMissing ensures that the Headers and Code are written to the
 // underlying ResponseWriter if they are not written yet (and nothing has been written to the body)
 func (p *Peek) FlushMissing() {
@@ -72,16 +106,16 @@ func (p *Peek) FlushMissing() {
 	p.FlushCode()
 }
 
-// Context gets the Context of the underlying response writer. It panics if the underlying response writer
-// does no implement Context
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
 func (p *Peek) Context(ctxPtr interface{}) bool {
-	return p.ResponseWriter.(Contexter).Context(ctxPtr)
+	return contextOf(p.ResponseWriter, ctxPtr)
 }
 
 // SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
 // does no implement Context
 func (p *Peek) SetContext(ctxPtr interface{}) {
-	p.ResponseWriter.(Contexter).SetContext(ctxPtr)
+	setContextOf(p.ResponseWriter, ctxPtr)
 }
 
 // Header returns the cached http.Header, tracking the call as change
@@ -117,6 +151,15 @@ func (p *Peek) IsOk() bool {
 //
 // See NewPeek for more informations about the usage of the proceed function.
 func (p *Peek) Write(b []byte) (int, error) {
+	if !p.check() {
+		return 0, io.EOF
+	}
+	return p.ResponseWriter.Write(b)
+}
+
+// check runs the proceed function, if not done yet, and returns if the body may be written.
+// If so, the write is tracked as change.
+func (p *Peek) check() bool {
 	if p.proceed != nil {
 		if !p.isChecked {
 			p.writeForbidden = !p.proceed(p)
@@ -124,11 +167,35 @@ func (p *Peek) Write(b []byte) (int, error) {
 		}
 	}
 	if p.writeForbidden {
-		return 0, io.EOF
+		return false
 	}
 	p.bodyWritten = true
 	p.changed = true
-	return p.ResponseWriter.Write(b)
+	return true
+}
+
+// ReadFrom is like Write for the content of r. It passes r to the io.ReaderFrom of the underlying
+// ResponseWriter, if it has one, so that the ResponseWriter of the server can use sendfile for files
+// served via http.ServeContent or http.ServeFile.
+func (p *Peek) ReadFrom(r io.Reader) (int64, error) {
+	if !p.check() {
+		return 0, io.EOF
+	}
+	return readFrom(p.ResponseWriter, r)
+}
+
+// readFrom copies r to w via the io.ReaderFrom of w, if it has one, otherwise via Write
+func readFrom(w io.Writer, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly hides all methods of a writer but Write, e.g. to copy to a writer via Write
+// inside its own ReadFrom method
+type writerOnly struct {
+	io.Writer
 }
 
 // Reset set the Peek to the defaults, so it will act as if it was freshly initialized.
@@ -187,3 +254,18 @@ func (p *Peek) FlushHeaders() {
 	}
 	p.headersWritten = true
 }
+
+// TryFlushCode is like FlushCode but returns ErrBodyFlushedBeforeCode instead of panicking
+func (p *Peek) TryFlushCode() (err error) {
+	defer catchError(ErrFlushOrder, &err)
+	p.FlushCode()
+	return nil
+}
+
+// TryFlushHeaders is like FlushHeaders but returns ErrCodeFlushedBeforeHeaders or ErrBodyFlushedBeforeCode
+// instead of panicking
+func (p *Peek) TryFlushHeaders() (err error) {
+	defer catchError(ErrFlushOrder, &err)
+	p.FlushHeaders()
+	return nil
+}
@@ -0,0 +1,209 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultPreloadScanBytes is the default of Preload.ScanBytes
+const DefaultPreloadScanBytes = 64 * 1024
+
+// preloadAsset is an asset referenced by an HTML page
+type preloadAsset struct {
+	url, as string
+}
+
+// String returns the asset as value of a Link header
+func (p preloadAsset) String() string {
+	return "<" + p.url + ">; rel=preload; as=" + p.as
+}
+
+// preloadAssetOf returns the critical asset referenced by the token, if there is one
+func preloadAssetOf(tok *HTMLToken) (asset preloadAsset, ok bool) {
+	switch {
+	case tok.IsTag("link"):
+		rel, _ := tok.AttrValue("rel")
+		if !strings.EqualFold(strings.TrimSpace(rel), "stylesheet") {
+			return
+		}
+		asset = preloadAsset{as: "style"}
+		asset.url, _ = tok.AttrValue("href")
+	case tok.IsTag("script"):
+		asset = preloadAsset{as: "script"}
+		asset.url, _ = tok.AttrValue("src")
+	default:
+		return
+	}
+	// only assets of the same origin, so that no crossorigin attribute is needed
+	if !strings.HasPrefix(asset.url, "/") || strings.HasPrefix(asset.url, "//") {
+		return asset, false
+	}
+	return asset, true
+}
+
+// Preload is a Wrapper that adds Link: rel=preload headers for the stylesheets and scripts referenced in the
+// head of HTML pages written by the next handlers, so that browsers start loading them before they parsed
+// the page. Only assets of the same origin (with URLs starting with a single "/") are preloaded.
+//
+// The body (and the headers) of HTML responses (by Content-Type, set or detected) with status 200 are held
+// back until the end of the head (</head> or <body>) has been written or the held back body exceeds ScanBytes,
+// then the headers are flushed with the Link headers and the body is written through. If Push is set, the
+// assets are also registered as PushCandidates (see CoordinatePush) or, if the context does not support them,
+// pushed via the http.Pusher of the underlying ResponseWriter (see Push) before the headers are flushed,
+// which is ignored if the connection does not support it.
+type Preload struct {
+	// ScanBytes is the maximal number of bytes of the body that are scanned for assets,
+	// DefaultPreloadScanBytes if 0
+	ScanBytes int
+
+	// Push pushes the assets via HTTP/2 server push
+	Push bool
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Preload{}
+
+// preloadWriter is the ResponseWriter passed to the next handlers by Preload
+type preloadWriter struct {
+	*Peek
+	preload   Preload
+	head      bool
+	started   bool
+	scanning  bool
+	held      []byte
+	tokenizer htmlTokenizer
+	assets    []preloadAsset
+	seen      map[string]bool
+}
+
+// start decides if the response is scanned
+func (p *preloadWriter) start(b []byte) {
+	p.started = true
+	header := p.Peek.Header()
+	code := p.Peek.Code
+	if p.head || (code != 0 && code != http.StatusOK) || header.Get("Content-Encoding") != "" {
+		return
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(b)
+		header.Set("Content-Type", ct)
+	}
+	mediaType, _, _ := strings.Cut(ct, ";")
+	p.scanning = strings.ToLower(strings.TrimSpace(mediaType)) == "text/html"
+}
+
+// scan collects the assets of the written bytes and returns true if the end of the head has been reached
+func (p *preloadWriter) scan(b []byte, final bool) (headEnd bool) {
+	p.tokenizer.buf = append(p.tokenizer.buf, b...)
+	for {
+		tok, ok := p.tokenizer.next(final)
+		if !ok {
+			return false
+		}
+		if (tok.Type == HTMLEndTag && tok.Data == "head") || tok.IsTag("body") {
+			return true
+		}
+		if asset, ok := preloadAssetOf(&tok); ok && !p.seen[asset.url] {
+			if p.seen == nil {
+				p.seen = map[string]bool{}
+			}
+			p.seen[asset.url] = true
+			p.assets = append(p.assets, asset)
+		}
+	}
+}
+
+// release adds the Link headers, pushes the assets and writes the held back body
+func (p *preloadWriter) release() error {
+	p.scanning = false
+	p.tokenizer = htmlTokenizer{}
+	header := p.Peek.Header()
+	for _, a := range p.assets {
+		header.Add("Link", a.String())
+		if p.preload.Push && !AddPushCandidate(p.Peek, a.url, nil) {
+			Push(p.Peek.ResponseWriter, a.url, nil)
+		}
+	}
+	held := p.held
+	p.held = nil
+	if len(held) == 0 {
+		return nil
+	}
+	_, err := p.Peek.Write(held)
+	return err
+}
+
+// Write holds back the body until the end of the head, then writes it through
+func (p *preloadWriter) Write(b []byte) (int, error) {
+	if !p.started {
+		p.start(b)
+	}
+	if !p.scanning {
+		return p.Peek.Write(b)
+	}
+	p.held = append(p.held, b...)
+	max := p.preload.ScanBytes
+	if max <= 0 {
+		max = DefaultPreloadScanBytes
+	}
+	if p.scan(b, false) || len(p.held) > max {
+		if err := p.release(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// ReadFrom writes the content of r. If the response is not scanned (anymore), r is passed to the
+// underlying ResponseWriter (see Peek.ReadFrom), so that files may be served via sendfile.
+func (p *preloadWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !p.started && p.Peek.Header().Get("Content-Type") != "" {
+		p.start(nil)
+	}
+	if p.started && !p.scanning {
+		return p.Peek.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{p}, r)
+}
+
+// Flush writes the held back body and flushes the underlying ResponseWriter
+func (p *preloadWriter) Flush() {
+	if p.scanning {
+		p.release()
+	}
+	p.Peek.FlushMissing()
+	flushInner(p.Peek.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (p *preloadWriter) Unwrap() http.ResponseWriter {
+	return p.Peek.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (pl Preload) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		pw := &preloadWriter{
+			Peek: NewPeek(rw, func(p *Peek) bool {
+				p.FlushMissing()
+				return true
+			}),
+			preload: pl,
+			head:    req.Method == http.MethodHead,
+		}
+		next.ServeHTTP(pw, req)
+		if pw.scanning {
+			pw.scan(nil, true)
+			pw.release()
+		}
+		pw.Peek.FlushMissing()
+	}
+	return f
+}
@@ -0,0 +1,67 @@
+package wrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+const preloadPage = `<html><head><link rel="stylesheet" href="/a.css"><link rel=icon href="/i.ico">` +
+	`<script src="/a.js"></script><script src="https://cdn.example.com/b.js"></script>` +
+	`<link rel="Stylesheet" href="/a.css"></head><body><script src="/late.js"></script></body></html>`
+
+func TestPreload(t *testing.T) {
+	for _, size := range []int{1, 5, len(preloadPage)} {
+		rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req, _ := http.NewRequest("GET", "/", nil)
+		New(Preload{Push: true}, Handler(writeSplitted(preloadPage, size))).ServeHTTP(rec, req)
+		assertResponse(t, rec.ResponseRecorder, preloadPage, 200)
+
+		links := rec.Header()["Link"]
+		expected := []string{"</a.css>; rel=preload; as=style", "</a.js>; rel=preload; as=script"}
+		if !reflect.DeepEqual(links, expected) {
+			t.Errorf("Link headers should be %v, but are %v", expected, links)
+		}
+		if !reflect.DeepEqual(rec.pushed, []string{"/a.css", "/a.js"}) {
+			t.Errorf("unexpected pushes %v", rec.pushed)
+		}
+	}
+}
+
+func TestPreloadScanBytes(t *testing.T) {
+	page := `<html><head><title>x</title><link rel="stylesheet" href="/a.css"></head></html>`
+	h := New(Preload{ScanBytes: 10}, Handler(writeSplitted(page, 5)))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, page, 200)
+
+	if links := rec.Header()["Link"]; len(links) != 0 {
+		t.Errorf("assets after ScanBytes should not be preloaded, got %v", links)
+	}
+}
+
+func TestPreloadSkip(t *testing.T) {
+	h := New(Preload{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(preloadPage))
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, preloadPage, 404)
+
+	if links := rec.Header()["Link"]; len(links) != 0 {
+		t.Errorf("error pages should not be scanned, got %v", links)
+	}
+}
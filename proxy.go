@@ -0,0 +1,94 @@
+package wrap
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream is the context type in which Proxy stores the upstream that has served the request
+type Upstream struct {
+	// URL is the chosen target
+	URL *url.URL
+
+	// Status is the status code of the upstream response, 0 if there is none
+	Status int
+
+	// Latency is the time until the upstream response headers were received
+	Latency time.Duration
+}
+
+// Proxy is a Wrapper that serves the request with a httputil.ReverseProxy. It does not run
+// the next handler.
+//
+// The targets are chosen round robin. The upstream response is written through the given Writers,
+// so that response writer wrappers like EscapeHTML can rewrite it. After the response has been written,
+// the chosen Upstream is stored in the Contexter (if there is one that supports it), so that surrounding
+// middleware may log it.
+type Proxy struct {
+	// Targets are the upstream URLs (see httputil.ProxyRequest.SetURL)
+	Targets []*url.URL
+
+	// Writers wrap the ResponseWriter the upstream response is written to. The first one is the outermost.
+	Writers []func(http.ResponseWriter) http.ResponseWriter
+
+	// Transport is the transport of the reverse proxy, defaults to http.DefaultTransport.
+	// It may be created with NewTransport.
+	Transport http.RoundTripper
+
+	// ErrorHandler handles errors of the round trip, defaults to a 502 Bad Gateway
+	ErrorHandler func(rw http.ResponseWriter, req *http.Request, err error)
+
+	counter uint64
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = &Proxy{}
+
+// choose returns the next target
+func (p *Proxy) choose() *url.URL {
+	n := atomic.AddUint64(&p.counter, 1)
+	return p.Targets[(n-1)%uint64(len(p.Targets))]
+}
+
+// Wrap implements the Wrapper interface
+func (p *Proxy) Wrap(next http.Handler) http.Handler {
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			up := requestValue(pr.In, proxyKey).(*upstreamState)
+			pr.SetURL(up.URL)
+			pr.SetXForwarded()
+		},
+		Transport: p.Transport,
+		ModifyResponse: func(res *http.Response) error {
+			if up, ok := requestValue(res.Request, proxyKey).(*upstreamState); ok {
+				up.Status = res.StatusCode
+				up.Latency = time.Since(up.start)
+			}
+			return nil
+		},
+		ErrorHandler: p.ErrorHandler,
+	}
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		up := &upstreamState{Upstream: Upstream{URL: p.choose()}, start: time.Now()}
+		w := rw
+		for i := len(p.Writers) - 1; i >= 0; i-- {
+			w = p.Writers[i](w)
+		}
+		rp.ServeHTTP(w, withRequestValue(req, proxyKey, up))
+		if _, ok := rw.(Contexter); ok {
+			TrySetContext(rw, &up.Upstream)
+		}
+	}
+	return f
+}
+
+// upstreamState is the per request state of a Proxy
+type upstreamState struct {
+	Upstream
+	start time.Time
+}
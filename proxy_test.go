@@ -0,0 +1,84 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type upstreamContext struct {
+	http.ResponseWriter
+	upstream Upstream
+}
+
+func (c *upstreamContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *Upstream:
+		*ty = c.upstream
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *upstreamContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *Upstream:
+		c.upstream = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c upstreamContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&upstreamContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func TestProxy(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(rw, "<%s %s>", name, req.URL.Path)
+		}))
+	}
+	a, b := backend("a"), backend("b")
+	defer a.Close()
+	defer b.Close()
+	ua, _ := url.Parse(a.URL)
+	ub, _ := url.Parse(b.URL)
+
+	var upstreams []string
+	h := New(
+		&upstreamContext{},
+		NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(rw, req)
+			var up Upstream
+			rw.(Contexter).Context(&up)
+			upstreams = append(upstreams, fmt.Sprintf("%s %d", up.URL.Host, up.Status))
+		}),
+		&Proxy{
+			Targets: []*url.URL{ua, ub},
+			Writers: []func(http.ResponseWriter) http.ResponseWriter{
+				func(rw http.ResponseWriter) http.ResponseWriter { return &EscapeHTML{rw} },
+			},
+		},
+	)
+
+	for _, exp := range []string{"&lt;a /x&gt;", "&lt;b /x&gt;", "&lt;a /x&gt;"} {
+		rec, req := newTestRequest("GET", "/x")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, exp, 200)
+	}
+
+	expected := []string{ua.Host + " 200", ub.Host + " 200", ua.Host + " 200"}
+	if fmt.Sprint(upstreams) != fmt.Sprint(expected) {
+		t.Errorf("expected upstreams %v, got %v", expected, upstreams)
+	}
+}
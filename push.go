@@ -0,0 +1,146 @@
+package wrap
+
+import (
+	"net/http"
+)
+
+// Push pushes the target via the http.Pusher of the ResponseWriter underlying rw (see ReclaimResponseWriter).
+// It returns http.ErrNotSupported, if there is none (e.g. the connection is no HTTP/2 connection).
+func Push(rw http.ResponseWriter, target string, opts *http.PushOptions) error {
+	p, ok := ReclaimResponseWriter(rw).(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// PushCandidate is an asset that should be pushed with the response
+type PushCandidate struct {
+	Target string
+	Opts   *http.PushOptions
+}
+
+// PushCandidates is the context type of the assets that wrappers want to push with the response, see
+// AddPushCandidate and CoordinatePush
+type PushCandidates []PushCandidate
+
+// has returns if the target is a candidate
+func (p PushCandidates) has(target string) bool {
+	for _, c := range p {
+		if c.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPushCandidate registers the target as PushCandidate in the context of rw, unless it is already registered.
+// It returns false, if the context does not support PushCandidates (i.e. there is no CoordinatePush in the stack
+// that will push it), in which case the caller may push the target itself via Push.
+func AddPushCandidate(rw http.ResponseWriter, target string, opts *http.PushOptions) bool {
+	var candidates PushCandidates
+	if found, err := TryContext(rw, &candidates); !found || err != nil {
+		return false
+	}
+	if candidates.has(target) {
+		return true
+	}
+	candidates = append(candidates, PushCandidate{Target: target, Opts: opts})
+	return TrySetContext(rw, &candidates) == nil
+}
+
+// CoordinatePush is a ContextWrapper that lets any following wrapper or handler register assets to push via
+// AddPushCandidate. Right before the status code is written (or the response is flushed), it pushes the
+// registered candidates via Push, each target once, so that wrappers do not push the same asset twice.
+// Candidates registered later are not pushed anymore. Pushes are ignored, if the connection does not support
+// them. It should be near the top of the stack.
+type CoordinatePush struct{}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = CoordinatePush{}
+
+// ValidateContext makes sure that ctx supports the PushCandidates type
+func (CoordinatePush) ValidateContext(ctx Contexter) {
+	var p PushCandidates
+	ctx.SetContext(&p)
+	ctx.Context(&p)
+}
+
+// pushWriter is the ResponseWriter passed to the next handlers by CoordinatePush
+type pushWriter struct {
+	http.ResponseWriter
+	pushed bool
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &pushWriter{}
+
+// push pushes the candidates, if not done yet
+func (p *pushWriter) push() {
+	if p.pushed {
+		return
+	}
+	p.pushed = true
+	var candidates PushCandidates
+	if found, err := TryContext(p.ResponseWriter, &candidates); !found || err != nil {
+		return
+	}
+	for _, c := range candidates {
+		if Push(p.ResponseWriter, c.Target, c.Opts) == http.ErrNotSupported {
+			return
+		}
+	}
+}
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (p *pushWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(p.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (p *pushWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(p.ResponseWriter, ctxPtr)
+}
+
+// WriteHeader pushes the candidates and writes the status code
+func (p *pushWriter) WriteHeader(code int) {
+	p.push()
+	p.ResponseWriter.WriteHeader(code)
+}
+
+// Write pushes the candidates and writes the body
+func (p *pushWriter) Write(b []byte) (int, error) {
+	p.push()
+	return p.ResponseWriter.Write(b)
+}
+
+// Flush pushes the candidates and flushes the underlying ResponseWriter
+func (p *pushWriter) Flush() {
+	p.push()
+	flushInner(p.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (p *pushWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (CoordinatePush) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ctx, ok := rw.(Contexter)
+		if !ok || IsProbe(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		var candidates PushCandidates
+		ctx.SetContext(&candidates)
+		pw := &pushWriter{ResponseWriter: rw}
+		next.ServeHTTP(pw, req)
+		pw.push()
+	}
+	return f
+}
@@ -0,0 +1,107 @@
+package wrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type pushContext struct {
+	http.ResponseWriter
+	candidates *PushCandidates
+}
+
+func (c *pushContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *PushCandidates:
+		if c.candidates == nil {
+			return false
+		}
+		*ty = *c.candidates
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *pushContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *PushCandidates:
+		p := *ty
+		c.candidates = &p
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c pushContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&pushContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+// registerPush is a wrapper that registers the targets as PushCandidates
+func registerPush(targets ...string) Wrapper {
+	var nf NextHandlerFunc
+	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		for _, t := range targets {
+			if !AddPushCandidate(rw, t, nil) {
+				panic("PushCandidates should be supported")
+			}
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return nf
+}
+
+func TestCoordinatePush(t *testing.T) {
+	ValidateWrapperContexts(&pushContext{}, CoordinatePush{})
+	h := New(
+		pushContext{},
+		CoordinatePush{},
+		registerPush("/a.css", "/b.js"),
+		registerPush("/a.css"),
+		Preload{Push: true},
+		Handler(writeSplitted(preloadPage, 10)),
+	)
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec.ResponseRecorder, preloadPage, 200)
+
+	if expected := []string{"/a.css", "/b.js", "/a.js"}; !reflect.DeepEqual(rec.pushed, expected) {
+		t.Errorf("pushes should be %v, but are %v", expected, rec.pushed)
+	}
+}
+
+func TestCoordinatePushLate(t *testing.T) {
+	h := New(pushContext{}, CoordinatePush{}, registerPush("/early"), HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(200)
+		AddPushCandidate(rw, "/late", nil)
+	}))
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(rec, req)
+
+	if !reflect.DeepEqual(rec.pushed, []string{"/early"}) {
+		t.Errorf("only the early candidate should be pushed, got %v", rec.pushed)
+	}
+}
+
+func TestAddPushCandidateWithoutContext(t *testing.T) {
+	if AddPushCandidate(httptest.NewRecorder(), "/a.css", nil) {
+		t.Errorf("AddPushCandidate should fail without Contexter")
+	}
+}
+
+func TestPush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := Push(rec, "/a.css", nil); err != http.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package wrap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RecordingExt is the file extension of recordings written by Record
+const RecordingExt = ".http"
+
+const (
+	recordingHeader = "# go-on/wrap recording\n"
+	recordingRemote = "# remote: "
+)
+
+// Record is a Wrapper that records each incoming request together with the final response
+// of the next handlers as a file inside Dir, so that it can be replayed later (see LoadRecordings).
+//
+// The response is written to a Buffer and flushed after it has been recorded, so Record
+// is not suitable for streaming responses.
+//
+// A recording is a text file starting with comment lines (beginning with #) for meta data
+// (e.g. the remote address), followed by the request and the response in HTTP/1.1 wire format.
+type Record struct {
+	// Dir is the directory the recordings are written to. It must exist.
+	Dir string
+
+	// OnError is called if a recording could not be written. If it is nil, errors are ignored.
+	OnError func(error)
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Record{}
+
+var recordCounter uint64
+
+// Wrap implements the Wrapper interface
+func (r Record) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		reqDump, err := dumpRequest(req)
+		bf := NewBuffer(rw)
+		next.ServeHTTP(bf, req)
+		if IsProbe(req) {
+			bf.FlushAll()
+			return
+		}
+		if err == nil {
+			err = r.write(req, reqDump, bf)
+		}
+		if err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		bf.FlushAll()
+	}
+	return f
+}
+
+// dumpRequest returns the request in wire format with a Content-Length header.
+// The body of req is replaced by a copy.
+func dumpRequest(req *http.Request) ([]byte, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	r := *req
+	r.Header = req.Header.Clone()
+	r.Header.Del("Transfer-Encoding")
+	r.TransferEncoding = nil
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	if len(body) > 0 {
+		r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	}
+	return httputil.DumpRequest(&r, true)
+}
+
+// write writes the recording to a new file
+func (r Record) write(req *http.Request, reqDump []byte, bf *Buffer) error {
+	var out bytes.Buffer
+	out.WriteString(recordingHeader)
+	out.WriteString(recordingRemote + req.RemoteAddr + "\n")
+	out.Write(reqDump)
+
+	code := bf.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	body := bf.Body()
+	resp := &http.Response{
+		StatusCode:    code,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        bf.header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	if err := resp.Write(&out); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%06d%s", time.Now().UnixNano(), atomic.AddUint64(&recordCounter, 1), RecordingExt)
+	return os.WriteFile(filepath.Join(r.Dir, name), out.Bytes(), 0644)
+}
+
+// Recording is a request and its response, recorded by Record
+type Recording struct {
+	// File is the file the recording has been loaded from
+	File string
+
+	// RemoteAddr is the remote address of the recorded request
+	RemoteAddr string
+
+	// Request is the request in HTTP/1.1 wire format
+	Request []byte
+
+	// Response is the response in HTTP/1.1 wire format
+	Response []byte
+}
+
+// ParseRecording parses the content of a recording file
+func ParseRecording(data []byte) (*Recording, error) {
+	rec := &Recording{}
+	rd := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := rd.Peek(1)
+		if err != nil || line[0] != '#' {
+			break
+		}
+		l, _ := rd.ReadString('\n')
+		if strings.HasPrefix(l, recordingRemote) {
+			rec.RemoteAddr = strings.TrimSpace(l[len(recordingRemote):])
+		}
+	}
+	rest, _ := io.ReadAll(rd)
+
+	// find the border between request and response by parsing the request
+	reqRd := bufio.NewReader(bytes.NewReader(rest))
+	req, err := http.ReadRequest(reqRd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.Discard, req.Body); err != nil {
+		return nil, err
+	}
+	remaining, _ := io.ReadAll(reqRd)
+	rec.Request = rest[:len(rest)-len(remaining)]
+	rec.Response = remaining
+	return rec, nil
+}
+
+// LoadRecordings loads all recordings inside the given directory, ordered by the time they have been recorded
+func LoadRecordings(dir string) ([]*Recording, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+RecordingExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	recs := make([]*Recording, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := ParseRecording(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		rec.File = file
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// NewRequest returns a new server side request for the recorded request
+func (r *Recording) NewRequest() (*http.Request, error) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(r.Request)))
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = r.RemoteAddr
+	return req, nil
+}
+
+// ExpectedResponse returns the recorded response
+func (r *Recording) ExpectedResponse() (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Response)), nil)
+}
+
+// Replay serves the recorded request with the given handler and returns the recorder of the response
+func (r *Recording) Replay(h http.Handler) (*httptest.ResponseRecorder, error) {
+	req, err := r.NewRequest()
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, nil
+}
+
+// Verify replays the recorded request with the given handler and returns an error if the
+// status code or the body differs from the recorded response.
+func (r *Recording) Verify(h http.Handler) error {
+	rec, err := r.Replay(h)
+	if err != nil {
+		return err
+	}
+	exp, err := r.ExpectedResponse()
+	if err != nil {
+		return err
+	}
+	expBody, err := io.ReadAll(exp.Body)
+	if err != nil {
+		return err
+	}
+	if rec.Code != exp.StatusCode {
+		return fmt.Errorf("%s: status code should be %d but is %d", r.File, exp.StatusCode, rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), expBody) {
+		return fmt.Errorf("%s: body should be %q but is %q", r.File, expBody, rec.Body.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	var errs []error
+	h := Stack(&context{}, Record{Dir: dir, OnError: func(err error) { errs = append(errs, err) }}, setUserIP{}, handleError{}, app{})
+
+	for _, addr := range []string{"127.0.0.1:45643", "garbage"} {
+		req, _ := http.NewRequest("POST", "/path?q=1", strings.NewReader("the body"))
+		req.RemoteAddr = addr
+		rec, _ := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		if rec.Body.Len() == 0 {
+			t.Errorf("response should be flushed after recording")
+		}
+	}
+
+	if len(errs) > 0 {
+		t.Fatalf("recording failed: %v", errs)
+	}
+
+	recs, err := LoadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 recordings, got %d", len(recs))
+	}
+
+	if recs[0].RemoteAddr != "127.0.0.1:45643" || recs[1].RemoteAddr != "garbage" {
+		t.Errorf("recordings should be ordered and keep the remote address, got %#v and %#v", recs[0].RemoteAddr, recs[1].RemoteAddr)
+	}
+
+	req, err := recs[0].NewRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.URL.String() != "/path?q=1" {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL)
+	}
+
+	exp, err := recs[1].ExpectedResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp.StatusCode != 500 {
+		t.Errorf("recorded status code should be 500, but is %d", exp.StatusCode)
+	}
+
+	for _, r := range recs {
+		if err := r.Verify(Stack(&context{}, setUserIP{}, handleError{}, app{})); err != nil {
+			t.Errorf("replay through the same stack should verify, but: %v", err)
+		}
+	}
+
+	if err := recs[0].Verify(New(Handler(write("other")))); err == nil {
+		t.Errorf("replay through another stack should not verify")
+	}
+}
@@ -0,0 +1,98 @@
+package wrap
+
+import (
+	"net/http"
+)
+
+// asRecover is the role in which the Recover wrapper reports panics to a PanicDebugger
+const asRecover = "Recover"
+
+// recoverer is the Wrapper returned by Recover
+type recoverer struct {
+	handler func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte)
+}
+
+// Recover returns a Wrapper that recovers panics of the next handlers in the stack.
+//
+// The next handlers write to a Peek, so the headers and the status code are only flushed when
+// the body is written. If a panic happens before, the cached headers and status code are discarded
+// and handler is called with the ResponseWriter that Recover received, so that it can write
+// a complete error response. If the body has already been (partially) written, the response can't
+// be repaired anymore: handler receives a ResponseWriter that discards everything (but may still
+// log the panic) and afterwards Recover panics with http.ErrAbortHandler, so that net/http aborts
+// the connection and the client does not take the truncated response for a complete one.
+//
+// If handler is nil, a 500 Internal Server Error is written. PanicPage returns handlers for development
+// (rendering the panic, the stack trace, the wrappers and context values) and for production.
+//
+// Panics with http.ErrAbortHandler or *ErrClientGone (see Disconnect) are not recovered.
+//
+// If DEBUG is set and the DEBUGGER is a PanicDebugger, the panic is reported to it, unless it has
+// already been reported by the debug wrappers of the next handlers.
+func Recover(handler func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte)) Wrapper {
+	return &recoverer{handler}
+}
+
+// Wrap implements the Wrapper interface
+func (r *recoverer) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		p := NewPeek(rw, func(p *Peek) bool {
+			p.FlushMissing()
+			return true
+		})
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if isAbort(recovered) {
+				panic(recovered)
+			}
+			stack := stackTrace()
+			r.debugPanic(req, recovered, stack)
+
+			if p.bodyWritten || p.codeWritten {
+				if r.handler != nil {
+					r.handler(discardWriter{}, req, recovered, stack)
+				}
+				panic(http.ErrAbortHandler)
+			}
+
+			if r.handler == nil {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			r.handler(rw, req, recovered, stack)
+		}()
+		next.ServeHTTP(p, req)
+		p.FlushMissing()
+	}
+	return f
+}
+
+// debugPanic reports the panic to the DEBUGGER if it is a PanicDebugger and it has not already
+// been reported by a debug wrapper
+func (r *recoverer) debugPanic(req *http.Request, recovered interface{}, stack []byte) {
+	if !IsDebug() || req == nil {
+		return
+	}
+	pd, ok := CurrentDebugger().(PanicDebugger)
+	if !ok {
+		return
+	}
+	if state, has := requestValue(req, debugKey).(*debugState); has {
+		if state.reported {
+			return
+		}
+		state.reported = true
+	}
+	pd.DebugPanic(req, r, asRecover, recovered, stack)
+}
+
+// discardWriter is a ResponseWriter that discards everything
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header         { return http.Header{} }
+func (discardWriter) WriteHeader(int)             {}
+func (discardWriter) Write(b []byte) (int, error) { return len(b), nil }
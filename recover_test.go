@@ -0,0 +1,143 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRecoverDefault(t *testing.T) {
+	h := New(
+		Recover(nil),
+		HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Cached", "yes")
+			rw.WriteHeader(201)
+			panic("boom")
+		}),
+	)
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "Internal Server Error", 500)
+
+	if rec.Header().Get("X-Cached") != "" {
+		t.Errorf("cached headers should be discarded")
+	}
+}
+
+func TestRecoverHandler(t *testing.T) {
+	var recovered interface{}
+	var stack []byte
+	h := New(
+		Recover(func(rw http.ResponseWriter, req *http.Request, rec interface{}, st []byte) {
+			recovered, stack = rec, st
+			rw.WriteHeader(503)
+			rw.Write([]byte("sorry"))
+		}),
+		Handler(panicker("boom")),
+	)
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "sorry", 503)
+
+	if recovered != "boom" || len(stack) == 0 {
+		t.Errorf("handler should receive the recovered value and the stack, got %#v", recovered)
+	}
+}
+
+func TestRecoverPartial(t *testing.T) {
+	var called bool
+	h := New(
+		Recover(func(rw http.ResponseWriter, req *http.Request, rec interface{}, st []byte) {
+			called = true
+			rw.WriteHeader(503)
+			rw.Write([]byte("sorry"))
+		}),
+		write("a"),
+		Handler(panicker("boom")),
+	)
+	rec, req := newTestRequest("GET", "/")
+	func() {
+		defer func() {
+			// the connection must be aborted, so that the truncated response is not taken for a complete one
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("expected panic with http.ErrAbortHandler, got %#v", p)
+			}
+		}()
+		h.ServeHTTP(rec, req)
+	}()
+	assertResponse(t, rec, "a", 200)
+
+	if !called {
+		t.Errorf("handler should be called for partially written responses")
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	var called bool
+	h := New(
+		Recover(func(rw http.ResponseWriter, req *http.Request, rec interface{}, st []byte) {
+			called = true
+		}),
+		HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			panic(http.ErrAbortHandler)
+		}),
+	)
+	rec, req := newTestRequest("GET", "/")
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("http.ErrAbortHandler should not be recovered, got %#v", p)
+			}
+		}()
+		h.ServeHTTP(rec, req)
+	}()
+
+	if called {
+		t.Errorf("handler should not be called for http.ErrAbortHandler")
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(Recover(nil), NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		writeHeader(rw, req)
+		writeCode(rw, req)
+	})).ServeHTTP(rec, req)
+	assertResponse(t, rec, "", 407)
+
+	if rec.Header().Get("a") != "b" {
+		t.Errorf("headers should be flushed")
+	}
+}
+
+func TestRecoverDebugPanic(t *testing.T) {
+	old := CurrentDebugger()
+	pd := &panicDebugger{}
+	SetDebugger(pd)
+	SetDebug()
+
+	h := New(
+		Recover(nil),
+		Handler(panicker("boom")),
+	)
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	if pd.calls != 1 || pd.role != asHandler {
+		t.Errorf("panic should be reported once by the debug wrapper of the handler, got %d calls, role %s", pd.calls, pd.role)
+	}
+
+	// without debug wrappers, Recover reports the panic itself
+	DisableDebug()
+	pd.calls = 0
+	h = New(Recover(nil), Handler(panicker("boom")))
+	SetDebug()
+
+	h.ServeHTTP(rec, req)
+	DisableDebug()
+	SetDebugger(old)
+
+	if pd.calls != 1 || pd.role != asRecover {
+		t.Errorf("panic should be reported once by Recover, got %d calls, role %s", pd.calls, pd.role)
+	}
+}
@@ -0,0 +1,52 @@
+package wrap
+
+import (
+	stdcontext "context"
+	"net/http"
+)
+
+// requestKey is the type of the keys under which this package stores internal per request data
+// inside the context.Context of a http.Request.
+// It is used for data that must be available regardless of the Contexter of a stack.
+type requestKey int
+
+const (
+	timingKey requestKey = iota
+	sampleKey
+	debugKey
+	coverageKey
+	probeKey
+	patternKey
+	roundTripKey
+	proxyKey
+	bodyLimitKey
+)
+
+// withRequestValue returns a shallow copy of req with the given value stored under the given key
+func withRequestValue(req *http.Request, key requestKey, val interface{}) *http.Request {
+	return req.WithContext(stdcontext.WithValue(req.Context(), key, val))
+}
+
+// requestValue returns the value stored under the given key inside req, nil if there is none
+func requestValue(req *http.Request, key requestKey) interface{} {
+	if req == nil {
+		return nil
+	}
+	return req.Context().Value(key)
+}
+
+// newProbeRequest returns a synthetic request that is used to probe stacks at build time.
+// Its context is already canceled, so that handlers waiting for it return immediately.
+func newProbeRequest() *http.Request {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/", nil)
+	return withRequestValue(req, probeKey, true)
+}
+
+// IsProbe returns if req is the synthetic request that is served when the wrappers of a stack are
+// probed at build time (see STRICT and Probe). Wrappers and handlers with side effects (like writing
+// files or changing a database) should skip it.
+func IsProbe(req *http.Request) bool {
+	return requestValue(req, probeKey) != nil
+}
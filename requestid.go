@@ -0,0 +1,165 @@
+package wrap
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader is the name of the request and response header that carries the request id
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID is the context type of the identifier of a request
+type RequestID string
+
+// requestIDFallback is used to generate request ids if crypto/rand fails
+var requestIDFallback uint64
+
+// NewRequestID returns a new random request id of 32 hex characters
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x-%x", time.Now().UnixNano(), atomic.AddUint64(&requestIDFallback, 1))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// NewUUID returns a new random (version 4) UUID as defined by RFC 4122, e.g.
+// "2c1d9a4e-5b7f-4c3a-9e8d-0f1a2b3c4d5e". It may be used as generator of SetRequestID.
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return NewRequestID()
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a new ULID (https://github.com/ulid/spec) of 26 characters, which sorts by the
+// time it has been created (in milliseconds). It may be used as generator of SetRequestID.
+func NewULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(b[6:]); err != nil {
+		binary.BigEndian.PutUint64(b[8:], atomic.AddUint64(&requestIDFallback, 1))
+	}
+
+	// 128 bits as 26 characters of 5 bits, the first character has 3 bits only
+	var out [26]byte
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// RequestIDOf returns the request id of the request: the RequestID of the context, if rw is a Contexter
+// supporting it, otherwise the X-Request-ID header of the request (see SetRequestID).
+// It returns an empty string if there is none.
+func RequestIDOf(rw http.ResponseWriter, req *http.Request) string {
+	var id RequestID
+	if found, err := TryContext(rw, &id); found && err == nil && id != "" {
+		return string(id)
+	}
+	return req.Header.Get(RequestIDHeader)
+}
+
+// validRequestID returns if the given id from an incoming request may be propagated
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 200 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// SetRequestID is a ContextWrapper that propagates the request id from the X-Request-ID header
+// (or the Headers) of the request or generates a new one, if there is none.
+// It is the convention of this package for request ids: wrappers and handlers get the request id
+// via RequestIDOf.
+//
+// The request id is set as X-Request-ID header on the request (so that the debugger and
+// later wrappers see it) and on the response. If the ResponseWriter is a Contexter, the request
+// id is also stored as RequestID inside the context.
+type SetRequestID struct {
+	// Generate generates a new request id. If it is nil, NewRequestID is used.
+	// NewUUID and NewULID are alternatives.
+	Generate func() string
+
+	// Headers are the request headers the request id is propagated from, the first valid one wins.
+	// If it is empty, X-Request-ID is used. Other headers are useful for load balancers that set e.g.
+	// X-Amzn-Trace-Id or X-Correlation-ID. The request id is always set as X-Request-ID.
+	Headers []string
+
+	// IgnoreIncoming lets SetRequestID always generate a new request id instead of propagating
+	// the one of the incoming request. Set it, if the clients are not trusted.
+	IgnoreIncoming bool
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = SetRequestID{}
+
+// ValidateContext makes sure that ctx supports the RequestID type
+func (SetRequestID) ValidateContext(ctx Contexter) {
+	var id RequestID
+	ctx.SetContext(&id)
+	ctx.Context(&id)
+}
+
+// incoming returns the first valid request id of the Headers of the request
+func (s SetRequestID) incoming(req *http.Request) string {
+	if len(s.Headers) == 0 {
+		return req.Header.Get(RequestIDHeader)
+	}
+	for _, h := range s.Headers {
+		if id := req.Header.Get(h); validRequestID(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// Wrap implements the Wrapper interface
+func (s SetRequestID) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		id := s.incoming(req)
+		if s.IgnoreIncoming || !validRequestID(id) {
+			if s.Generate != nil {
+				id = s.Generate()
+			} else {
+				id = NewRequestID()
+			}
+		}
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(RequestIDHeader, id)
+		rw.Header().Set(RequestIDHeader, id)
+		if ctx, ok := rw.(Contexter); ok {
+			rid := RequestID(id)
+			ctx.SetContext(&rid)
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,191 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type requestIDContext struct {
+	http.ResponseWriter
+	id *RequestID
+}
+
+func (c *requestIDContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *RequestID:
+		if c.id == nil {
+			return false
+		}
+		*ty = *c.id
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *requestIDContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *RequestID:
+		id := *ty
+		c.id = &id
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c requestIDContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&requestIDContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func writeRequestID(rw http.ResponseWriter, req *http.Request) {
+	var id RequestID
+	rw.(Contexter).Context(&id)
+	rw.Write([]byte(id))
+}
+
+func TestSetRequestIDPropagate(t *testing.T) {
+	ValidateWrapperContexts(&requestIDContext{}, SetRequestID{})
+	h := Stack(&requestIDContext{}, SetRequestID{}, HandlerFunc(writeRequestID))
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "abc", 200)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc" {
+		t.Errorf("response header should be %#v, but is %#v", "abc", got)
+	}
+}
+
+func TestSetRequestIDGenerate(t *testing.T) {
+	h := New(SetRequestID{Generate: func() string { return "gen" }, IgnoreIncoming: true})
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "gen" {
+		t.Errorf("response header should be %#v, but is %#v", "gen", got)
+	}
+
+	if got := req.Header.Get(RequestIDHeader); got != "gen" {
+		t.Errorf("request header should be %#v, but is %#v", "gen", got)
+	}
+
+	rec, req = newTestRequest("GET", "/")
+	req.Header.Set(RequestIDHeader, "in valid")
+	New(SetRequestID{}).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); len(got) != 32 {
+		t.Errorf("generated request id should have 32 characters, but is %#v", got)
+	}
+}
+
+func TestDebugRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	SetDebug()
+	defer DisableDebug()
+	h := New(SetRequestID{Generate: func() string { return "xyz" }}, Handler(write("a")))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	splitted := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if len(splitted) != 4 {
+		t.Fatalf("expected 4 lines, got %d", len(splitted))
+	}
+
+	// the first wrapper runs before the request id is set
+	for _, line := range splitted[1:] {
+		if !strings.Contains(line, "[xyz] GET /") {
+			t.Errorf("%#v should contain the request id", line)
+		}
+	}
+}
+
+func TestSetRequestIDHeaders(t *testing.T) {
+	h := New(SetRequestID{Headers: []string{"X-Correlation-ID", "X-Amzn-Trace-Id"}})
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set("X-Correlation-ID", "in valid")
+	req.Header.Set("X-Amzn-Trace-Id", "trace")
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "trace" {
+		t.Errorf("response header should be %#v, but is %#v", "trace", got)
+	}
+}
+
+func TestRequestIDOf(t *testing.T) {
+	var ids []string
+	record := func(rw http.ResponseWriter, req *http.Request) { ids = append(ids, RequestIDOf(rw, req)) }
+
+	h := Stack(&requestIDContext{}, SetRequestID{Generate: func() string { return "ctx" }}, HandlerFunc(record))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	rec, req = newTestRequest("GET", "/")
+	New(SetRequestID{Generate: func() string { return "header" }}, HandlerFunc(record)).ServeHTTP(rec, req)
+
+	rec, req = newTestRequest("GET", "/")
+	New(HandlerFunc(record)).ServeHTTP(rec, req)
+
+	if strings.Join(ids, ",") != "ctx,header," {
+		t.Errorf("unexpected request ids %v", ids)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	id := NewUUID()
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("invalid UUID %#v", id)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	a := NewULID()
+	time.Sleep(2 * time.Millisecond)
+	b := NewULID()
+	re := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	if !re.MatchString(a) || !re.MatchString(b) {
+		t.Errorf("invalid ULIDs %#v, %#v", a, b)
+	}
+	if a >= b {
+		t.Errorf("ULID %#v should sort before %#v", a, b)
+	}
+}
+
+func TestLogDebuggerRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	l := &logDebugger{log.New(&buf, "", 0)}
+	_, req := newTestRequest("GET", "/")
+	req.Header.Set(RequestIDHeader, "xyz")
+	l.Debug(req, write("a"), asHandler)
+	l.DebugPanic(req, write("a"), asHandler, "boom", nil)
+	l.DebugWriteError(req, write("a"), asHandler, io.ErrClosedPipe)
+	l.DebugTransport(req, write("a"), "http.RoundTripper")
+	l.DebugTransportDone(req, write("a"), "http.RoundTripper", 200, nil, time.Second)
+	l.DebugTransportDone(req, write("a"), "http.RoundTripper", 0, io.EOF, time.Second)
+	l.DebugEvent(req, Event{Name: "e"})
+
+	splitted := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(splitted) != 7 {
+		t.Fatalf("expected 7 lines, got %d", len(splitted))
+	}
+	for _, line := range splitted {
+		if !strings.HasPrefix(line, "[xyz] ") {
+			t.Errorf("%#v should start with the request id", line)
+		}
+	}
+}
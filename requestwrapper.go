@@ -0,0 +1,40 @@
+package wrap
+
+import "net/http"
+
+// asRequestWrapper is the role of a RequestWrapper used via ModifyRequest
+const asRequestWrapper = "RequestWrapper"
+
+// RequestWrapper transforms the request before it is passed to the next handler, e.g. rewriting
+// the URL or adding headers
+type RequestWrapper interface {
+	// WrapRequest returns the request for the next handler. It may be the given request,
+	// a modified clone of it or a new request. It must not return nil.
+	WrapRequest(req *http.Request) *http.Request
+}
+
+// RequestWrapperFunc is an adapter for a function that acts as RequestWrapper
+type RequestWrapperFunc func(*http.Request) *http.Request
+
+// WrapRequest makes the RequestWrapperFunc fulfill the RequestWrapper interface by calling itself.
+func (rf RequestWrapperFunc) WrapRequest(req *http.Request) *http.Request { return rf(req) }
+
+// ModifyRequest returns a Wrapper for a RequestWrapper. The returned Wrapper passes the request
+// returned by the RequestWrapper to the next handler. If DEBUG is set, the RequestWrapper is debugged
+// in the role "RequestWrapper".
+func ModifyRequest(r RequestWrapper) Wrapper {
+	var nf NextHandlerFunc
+
+	if IsDebug() {
+		d := newDebug(r, asRequestWrapper, nil)
+		nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			var f http.HandlerFunc
+			f = func(rw http.ResponseWriter, req *http.Request) { next.ServeHTTP(rw, r.WrapRequest(req)) }
+			d.serve(f, rw, req)
+		}
+		return nf
+	}
+
+	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) { next.ServeHTTP(rw, r.WrapRequest(req)) }
+	return nf
+}
@@ -0,0 +1,58 @@
+package wrap
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// lowerPath is a RequestWrapper that lowercases the path
+type lowerPath struct{}
+
+func (lowerPath) WrapRequest(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+	req.URL.Path = strings.ToLower(req.URL.Path)
+	return req
+}
+
+func writePath(rw http.ResponseWriter, req *http.Request) {
+	rw.Write([]byte(req.URL.Path))
+}
+
+func TestModifyRequest(t *testing.T) {
+	h := New(
+		ModifyRequest(lowerPath{}),
+		ModifyRequest(RequestWrapperFunc(func(req *http.Request) *http.Request {
+			req = req.Clone(req.Context())
+			req.URL.Path += "/x"
+			return req
+		})),
+		HandlerFunc(writePath),
+	)
+	rec, req := newTestRequest("GET", "/A/B")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "/a/b/x", 200)
+
+	if req.URL.Path != "/A/B" {
+		t.Errorf("original request should not be changed")
+	}
+}
+
+func TestModifyRequestDebug(t *testing.T) {
+	var buf bytes.Buffer
+	old := CurrentDebugger()
+	NewLogDebugger(&buf, 0)
+	SetDebug()
+	defer DisableDebug()
+	h := New(ModifyRequest(lowerPath{}), HandlerFunc(writePath))
+
+	rec, req := newTestRequest("GET", "/A")
+	h.ServeHTTP(rec, req)
+	SetDebugger(old)
+	assertResponse(t, rec, "/a", 200)
+
+	if out := buf.String(); !strings.Contains(out, "GET /A wrap.lowerPath as RequestWrapper") {
+		t.Errorf("unexpected debug output %#v", out)
+	}
+}
@@ -0,0 +1,19 @@
+package wrap
+
+import (
+	"net/http"
+	"time"
+)
+
+// ResponseController returns a http.ResponseController for the ResponseWriter underlying rw
+// (see ReclaimResponseWriter). Since Contexters usually have no Unwrap method, http.NewResponseController
+// can't reach the ResponseWriter of the server through them and its methods would return
+// http.ErrNotSupported.
+func ResponseController(rw http.ResponseWriter) *http.ResponseController {
+	return http.NewResponseController(ReclaimResponseWriter(rw))
+}
+
+// setWriteDeadline sets the write deadline of the connection of the ResponseWriter underlying rw
+func setWriteDeadline(rw http.ResponseWriter, at time.Time) error {
+	return ResponseController(rw).SetWriteDeadline(at)
+}
@@ -0,0 +1,16 @@
+package wrap
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseController(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := ResponseController(&context{ResponseWriter: rec}).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Flushed {
+		t.Errorf("the underlying ResponseWriter should be flushed")
+	}
+}
@@ -2,6 +2,8 @@ package wrap
 
 import (
 	"bufio"
+	"bytes"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -657,3 +659,119 @@ func TestCloseNotifier(t *testing.T) {
 	}
 
 }
+
+func TestReleasePeek(t *testing.T) {
+	rec, _ := newTestRequest("GET", "/")
+	p := AcquirePeek(rec, nil)
+	p.Header().Set("X-Test", "1")
+	p.WriteHeader(201)
+	p.Write([]byte("body"))
+	ReleasePeek(p)
+
+	if p.ResponseWriter != nil || p.Code != 0 || p.HasChanged() || len(p.header) != 0 || p.bodyWritten {
+		t.Errorf("released Peek should be reset, got %#v", p)
+	}
+}
+
+func TestReleaseBuffer(t *testing.T) {
+	rec, _ := newTestRequest("GET", "/")
+	bf := AcquireBuffer(rec)
+	bf.Header().Set("X-Test", "1")
+	bf.WriteHeader(201)
+	bf.Write([]byte("body"))
+	ReleaseBuffer(bf)
+
+	if bf.ResponseWriter != nil || bf.Code != 0 || bf.HasChanged() || len(bf.header) != 0 || bf.Buffer.Len() != 0 {
+		t.Errorf("released Buffer should be reset, got %#v", bf)
+	}
+}
+
+func TestStreamBuffer(t *testing.T) {
+	upper := func(bf *Buffer, body io.Reader) {
+		bf.FlushHeaders()
+		bf.FlushCode()
+		buf := make([]byte, 3)
+		for {
+			n, err := body.Read(buf)
+			bf.ResponseWriter.Write(bytes.ToUpper(buf[:n]))
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	rec, _ := newTestRequest("GET", "/")
+	bf := NewStreamBuffer(rec, 4, upper)
+	bf.Header().Set("X-Test", "1")
+	bf.WriteHeader(201)
+	bf.Write([]byte("abc"))
+	if bf.IsStreaming() {
+		t.Errorf("Buffer should not stream below the threshold")
+	}
+	bf.Write([]byte("defgh"))
+	bf.Write([]byte("ij"))
+	if !bf.IsStreaming() {
+		t.Errorf("Buffer should stream above the threshold")
+	}
+	bf.Done()
+
+	assertResponse(t, rec, "ABCDEFGHIJ", 201)
+	if rec.Header().Get("X-Test") != "1" {
+		t.Errorf("headers should be flushed by the stream function")
+	}
+	if bf.Buffer.Len() != 8 {
+		t.Errorf("only the bytes up to the threshold should be buffered, got %d", bf.Buffer.Len())
+	}
+
+	rec, _ = newTestRequest("GET", "/")
+	bf = NewStreamBuffer(rec, 4, upper)
+	bf.Write([]byte("abc"))
+	bf.Done()
+	if bf.IsStreaming() || bf.BodyString() != "abc" {
+		t.Errorf("Buffer below the threshold should buffer, got %q", bf.BodyString())
+	}
+}
+
+func TestStreamBufferStopped(t *testing.T) {
+	rec, _ := newTestRequest("GET", "/")
+	bf := NewStreamBuffer(rec, 1, func(bf *Buffer, body io.Reader) {})
+	bf.Write([]byte("ab"))
+
+	if _, err := bf.Write([]byte("c")); err != io.ErrClosedPipe {
+		t.Errorf("write after the stream function returned should fail with io.ErrClosedPipe, got %v", err)
+	}
+	bf.Done()
+}
+
+func TestPassThroughBuffer(t *testing.T) {
+	rec, _ := newTestRequest("GET", "/")
+	bf := NewPassThroughBuffer(rec, 4)
+	bf.Header().Set("X-Test", "1")
+	bf.WriteHeader(201)
+	bf.Write([]byte("abc"))
+	if bf.IsPassingThrough() || rec.Body.Len() != 0 {
+		t.Errorf("Buffer should buffer below the threshold")
+	}
+	bf.Write([]byte("de"))
+	if !bf.IsPassingThrough() || rec.Body.String() != "abcde" || bf.Buffer.Len() != 0 {
+		t.Errorf("Buffer should pass through above the threshold, got %q", rec.Body.String())
+	}
+	bf.WriteHeader(500)
+	bf.Header().Set("X-Trailer", "2")
+	bf.Write([]byte("fg"))
+	bf.FlushAll()
+
+	assertResponse(t, rec, "abcdefg", 201)
+	if rec.Header().Get("X-Test") != "1" || rec.Header().Get("X-Trailer") != "2" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	rec, _ = newTestRequest("GET", "/")
+	bf = NewPassThroughBuffer(rec, 4)
+	bf.Write([]byte("abc"))
+	if bf.IsPassingThrough() || bf.BodyString() != "abc" || rec.Body.Len() != 0 {
+		t.Errorf("Buffer below the threshold should buffer, got %q", bf.BodyString())
+	}
+	bf.FlushAll()
+	assertResponse(t, rec, "abc", 200)
+}
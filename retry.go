@@ -0,0 +1,139 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// idempotentMethods are the methods that may be retried
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PUT":     true,
+	"DELETE":  true,
+}
+
+// DefaultRetryCodes are the status codes that are retried by Retry, if Codes is not set
+var DefaultRetryCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// Retry is a Wrapper that runs the next handler against a Buffer and, if the buffered response has one
+// of the given status codes, discards it and runs the next handler again, after waiting for the backoff.
+// The response of the final attempt is flushed to the real ResponseWriter.
+//
+// Only requests with idempotent methods (GET, HEAD, OPTIONS, TRACE, PUT and DELETE) are retried,
+// others are passed to the next handler untouched. The request body is read into memory, so that
+// it can be replayed for every attempt. Retrying stops when the context of the request is done.
+type Retry struct {
+	// Retries is the maximal number of retries after the first attempt. Defaults to 2.
+	Retries int
+
+	// Codes are the status codes that are retried. Defaults to DefaultRetryCodes.
+	Codes []int
+
+	// Backoff returns the time to wait before the given retry (starting with 1).
+	// Defaults to an exponential backoff beginning with 100ms.
+	Backoff func(retry int) time.Duration
+
+	// OnRetry is called before each retry with the status code of the failed attempt (optional)
+	OnRetry func(req *http.Request, retry int, code int)
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Retry{}
+
+func (r Retry) retries() int {
+	if r.Retries <= 0 {
+		return 2
+	}
+	return r.Retries
+}
+
+func (r Retry) retryable(code int) bool {
+	return retryableCode(r.Codes, code)
+}
+
+// retryableCode returns if the code is one of codes, which default to DefaultRetryCodes
+func retryableCode(codes []int, code int) bool {
+	if len(codes) == 0 {
+		codes = DefaultRetryCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// exponentialBackoff is the default backoff of Retry and RetryTransport
+func exponentialBackoff(retry int) time.Duration {
+	return (100 * time.Millisecond) << uint(retry-1)
+}
+
+func (r Retry) backoff(retry int) time.Duration {
+	if r.Backoff != nil {
+		return r.Backoff(retry)
+	}
+	return exponentialBackoff(retry)
+}
+
+// sleep waits for the given duration and returns false if the request is done before
+func sleep(req *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return req.Context().Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-req.Context().Done():
+		return false
+	}
+}
+
+// Wrap implements the Wrapper interface
+func (r Retry) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if !idempotentMethods[req.Method] || IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			var err error
+			body, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+		}
+
+		for retry := 0; ; retry++ {
+			if body != nil {
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			bf := NewBuffer(rw)
+			next.ServeHTTP(bf, req)
+			if retry == r.retries() || !r.retryable(bf.Code) {
+				bf.FlushAll()
+				return
+			}
+			if r.OnRetry != nil {
+				r.OnRetry(req, retry+1, bf.Code)
+			}
+			if !sleep(req, r.backoff(retry+1)) {
+				bf.FlushAll()
+				return
+			}
+		}
+	}
+	return f
+}
@@ -0,0 +1,86 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flaky fails with the given codes before it succeeds
+type flaky struct {
+	codes    []int
+	attempts int
+	bodies   []string
+}
+
+func (f *flaky) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		f.bodies = append(f.bodies, string(b))
+	}
+	f.attempts++
+	if f.attempts <= len(f.codes) {
+		rw.Header().Set("X-Failed", "yes")
+		rw.WriteHeader(f.codes[f.attempts-1])
+		fmt.Fprintf(rw, "attempt %d", f.attempts)
+		return
+	}
+	fmt.Fprintf(rw, "attempt %d", f.attempts)
+}
+
+func noBackoff(int) time.Duration { return 0 }
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		method   string
+		codes    []int
+		body     string
+		code     int
+		attempts int
+	}{
+		{"GET", []int{503, 502}, "attempt 3", 200, 3},
+		{"GET", []int{503, 503, 503}, "attempt 3", 503, 3},
+		{"GET", []int{500}, "attempt 1", 500, 1},
+		{"POST", []int{503}, "attempt 1", 503, 1},
+	}
+
+	for i, test := range tests {
+		f := &flaky{codes: test.codes}
+		rec, req := newTestRequest(test.method, "/")
+		New(Retry{Backoff: noBackoff}, Handler(f)).ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, test.code)
+		if f.attempts != test.attempts {
+			t.Errorf("[%d] expected %d attempts, got %d", i, test.attempts, f.attempts)
+		}
+		if test.code == 200 && rec.Header().Get("X-Failed") != "" {
+			t.Errorf("[%d] headers of failed attempts should be discarded", i)
+		}
+	}
+}
+
+func TestRetryBody(t *testing.T) {
+	f := &flaky{codes: []int{504}}
+	var retries []string
+	r := Retry{
+		Retries: 1,
+		Backoff: noBackoff,
+		OnRetry: func(req *http.Request, retry int, code int) {
+			retries = append(retries, fmt.Sprintf("%d:%d", retry, code))
+		},
+	}
+	rec, _ := newTestRequest("PUT", "/")
+	req, _ := http.NewRequest("PUT", "/", strings.NewReader("payload"))
+	New(r, Handler(f)).ServeHTTP(rec, req)
+	assertResponse(t, rec, "attempt 2", 200)
+
+	if strings.Join(f.bodies, ",") != "payload,payload" {
+		t.Errorf("body should be replayed, got %v", f.bodies)
+	}
+
+	if strings.Join(retries, ",") != "1:504" {
+		t.Errorf("unexpected retries %v", retries)
+	}
+}
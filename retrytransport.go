@@ -0,0 +1,178 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryTransport is a RoundTripWrapper that retries failed requests with an exponential backoff.
+// A request fails if the next RoundTripper returns an error or a response with one of the given
+// status codes. Only idempotent requests are retried (see Idempotent).
+//
+// If the response has a Retry-After header (in seconds or as HTTP date), it is waited for that time
+// instead of the backoff, but not longer than MaxRetryAfter; otherwise the response is returned.
+//
+// To be able to resend the body, it is taken from the GetBody function of the request. If there is none,
+// the body is read into memory and GetBody is set. A body larger than MaxBodyBytes is not read into memory,
+// the request is then sent once without retries.
+//
+// If the request has a RoundTripContext, the current Attempt is stored in it.
+type RetryTransport struct {
+	// Retries is the maximal number of retries after the first attempt. Defaults to 2.
+	Retries int
+
+	// Codes are the status codes that are retried. Defaults to DefaultRetryCodes.
+	Codes []int
+
+	// Backoff returns the time to wait before the given retry (starting with 1).
+	// Defaults to an exponential backoff beginning with 100ms.
+	Backoff func(retry int) time.Duration
+
+	// MaxRetryAfter is the maximal time that is waited for a Retry-After header. Defaults to 30s.
+	MaxRetryAfter time.Duration
+
+	// Idempotent returns if the request may be retried. Defaults to requests with the methods GET, HEAD,
+	// OPTIONS, TRACE, PUT and DELETE and requests with an Idempotency-Key or X-Idempotency-Key header.
+	Idempotent func(req *http.Request) bool
+
+	// MaxBodyBytes is the maximal size of a request body without GetBody that is read into memory to be
+	// resent. Defaults to 1 MiB.
+	MaxBodyBytes int64
+}
+
+// retryDrainLimit is the maximal number of bytes that are read from the body of a failed response
+// before it is closed, so that the connection may be reused
+const retryDrainLimit = 64 << 10
+
+// make sure to fulfill the RoundTripWrapper interface
+var _ RoundTripWrapper = RetryTransport{}
+
+func (r RetryTransport) idempotent(req *http.Request) bool {
+	if r.Idempotent != nil {
+		return r.Idempotent(req)
+	}
+	if idempotentMethods[req.Method] {
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+func (r RetryTransport) maxBodyBytes() int64 {
+	if r.MaxBodyBytes <= 0 {
+		return 1 << 20
+	}
+	return r.MaxBodyBytes
+}
+
+func (r RetryTransport) maxRetryAfter() time.Duration {
+	if r.MaxRetryAfter <= 0 {
+		return 30 * time.Second
+	}
+	return r.MaxRetryAfter
+}
+
+// wait returns the time to wait before the given retry and if the request should be retried
+func (r RetryTransport) wait(res *http.Response, retry int) (time.Duration, bool) {
+	if res != nil {
+		if after, has := retryAfter(res.Header.Get("Retry-After")); has {
+			return after, after <= r.maxRetryAfter()
+		}
+	}
+	if r.Backoff != nil {
+		return r.Backoff(retry), true
+	}
+	return exponentialBackoff(retry), true
+}
+
+// retryAfter parses the value of a Retry-After header
+func retryAfter(val string) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// rewindable makes sure that the request has a GetBody function, reading at most limit bytes of the body
+// into memory. If the body is larger, the request is returned with a body that replays the read bytes
+// before the rest and false, since it can't be resent.
+func rewindable(req *http.Request, limit int64) (*http.Request, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return req, true, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, false, err
+	}
+	req = req.Clone(req.Context())
+	if int64(len(body)) > limit {
+		req.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+		return req, false, nil
+	}
+	req.Body.Close()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return req, true, nil
+}
+
+// WrapRoundTrip implements the RoundTripWrapper interface
+func (r RetryTransport) WrapRoundTrip(next http.RoundTripper) http.RoundTripper {
+	retries := r.Retries
+	if retries <= 0 {
+		retries = 2
+	}
+
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !r.idempotent(req) {
+			return next.RoundTrip(req)
+		}
+		req, ok, err := rewindable(req, r.maxBodyBytes())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return next.RoundTrip(req)
+		}
+
+		for retry := 0; ; retry++ {
+			attempt := Attempt(retry + 1)
+			SetRoundTripContext(req, &attempt)
+			res, err := next.RoundTrip(req)
+			if retry == retries || (err == nil && !retryableCode(r.Codes, res.StatusCode)) {
+				return res, err
+			}
+			d, ok := r.wait(res, retry+1)
+			if !ok {
+				return res, err
+			}
+			if res != nil {
+				io.Copy(io.Discard, io.LimitReader(res.Body, retryDrainLimit))
+				res.Body.Close()
+			}
+			if !sleep(req, d) {
+				return nil, req.Context().Err()
+			}
+			if req.GetBody != nil {
+				req = req.Clone(req.Context())
+				if req.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	})
+}
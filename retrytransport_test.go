@@ -0,0 +1,155 @@
+package wrap
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails with the given codes (0 means a network error) before it succeeds
+type flakyTransport struct {
+	codes      []int
+	retryAfter string
+	bodies     []string
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body string
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+	code := 200
+	if len(f.bodies) <= len(f.codes) {
+		code = f.codes[len(f.bodies)-1]
+	}
+	if code == 0 {
+		return nil, errors.New("connection reset")
+	}
+	res := &http.Response{StatusCode: code, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}
+	if f.retryAfter != "" {
+		res.Header.Set("Retry-After", f.retryAfter)
+	}
+	return res, nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		method     string
+		header     string
+		codes      []int
+		retryAfter string
+		code       int
+		attempts   int
+	}{
+		{"GET", "", []int{503, 0}, "", 200, 3},
+		{"GET", "", []int{503, 503, 503}, "", 503, 3},
+		{"GET", "", []int{500}, "", 500, 1},
+		{"POST", "", []int{503}, "", 503, 1},
+		{"POST", "key", []int{503}, "", 200, 2},
+		{"GET", "", []int{503}, "0", 200, 2},
+		{"GET", "", []int{503}, "3600", 503, 1},
+	}
+
+	for i, test := range tests {
+		f := &flakyTransport{codes: test.codes, retryAfter: test.retryAfter}
+		rt := NewTransport(RetryTransport{Backoff: noBackoff, MaxRetryAfter: time.Second}, RoundTripper(f))
+		req, _ := http.NewRequest(test.method, "http://example.com/", strings.NewReader("payload"))
+		if test.header != "" {
+			req.Header.Set("Idempotency-Key", test.header)
+		}
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Errorf("[%d] unexpected error %v", i, err)
+			continue
+		}
+		if res.StatusCode != test.code {
+			t.Errorf("[%d] expected status %d, got %d", i, test.code, res.StatusCode)
+		}
+		if len(f.bodies) != test.attempts {
+			t.Errorf("[%d] expected %d attempts, got %d", i, test.attempts, len(f.bodies))
+		}
+		for _, b := range f.bodies {
+			if b != "payload" {
+				t.Errorf("[%d] body should be replayed, got %#v", i, b)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("120"); !ok || d != 2*time.Minute {
+		t.Errorf("unexpected result %s %v", d, ok)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Errorf("unexpected result %s %v", d, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Errorf("invalid values should be ignored")
+	}
+}
+
+func TestRetryTransportBodyLimit(t *testing.T) {
+	for _, max := range []int64{0, 3} {
+		f := &flakyTransport{codes: []int{503}}
+		rt := NewTransport(RetryTransport{Backoff: noBackoff, MaxBodyBytes: max}, RoundTripper(f))
+		req, _ := http.NewRequest("PUT", "http://example.com/", io.NopCloser(strings.NewReader("payload")))
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error %v", max, err)
+		}
+
+		// a body larger than MaxBodyBytes is sent once, completely
+		code, attempts := 200, 2
+		if max == 3 {
+			code, attempts = 503, 1
+		}
+		if res.StatusCode != code || len(f.bodies) != attempts {
+			t.Errorf("[%d] expected status %d after %d attempts, got %d after %d", max, code, attempts, res.StatusCode, len(f.bodies))
+		}
+		for _, b := range f.bodies {
+			if b != "payload" {
+				t.Errorf("[%d] expected the complete body, got %#v", max, b)
+			}
+		}
+	}
+}
+
+// endlessBody counts the bytes that are read from it
+type endlessBody struct {
+	read   int64
+	closed bool
+}
+
+func (e *endlessBody) Read(p []byte) (int, error) {
+	e.read += int64(len(p))
+	return len(p), nil
+}
+
+func (e *endlessBody) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestRetryTransportDrainLimit(t *testing.T) {
+	body := &endlessBody{}
+	calls := 0
+	rt := NewTransport(RetryTransport{Backoff: noBackoff}, RoundTripper(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: 503, Header: http.Header{}, Body: body, Request: req}, nil
+		}
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})))
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if res, err := rt.RoundTrip(req); err != nil || res.StatusCode != 200 {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+	if !body.closed || body.read > retryDrainLimit {
+		t.Errorf("the failed body should be closed after at most %d bytes, got %d (closed %v)", retryDrainLimit, body.read, body.closed)
+	}
+}
@@ -0,0 +1,173 @@
+package wrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// RewriteRule is a rule of a Rewrite. A rule either strips a prefix from the path (StripPrefix) or
+// rewrites the path matching the regular expression Match to Replace (which may refer to submatches
+// like $1, see regexp.Regexp.Expand). If Redirect is set, the client is redirected to the rewritten URL
+// with Redirect as status code instead.
+//
+// Rules may be loaded from JSON, see LoadRewriteRules.
+type RewriteRule struct {
+	// StripPrefix is the prefix that is removed from the path. It only matches whole path segments.
+	StripPrefix string `json:"strip_prefix,omitempty"`
+
+	// Match is the regular expression the path must match
+	Match string `json:"match,omitempty"`
+
+	// Replace is the replacement of the path matching Match; it may be an absolute URL for redirects
+	Replace string `json:"replace,omitempty"`
+
+	// Redirect is the status code of the redirect, e.g. 301; 0 rewrites the path internally
+	Redirect int `json:"redirect,omitempty"`
+
+	// Last stops the processing of the following rules, if the rule has matched
+	Last bool `json:"last,omitempty"`
+}
+
+// String returns a short description of the rule
+func (r RewriteRule) String() string {
+	var s string
+	if r.StripPrefix != "" {
+		s = fmt.Sprintf("strip %s", r.StripPrefix)
+	} else {
+		s = fmt.Sprintf("%s -> %s", r.Match, r.Replace)
+	}
+	if r.Redirect != 0 {
+		s += fmt.Sprintf(" (%d)", r.Redirect)
+	}
+	if r.Last {
+		s += " (last)"
+	}
+	return s
+}
+
+// LoadRewriteRules reads rules from a JSON array like
+//
+//	[{"strip_prefix": "/api"}, {"match": "^/old/(.*)$", "replace": "/new/$1", "redirect": 301}]
+func LoadRewriteRules(r io.Reader) ([]RewriteRule, error) {
+	var rules []RewriteRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// compiledRule is a RewriteRule with its compiled regular expression
+type compiledRule struct {
+	RewriteRule
+	re *regexp.Regexp
+}
+
+// apply returns the rewritten path and if the rule has matched
+func (c *compiledRule) apply(path string) (string, bool) {
+	if c.re == nil {
+		rest := strings.TrimPrefix(path, c.StripPrefix)
+		if rest == path || (rest != "" && rest[0] != '/' && !strings.HasSuffix(c.StripPrefix, "/")) {
+			return path, false
+		}
+		path = rest
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return path, true
+	}
+	m := c.re.FindStringSubmatchIndex(path)
+	if m == nil {
+		return path, false
+	}
+	return string(c.re.ExpandString(nil, c.Replace, path, m)), true
+}
+
+// Rewrite is a Wrapper that applies an ordered list of RewriteRules to the path of the request
+// before the next handler is run. It is Inspectable and shows its rules and the number of rewritten
+// and redirected requests in the InspectorHandler.
+type Rewrite struct {
+	rules      []*compiledRule
+	rewritten  uint64
+	redirected uint64
+}
+
+// make sure to fulfill the Wrapper and Inspectable interfaces
+var (
+	_ Wrapper     = &Rewrite{}
+	_ Inspectable = &Rewrite{}
+)
+
+// NewRewrite returns a Rewrite for the given rules. It returns an error if a rule is invalid.
+func NewRewrite(rules ...RewriteRule) (*Rewrite, error) {
+	rw := &Rewrite{}
+	for i, r := range rules {
+		c := &compiledRule{RewriteRule: r}
+		switch {
+		case r.StripPrefix != "" && r.Match != "":
+			return nil, fmt.Errorf("rewrite rule %d: strip_prefix and match are exclusive", i)
+		case r.StripPrefix == "" && r.Match == "":
+			return nil, fmt.Errorf("rewrite rule %d: strip_prefix or match is required", i)
+		case r.Match != "":
+			re, err := regexp.Compile(r.Match)
+			if err != nil {
+				return nil, fmt.Errorf("rewrite rule %d: %v", i, err)
+			}
+			c.re = re
+		}
+		if r.Redirect != 0 && (r.Redirect < 300 || r.Redirect > 399) {
+			return nil, fmt.Errorf("rewrite rule %d: invalid redirect status %d", i, r.Redirect)
+		}
+		rw.rules = append(rw.rules, c)
+	}
+	return rw, nil
+}
+
+// InspectState implements the Inspectable interface
+func (r *Rewrite) InspectState() string {
+	descr := make([]string, len(r.rules))
+	for i, c := range r.rules {
+		descr[i] = c.String()
+	}
+	return fmt.Sprintf("%s; %d rewritten, %d redirected",
+		strings.Join(descr, ", "), atomic.LoadUint64(&r.rewritten), atomic.LoadUint64(&r.redirected))
+}
+
+// Wrap implements the Wrapper interface
+func (r *Rewrite) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		path, changed := req.URL.Path, false
+		for _, c := range r.rules {
+			p, matched := c.apply(path)
+			if !matched {
+				continue
+			}
+			path, changed = p, true
+			if c.Redirect != 0 {
+				atomic.AddUint64(&r.redirected, 1)
+				if req.URL.RawQuery != "" && !strings.Contains(path, "?") {
+					path += "?" + req.URL.RawQuery
+				}
+				http.Redirect(rw, req, path, c.Redirect)
+				return
+			}
+			if c.Last {
+				break
+			}
+		}
+		if changed {
+			atomic.AddUint64(&r.rewritten, 1)
+			u := *req.URL
+			u.Path, u.RawPath = path, ""
+			req = req.WithContext(req.Context())
+			req.URL = &u
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,71 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	rules, err := LoadRewriteRules(strings.NewReader(`[
+		{"strip_prefix": "/api"},
+		{"match": "^/old/(.*)$", "replace": "/new/$1", "redirect": 301},
+		{"match": "^/v1/(.*)$", "replace": "/v2/$1", "last": true},
+		{"match": "^/v2/(.*)$", "replace": "/v3/$1"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRewrite(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(r, HandlerFunc(writePath))
+
+	tests := []struct {
+		path     string
+		exp      string
+		code     int
+		location string
+	}{
+		{"/api/items", "/items", 200, ""},
+		{"/api", "/", 200, ""},
+		{"/api/old/x?a=b", "", 301, "/new/x?a=b"},
+		{"/v1/x", "/v2/x", 200, ""},
+		{"/v2/x", "/v3/x", 200, ""},
+		{"/other", "/other", 200, ""},
+		{"/apis", "/apis", 200, ""},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", test.path)
+		h.ServeHTTP(rec, req)
+		if test.code == 200 {
+			assertResponse(t, rec, test.exp, 200)
+			continue
+		}
+		if rec.Code != test.code || rec.Header().Get("Location") != test.location {
+			t.Errorf("%s should redirect with %d to %s, got %d to %s", test.path, test.code, test.location, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	exp := "strip /api, ^/old/(.*)$ -> /new/$1 (301), ^/v1/(.*)$ -> /v2/$1 (last), ^/v2/(.*)$ -> /v3/$1; 4 rewritten, 1 redirected"
+	if got := r.InspectState(); got != exp {
+		t.Errorf("InspectState should be %q, got %q", exp, got)
+	}
+}
+
+func TestNewRewriteInvalid(t *testing.T) {
+	tests := []RewriteRule{
+		{},
+		{StripPrefix: "/a", Match: "b"},
+		{Match: "("},
+		{Match: "a", Redirect: 200},
+	}
+
+	for _, rule := range tests {
+		if _, err := NewRewrite(rule); err == nil {
+			t.Errorf("rule %#v should be invalid", rule)
+		}
+	}
+}
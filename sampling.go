@@ -0,0 +1,132 @@
+package wrap
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler decides if a request should be debugged. It is asked once per request
+// (by the first debugged wrapper the request reaches), so either every wrapper of a request
+// is debugged or none.
+type Sampler interface {
+	Sample(req *http.Request) bool
+}
+
+// SamplerFunc is an adapter for a function that acts as Sampler
+type SamplerFunc func(req *http.Request) bool
+
+// Sample makes the SamplerFunc fulfill the Sampler interface by calling itself.
+func (sf SamplerFunc) Sample(req *http.Request) bool { return sf(req) }
+
+// debugSampler holds the samplerBox of the Sampler that is used by the debug wrappers,
+// a nil Sampler means every request is debugged
+var debugSampler atomic.Value
+
+// samplerBox lets an atomic.Value hold Samplers of different types
+type samplerBox struct {
+	Sampler
+}
+
+// SetDebugSampler enables debugging (see SetDebug) and lets the given Sampler decide which requests are
+// debugged, so that debugging may stay enabled in production at low overhead.
+// A nil Sampler debugs every request (which is the default). It is safe to call while requests are served.
+// Like SetDebug it may be used in a var declaration:
+//
+//	var _ = wrap.SetDebugSampler(wrap.SampleOneIn(100))
+func SetDebugSampler(s Sampler) bool {
+	debugSampler.Store(samplerBox{s})
+	return SetDebug()
+}
+
+// sampleRequest returns if req should be debugged. The decision is made once per request
+// by the debugSampler and stored inside the returned request.
+func sampleRequest(req *http.Request) (*http.Request, bool) {
+	s, _ := debugSampler.Load().(samplerBox)
+	if s.Sampler == nil || req == nil {
+		return req, true
+	}
+	if sampled, has := requestValue(req, sampleKey).(bool); has {
+		return req, sampled
+	}
+	sampled := s.Sample(req)
+	return withRequestValue(req, sampleKey, sampled), sampled
+}
+
+// SampleOneIn returns a Sampler that samples every n-th request.
+func SampleOneIn(n uint64) Sampler {
+	if n <= 1 {
+		return SamplerFunc(func(*http.Request) bool { return true })
+	}
+	var counter uint64
+	return SamplerFunc(func(*http.Request) bool {
+		return atomic.AddUint64(&counter, 1)%n == 1
+	})
+}
+
+// rateSampler samples at most max requests per second
+type rateSampler struct {
+	mx     sync.Mutex
+	max    int
+	second int64
+	count  int
+}
+
+func (r *rateSampler) Sample(*http.Request) bool {
+	now := time.Now().Unix()
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if now != r.second {
+		r.second = now
+		r.count = 0
+	}
+	if r.count >= r.max {
+		return false
+	}
+	r.count++
+	return true
+}
+
+// SampleRate returns a Sampler that samples at most perSecond requests per second.
+func SampleRate(perSecond int) Sampler {
+	return &rateSampler{max: perSecond}
+}
+
+// SampleHeader returns a Sampler that samples only the requests that carry the header
+// with the given name and the given secret as value. The header is removed from the request,
+// so that it is not passed to backends.
+//
+// Since the debug wrappers are only built if DEBUG is set, SampleHeader allows to have the
+// debugging built into production stacks while being dormant until a request with the secret
+// header comes in:
+//
+//	var _ = wrap.SetDebugSampler(wrap.SampleHeader("X-Debug", os.Getenv("DEBUG_SECRET")))
+//
+// If secret is empty, no request is sampled.
+func SampleHeader(name, secret string) Sampler {
+	return SamplerFunc(func(req *http.Request) bool {
+		if secret == "" {
+			return false
+		}
+		val := req.Header.Get(name)
+		if val == "" {
+			return false
+		}
+		req.Header.Del(name)
+		return subtle.ConstantTimeCompare([]byte(val), []byte(secret)) == 1
+	})
+}
+
+// SampleAny returns a Sampler that samples a request if any of the given samplers does
+func SampleAny(samplers ...Sampler) Sampler {
+	return SamplerFunc(func(req *http.Request) bool {
+		for _, s := range samplers {
+			if s.Sample(req) {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,130 @@
+package wrap
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebugSampler(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	SetDebugSampler(SampleOneIn(2))
+
+	h := New(
+		write("one"),
+		Handler(write("two")),
+	)
+
+	for i := 0; i < 4; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "onetwo", 200)
+	}
+	SetDebugSampler(nil)
+	DisableDebug()
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	splitted := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	// 2 of 4 requests with 4 debugged objects each
+	if len(splitted) != 8 {
+		t.Errorf("expected 8 lines, got %d", len(splitted))
+	}
+}
+
+func TestSampleOneIn(t *testing.T) {
+	s := SampleOneIn(3)
+	var sampled int
+	for i := 0; i < 9; i++ {
+		if s.Sample(nil) {
+			sampled++
+		}
+	}
+	if sampled != 3 {
+		t.Errorf("expected 3 sampled requests, got %d", sampled)
+	}
+
+	s = SampleOneIn(0)
+	if !s.Sample(nil) {
+		t.Errorf("SampleOneIn(0) should sample every request")
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	s := SampleRate(2)
+	var sampled int
+	for i := 0; i < 10; i++ {
+		if s.Sample(nil) {
+			sampled++
+		}
+	}
+	// the second might have changed in between
+	if sampled < 2 || sampled > 4 {
+		t.Errorf("expected 2 sampled requests, got %d", sampled)
+	}
+}
+
+func TestSampleRequestOnce(t *testing.T) {
+	var calls int
+	SetDebugSampler(SamplerFunc(func(*http.Request) bool {
+		calls++
+		return false
+	}))
+	DisableDebug()
+	defer SetDebugSampler(nil)
+	defer DisableDebug()
+
+	_, req := newTestRequest("GET", "/")
+	req, sampled := sampleRequest(req)
+	if sampled {
+		t.Errorf("request should not be sampled")
+	}
+	_, sampled = sampleRequest(req)
+	if sampled || calls != 1 {
+		t.Errorf("sampler should be asked once and the decision kept, got %v after %d calls", sampled, calls)
+	}
+}
+
+func TestSampleHeader(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogDebugger(&buf, 0)
+	SetDebugSampler(SampleAny(SampleHeader("X-Debug", "secret")))
+
+	h := New(
+		Handler(write("one")),
+	)
+
+	for _, val := range []string{"", "wrong", "secret"} {
+		rec, req := newTestRequest("GET", "/"+val)
+		if val != "" {
+			req.Header.Set("X-Debug", val)
+		}
+		h.ServeHTTP(rec, req)
+		if req.Header.Get("X-Debug") != "" {
+			t.Errorf("X-Debug header should be removed from the request")
+		}
+	}
+
+	SetDebugSampler(nil)
+	DisableDebug()
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	splitted := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	// 3 debugged objects of the request with the secret
+	if len(splitted) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(splitted))
+	}
+
+	if !strings.Contains(splitted[0], "GET /secret") {
+		t.Errorf("only the request with the secret should be debugged, got %#v", splitted[0])
+	}
+
+	_, req := newTestRequest("GET", "/")
+	if SampleHeader("X-Debug", "").Sample(req) {
+		t.Errorf("empty secret should never sample")
+	}
+}
@@ -0,0 +1,160 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// timingEntry is the time at which a debugged object was entered
+type timingEntry struct {
+	obj  interface{}
+	role string
+	at   time.Time
+}
+
+// timings collects the timing entries of a single request
+type timings struct {
+	mx      sync.Mutex
+	start   time.Time
+	entries []timingEntry
+}
+
+func (t *timings) add(obj interface{}, role string) {
+	t.mx.Lock()
+	t.entries = append(t.entries, timingEntry{obj, role, time.Now()})
+	t.mx.Unlock()
+}
+
+// header returns the value of the Server-Timing header. The duration of an entry is the time
+// until the next entry has been entered, the duration of the last entry is the time until now.
+func (t *timings) header() string {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	now := time.Now()
+	var bf bytes.Buffer
+	for i, e := range t.entries {
+		end := now
+		if i+1 < len(t.entries) {
+			end = t.entries[i+1].at
+		}
+		fmt.Fprintf(&bf, "%s;desc=%q;dur=%s, ", timingToken(fmt.Sprintf("%T", e.obj)), e.role, timingMillis(end.Sub(e.at)))
+	}
+	fmt.Fprintf(&bf, "total;dur=%s", timingMillis(now.Sub(t.start)))
+	return bf.String()
+}
+
+func timingMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// timingToken replaces all characters of s that are not allowed inside a header token by an underscore
+func timingToken(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case bytes.IndexByte([]byte("!#$%&'*+-.^_`|~"), c) >= 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// TimingDebugger is a Debugger that records the time at which each debugged object is entered,
+// if the request is served by a ServerTiming wrapper. The recorded timings are written
+// to the Server-Timing header by the ServerTiming wrapper.
+//
+// If Debugger is not nil, each call is passed to it, so TimingDebugger may be combined with other debuggers:
+//
+//	wrap.SetDebugger(&wrap.TimingDebugger{wrap.CurrentDebugger()})
+type TimingDebugger struct {
+	Debugger Debugger
+}
+
+// Debug records the time for the request and calls the inner Debugger
+func (t *TimingDebugger) Debug(req *http.Request, obj interface{}, role string) {
+	if tm, ok := requestValue(req, timingKey).(*timings); ok {
+		tm.add(obj, role)
+	}
+	if t.Debugger != nil {
+		t.Debugger.Debug(req, obj, role)
+	}
+}
+
+// ServerTiming is a Wrapper that writes the timings that have been recorded by the
+// TimingDebugger to the Server-Timing response header, before the status code or the
+// body is written. So the browser devtools show how much time each wrapper took to pass
+// the request to the next one.
+//
+// ServerTiming is meant for development and requires debugging to be enabled (see SetDebug) and
+// the Debugger to be a TimingDebugger. It should be the first wrapper in a stack (or the first after
+// the ContextInjecter).
+type ServerTiming struct{}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = ServerTiming{}
+
+// Wrap implements the Wrapper interface
+func (ServerTiming) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		tm := &timings{start: time.Now()}
+		w := &serverTimingWriter{ResponseWriter: rw, timings: tm}
+		next.ServeHTTP(w, withRequestValue(req, timingKey, tm))
+		w.setHeader()
+	}
+	return f
+}
+
+// serverTimingWriter sets the Server-Timing header before anything is written
+type serverTimingWriter struct {
+	http.ResponseWriter
+	timings *timings
+	sent    bool
+	caps    capabilities
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &serverTimingWriter{}
+
+func (s *serverTimingWriter) setHeader() {
+	if s.sent {
+		return
+	}
+	s.sent = true
+	s.ResponseWriter.Header().Set("Server-Timing", s.timings.header())
+}
+
+// WriteHeader sets the Server-Timing header and writes the status code to the underlying ResponseWriter
+func (s *serverTimingWriter) WriteHeader(code int) {
+	s.setHeader()
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write sets the Server-Timing header and writes to the underlying ResponseWriter
+func (s *serverTimingWriter) Write(b []byte) (int, error) {
+	s.setHeader()
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush sets the Server-Timing header and flushes the underlying ResponseWriter if it is a http.Flusher
+func (s *serverTimingWriter) Flush() {
+	s.setHeader()
+	s.caps.flush(s.ResponseWriter)
+}
+
+// Context gets the Context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (s *serverTimingWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(s.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (s *serverTimingWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(s.ResponseWriter, ctxPtr)
+}
@@ -0,0 +1,62 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	old := CurrentDebugger()
+	SetDebugger(&TimingDebugger{})
+	SetDebug()
+
+	h := New(
+		ServerTiming{},
+		NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(rw, req)
+		}),
+		Handler(write("b")),
+	)
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	DisableDebug()
+	SetDebugger(old)
+
+	assertResponse(t, rec, "b", 200)
+
+	st := rec.Header().Get("Server-Timing")
+
+	expected := []string{
+		`wrap.NextHandlerFunc;desc="Wrapper";dur=`,
+		`wrap.NextHandlerFunc;desc="NextHandlerFunc";dur=`,
+		`wrap.write;desc="http.Handler";dur=`,
+		`total;dur=`,
+	}
+
+	for _, exp := range expected {
+		if !strings.Contains(st, exp) {
+			t.Errorf("Server-Timing header should contain %#v, but is %#v", exp, st)
+		}
+	}
+
+	if strings.Contains(st, "ServerTiming") {
+		t.Errorf("Server-Timing header should not contain the ServerTiming wrapper itself, but is %#v", st)
+	}
+}
+
+func TestServerTimingNoWrite(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(ServerTiming{}).ServeHTTP(rec, req)
+
+	if st := rec.Header().Get("Server-Timing"); !strings.HasPrefix(st, "total;dur=") {
+		t.Errorf("Server-Timing header should start with total, but is %#v", st)
+	}
+}
+
+func TestTimingToken(t *testing.T) {
+	if got := timingToken("func(http.ResponseWriter, *http.Request)"); got != "func_http.ResponseWriter__*http.Request_" {
+		t.Errorf("unexpected token %#v", got)
+	}
+}
@@ -0,0 +1,244 @@
+package wrap
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header that carries the signature of a request signed by SignTransport
+const SignatureHeader = "X-Signature"
+
+// SignatureAlgorithms are the supported HMAC algorithms by name. Further algorithms may be added
+// before any request is signed or verified.
+var SignatureAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+// ErrInvalidSignature is the error for requests with a missing or invalid signature
+var ErrInvalidSignature = errors.New("invalid request signature")
+
+// Principal is the context type in which VerifySignature stores the key id of a verified request
+type Principal string
+
+// signingString returns the string that is signed: method, path with query, date and the hex encoded
+// SHA-256 hash of the body, separated by newlines
+func signingString(req *http.Request, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		req.Header.Get("Date"),
+		hex.EncodeToString(sum[:]),
+	}, "\n"))
+}
+
+// sign returns the base64 encoded signature
+func sign(algorithm string, key []byte, req *http.Request, body []byte) (string, error) {
+	newHash, ok := SignatureAlgorithms[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unknown signature algorithm %q", algorithm)
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(signingString(req, body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// bufferRequestBody returns the body of the request and replaces it with a reader of the same bytes.
+// If the request has a GetBody function (e.g. from BufferBody), the body is read from it instead.
+// If limit is greater than 0 and the body is larger, a *http.MaxBytesError is returned.
+func bufferRequestBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return readLimited(body, limit)
+	}
+	body, err := readLimited(req.Body, limit)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// readLimited reads r completely, failing with a *http.MaxBytesError after more than limit bytes,
+// if limit is greater than 0
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(body)) > limit {
+		err = &http.MaxBytesError{Limit: limit}
+	}
+	return body, err
+}
+
+// SignTransport is a RoundTripWrapper that signs outgoing requests with a HMAC over the method, the path,
+// the Date header (which is set if missing) and the hash of the body. The signature is sent in the
+// SignatureHeader as keyId="...",algorithm="...",signature="...". See VerifySignature for the server side.
+type SignTransport struct {
+	// KeyID identifies the key on the server
+	KeyID string
+
+	// Key is the shared secret
+	Key []byte
+
+	// Algorithm is the name of one of the SignatureAlgorithms. Defaults to "hmac-sha256".
+	Algorithm string
+}
+
+// make sure to fulfill the RoundTripWrapper interface
+var _ RoundTripWrapper = SignTransport{}
+
+func (s SignTransport) algorithm() string {
+	if s.Algorithm == "" {
+		return "hmac-sha256"
+	}
+	return s.Algorithm
+}
+
+// WrapRoundTrip implements the RoundTripWrapper interface
+func (s SignTransport) WrapRoundTrip(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		body, err := bufferRequestBody(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		if req.Header.Get("Date") == "" {
+			req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		}
+		sig, err := sign(s.algorithm(), s.Key, req, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(SignatureHeader, fmt.Sprintf(`keyId="%s",algorithm="%s",signature="%s"`, s.KeyID, s.algorithm(), sig))
+		return next.RoundTrip(req)
+	})
+}
+
+// parseSignature parses the value of the SignatureHeader
+func parseSignature(val string) (params map[string]string) {
+	params = map[string]string{}
+	for _, part := range strings.Split(val, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return
+}
+
+// VerifySignature is a ContextWrapper that verifies requests signed by SignTransport. If the signature
+// is valid, the key id is stored as Principal in the Contexter and the next handler is run, otherwise
+// a 401 Unauthorized is written. Since the body has to be read into memory before the signature is
+// checked, requests with a body larger than MaxBodyBytes are rejected with 413 Request Entity Too Large.
+type VerifySignature struct {
+	// Keys returns the key for the given key id and false if there is none
+	Keys func(keyID string) ([]byte, bool)
+
+	// MaxSkew is the maximal difference between the Date header and the current time. Defaults to 5 minutes.
+	MaxSkew time.Duration
+
+	// MaxBodyBytes is the maximal size of the body that is read to verify the signature. Defaults to 1 MiB.
+	MaxBodyBytes int64
+}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = VerifySignature{}
+
+// ValidateContext makes sure that ctx supports the Principal type
+func (VerifySignature) ValidateContext(ctx Contexter) {
+	var p Principal
+	ctx.SetContext(&p)
+	ctx.Context(&p)
+}
+
+func (v VerifySignature) maxBodyBytes() int64 {
+	if v.MaxBodyBytes <= 0 {
+		return 1 << 20
+	}
+	return v.MaxBodyBytes
+}
+
+func (v VerifySignature) maxSkew() time.Duration {
+	if v.MaxSkew <= 0 {
+		return 5 * time.Minute
+	}
+	return v.MaxSkew
+}
+
+// Verify checks the signature of the request and returns the principal. If the body is larger than
+// MaxBodyBytes, the error is a *http.MaxBytesError (see BodyTooLarge).
+func (v VerifySignature) Verify(req *http.Request) (Principal, error) {
+	params := parseSignature(req.Header.Get(SignatureHeader))
+	keyID, algorithm, sig := params["keyId"], params["algorithm"], params["signature"]
+	if keyID == "" || sig == "" {
+		return "", ErrInvalidSignature
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid date", ErrInvalidSignature)
+	}
+	if skew := time.Since(date); skew > v.maxSkew() || skew < -v.maxSkew() {
+		return "", fmt.Errorf("%w: date out of range", ErrInvalidSignature)
+	}
+
+	key, ok := v.Keys(keyID)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key id", ErrInvalidSignature)
+	}
+
+	body, err := bufferRequestBody(req, v.maxBodyBytes())
+	if err != nil {
+		return "", err
+	}
+
+	expected, err := sign(algorithm, key, req, body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return "", ErrInvalidSignature
+	}
+	return Principal(keyID), nil
+}
+
+// Wrap implements the Wrapper interface
+func (v VerifySignature) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		p, err := v.Verify(req)
+		if BodyTooLarge(err) {
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		rw.(Contexter).SetContext(&p)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,131 @@
+package wrap
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type principalContext struct {
+	http.ResponseWriter
+	principal Principal
+}
+
+func (c *principalContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *Principal:
+		*ty = c.principal
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *principalContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *Principal:
+		c.principal = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c principalContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&principalContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func TestSignAndVerify(t *testing.T) {
+	keys := map[string][]byte{"svc-a": []byte("secret")}
+	verify := VerifySignature{Keys: func(id string) ([]byte, bool) {
+		k, ok := keys[id]
+		return k, ok
+	}}
+	ValidateWrapperContexts(&principalContext{}, verify)
+
+	server := httptest.NewServer(Stack(&principalContext{}, verify, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var p Principal
+		rw.(Contexter).Context(&p)
+		b, _ := io.ReadAll(req.Body)
+		rw.Write([]byte(string(p) + ":" + string(b)))
+	})))
+	defer server.Close()
+
+	tests := []struct {
+		transport http.RoundTripper
+		code      int
+		body      string
+	}{
+		{NewTransport(SignTransport{KeyID: "svc-a", Key: []byte("secret")}), 200, "svc-a:payload"},
+		{NewTransport(SignTransport{KeyID: "svc-a", Key: []byte("secret"), Algorithm: "hmac-sha512"}), 200, "svc-a:payload"},
+		{NewTransport(SignTransport{KeyID: "svc-a", Key: []byte("wrong")}), 401, "Unauthorized\n"},
+		{NewTransport(SignTransport{KeyID: "svc-b", Key: []byte("secret")}), 401, "Unauthorized\n"},
+		{http.DefaultTransport, 401, "Unauthorized\n"},
+	}
+
+	for i, test := range tests {
+		client := &http.Client{Transport: test.transport}
+		res, err := client.Post(server.URL+"/x?y=1", "text/plain", strings.NewReader("payload"))
+		body := readBody(t, res, err)
+		if res.StatusCode != test.code || body != test.body {
+			t.Errorf("[%d] expected %d %#v, got %d %#v", i, test.code, test.body, res.StatusCode, body)
+		}
+	}
+}
+
+func TestVerifySignatureDate(t *testing.T) {
+	verify := VerifySignature{Keys: func(string) ([]byte, bool) { return []byte("k"), true }}
+	var signed *http.Request
+	rt := NewTransport(SignTransport{KeyID: "a", Key: []byte("k")}, RoundTripper(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		signed = req
+		return nil, errors.New("not sent")
+	})))
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	rt.RoundTrip(req)
+
+	if _, err := verify.Verify(signed); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("old date should be rejected, got %v", err)
+	}
+}
+
+func TestVerifySignatureMaxBodyBytes(t *testing.T) {
+	verify := VerifySignature{Keys: func(string) ([]byte, bool) { return []byte("k"), true }, MaxBodyBytes: 5}
+	h := Stack(&principalContext{}, verify, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		rw.Write(b)
+	}))
+
+	tests := []struct {
+		body string
+		code int
+		exp  string
+	}{
+		{"12345", 200, "12345"},
+		{"123456", 413, "Request Entity Too Large"},
+	}
+
+	for _, test := range tests {
+		var signed *http.Request
+		rt := NewTransport(SignTransport{KeyID: "a", Key: []byte("k")}, RoundTripper(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			signed = req
+			return nil, errors.New("not sent")
+		})))
+		req, _ := http.NewRequest("POST", "http://example.com/", strings.NewReader(test.body))
+		rt.RoundTrip(req)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, signed)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
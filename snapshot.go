@@ -0,0 +1,49 @@
+package wrap
+
+import (
+	"net/http"
+)
+
+// Snapshot is a response as value: status code, headers and body
+type Snapshot struct {
+	// Code is the status code, http.StatusOK if 0
+	Code int
+
+	// Header are the headers of the response, they replace headers of the same name
+	Header http.Header
+
+	// Body is the body of the response
+	Body []byte
+}
+
+// make sure to fulfill the http.Handler interface
+var _ http.Handler = &Snapshot{}
+
+// ServeHTTP writes the snapshot to the ResponseWriter
+func (s *Snapshot) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	header := rw.Header()
+	for k, v := range s.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	code := s.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	rw.WriteHeader(code)
+	rw.Write(s.Body)
+}
+
+// Respond returns a Wrapper for a pure function from request to response snapshot, which allows
+// a functional style for simple endpoints that can be unit tested without a ResponseWriter.
+// If fn returns nil, the next handler is run, otherwise the snapshot is written and the next handler is not run.
+func Respond(fn func(*http.Request) *Snapshot) Wrapper {
+	var nf NextHandlerFunc
+	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		if s := fn(req); s != nil {
+			s.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(rw, req)
+	}
+	return nf
+}
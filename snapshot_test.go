@@ -0,0 +1,40 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+func hello(req *http.Request) *Snapshot {
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		return nil
+	}
+	return &Snapshot{
+		Code:   201,
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   []byte("hello " + name),
+	}
+}
+
+func TestRespond(t *testing.T) {
+	// the function itself is easily testable
+	_, req := newTestRequest("GET", "/?name=x")
+	if s := hello(req); s == nil || string(s.Body) != "hello x" {
+		t.Errorf("unexpected snapshot %v", s)
+	}
+
+	h := New(Respond(hello), writeStop("fallthrough"))
+
+	rec, req := newTestRequest("GET", "/?name=world")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "hello world", 201)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("content type should be text/plain, but is %#v", ct)
+	}
+
+	rec, req = newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "fallthrough", 200)
+}
+
@@ -0,0 +1,70 @@
+package wrap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType
+const sniffLen = 512
+
+// PeekRequest reads the first n bytes of the body of req (fewer if the body is shorter) and replaces
+// the body with one that returns them again before the rest, so that the next handler still gets the
+// complete body. It is the request counterpart of PeekResponse.
+func PeekRequest(req *http.Request, n int) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	head := make([]byte, n)
+	read, err := io.ReadFull(req.Body, head)
+	head = head[:read]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	req.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(head), req.Body), Closer: req.Body}
+	return head, err
+}
+
+// SniffedType is the context type of the content type of the request body as detected by SniffBody
+type SniffedType string
+
+// SniffBody is a ContextWrapper that detects the content type of the request body via
+// http.DetectContentType and stores it as SniffedType, so that routing or validation middleware
+// may branch on the actual payload instead of the Content-Type header sent by the client.
+// Only the first 512 bytes of the body are read and they are passed on to the next handler.
+// The SniffedType of a request without body is "".
+type SniffBody struct{}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = SniffBody{}
+
+// ValidateContext makes sure that ctx supports the SniffedType type
+func (SniffBody) ValidateContext(ctx Contexter) {
+	var s SniffedType
+	ctx.SetContext(&s)
+	ctx.Context(&s)
+}
+
+// Wrap implements the Wrapper interface
+func (SniffBody) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		head, err := PeekRequest(req, sniffLen)
+		if err != nil {
+			code := http.StatusBadRequest
+			if BodyTooLarge(err) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			reportError(rw, &HTTPError{Code: code, Err: err})
+			return
+		}
+		var s SniffedType
+		if len(head) > 0 {
+			s = SniffedType(http.DetectContentType(head))
+		}
+		rw.(Contexter).SetContext(&s)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
@@ -0,0 +1,82 @@
+package wrap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type sniffContext struct {
+	http.ResponseWriter
+	sniffed SniffedType
+}
+
+func (c *sniffContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *SniffedType:
+		*ty = c.sniffed
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *sniffContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *SniffedType:
+		c.sniffed = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c sniffContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&sniffContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func TestSniffBody(t *testing.T) {
+	ValidateWrapperContexts(&sniffContext{}, SniffBody{})
+
+	h := New(sniffContext{}, SniffBody{}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var s SniffedType
+		rw.(Contexter).Context(&s)
+		b, _ := io.ReadAll(req.Body)
+		fmt.Fprintf(rw, "%s|%s", s, b)
+	}))
+
+	tests := []struct {
+		body string
+		exp  string
+	}{
+		{"<html><body>hi</body></html>", "text/html; charset=utf-8|<html><body>hi</body></html>"},
+		{"%PDF-1.4", "application/pdf|%PDF-1.4"},
+		{strings.Repeat("a", 600), "text/plain; charset=utf-8|" + strings.Repeat("a", 600)},
+		{"", "|"},
+	}
+
+	for _, test := range tests {
+		rec, _ := newTestRequest("POST", "/")
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, 200)
+	}
+}
+
+func TestPeekRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("hello world"))
+	head, err := PeekRequest(req, 5)
+	if err != nil || string(head) != "hello" {
+		t.Errorf("head should be %q, got %q (%v)", "hello", head, err)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != "hello world" {
+		t.Errorf("body should be %q, got %q", "hello world", b)
+	}
+}
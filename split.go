@@ -0,0 +1,104 @@
+package wrap
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+)
+
+// Variant is the context type in which a Splitter stores the chosen variant ("A" or "B")
+type Variant string
+
+const (
+	// VariantA is the variant of the first wrapper of a Split
+	VariantA Variant = "A"
+
+	// VariantB is the variant of the second wrapper of a Split
+	VariantB Variant = "B"
+)
+
+// Splitter is the Wrapper returned by Split
+type Splitter struct {
+	// PercentA is the percentage of the requests served by A
+	PercentA int
+
+	// A and B are the variants, each wrapping the next handler
+	A, B Wrapper
+
+	// Cookie is the name of a cookie that makes the assignment sticky (optional).
+	// If the request has no valid cookie, it is set on the response.
+	Cookie string
+
+	// Key returns a value (e.g. the user id from the Contexter) whose hash determines the
+	// variant (optional). It takes precedence over Cookie, if it returns a non empty string.
+	Key func(rw http.ResponseWriter, req *http.Request) string
+
+	// percentA overrides PercentA if set, allowing it to change while serving
+	percentA func() int
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = &Splitter{}
+
+// Split returns a Wrapper that serves percentA percent of the requests with a and the rest with b,
+// so that two stack variants can serve traffic side by side. Set Cookie or Key of the returned
+// Splitter for a sticky assignment.
+//
+// The chosen Variant is stored in the Contexter (if there is one that supports it) for logging.
+func Split(percentA int, a, b Wrapper) *Splitter {
+	return &Splitter{PercentA: percentA, A: a, B: b}
+}
+
+// byPercent returns the variant for a number between 0 and 99
+func (s *Splitter) byPercent(n int) Variant {
+	percent := s.PercentA
+	if s.percentA != nil {
+		percent = s.percentA()
+	}
+	if n < percent {
+		return VariantA
+	}
+	return VariantB
+}
+
+// choose returns the variant for the request
+func (s *Splitter) choose(rw http.ResponseWriter, req *http.Request) Variant {
+	if s.Key != nil {
+		if key := s.Key(rw, req); key != "" {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			return s.byPercent(int(h.Sum32() % 100))
+		}
+	}
+
+	if s.Cookie == "" {
+		return s.byPercent(rand.Intn(100))
+	}
+
+	if c, err := req.Cookie(s.Cookie); err == nil {
+		if v := Variant(c.Value); v == VariantA || v == VariantB {
+			return v
+		}
+	}
+	v := s.byPercent(rand.Intn(100))
+	http.SetCookie(rw, &http.Cookie{Name: s.Cookie, Value: string(v), Path: "/", HttpOnly: true})
+	return v
+}
+
+// Wrap implements the Wrapper interface
+func (s *Splitter) Wrap(next http.Handler) http.Handler {
+	a, b := s.A.Wrap(next), s.B.Wrap(next)
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		v := s.choose(rw, req)
+		if _, ok := rw.(Contexter); ok {
+			TrySetContext(rw, &v)
+		}
+		if v == VariantA {
+			a.ServeHTTP(rw, req)
+			return
+		}
+		b.ServeHTTP(rw, req)
+	}
+	return f
+}
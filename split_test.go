@@ -0,0 +1,64 @@
+package wrap
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for _, test := range []struct {
+		percent int
+		body    string
+	}{{100, "ab"}, {0, "bb"}} {
+		rec, req := newTestRequest("GET", "/")
+		New(Split(test.percent, write("a"), write("b")), writeStop("b")).ServeHTTP(rec, req)
+		assertResponse(t, rec, test.body, 200)
+	}
+}
+
+func TestSplitDistribution(t *testing.T) {
+	h := New(Split(30, writeStop("a"), writeStop("b")))
+	var a int
+	for i := 0; i < 1000; i++ {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		if rec.Body.String() == "a" {
+			a++
+		}
+	}
+	if a < 200 || a > 400 {
+		t.Errorf("about 30%% should be served by a, but %d of 1000 were", a)
+	}
+}
+
+func TestSplitSticky(t *testing.T) {
+	s := Split(50, writeStop("A"), writeStop("B"))
+	s.Cookie = "variant"
+	h := New(s)
+
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	cookie := rec.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(cookie, "variant="+rec.Body.String()) {
+		t.Fatalf("cookie should be set to the variant, but is %#v", cookie)
+	}
+
+	for i := 0; i < 10; i++ {
+		rec, req = newTestRequest("GET", "/")
+		req.Header.Set("Cookie", "variant=B")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "B", 200)
+	}
+
+	s.Key = func(rw http.ResponseWriter, req *http.Request) string { return req.URL.Query().Get("user") }
+	first := ""
+	for i := 0; i < 10; i++ {
+		rec, req = newTestRequest("GET", "/?user=42")
+		h.ServeHTTP(rec, req)
+		if first == "" {
+			first = rec.Body.String()
+		}
+		assertResponse(t, rec, first, 200)
+	}
+}
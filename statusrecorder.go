@@ -0,0 +1,118 @@
+package wrap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// statusRecorder is a transparent ResponseWriter wrapper that remembers the status code
+// and the number of body bytes that have been written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	// code is the status code that has been written, 0 if nothing has been written
+	code int
+
+	// bytes is the number of body bytes that have been written
+	bytes int
+
+	caps capabilities
+}
+
+func newStatusRecorder(rw http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: rw}
+}
+
+// responseWriter returns the ResponseWriter that should be passed to the next handler.
+// It is a Contexter if (and only if) the underlying ResponseWriter is one, so that
+// middleware may still check if there is a Contexter.
+func (s *statusRecorder) responseWriter() http.ResponseWriter {
+	if _, ok := s.ResponseWriter.(Contexter); ok {
+		return &contextStatusRecorder{s}
+	}
+	return s
+}
+
+// Status returns the status code that has been sent, which is http.StatusOK
+// if the body has been written without calling WriteHeader and 0 if nothing has been written.
+func (s *statusRecorder) Status() int {
+	return s.code
+}
+
+// WriteHeader tracks the status code and passes it to the underlying ResponseWriter
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write tracks the number of written bytes and passes them to the underlying ResponseWriter
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// ReadFrom tracks the number of written bytes and passes r to the underlying ResponseWriter, via its
+// io.ReaderFrom, if it has one (see Peek.ReadFrom)
+func (s *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	n, err := readFrom(s.ResponseWriter, r)
+	s.bytes += int(n)
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter if it is a http.Flusher
+func (s *statusRecorder) Flush() {
+	s.caps.flush(s.ResponseWriter)
+}
+
+// Hijack hijacks the underlying ResponseWriter if it is a http.Hijacker
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, brw, err, ok := s.caps.hijack(s.ResponseWriter)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a http.Hijacker", ReclaimResponseWriter(s.ResponseWriter))
+	}
+	return c, brw, err
+}
+
+// Unwrap returns the underlying ResponseWriter, so that http.ResponseController reaches it
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// statusClass returns the class of the given status code, e.g. "2xx".
+// A code of 0 means that nothing has been written, which net/http treats as 200.
+func statusClass(code int) string {
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// contextStatusRecorder is a statusRecorder for an underlying Contexter
+type contextStatusRecorder struct {
+	*statusRecorder
+}
+
+// make sure to fulfill the Contexter interface
+var _ Contexter = &contextStatusRecorder{}
+
+// Context gets the Context of the underlying response writer.
+func (c *contextStatusRecorder) Context(ctxPtr interface{}) bool {
+	return contextOf(c.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer.
+func (c *contextStatusRecorder) SetContext(ctxPtr interface{}) {
+	setContextOf(c.ResponseWriter, ctxPtr)
+}
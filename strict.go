@@ -0,0 +1,222 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrNilWrapper is reported by Probe for a nil wrapper
+	ErrNilWrapper = errors.New("nil wrapper")
+
+	// ErrNextNotCalled is reported by Probe for a wrapper that did not call the next handler when serving
+	// the probe request, although it is neither the last wrapper nor a Terminator that terminates
+	ErrNextNotCalled = errors.New("next handler not called")
+)
+
+// strictOption is the Wrapper returned by WithStrict
+type strictOption struct{}
+
+// Wrap returns next, so that the option does nothing, if it is not stripped
+func (strictOption) Wrap(next http.Handler) http.Handler { return next }
+
+// WithStrict returns an option that may be passed as any of the wrappers to New, Compile and Stack,
+// like
+//
+//	wrap.Stack(&ctx{}, wrap.WithStrict(), a, b, c)
+//
+// The option is removed from the wrappers and the wrappers are probed via Probe before the stack
+// is built. If there is any problem, the stack constructor panics with the ProbeErrors,
+// so that the problems are detected before the server starts taking traffic.
+func WithStrict() Wrapper {
+	return strictOption{}
+}
+
+// applyStrict removes the options returned by WithStrict from the wrappers. If there was one,
+// it panics with the errors of Probe.
+func applyStrict(wrapper []Wrapper) []Wrapper {
+	var strict bool
+	for _, w := range wrapper {
+		if _, ok := w.(strictOption); ok {
+			strict = true
+		}
+	}
+	if !strict {
+		return wrapper
+	}
+	ws := make([]Wrapper, 0, len(wrapper))
+	for _, w := range wrapper {
+		if _, ok := w.(strictOption); !ok {
+			ws = append(ws, w)
+		}
+	}
+	if err := Probe(ws...); err != nil {
+		panic(err)
+	}
+	return ws
+}
+
+// ProbeError is a problem of a wrapper found by Probe
+type ProbeError struct {
+	// Index is the position of the wrapper
+	Index int
+
+	// Wrapper is the failing wrapper
+	Wrapper Wrapper
+
+	// Err is the problem, one of ErrNilWrapper, ErrNextNotCalled, *ErrDuplicateContexter, *ErrUnreachableWrapper,
+	// ErrBodyFlushedBeforeCode, ErrCodeFlushedBeforeHeaders or an error for a panic
+	Err error
+}
+
+// Error returns the error message
+func (p *ProbeError) Error() string {
+	return fmt.Sprintf("%d %T: %s", p.Index, p.Wrapper, p.Err.Error())
+}
+
+// Unwrap returns the problem
+func (p *ProbeError) Unwrap() error {
+	return p.Err
+}
+
+// ProbeErrors is the error returned by Probe
+type ProbeErrors []*ProbeError
+
+// Error returns the error messages of all problems, one per line
+func (p ProbeErrors) Error() string {
+	msgs := make([]string, len(p))
+	for i, err := range p {
+		msgs[i] = err.Error()
+	}
+	return "probing the stack failed:\n" + strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the failing wrappers
+func (p ProbeErrors) Unwrap() []error {
+	errs := make([]error, len(p))
+	for i, err := range p {
+		errs[i] = err
+	}
+	return errs
+}
+
+// probeWriter is the ResponseWriter that records the order in which a wrapper writes
+// headers, status code and body when serving the probe request
+type probeWriter struct {
+	header http.Header
+
+	// sent are the headers at the time the status code was written, nil before
+	sent http.Header
+	errs []error
+}
+
+func (p *probeWriter) Header() http.Header { return p.header }
+
+func (p *probeWriter) WriteHeader(code int) {
+	if p.sent == nil {
+		p.sent = p.header.Clone()
+	}
+}
+
+func (p *probeWriter) Write(b []byte) (int, error) {
+	p.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+func (p *probeWriter) Flush() {
+	p.WriteHeader(http.StatusOK)
+}
+
+// err returns ErrCodeFlushedBeforeHeaders if headers were changed after the status code was written
+func (p *probeWriter) err() error {
+	if p.sent != nil && !reflect.DeepEqual(p.sent, p.header) {
+		return ErrCodeFlushedBeforeHeaders{}
+	}
+	return nil
+}
+
+// probeWrapper serves the probe request with the given wrapper (inside the Contexter injected by inject,
+// if it is not nil) and returns the problems
+func probeWrapper(inject ContextInjecter, w Wrapper, last bool) (errs []error) {
+	var called bool
+	var next http.HandlerFunc
+	next = func(http.ResponseWriter, *http.Request) { called = true }
+	h := w.Wrap(next)
+	if inject != nil && inject != w {
+		h = inject.Wrap(h)
+	}
+	rw := &probeWriter{header: http.Header{}}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if err, ok := p.(error); ok {
+					errs = append(errs, fmt.Errorf("panics: %w", err))
+					return
+				}
+				errs = append(errs, fmt.Errorf("panics: %v", p))
+			}
+		}()
+		h.ServeHTTP(rw, newProbeRequest())
+		if t, ok := w.(Terminator); !called && !last && !(ok && t.Terminates()) {
+			errs = append(errs, ErrNextNotCalled)
+		}
+	}()
+	if err := rw.err(); err != nil {
+		errs = append(errs, err)
+	}
+	return
+}
+
+// Probe checks the given wrappers before they are used to build a stack and returns ProbeErrors for all
+// problems that are found, nil if there are none. Each wrapper is probed by serving a synthetic request
+// (see IsProbe) with the wrapper alone (inside the Contexter of the first wrapper, if it is a ContextInjecter).
+// Probe reports
+//
+//   - nil wrappers (ErrNilWrapper)
+//   - wrappers after the first one that inject a Contexter (*ErrDuplicateContexter)
+//   - wrappers after a terminating Terminator (*ErrUnreachableWrapper)
+//   - wrappers that do not call the next handler, although they are neither the last wrapper
+//     nor a terminating Terminator (ErrNextNotCalled)
+//   - wrappers that panic
+//   - wrappers that change headers after the status code has been written (ErrCodeFlushedBeforeHeaders)
+//
+// Stack, New and Compile run Probe if WithStrict is given.
+func Probe(wrapper ...Wrapper) error {
+	var errs ProbeErrors
+	add := func(i int, err error) {
+		errs = append(errs, &ProbeError{Index: i, Wrapper: wrapper[i], Err: err})
+	}
+
+	var inject ContextInjecter
+	if len(wrapper) > 0 {
+		inject, _ = wrapper[0].(ContextInjecter)
+	}
+	first := -1
+	for i, w := range wrapper {
+		if w == nil {
+			add(i, ErrNilWrapper)
+			continue
+		}
+		if injectsContexter(w) {
+			if first >= 0 {
+				add(i, &ErrDuplicateContexter{First: wrapper[first], FirstIndex: first, Second: w, SecondIndex: i})
+			} else {
+				first = i
+			}
+		}
+		for _, err := range probeWrapper(inject, w, i == len(wrapper)-1) {
+			add(i, err)
+		}
+	}
+	if err := findUnreachable(wrapper); err != nil {
+		add(err.(*ErrUnreachableWrapper).Index, err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
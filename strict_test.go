@@ -0,0 +1,87 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// headerAfterWrite sets a header after writing the body
+type headerAfterWrite struct{}
+
+func (headerAfterWrite) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("a"))
+		rw.Header().Set("X-Late", "1")
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func TestProbe(t *testing.T) {
+	if err := Probe(&context{}, write("a"), writeStop("b")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := Probe(&context{}, nil, writeStop("x"), requestIDContext{}, headerAfterWrite{}, Handler(panicker("boom")), terminator(true), write("z"))
+	var errs ProbeErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ProbeErrors, got %v", err)
+	}
+
+	var dup *ErrDuplicateContexter
+	var un *ErrUnreachableWrapper
+	expected := []struct {
+		index int
+		is    func(error) bool
+	}{
+		{1, func(err error) bool { return errors.Is(err, ErrNilWrapper) }},
+		{2, func(err error) bool { return errors.Is(err, ErrNextNotCalled) }},
+		{3, func(err error) bool { return errors.As(err, &dup) && dup.FirstIndex == 0 }},
+		{4, func(err error) bool { return errors.Is(err, ErrCodeFlushedBeforeHeaders{}) }},
+		{5, func(err error) bool { return strings.Contains(err.Error(), "panics: boom") }},
+		{5, func(err error) bool { return errors.As(err, &un) && un.Index == 5 }},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), err)
+	}
+	for i, exp := range expected {
+		if errs[i].Index != exp.index || !exp.is(errs[i]) {
+			t.Errorf("[%d] unexpected error %v", i, errs[i])
+		}
+	}
+
+	if !strings.HasPrefix(err.Error(), "probing the stack failed:\n1 <nil>: nil wrapper\n2 wrap.writeStop: next handler not called\n") {
+		t.Errorf("unexpected error message %#v", err.Error())
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	for _, h := range []http.Handler{
+		New(WithStrict(), write("a"), writeStop("b")),
+		Compile(write("a"), WithStrict(), writeStop("b")),
+		Stack(&context{}, write("a"), writeStop("b"), WithStrict()),
+	} {
+		rec, req := newTestRequest("GET", "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "ab", http.StatusOK)
+	}
+
+	for name, fn := range map[string]func(){
+		"New":     func() { New(WithStrict(), writeStop("a"), write("b")) },
+		"Compile": func() { Compile(WithStrict(), write("a"), nil) },
+		"Stack":   func() { Stack(&context{}, WithStrict(), requestIDContext{}) },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(ProbeErrors); !ok {
+					t.Errorf("%s should panic with ProbeErrors", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
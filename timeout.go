@@ -0,0 +1,58 @@
+package wrap
+
+import (
+	stdcontext "context"
+	"net/http"
+	"time"
+)
+
+// Deadline is the context type of the deadline set by Timeout
+type Deadline struct {
+	// At is the point in time when the request times out
+	At time.Time
+
+	// Cancel cancels the context of the request. It may be called to release resources early.
+	Cancel stdcontext.CancelFunc
+}
+
+// Timeout is a Wrapper that lets the request time out after Duration: the context of the request
+// gets the deadline and the write deadline of the connection is set to the same point in time
+// (via http.ResponseController on the underlying ResponseWriter, see ReclaimResponseWriter), so that
+// handlers observing the context and writes of the response observe the same timeout.
+// An earlier deadline of the request context is kept.
+//
+// If the ResponseWriter is a Contexter supporting the Deadline type, the deadline and the cancel function
+// are stored. If the deadline has been exceeded and nothing has been written, the request is answered
+// with 503 Service Unavailable (see HTTPError for how the error is reported).
+// Timeout waits for handlers that ignore the context, BufferedTimeout answers without waiting for them.
+type Timeout struct {
+	Duration time.Duration
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Timeout{}
+
+// Wrap implements the Wrapper interface
+func (t Timeout) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		ctx, cancel := stdcontext.WithTimeout(req.Context(), t.Duration)
+		defer cancel()
+		at, _ := ctx.Deadline()
+
+		setWriteDeadline(rw, at)
+
+		dl := Deadline{At: at, Cancel: cancel}
+		if _, ok := rw.(Contexter); ok {
+			TrySetContext(rw, &dl)
+		}
+
+		rec := newStatusRecorder(rw)
+		next.ServeHTTP(rec.responseWriter(), req.WithContext(ctx))
+
+		if rec.Status() == 0 && ctx.Err() == stdcontext.DeadlineExceeded {
+			reportError(rw, &HTTPError{Code: http.StatusServiceUnavailable, Err: ctx.Err()})
+		}
+	}
+	return f
+}
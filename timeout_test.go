@@ -0,0 +1,102 @@
+package wrap
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	slow := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	fast := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Deadline(); !ok {
+			t.Errorf("request context should have a deadline")
+		}
+		rw.Write([]byte("fast"))
+	})
+
+	tests := []struct {
+		h    http.Handler
+		exp  string
+		code int
+	}{
+		{New(Timeout{Duration: time.Millisecond}, slow), "Service Unavailable", 503},
+		{New(Timeout{Duration: time.Second}, fast), "fast", 200},
+		{Stack(&context{}, HandleError{}, Timeout{Duration: time.Millisecond}, slow), "Service Unavailable", 503},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		test.h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+}
+
+func TestTimeoutKeepsEarlierDeadline(t *testing.T) {
+	var got time.Time
+	h := New(Timeout{Duration: time.Millisecond}, Timeout{Duration: time.Hour}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got, _ = req.Context().Deadline()
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+
+	if time.Until(got) > time.Minute {
+		t.Errorf("the earlier deadline should be kept, got %s", got)
+	}
+}
+
+func TestBufferedTimeout(t *testing.T) {
+	slow := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("partial"))
+		<-req.Context().Done()
+		time.Sleep(time.Millisecond)
+		if _, err := rw.Write([]byte("late")); err != http.ErrHandlerTimeout && !IsProbe(req) {
+			t.Errorf("late write should fail with http.ErrHandlerTimeout, got %v", err)
+		}
+	})
+	fast := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Fast", "1")
+		rw.WriteHeader(201)
+		rw.Write([]byte("fast"))
+	})
+
+	tests := []struct {
+		h    http.Handler
+		exp  string
+		code int
+	}{
+		{New(BufferedTimeout{Duration: time.Millisecond}, slow), "Service Unavailable", 503},
+		{New(BufferedTimeout{Duration: time.Second}, fast), "fast", 201},
+		{Stack(&context{}, HandleError{}, BufferedTimeout{Duration: time.Millisecond}, slow), "Service Unavailable", 503},
+	}
+
+	for _, test := range tests {
+		rec, req := newTestRequest("GET", "/")
+		test.h.ServeHTTP(rec, req)
+		assertResponse(t, rec, test.exp, test.code)
+	}
+
+	// let the slow handlers finish
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestBufferedTimeoutContexter(t *testing.T) {
+	h := Stack(&context{}, setUserIP{}, BufferedTimeout{Duration: time.Second}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var ip userIP
+		rw.(Contexter).Context(&ip)
+		rw.Write([]byte(net.IP(ip).String()))
+	}))
+	rec, req := newTestRequest("GET", "/")
+	req.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "192.0.2.1", 200)
+}
+
+func TestBufferedTimeoutPanic(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	New(Recover(nil), BufferedTimeout{Duration: time.Second}, Handler(panicker("boom"))).ServeHTTP(rec, req)
+	assertResponse(t, rec, "Internal Server Error", 500)
+}
@@ -0,0 +1,231 @@
+package wrap
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BodyTransformer transforms response bodies of certain content types while they are written,
+// e.g. a HTML, CSS or JS minifier or an image optimizer
+type BodyTransformer struct {
+	// Name identifies the transformer in the TransformStats
+	Name string
+
+	// ContentTypes are the media types of the responses that are transformed, e.g. "text/html",
+	// "image/*" or "*/*" for all responses
+	ContentTypes []string
+
+	// NewWriter returns a writer that writes the transformed body to w. Close is called when the
+	// response has been written. If it has a Flush() error method, it is called when the response is flushed.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// matches returns if the transformer applies to the media type
+func (b *BodyTransformer) matches(mediaType string) bool {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	for _, ct := range b.ContentTypes {
+		ct = strings.ToLower(ct)
+		if ct == mediaType || ct == typ+"/*" || ct == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// TransformStats are the metrics of a BodyTransformer for a response
+type TransformStats struct {
+	// Transformer is the name of the BodyTransformer
+	Transformer string
+
+	// In is the number of bytes written to the transformer
+	In int64
+
+	// Out is the number of bytes written by the transformer
+	Out int64
+
+	// Duration is the time spent inside the transformer (without the time of the following transformers
+	// and of writing to the ResponseWriter)
+	Duration time.Duration
+}
+
+// Transform is a Wrapper that passes the bodies written by the next handlers through the Transformers matching
+// their Content-Type. The Content-Type is taken from the headers (which are held back by a Peek until the body is
+// written) or detected from the first write. The matching transformers are chained in the given order and applied
+// while the body is written, so the body is never held completely in memory. The Content-Length header of
+// transformed responses is removed.
+//
+// Responses to HEAD requests, responses without body (204, 304) and responses with a Content-Encoding are
+// not transformed, so Transform should come after Compress in the stack.
+//
+// OnStats receives the TransformStats of every transformer that has been applied to a response, e.g. to pass
+// them to an Exporter or Metrics.
+type Transform struct {
+	Transformers []BodyTransformer
+
+	// OnStats is called for each applied transformer after the response has been written (optional)
+	OnStats func(req *http.Request, stats TransformStats)
+}
+
+// make sure to fulfill the Wrapper interface
+var _ Wrapper = Transform{}
+
+// transformStage is a BodyTransformer applied to a response
+type transformStage struct {
+	stats TransformStats
+	w     io.WriteCloser
+
+	// inclusive is the time spent in the Write and Close calls of w, including the following stages
+	inclusive time.Duration
+
+	// downstream is the time spent in writing to the following stage
+	downstream time.Duration
+}
+
+// Write writes to the transformer
+func (s *transformStage) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := s.w.Write(b)
+	s.inclusive += time.Since(start)
+	s.stats.In += int64(n)
+	return n, err
+}
+
+// Close closes the transformer
+func (s *transformStage) Close() error {
+	start := time.Now()
+	err := s.w.Close()
+	s.inclusive += time.Since(start)
+	return err
+}
+
+// stageOutput counts the bytes written by a transformer and the time spent in the following stage
+type stageOutput struct {
+	stage *transformStage
+	next  io.Writer
+}
+
+func (o *stageOutput) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := o.next.Write(b)
+	o.stage.downstream += time.Since(start)
+	o.stage.stats.Out += int64(n)
+	return n, err
+}
+
+// transformWriter is the ResponseWriter passed to the next handlers by Transform
+type transformWriter struct {
+	*Peek
+	transform Transform
+	head      bool
+	started   bool
+	stages    []*transformStage
+}
+
+// start chooses the transformers for the response
+func (t *transformWriter) start(b []byte) {
+	t.started = true
+	header := t.Peek.Header()
+	code := t.Peek.Code
+	if t.head || code == http.StatusNoContent || code == http.StatusNotModified || header.Get("Content-Encoding") != "" {
+		return
+	}
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(b)
+		header.Set("Content-Type", ct)
+	}
+	mediaType, _, _ := strings.Cut(ct, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	var out io.Writer = t.Peek
+	var stages []*transformStage
+	for i := len(t.transform.Transformers) - 1; i >= 0; i-- {
+		bt := &t.transform.Transformers[i]
+		if !bt.matches(mediaType) {
+			continue
+		}
+		s := &transformStage{stats: TransformStats{Transformer: bt.Name}}
+		s.w = bt.NewWriter(&stageOutput{stage: s, next: out})
+		out = s
+		stages = append([]*transformStage{s}, stages...)
+	}
+	if len(stages) > 0 {
+		header.Del("Content-Length")
+		t.stages = stages
+	}
+}
+
+// Write writes the body through the transformers
+func (t *transformWriter) Write(b []byte) (int, error) {
+	if !t.started {
+		t.start(b)
+	}
+	if len(t.stages) == 0 {
+		return t.Peek.Write(b)
+	}
+	return t.stages[0].Write(b)
+}
+
+// ReadFrom writes the content of r. If no transformer applies to the response, r is passed to the
+// underlying ResponseWriter (see Peek.ReadFrom), so that files may be served via sendfile.
+func (t *transformWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !t.started && t.Peek.Header().Get("Content-Type") != "" {
+		t.start(nil)
+	}
+	if t.started && len(t.stages) == 0 {
+		return t.Peek.ReadFrom(r)
+	}
+	return io.Copy(writerOnly{t}, r)
+}
+
+// Flush flushes the transformers and the underlying ResponseWriter
+func (t *transformWriter) Flush() {
+	for _, s := range t.stages {
+		if f, ok := s.w.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	t.Peek.FlushMissing()
+	flushInner(t.Peek.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (t *transformWriter) Unwrap() http.ResponseWriter {
+	return t.Peek.ResponseWriter
+}
+
+// finish closes the transformers in order and reports their stats
+func (t *transformWriter) finish(req *http.Request) {
+	for _, s := range t.stages {
+		s.Close()
+	}
+	t.Peek.FlushMissing()
+	if t.transform.OnStats == nil {
+		return
+	}
+	for _, s := range t.stages {
+		stats := s.stats
+		stats.Duration = s.inclusive - s.downstream
+		t.transform.OnStats(req, stats)
+	}
+}
+
+// Wrap implements the Wrapper interface
+func (t Transform) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		tw := &transformWriter{Peek: NewPeek(rw, func(p *Peek) bool {
+			p.FlushMissing()
+			return true
+		}), transform: t, head: req.Method == http.MethodHead}
+		next.ServeHTTP(tw, req)
+		tw.finish(req)
+	}
+	return f
+}
@@ -0,0 +1,200 @@
+package wrap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spaceStripper removes all spaces of the body
+type spaceStripper struct{ w io.Writer }
+
+func (s spaceStripper) Write(b []byte) (int, error) {
+	if _, err := s.w.Write(bytes.ReplaceAll(b, []byte(" "), nil)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (spaceStripper) Close() error { return nil }
+
+// closeMarker writes a marker when it is closed
+type closeMarker struct{ w io.Writer }
+
+func (c closeMarker) Write(b []byte) (int, error) { return c.w.Write(b) }
+func (c closeMarker) Close() error {
+	_, err := io.WriteString(c.w, "<!-- end -->")
+	return err
+}
+
+var (
+	stripSpaces = BodyTransformer{
+		Name:         "strip",
+		ContentTypes: []string{"text/html", "text/css"},
+		NewWriter:    func(w io.Writer) io.WriteCloser { return spaceStripper{w} },
+	}
+	markClose = BodyTransformer{
+		Name:         "mark",
+		ContentTypes: []string{"text/*"},
+		NewWriter:    func(w io.Writer) io.WriteCloser { return closeMarker{w} },
+	}
+)
+
+func TestTransformChain(t *testing.T) {
+	var mx sync.Mutex
+	var stats []TransformStats
+	tr := Transform{
+		Transformers: []BodyTransformer{stripSpaces, markClose},
+		OnStats: func(req *http.Request, s TransformStats) {
+			mx.Lock()
+			stats = append(stats, s)
+			mx.Unlock()
+		},
+	}
+	h := New(tr, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Header().Set("Content-Length", "9")
+		rw.Write([]byte("<p> a </p>"))
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "<p>a</p><!-- end -->", 200)
+
+	if cl := rec.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("Content-Length should be removed, but is %#v", cl)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 transformers, got %d", len(stats))
+	}
+	if s := stats[0]; s.Transformer != "strip" || s.In != 10 || s.Out != 8 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if s := stats[1]; s.Transformer != "mark" || s.In != 8 || s.Out != 20 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestTransformDetectContentType(t *testing.T) {
+	h := New(Transform{Transformers: []BodyTransformer{markClose}}, Handler(writeString("<html> x")))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "<html> x<!-- end -->", 200)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type should be detected, but is %#v", ct)
+	}
+}
+
+func TestTransformSkip(t *testing.T) {
+	tr := Transform{Transformers: []BodyTransformer{stripSpaces}}
+
+	tests := []struct {
+		method string
+		header string
+		value  string
+	}{
+		{"GET", "Content-Type", "application/json"},
+		{"GET", "Content-Encoding", "gzip"},
+		{"HEAD", "Content-Type", "text/html"},
+	}
+
+	for _, test := range tests {
+		h := New(tr, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set(test.header, test.value)
+			rw.Write([]byte("a b"))
+		}))
+		rec, req := newTestRequest(test.method, "/")
+		h.ServeHTTP(rec, req)
+		assertResponse(t, rec, "a b", 200)
+	}
+}
+
+func TestTransformCode(t *testing.T) {
+	h := New(Transform{Transformers: []BodyTransformer{markClose}}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	rec, req := newTestRequest("GET", "/")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "", 404)
+}
+
+// readerFromRecorder records if the body has been written via ReadFrom
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom++
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestTransformReadFrom(t *testing.T) {
+	content := strings.NewReader("<p> a </p>")
+	h := New(
+		AccessLog{Out: io.Discard},
+		Compress{},
+		Transform{Transformers: []BodyTransformer{stripSpaces}},
+		Include{},
+		RewriteHTML{Visitors: []HTMLVisitor{InjectBeforeBodyEnd("<script></script>")}},
+		Preload{},
+		HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			content.Seek(0, io.SeekStart)
+			http.ServeContent(rw, req, req.URL.Path, time.Time{}, content)
+		}),
+	)
+
+	tests := []struct {
+		path     string
+		body     string
+		readFrom int
+	}{
+		{"/a.txt", "<p> a </p>", 1},
+		{"/a.html", "<p>a</p>", 0},
+	}
+
+	for _, test := range tests {
+		rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req, _ := http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(rec, req)
+
+		if got := rec.Body.String(); got != test.body {
+			t.Errorf("%s: body should be %#v, but is %#v", test.path, test.body, got)
+		}
+
+		if rec.readFrom != test.readFrom {
+			t.Errorf("%s: ReadFrom should be called %d times, but was called %d times", test.path, test.readFrom, rec.readFrom)
+		}
+	}
+}
+
+func TestTransformFlushCompress(t *testing.T) {
+	rec, req := newTestRequest("GET", "/")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	var flushed string
+	New(Compress{}, Transform{Transformers: []BodyTransformer{stripSpaces}}, HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/html")
+		rw.Write([]byte("<p> a </p>"))
+		rw.(http.Flusher).Flush()
+		gz, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 8)
+		io.ReadFull(gz, b)
+		flushed = string(b)
+	})).ServeHTTP(rec, req)
+
+	if flushed != "<p>a</p>" {
+		t.Errorf("Flush should flush the encoder of Compress, got %#v", flushed)
+	}
+}
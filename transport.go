@@ -0,0 +1,108 @@
+package wrap
+
+import (
+	"net/http"
+	"time"
+)
+
+var (
+	asRoundTripWrapper = "RoundTripWrapper"
+	asRoundTripper     = "http.RoundTripper"
+)
+
+// RoundTripWrapper can wrap a http.RoundTripper with another one. It is the client side counterpart
+// of Wrapper and allows to compose the transport of a http.Client (auth headers, retries, tracing).
+type RoundTripWrapper interface {
+	// WrapRoundTrip wraps the next http.RoundTripper of the transport stack and returns a wrapping http.RoundTripper.
+	// If it does not call next.RoundTrip, nobody will.
+	WrapRoundTrip(next http.RoundTripper) http.RoundTripper
+}
+
+// RoundTripWrapperFunc is an adapter for a function that acts as RoundTripWrapper
+type RoundTripWrapperFunc func(http.RoundTripper) http.RoundTripper
+
+// WrapRoundTrip makes the RoundTripWrapperFunc fulfill the RoundTripWrapper interface by calling itself.
+func (wf RoundTripWrapperFunc) WrapRoundTrip(next http.RoundTripper) http.RoundTripper { return wf(next) }
+
+// RoundTripperFunc is an adapter for a function that acts as http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip makes the RoundTripperFunc fulfill the http.RoundTripper interface by calling itself.
+func (rf RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return rf(req) }
+
+// RoundTripper returns a RoundTripWrapper for a http.RoundTripper.
+// The returned RoundTripWrapper simply runs the given RoundTripper and ignores the
+// next RoundTripper in the stack. It is useful to end a transport stack with another
+// transport than http.DefaultTransport.
+func RoundTripper(rt http.RoundTripper) RoundTripWrapper {
+	var wf RoundTripWrapperFunc
+	if IsDebug() {
+		wf = func(http.RoundTripper) http.RoundTripper {
+			return &debugTransport{Object: rt, Role: asRoundTripper, RoundTripper: rt}
+		}
+		return wf
+	}
+	wf = func(http.RoundTripper) http.RoundTripper { return rt }
+	return wf
+}
+
+// NewTransport returns the wrapping http.RoundTripper that is build like New builds a
+// http.Handler: the first given wrapper wraps the RoundTripper returned by the second one and so on.
+// The last wrapper receives http.DefaultTransport (see RoundTripper to use another one).
+//
+// If DEBUG is set, each RoundTripper is wrapped with a debug struct that calls DEBUGGER.Debug before
+// running it.
+func NewTransport(wrapper ...RoundTripWrapper) (rt http.RoundTripper) {
+	rt = http.DefaultTransport
+	for i := len(wrapper) - 1; i >= 0; i-- {
+		rt = wrapper[i].WrapRoundTrip(rt)
+		if IsDebug() {
+			rt = &debugTransport{Object: wrapper[i], Role: asRoundTripWrapper, RoundTripper: rt}
+		}
+	}
+	return
+}
+
+// debugTransport is the client side counterpart of debug
+type debugTransport struct {
+	Object interface{}
+	Role   string
+	http.RoundTripper
+}
+
+func (d *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !IsDebug() {
+		return d.RoundTripper.RoundTrip(req)
+	}
+	dbg := CurrentDebugger()
+	td, ok := dbg.(TransportDebugger)
+	if !ok {
+		dbg.Debug(req, d.Object, d.Role)
+		return d.RoundTripper.RoundTrip(req)
+	}
+	td.DebugTransport(req, d.Object, d.Role)
+	start := time.Now()
+	res, err := d.RoundTripper.RoundTrip(req)
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	td.DebugTransportDone(req, d.Object, d.Role, status, err, time.Since(start))
+	return res, err
+}
+
+// TransportDebugger is a Debugger that has special support for the client side transport stacks
+// (see NewTransport). If the DEBUGGER is a TransportDebugger, DebugTransport is called instead
+// of Debug and DebugTransportDone after the RoundTripper returned.
+type TransportDebugger interface {
+	Debugger
+
+	// DebugTransport receives the outgoing request, the object that wraps and the role in which
+	// the object acts (see Debugger)
+	DebugTransport(req *http.Request, obj interface{}, role string)
+
+	// DebugTransportDone receives the outgoing request, the object and role, the status code
+	// of the response (0 if there is none), the error returned by the RoundTripper and the duration
+	// of the round trip, including the next RoundTrippers.
+	DebugTransportDone(req *http.Request, obj interface{}, role string, status int, err error, d time.Duration)
+}
@@ -0,0 +1,104 @@
+package wrap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// setHeader is a RoundTripWrapper that sets a request header
+type setHeader [2]string
+
+func (s setHeader) WrapRoundTrip(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set(s[0], s[1])
+		return next.RoundTrip(req)
+	})
+}
+
+// echoTransport responds with the request headers X-A and X-B
+var echoTransport = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+	body := req.Header.Get("X-A") + req.Header.Get("X-B")
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+})
+
+func readBody(t *testing.T, res *http.Response, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	return string(b)
+}
+
+func TestNewTransport(t *testing.T) {
+	client := &http.Client{Transport: NewTransport(
+		setHeader{"X-A", "a"},
+		setHeader{"X-B", "b"},
+		RoundTripper(echoTransport),
+	)}
+
+	res, err := client.Get("http://example.com/")
+	if got := readBody(t, res, err); got != "ab" {
+		t.Errorf("expected body %#v, got %#v", "ab", got)
+	}
+}
+
+func TestNewTransportDebug(t *testing.T) {
+	var buf bytes.Buffer
+	old := CurrentDebugger()
+	NewLogDebugger(&buf, 0)
+	SetDebug()
+	defer DisableDebug()
+	rt := NewTransport(setHeader{"X-A", "a"}, RoundTripper(echoTransport))
+
+	req, _ := http.NewRequest("GET", "http://example.com/x", nil)
+	res, err := rt.RoundTrip(req)
+	SetDebugger(old)
+	readBody(t, res, err)
+
+	out := buf.String()
+	expected := []string{
+		"client GET http://example.com/x wrap.setHeader as RoundTripWrapper\n",
+		"client GET http://example.com/x wrap.RoundTripperFunc as http.RoundTripper\n",
+		"client GET http://example.com/x wrap.setHeader as RoundTripWrapper returned 200 after ",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(out, exp) {
+			t.Errorf("debug output should contain %#v, but is %#v", exp, out)
+		}
+	}
+}
+
+// failingTransport always fails
+var failingTransport = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("unreachable")
+})
+
+func TestTransportDebuggerFailure(t *testing.T) {
+	var buf bytes.Buffer
+	old := CurrentDebugger()
+	NewLogDebugger(&buf, 0)
+	SetDebug()
+	defer DisableDebug()
+	rt := NewTransport(RoundTripper(failingTransport))
+
+	req, _ := http.NewRequest("POST", "http://example.com/y", nil)
+	rt.RoundTrip(req)
+	SetDebugger(old)
+
+	exp := "client POST http://example.com/y wrap.RoundTripperFunc as http.RoundTripper failed after "
+	if out := buf.String(); !strings.Contains(out, exp) || !strings.Contains(out, ": unreachable\n") {
+		t.Errorf("debug output should contain %#v, but is %#v", exp, out)
+	}
+}
@@ -0,0 +1,106 @@
+package wrap
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// RoundTripContext is the per request context of a transport stack, the client side counterpart of
+// a Contexter. It is attached to the context of the outgoing request by InjectRoundTripContext and shared
+// by the RoundTripWrappers of the stack (e.g. for auth tokens, trace ids or attempt counts).
+//
+// Like with a Contexter, values are distinguished by their type and accessed via pointers.
+// A RoundTripContext is safe for concurrent use.
+type RoundTripContext struct {
+	mx     sync.RWMutex
+	values map[reflect.Type]reflect.Value
+}
+
+// elemType returns the type the given pointer points to
+func elemType(ctxPtr interface{}) (reflect.Type, bool) {
+	ty := reflect.TypeOf(ctxPtr)
+	if ty == nil || ty.Kind() != reflect.Ptr || reflect.ValueOf(ctxPtr).IsNil() {
+		return nil, false
+	}
+	return ty.Elem(), true
+}
+
+// Context lets the given pointer point to the saved value of the same type.
+// It returns if it has found something and panics with *ErrUnsupportedContextGetter if ctxPtr
+// is no non nil pointer.
+func (c *RoundTripContext) Context(ctxPtr interface{}) (found bool) {
+	ty, ok := elemType(ctxPtr)
+	if !ok {
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	c.mx.RLock()
+	val, has := c.values[ty]
+	c.mx.RUnlock()
+	if !has {
+		return false
+	}
+	reflect.ValueOf(ctxPtr).Elem().Set(val)
+	return true
+}
+
+// SetContext saves the value the given pointer points to. It panics with *ErrUnsupportedContextSetter
+// if ctxPtr is no non nil pointer.
+func (c *RoundTripContext) SetContext(ctxPtr interface{}) {
+	ty, ok := elemType(ctxPtr)
+	if !ok {
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+	c.mx.Lock()
+	if c.values == nil {
+		c.values = map[reflect.Type]reflect.Value{}
+	}
+	c.values[ty] = reflect.ValueOf(ctxPtr).Elem()
+	c.mx.Unlock()
+}
+
+// RoundTripContextOf returns the RoundTripContext of the outgoing request, nil if there is none
+func RoundTripContextOf(req *http.Request) *RoundTripContext {
+	c, _ := requestValue(req, roundTripKey).(*RoundTripContext)
+	return c
+}
+
+// GetRoundTripContext is a shortcut to get a value from the RoundTripContext of the request.
+// It returns false if there is no RoundTripContext or no value of the type.
+func GetRoundTripContext(req *http.Request, ctxPtr interface{}) bool {
+	c := RoundTripContextOf(req)
+	if c == nil {
+		return false
+	}
+	return c.Context(ctxPtr)
+}
+
+// SetRoundTripContext is a shortcut to set a value in the RoundTripContext of the request.
+// It returns false if there is no RoundTripContext.
+func SetRoundTripContext(req *http.Request, ctxPtr interface{}) bool {
+	c := RoundTripContextOf(req)
+	if c == nil {
+		return false
+	}
+	c.SetContext(ctxPtr)
+	return true
+}
+
+// InjectRoundTripContext returns a RoundTripWrapper that attaches a new RoundTripContext to the
+// outgoing request, unless it already has one. It should be the first wrapper of a transport stack.
+func InjectRoundTripContext() RoundTripWrapper {
+	var wf RoundTripWrapperFunc
+	wf = func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if RoundTripContextOf(req) == nil {
+				req = withRequestValue(req, roundTripKey, &RoundTripContext{})
+			}
+			return next.RoundTrip(req)
+		})
+	}
+	return wf
+}
+
+// Attempt is the context type of the current attempt of an outgoing request (starting with 1).
+// It is set by RetryTransport if the request has a RoundTripContext.
+type Attempt int
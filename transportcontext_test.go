@@ -0,0 +1,69 @@
+package wrap
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// token is a context type for the transport
+type token string
+
+// setToken stores a token in the RoundTripContext
+type setToken string
+
+func (s setToken) WrapRoundTrip(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t := token(s)
+		SetRoundTripContext(req, &t)
+		return next.RoundTrip(req)
+	})
+}
+
+func TestRoundTripContext(t *testing.T) {
+	var seen []string
+	f := &flakyTransport{codes: []int{503}}
+	rt := NewTransport(
+		InjectRoundTripContext(),
+		setToken("secret"),
+		RetryTransport{Backoff: noBackoff},
+		RoundTripWrapperFunc(func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				var tok token
+				var attempt Attempt
+				GetRoundTripContext(req, &tok)
+				GetRoundTripContext(req, &attempt)
+				seen = append(seen, fmt.Sprintf("%s:%d", tok, attempt))
+				return next.RoundTrip(req)
+			})
+		}),
+		RoundTripper(f),
+	)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil || res.StatusCode != 200 {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+
+	if got := strings.Join(seen, ","); got != "secret:1,secret:2" {
+		t.Errorf("unexpected context values %#v", got)
+	}
+}
+
+func TestRoundTripContextWithoutInjecter(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	var tok token = "x"
+	if SetRoundTripContext(req, &tok) || GetRoundTripContext(req, &tok) {
+		t.Errorf("without RoundTripContext nothing should be set or found")
+	}
+
+	c := &RoundTripContext{}
+	defer func() {
+		if _, ok := recover().(*ErrUnsupportedContextSetter); !ok {
+			t.Errorf("SetContext should panic for non pointers")
+		}
+	}()
+	c.SetContext(tok)
+}
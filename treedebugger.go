@@ -0,0 +1,175 @@
+package wrap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// treeNode is a debugged object inside the tree of a request
+type treeNode struct {
+	obj       interface{}
+	role      string
+	status    int
+	dur       time.Duration
+	panicked  bool
+	recovered interface{}
+	parent    *treeNode
+	children  []*treeNode
+}
+
+// treeTrace is the tree of a single request
+type treeTrace struct {
+	mx      sync.Mutex
+	req     *http.Request
+	root    *treeNode
+	current *treeNode
+}
+
+// treeDebugger renders the debugged objects of each request as an indented tree
+type treeDebugger struct {
+	mx    sync.Mutex
+	out   io.Writer
+	color bool
+}
+
+// make sure to fulfill the PanicDebugger and ExitDebugger interfaces
+var _ PanicDebugger = &treeDebugger{}
+var _ ExitDebugger = &treeDebugger{}
+
+// NewTreeDebugger sets the Debugger (see SetDebugger) to a debugger meant for local development that writes
+// the traversal of each request through the debugged objects as an indented tree to out,
+// once the request has been served. Each object is shown with its role, the status code written
+// through it and the time it took (including its next handlers). Panics are shown as well.
+//
+// If color is true, the status codes and panics are colored with ANSI escape codes for terminals.
+func NewTreeDebugger(out io.Writer, color bool) {
+	SetDebugger(&treeDebugger{out: out, color: color})
+}
+
+// trace returns the treeTrace of the request, nil if there is no debugState
+func (t *treeDebugger) trace(req *http.Request) *treeTrace {
+	state, has := requestValue(req, debugKey).(*debugState)
+	if !has {
+		return nil
+	}
+	tr, has := state.trace.(*treeTrace)
+	if !has {
+		root := &treeNode{}
+		tr = &treeTrace{req: req, root: root, current: root}
+		state.trace = tr
+	}
+	return tr
+}
+
+// Debug adds the object to the tree of the request
+func (t *treeDebugger) Debug(req *http.Request, obj interface{}, role string) {
+	tr := t.trace(req)
+	if tr == nil {
+		t.write([]byte(fmt.Sprintf("%T as %s\n", obj, role)))
+		return
+	}
+	tr.mx.Lock()
+	n := &treeNode{obj: obj, role: role, parent: tr.current}
+	tr.current.children = append(tr.current.children, n)
+	tr.current = n
+	tr.mx.Unlock()
+}
+
+// DebugPanic marks the current object of the tree as panicked
+func (t *treeDebugger) DebugPanic(req *http.Request, obj interface{}, role string, recovered interface{}, stack []byte) {
+	tr := t.trace(req)
+	if tr == nil {
+		return
+	}
+	tr.mx.Lock()
+	tr.current.panicked = true
+	tr.current.recovered = recovered
+	tr.mx.Unlock()
+}
+
+// DebugExit finishes the current object of the tree and writes the tree, if it was the outermost
+func (t *treeDebugger) DebugExit(req *http.Request, obj interface{}, role string, status int, d time.Duration) {
+	tr := t.trace(req)
+	if tr == nil || tr.current == tr.root {
+		return
+	}
+	tr.mx.Lock()
+	n := tr.current
+	n.status, n.dur = status, d
+	tr.current = n.parent
+	finished := tr.current == tr.root
+	tr.mx.Unlock()
+
+	if finished {
+		var bf bytes.Buffer
+		t.render(&bf, tr)
+		t.write(bf.Bytes())
+	}
+}
+
+func (t *treeDebugger) write(b []byte) {
+	t.mx.Lock()
+	t.out.Write(b)
+	t.mx.Unlock()
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorGray   = "\x1b[90m"
+)
+
+func (t *treeDebugger) colored(color, s string) string {
+	if !t.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func (t *treeDebugger) status(code int) string {
+	switch {
+	case code == 0:
+		return t.colored(colorGray, "---")
+	case code >= 500:
+		return t.colored(colorRed, fmt.Sprint(code))
+	case code >= 400:
+		return t.colored(colorYellow, fmt.Sprint(code))
+	case code >= 300:
+		return t.colored(colorCyan, fmt.Sprint(code))
+	default:
+		return t.colored(colorGreen, fmt.Sprint(code))
+	}
+}
+
+func (t *treeDebugger) render(bf *bytes.Buffer, tr *treeTrace) {
+	fmt.Fprintf(bf, "%s %s", tr.req.Method, tr.req.URL.Path)
+	if id := tr.req.Header.Get(RequestIDHeader); id != "" {
+		fmt.Fprintf(bf, " [%s]", id)
+	}
+	bf.WriteString("\n")
+	for i, n := range tr.root.children {
+		t.renderNode(bf, n, "", i == len(tr.root.children)-1)
+	}
+}
+
+func (t *treeDebugger) renderNode(bf *bytes.Buffer, n *treeNode, prefix string, last bool) {
+	branch, indent := "├─ ", "│  "
+	if last {
+		branch, indent = "└─ ", "   "
+	}
+	fmt.Fprintf(bf, "%s%s%T as %s %s %s", prefix, branch, n.obj, n.role, t.status(n.status), n.dur.Round(time.Microsecond))
+	if n.panicked {
+		bf.WriteString(" " + t.colored(colorRed, fmt.Sprintf("panic: %v", n.recovered)))
+	}
+	bf.WriteString("\n")
+	for i, c := range n.children {
+		t.renderNode(bf, c, prefix+indent, i == len(n.children)-1)
+	}
+}
@@ -0,0 +1,70 @@
+package wrap
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"testing"
+)
+
+var durationRegexp = regexp.MustCompile(`[0-9.]+(ns|µs|ms|s)`)
+
+func TestTreeDebugger(t *testing.T) {
+	var buf bytes.Buffer
+	NewTreeDebugger(&buf, false)
+	SetDebug()
+	defer DisableDebug()
+
+	h := New(
+		write("one"),
+		Handler(writeStop("two")),
+	)
+
+	rec, req := newTestRequest("GET", "/a")
+	h.ServeHTTP(rec, req)
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	assertResponse(t, rec, "onetwo", 200)
+
+	expected := `GET /a
+└─ wrap.write as Wrapper 200 D
+   └─ wrap.NextHandlerFunc as Wrapper 200 D
+      └─ wrap.NextHandlerFunc as NextHandlerFunc 200 D
+         └─ wrap.writeStop as http.Handler 200 D
+`
+	got := durationRegexp.ReplaceAllString(buf.String(), "D")
+
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestTreeDebuggerPanic(t *testing.T) {
+	var buf bytes.Buffer
+	NewTreeDebugger(&buf, true)
+	SetDebug()
+	defer DisableDebug()
+
+	h := New(
+		write("a"),
+		Handler(panicker("boom")),
+	)
+
+	rec, req := newTestRequest("GET", "/")
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(rec, req)
+	}()
+	NewLogDebugger(&bytes.Buffer{}, log.LstdFlags)
+
+	expected := "GET /\n" +
+		"└─ wrap.write as Wrapper \x1b[32m200\x1b[0m D\n" +
+		"   └─ wrap.NextHandlerFunc as Wrapper \x1b[90m---\x1b[0m D\n" +
+		"      └─ wrap.NextHandlerFunc as NextHandlerFunc \x1b[90m---\x1b[0m D\n" +
+		"         └─ wrap.panicker as http.Handler \x1b[90m---\x1b[0m D \x1b[31mpanic: boom\x1b[0m\n"
+	got := durationRegexp.ReplaceAllString(buf.String(), "D")
+
+	if got != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, got)
+	}
+}
@@ -0,0 +1,70 @@
+package wrap
+
+import (
+	"fmt"
+	"log"
+)
+
+// Terminator is implemented by Wrappers that may never call the next handler. Terminates returns true
+// if the next handler is never called. The Wrappers returned by Handler and HandlerFunc are treated
+// as terminating Terminators, too.
+// Wrappers after a terminating Wrapper are unreachable, which New, Compile and Stack report via
+// WarnUnreachable (or by panicking, if STRICT is set).
+type Terminator interface {
+	Terminates() bool
+}
+
+// ErrUnreachableWrapper is the error returned if a terminating Wrapper is followed by other Wrappers,
+// that are never run.
+type ErrUnreachableWrapper struct {
+	// Terminating is the terminating wrapper and Index its position
+	Terminating Wrapper
+	Index       int
+
+	// Unreachable are the wrappers after the terminating one
+	Unreachable []Wrapper
+}
+
+// Error returns the error message
+func (e *ErrUnreachableWrapper) Error() string {
+	return fmt.Sprintf("unreachable wrappers in stack: %T at %d terminates, %d wrapper(s) after it never run", e.Terminating, e.Index, len(e.Unreachable))
+}
+
+// WarnUnreachable is called by New, Compile and Stack, if a terminating Wrapper is followed by other wrappers
+// and STRICT is not set. By default it logs the error with the standard logger.
+// It may be replaced, e.g. to fail tests, before any call to New.
+var WarnUnreachable = func(err *ErrUnreachableWrapper) {
+	log.Printf("go-on/wrap: %s", err)
+}
+
+// checkUnreachable reports unreachable wrappers: it panics with the *ErrUnreachableWrapper if STRICT is set and
+// calls WarnUnreachable otherwise
+func checkUnreachable(wrapper []Wrapper) {
+	err := findUnreachable(wrapper)
+	if err == nil {
+		return
+	}
+	if IsStrict() {
+		panic(err)
+	}
+	WarnUnreachable(err.(*ErrUnreachableWrapper))
+}
+
+// terminates returns if w is a Terminator that terminates or has been returned by Handler or HandlerFunc
+func terminates(w Wrapper) bool {
+	if t, ok := w.(Terminator); ok {
+		return t.Terminates()
+	}
+	return isAdapter(w)
+}
+
+// findUnreachable returns an *ErrUnreachableWrapper if one of the given wrappers, except the last one,
+// is a Terminator that terminates, nil otherwise
+func findUnreachable(wrapper []Wrapper) error {
+	for i := 0; i < len(wrapper)-1; i++ {
+		if terminates(wrapper[i]) {
+			return &ErrUnreachableWrapper{Terminating: wrapper[i], Index: i, Unreachable: wrapper[i+1:]}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// terminator is a Wrapper that terminates, if it is set
+type terminator bool
+
+func (t terminator) Terminates() bool { return bool(t) }
+
+func (t terminator) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if !t {
+			next.ServeHTTP(rw, req)
+		}
+	}
+	return f
+}
+
+func TestHandlerIsNextHandlerFunc(t *testing.T) {
+	for _, w := range []Wrapper{Handler(write("a")), HandlerFunc(writeCode)} {
+		if _, ok := w.(NextHandlerFunc); !ok {
+			t.Errorf("%T should be a NextHandlerFunc", w)
+		}
+	}
+}
+
+func TestFindUnreachable(t *testing.T) {
+	tests := []struct {
+		wrapper []Wrapper
+		index   int
+	}{
+		{[]Wrapper{write("a"), terminator(true)}, -1},
+		{[]Wrapper{terminator(false), write("a")}, -1},
+		{[]Wrapper{write("a"), terminator(true), write("b"), write("c")}, 1},
+		{[]Wrapper{HandlerFunc(writeCode), write("a")}, 0},
+		{[]Wrapper{write("a"), Handler(write("b")), write("c")}, 1},
+		{[]Wrapper{NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {}), write("a")}, -1},
+		{nil, -1},
+	}
+
+	for i, test := range tests {
+		err := findUnreachable(test.wrapper)
+		if test.index < 0 {
+			if err != nil {
+				t.Errorf("[%d] expected no error, got %v", i, err)
+			}
+			continue
+		}
+		var un *ErrUnreachableWrapper
+		if !errors.As(err, &un) {
+			t.Errorf("[%d] expected *ErrUnreachableWrapper, got %v", i, err)
+			continue
+		}
+		if un.Index != test.index || len(un.Unreachable) != len(test.wrapper)-test.index-1 {
+			t.Errorf("[%d] expected terminating wrapper at %d, got %d with %d unreachable", i, test.index, un.Index, len(un.Unreachable))
+		}
+	}
+}
+
+// catchUnreachable replaces WarnUnreachable until the returned function is called,
+// which returns the reported errors
+func catchUnreachable() func() []*ErrUnreachableWrapper {
+	var errs []*ErrUnreachableWrapper
+	old := WarnUnreachable
+	WarnUnreachable = func(err *ErrUnreachableWrapper) { errs = append(errs, err) }
+	return func() []*ErrUnreachableWrapper {
+		WarnUnreachable = old
+		return errs
+	}
+}
+
+func TestStackUnreachable(t *testing.T) {
+	done := catchUnreachable()
+	Stack(&context{}, terminator(true), write("a"))
+	Stack(&context{}, HandlerFunc(func(http.ResponseWriter, *http.Request) {}), write("a"))
+	Stack(&context{}, write("a"), Handler(http.NotFoundHandler()))
+	errs := done()
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", errs)
+	}
+	if got, exp := errs[0].Error(), "unreachable wrappers in stack: wrap.terminator at 1 terminates, 1 wrapper(s) after it never run"; got != exp {
+		t.Errorf("expected error message %#v, got %#v", exp, got)
+	}
+	if got, exp := errs[1].Error(), "unreachable wrappers in stack: wrap.NextHandlerFunc at 1 terminates, 1 wrapper(s) after it never run"; got != exp {
+		t.Errorf("expected error message %#v, got %#v", exp, got)
+	}
+}
+
+func TestNewStrictUnreachable(t *testing.T) {
+	done := catchUnreachable()
+	New(terminator(true), write("a"))
+	if errs := done(); len(errs) != 1 {
+		t.Errorf("New should warn about unreachable wrappers, got %v", errs)
+	}
+
+	SetStrict()
+	defer func() {
+		DisableStrict()
+		if _, ok := recover().(*ErrUnreachableWrapper); !ok {
+			t.Errorf("New should panic with *ErrUnreachableWrapper in strict mode")
+		}
+	}()
+	New(terminator(true), write("a"))
+}
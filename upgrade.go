@@ -0,0 +1,123 @@
+package wrap
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IsUpgrade returns if the request asks for a protocol upgrade (e.g. to WebSocket), i.e. it has an Upgrade
+// header and the Connection header contains the "upgrade" token.
+//
+// Wrappers that touch the body of the response (Cache, Compress, Transform, Include, RewriteHTML, Preload,
+// BufferedTimeout, Retry, Record and Fallback) pass upgrade requests to the next handlers unchanged, so that
+// they can hijack the connection.
+func IsUpgrade(req *http.Request) bool {
+	if req.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade is the context type of a request that asks for a protocol upgrade, see DetectUpgrade
+type Upgrade struct {
+	// Protocol is the value of the Upgrade header of the request, e.g. "websocket"
+	Protocol string
+
+	// Upgraded is true, if the connection has been hijacked (via DetectUpgrade or the Hijack helper)
+	Upgraded bool
+}
+
+// DetectUpgrade is a ContextWrapper for upgrade requests (see IsUpgrade). It passes the next handlers
+// a ResponseWriter whose Hijack method hijacks the ResponseWriter of the server (see ReclaimResponseWriter),
+// even if wrappers in between do not implement http.Hijacker. If the ResponseWriter is a Contexter,
+// an Upgrade is stored in the context, so that e.g. logging middleware can see whether the request
+// has been upgraded after the next handlers returned. Other requests are passed through unchanged.
+type DetectUpgrade struct{}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = DetectUpgrade{}
+
+// ValidateContext makes sure that ctx supports the Upgrade type
+func (DetectUpgrade) ValidateContext(ctx Contexter) {
+	var u Upgrade
+	ctx.SetContext(&u)
+	ctx.Context(&u)
+}
+
+// markUpgraded sets Upgraded of the Upgrade in the context of rw, if there is one
+func markUpgraded(rw http.ResponseWriter) {
+	var u Upgrade
+	if found, err := TryContext(rw, &u); found && err == nil {
+		u.Upgraded = true
+		TrySetContext(rw, &u)
+	}
+}
+
+// upgradeWriter is the ResponseWriter passed to the next handlers by DetectUpgrade
+type upgradeWriter struct {
+	http.ResponseWriter
+}
+
+// make sure to fulfill the Contexter and http.Hijacker interfaces
+var (
+	_ Contexter     = &upgradeWriter{}
+	_ http.Hijacker = &upgradeWriter{}
+)
+
+// Context gets the context of the underlying response writer. If the underlying response writer
+// is no Contexter, only *http.ResponseWriter is supported.
+func (u *upgradeWriter) Context(ctxPtr interface{}) bool {
+	return contextOf(u.ResponseWriter, ctxPtr)
+}
+
+// SetContext sets the Context of the underlying response writer. It panics if the underlying response writer
+// does no implement Contexter
+func (u *upgradeWriter) SetContext(ctxPtr interface{}) {
+	setContextOf(u.ResponseWriter, ctxPtr)
+}
+
+// Hijack hijacks the connection of the ResponseWriter of the server
+func (u *upgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err, ok := Hijack(u.ResponseWriter)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not a http.Hijacker", ReclaimResponseWriter(u.ResponseWriter))
+	}
+	return conn, brw, err
+}
+
+// Flush flushes the underlying ResponseWriter
+func (u *upgradeWriter) Flush() {
+	flushInner(u.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter
+func (u *upgradeWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+// Wrap implements the Wrapper interface
+func (DetectUpgrade) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		if !IsUpgrade(req) {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		if ctx, ok := rw.(Contexter); ok {
+			u := Upgrade{Protocol: req.Header.Get("Upgrade")}
+			ctx.SetContext(&u)
+		}
+		next.ServeHTTP(&upgradeWriter{rw}, req)
+	}
+	return f
+}
@@ -0,0 +1,122 @@
+package wrap
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type upgradeContext struct {
+	http.ResponseWriter
+	upgrade *Upgrade
+}
+
+func (c *upgradeContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *Upgrade:
+		if c.upgrade == nil {
+			return false
+		}
+		*ty = *c.upgrade
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *upgradeContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *Upgrade:
+		u := *ty
+		c.upgrade = &u
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that can be hijacked
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func newUpgradeRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Accept-Encoding", "gzip")
+	return req
+}
+
+func TestIsUpgrade(t *testing.T) {
+	if !IsUpgrade(newUpgradeRequest()) {
+		t.Errorf("request should be an upgrade request")
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Upgrade", "websocket")
+	if IsUpgrade(req) {
+		t.Errorf("request without Connection: upgrade should be no upgrade request")
+	}
+}
+
+func TestDetectUpgrade(t *testing.T) {
+	ValidateWrapperContexts(&upgradeContext{}, DetectUpgrade{})
+	ctx := &upgradeContext{}
+	hijack := func(rw http.ResponseWriter, req *http.Request) {
+		hj, ok := rw.(http.Hijacker)
+		if !ok {
+			t.Fatalf("%T should be a http.Hijacker", rw)
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	h := New(withUpgradeContext(ctx), DetectUpgrade{}, Compress{}, &Cache{}, Transform{}, HandlerFunc(hijack))
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, newUpgradeRequest())
+	if !rec.hijacked {
+		t.Errorf("the connection should be hijacked")
+	}
+	if rec.Header().Get("Vary") != "" {
+		t.Errorf("Compress should step aside for upgrade requests")
+	}
+	if ctx.upgrade == nil || ctx.upgrade.Protocol != "websocket" || !ctx.upgrade.Upgraded {
+		t.Errorf("unexpected Upgrade in context: %+v", ctx.upgrade)
+	}
+}
+
+// withUpgradeContext serves the next handlers with the given context wrapping the ResponseWriter
+func withUpgradeContext(ctx *upgradeContext) Wrapper {
+	var nf NextHandlerFunc
+	nf = func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+		ctx.ResponseWriter = rw
+		next.ServeHTTP(ctx, req)
+	}
+	return nf
+}
+
+func TestHijackMarksUpgraded(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	ctx := &upgradeContext{ResponseWriter: rec, upgrade: &Upgrade{Protocol: "h2c"}}
+	conn, _, err, ok := Hijack(ctx)
+	if !ok || err != nil {
+		t.Fatalf("Hijack failed: %v, %v", ok, err)
+	}
+	conn.Close()
+	if !ctx.upgrade.Upgraded {
+		t.Errorf("Upgrade should be marked as Upgraded")
+	}
+}
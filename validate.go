@@ -0,0 +1,124 @@
+package wrap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationError reports the failures of the ValidateContext method of a ContextWrapper
+type ValidationError struct {
+	// Index is the position of the wrapper in the list given to ValidateWrapperContextsAll
+	Index int
+
+	// Wrapper is the failing ContextWrapper
+	Wrapper Wrapper
+
+	// Errs are the failures. Unsupported context types are reported as *ErrUnsupportedContextGetter
+	// and *ErrUnsupportedContextSetter, other panics as errors.
+	Errs []error
+}
+
+// Error returns the error message
+func (v *ValidationError) Error() string {
+	msgs := make([]string, len(v.Errs))
+	for i, err := range v.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d %T: %s", v.Index, v.Wrapper, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the failures
+func (v *ValidationError) Unwrap() []error {
+	return v.Errs
+}
+
+// ValidationErrors is the error returned by ValidateWrapperContextsAll
+type ValidationErrors []*ValidationError
+
+// Error returns the error messages of all failing wrappers, one per line
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, err := range v {
+		msgs[i] = err.Error()
+	}
+	return "invalid wrapper contexts:\n" + strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the failing wrappers
+func (v ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, err := range v {
+		errs[i] = err
+	}
+	return errs
+}
+
+// validatingContexter passes the calls to the wrapped Contexter and collects the
+// unsupported context types instead of panicking
+type validatingContexter struct {
+	Contexter
+	errs []error
+}
+
+// catch records a panic with an unsupported context error and repanics everything else
+func (v *validatingContexter) catch() {
+	if p := recover(); p != nil {
+		if err, ok := p.(error); ok && errors.Is(err, ErrUnsupportedContext) {
+			v.errs = append(v.errs, err)
+			return
+		}
+		panic(p)
+	}
+}
+
+// Context returns false for unsupported types
+func (v *validatingContexter) Context(ctxPtr interface{}) bool {
+	defer v.catch()
+	return v.Contexter.Context(ctxPtr)
+}
+
+// SetContext ignores unsupported types
+func (v *validatingContexter) SetContext(ctxPtr interface{}) {
+	defer v.catch()
+	v.Contexter.SetContext(ctxPtr)
+}
+
+// validate runs the ValidateContext method of cw and returns all failures
+func validate(ctx Contexter, cw ContextWrapper) []error {
+	v := &validatingContexter{Contexter: ctx}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if err, ok := p.(error); ok {
+					v.errs = append(v.errs, err)
+					return
+				}
+				v.errs = append(v.errs, fmt.Errorf("%v", p))
+			}
+		}()
+		cw.ValidateContext(v)
+	}()
+	return v.errs
+}
+
+// ValidateWrapperContextsAll is like ValidateWrapperContexts, but instead of panicking at the first
+// failure it runs the ValidateContext method of every ContextWrapper and returns a ValidationErrors
+// naming each failing wrapper and each unsupported context type. It returns nil if all wrappers are
+// valid.
+func ValidateWrapperContextsAll(ctx Contexter, wrapper ...Wrapper) error {
+	var errs ValidationErrors
+	for i, wr := range wrapper {
+		cw, ok := wr.(ContextWrapper)
+		if !ok {
+			continue
+		}
+		if failures := validate(ctx, cw); len(failures) > 0 {
+			errs = append(errs, &ValidationError{Index: i, Wrapper: wr, Errs: failures})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
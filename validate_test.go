@@ -0,0 +1,59 @@
+package wrap
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type validatePanic struct{}
+
+func (validatePanic) Wrap(next http.Handler) http.Handler { return next }
+
+func (validatePanic) ValidateContext(Contexter) { panic("broken") }
+
+func TestValidateWrapperContextsAll(t *testing.T) {
+	if err := ValidateWrapperContextsAll(&context{}, setUserIP{}, handleError{}, app{}, write("a")); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := ValidateWrapperContextsAll(&requestIDContext{}, setUserIP{}, write("a"), app{}, SetRequestID{}, validatePanic{})
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+
+	if len(verrs) != 3 {
+		t.Fatalf("expected 3 failing wrappers, got %d: %v", len(verrs), err)
+	}
+
+	if verrs[0].Index != 0 || len(verrs[0].Errs) != 2 {
+		t.Errorf("setUserIP should fail for userIP and error, got %v", verrs[0])
+	}
+
+	if verrs[1].Index != 2 || len(verrs[1].Errs) != 1 {
+		t.Errorf("app should fail for userIP, got %v", verrs[1])
+	}
+
+	if verrs[2].Index != 4 || verrs[2].Errs[0].Error() != "broken" {
+		t.Errorf("validatePanic should fail with broken, got %v", verrs[2])
+	}
+
+	if !errors.Is(err, ErrUnsupportedContext) {
+		t.Errorf("errors.Is should find ErrUnsupportedContext")
+	}
+
+	var setter *ErrUnsupportedContextSetter
+	if !errors.As(err, &setter) {
+		t.Errorf("errors.As should find *ErrUnsupportedContextSetter")
+	}
+
+	msg := err.Error()
+	for _, exp := range []string{"0 wrap.setUserIP: setting the context type *wrap.userIP", "*error", "2 wrap.app: getting the context type *wrap.userIP", "4 wrap.validatePanic: broken"} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("error message should contain %#v, but is:\n%s", exp, msg)
+		}
+	}
+}
@@ -27,8 +27,21 @@ type Wrapper interface {
 //
 // If DEBUG is set, each handler is wrapped with a Debug struct that calls DEBUGGER.Debug before
 // running the handler.
+//
+// If a Terminator that terminates is followed by other wrappers, New calls WarnUnreachable.
+// If STRICT is set, New panics with *ErrDuplicateContexter if more than one of the wrappers injects a Contexter
+// and with *ErrUnreachableWrapper instead of calling WarnUnreachable.
+//
+// If one of the wrappers is WithStrict(), New panics with the ProbeErrors of Probe, if there are any.
 func New(wrapper ...Wrapper) (h http.Handler) {
-	if DEBUG {
+	wrapper = applyStrict(wrapper)
+	if IsStrict() {
+		if err := findDuplicateContexter(wrapper); err != nil {
+			panic(err)
+		}
+	}
+	checkUnreachable(wrapper)
+	if IsDebug() {
 		return _debug(wrapper...)
 	}
 	h = NoOp
@@ -187,6 +200,8 @@ type ContextWrapper interface {
 // interface and is passed to this function, then any missing support for a context type
 // needed by a Wrapper would be uncovered. If then this function is called early it
 // would save many headaches.
+// ValidateWrapperContexts panics at the first failure, see ValidateWrapperContextsAll for a variant
+// that reports all of them.
 func ValidateWrapperContexts(ctx Contexter, wrapper ...Wrapper) {
 	for _, wr := range wrapper {
 		val, ok := wr.(ContextWrapper)
@@ -203,10 +218,14 @@ func ValidateWrapperContexts(ctx Contexter, wrapper ...Wrapper) {
 // and every middleware may type assert the ResponseWriter to a Contexter in order to get and
 // set context.
 // Stack panics if inject is not valid.
-// Stack should only be called once per application and must not be embedded into other stacks
+// Stack should only be called once per application and must not be embedded into other stacks.
+// If STRICT is set, Stack (like New) probes the wrappers with a synthetic request (see IsProbe) and
+// panics with *ErrDuplicateContexter if another wrapper injects a Contexter.
+// Like New, Stack calls WarnUnreachable if a Terminator that terminates is followed by other wrappers.
+// If one of the wrappers is WithStrict(), Stack panics with the ProbeErrors of Probe, if there are any.
 func Stack(inject ContextInjecter, wrapper ...Wrapper) (h http.Handler) {
 	ValidateContextInjecter(inject)
 	st := []Wrapper{inject}
-	st = append(st, wrapper...)
+	st = applyStrict(append(st, wrapper...))
 	return New(st...)
 }
@@ -0,0 +1,78 @@
+package wrapctx
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/go-on/wrap"
+)
+
+// Contexter is a Contexter supporting all registered context types. Its zero value is a ContextInjecter
+// that injects a new Contexter for each request:
+//
+//	wrap.Stack(&wrapctx.Contexter{}, wrappers...)
+//
+// For types that are not registered Context and SetContext panic with *wrap.ErrUnsupportedContextGetter and
+// *wrap.ErrUnsupportedContextSetter. The values are kept in a map. For a faster Contexter with a field per
+// context type, see Generate.
+type Contexter struct {
+	http.ResponseWriter
+
+	mx     sync.Mutex
+	values map[reflect.Type]reflect.Value
+}
+
+// make sure to fulfill the ContextInjecter interface
+var _ wrap.ContextInjecter = &Contexter{}
+
+// Context lets ctxPtr point to the saved context of the same type and returns if there is one.
+// *http.ResponseWriter is set to the underlying ResponseWriter.
+func (c *Contexter) Context(ctxPtr interface{}) bool {
+	if rw, ok := ctxPtr.(*http.ResponseWriter); ok {
+		*rw = c.ResponseWriter
+		return true
+	}
+	t := reflect.TypeOf(ctxPtr)
+	if t == nil || t.Kind() != reflect.Ptr || !isRegistered(t.Elem()) {
+		panic(&wrap.ErrUnsupportedContextGetter{Type: ctxPtr})
+	}
+	c.mx.Lock()
+	v, has := c.values[t]
+	c.mx.Unlock()
+	if !has {
+		return false
+	}
+	reflect.ValueOf(ctxPtr).Elem().Set(v)
+	return true
+}
+
+// SetContext saves the value ctxPtr points to
+func (c *Contexter) SetContext(ctxPtr interface{}) {
+	t := reflect.TypeOf(ctxPtr)
+	if t == nil || t.Kind() != reflect.Ptr || !isRegistered(t.Elem()) {
+		panic(&wrap.ErrUnsupportedContextSetter{Type: ctxPtr})
+	}
+	v := reflect.New(t.Elem()).Elem()
+	v.Set(reflect.ValueOf(ctxPtr).Elem())
+	c.mx.Lock()
+	if c.values == nil {
+		c.values = map[reflect.Type]reflect.Value{}
+	}
+	c.values[t] = v
+	c.mx.Unlock()
+}
+
+// Flush flushes the underlying ResponseWriter if it is a http.Flusher
+func (c *Contexter) Flush() {
+	wrap.Flush(c.ResponseWriter)
+}
+
+// Wrap implements the wrap.Wrapper interface by injecting a new Contexter into the stack
+func (c *Contexter) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&Contexter{ResponseWriter: rw}, req)
+	}
+	return f
+}
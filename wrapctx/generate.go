@@ -0,0 +1,272 @@
+package wrapctx
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// imports assigns the names of the imported packages of the generated source
+type imports map[string]string
+
+// name returns the name under which the package with the given path is imported
+func (im imports) name(pkgPath string) string {
+	if n, has := im[pkgPath]; has {
+		return n
+	}
+	base := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, path.Base(pkgPath))
+	n := base
+	for i := 2; im.taken(n); i++ {
+		n = fmt.Sprintf("%s%d", base, i)
+	}
+	im[pkgPath] = n
+	return n
+}
+
+// taken returns if the name is already used by an import or reserved
+func (im imports) taken(n string) bool {
+	if n == "http" || n == "wrap" || token.IsKeyword(n) {
+		return true
+	}
+	for _, other := range im {
+		if other == n {
+			return true
+		}
+	}
+	return false
+}
+
+// typeExpr returns the Go expression of the type, importing the packages of named types
+func (im imports) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if strings.ContainsRune(t.Name(), '[') {
+			return "", fmt.Errorf("instantiated generic type %s is not supported", t)
+		}
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if t.PkgPath() == "net/http" {
+			return "http." + t.Name(), nil
+		}
+		if t.PkgPath() == "github.com/go-on/wrap" {
+			return "wrap." + t.Name(), nil
+		}
+		return im.name(t.PkgPath()) + "." + t.Name(), nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		elem, err := im.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		case reflect.Array:
+			return fmt.Sprintf("[%d]%s", t.Len(), elem), nil
+		}
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, nil
+		case reflect.SendDir:
+			return "chan<- " + elem, nil
+		}
+		return "chan " + elem, nil
+	case reflect.Map:
+		key, err := im.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := im.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + elem, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+	return "", fmt.Errorf("unnamed type %s is not supported", t)
+}
+
+// fieldName returns the name of the field for the context type registered under the given name,
+// e.g. request-id => requestID
+func fieldName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	var bf strings.Builder
+	for i, p := range parts {
+		r := []rune(p)
+		if i == 0 {
+			r[0] = unicode.ToLower(r[0])
+		} else if up := strings.ToUpper(p); up == "ID" || up == "URL" || up == "IP" || up == "HTTP" {
+			r = []rune(up)
+		} else {
+			r[0] = unicode.ToUpper(r[0])
+		}
+		bf.WriteString(string(r))
+	}
+	f := bf.String()
+	if f == "" || !unicode.IsLetter([]rune(f)[0]) || token.IsKeyword(f) {
+		r := []rune(f)
+		if len(r) > 0 {
+			r[0] = unicode.ToUpper(r[0])
+		}
+		f = "ctx" + string(r)
+	}
+	return f
+}
+
+// field is a field of the generated Contexter
+type field struct {
+	Name, Type string
+}
+
+var contexterTemplate = template.Must(template.New("contexter").Parse(`// Code generated by wrapctx.Generate. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"net/http"
+
+	"github.com/go-on/wrap"
+{{range $path, $name := .Imports}}	{{$name}} "{{$path}}"
+{{end}})
+
+// {{.Name}} is a Contexter supporting the context types registered with wrapctx.
+// Its Wrap method injects a new {{.Name}} for each request.
+type {{.Name}} struct {
+	http.ResponseWriter
+{{range .Fields}}	{{.Name}}    {{.Type}}
+	{{.Name}}Set bool
+{{end}}}
+
+// make sure to fulfill the ContextInjecter interface
+var _ wrap.ContextInjecter = &{{.Name}}{}
+
+// Context lets ctxPtr point to the saved context of the same type and returns if there is one
+func (c *{{.Name}}) Context(ctxPtr interface{}) bool {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+{{range .Fields}}	case *{{.Type}}:
+		if !c.{{.Name}}Set {
+			return false
+		}
+		*ty = c.{{.Name}}
+{{end}}	default:
+		panic(&wrap.ErrUnsupportedContextGetter{Type: ctxPtr})
+	}
+	return true
+}
+
+// SetContext saves the value ctxPtr points to
+func (c *{{.Name}}) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+{{range .Fields}}	case *{{.Type}}:
+		c.{{.Name}} = *ty
+		c.{{.Name}}Set = true
+{{end}}	default:
+		panic(&wrap.ErrUnsupportedContextSetter{Type: ctxPtr})
+	}
+}
+
+// Flush flushes the underlying ResponseWriter if it is a http.Flusher
+func (c *{{.Name}}) Flush() {
+	wrap.Flush(c.ResponseWriter)
+}
+
+// Wrap implements the wrap.Wrapper interface by injecting a new {{.Name}} into the stack
+func (c *{{.Name}}) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&{{.Name}}{ResponseWriter: rw}, req)
+	}
+	return f
+}
+`))
+
+// generate writes the source of a Contexter supporting the given context types
+func generate(w io.Writer, pkg, name string, entries []Entry) error {
+	im := imports{}
+	var fields []field
+	names := map[string]string{}
+	for _, e := range entries {
+		t, err := im.typeExpr(e.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.Name, err)
+		}
+		f := field{Name: fieldName(e.Name), Type: t}
+		for _, n := range []string{f.Name, f.Name + "Set"} {
+			if other, has := names[n]; has {
+				return fmt.Errorf("%s and %s have the same field name %s", other, e.Name, n)
+			}
+			names[n] = e.Name
+		}
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(a, b int) bool { return fields[a].Name < fields[b].Name })
+
+	var bf bytes.Buffer
+	err := contexterTemplate.Execute(&bf, map[string]interface{}{
+		"Package": pkg,
+		"Name":    name,
+		"Imports": im,
+		"Fields":  fields,
+	})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(bf.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated code does not compile: %v\n%s", err, bf.String())
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// Generate writes the source of a Contexter type with the given name inside the given package to w.
+// The Contexter has a field per registered context type and supports exactly the registered types.
+// Generate is meant to be called by a small program that imports the package registering the types,
+// e.g. via go:generate:
+//
+//	//go:generate go run ./gen
+//
+//	// gen/main.go
+//	package main
+//
+//	import (
+//		"os"
+//
+//		"github.com/go-on/wrap/wrapctx"
+//		_ "example.com/app/ctxkeys"
+//	)
+//
+//	func main() {
+//		f, _ := os.Create("context_gen.go")
+//		defer f.Close()
+//		if err := wrapctx.Generate(f, "app", "Context"); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// Types must be named types or pointers, slices, arrays, maps and channels of them. Types of internal
+// packages and unexported types can't be imported by the generated code, which then does not compile.
+func Generate(w io.Writer, pkg, name string) error {
+	return generate(w, pkg, name, Registered())
+}
@@ -0,0 +1,143 @@
+// Package wrapctx is a central registry of context types for Contexters. Each context type is registered
+// once under a name, which returns a typed Key:
+//
+//	package ctxkeys
+//
+//	var (
+//		User      = wrapctx.Register[auth.User]("user")
+//		RequestID = wrapctx.Register[wrap.RequestID]("request-id")
+//	)
+//
+// Middleware gets and sets the context values via the keys, so a typo or a type that was not registered
+// is a compile error rather than an ErrUnsupportedContext panic at runtime:
+//
+//	u, found := ctxkeys.User.Get(rw)
+//	ctxkeys.User.Set(rw, u)
+//
+// Keys work with every Contexter that supports their type. The Contexter type of this package supports all
+// registered types dynamically and Generate writes the source of a static Contexter for them, so that the
+// registry is the single source of truth for the context types of an application.
+package wrapctx
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-on/wrap"
+)
+
+// Entry is a registered context type
+type Entry struct {
+	Name string
+	Type reflect.Type
+}
+
+var registry = struct {
+	sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}{byName: map[string]reflect.Type{}, byType: map[reflect.Type]string{}}
+
+// Key is the typed accessor of a registered context type T
+type Key[T any] struct {
+	name string
+}
+
+// Register registers the context type T under the given name and returns its Key.
+// It panics if the name is empty, the name or the type is already registered or T is http.ResponseWriter,
+// which every Contexter supports anyway. Register is meant to be called in var declarations of a central package.
+func Register[T any](name string) Key[T] {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if name == "" {
+		panic(fmt.Sprintf("wrapctx: empty name for %s", t))
+	}
+	if t == reflect.TypeOf((*http.ResponseWriter)(nil)).Elem() {
+		panic("wrapctx: http.ResponseWriter can't be registered")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if other, has := registry.byName[name]; has {
+		panic(fmt.Sprintf("wrapctx: name %q is already registered for %s", name, other))
+	}
+	if other, has := registry.byType[t]; has {
+		panic(fmt.Sprintf("wrapctx: %s is already registered as %q", t, other))
+	}
+	registry.byName[name] = t
+	registry.byType[t] = name
+	return Key[T]{name}
+}
+
+// Registered returns the registered context types, sorted by name
+func Registered() []Entry {
+	registry.RLock()
+	defer registry.RUnlock()
+	entries := make([]Entry, 0, len(registry.byName))
+	for name, t := range registry.byName {
+		entries = append(entries, Entry{name, t})
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Name < entries[b].Name })
+	return entries
+}
+
+// isRegistered returns if the type is registered
+func isRegistered(t reflect.Type) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	_, has := registry.byType[t]
+	return has
+}
+
+// Name returns the name the type is registered under
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Type returns the registered type
+func (k Key[T]) Type() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// String returns the name and the type
+func (k Key[T]) String() string {
+	return fmt.Sprintf("%s (%s)", k.name, k.Type())
+}
+
+// Get returns the value of the context type inside the Contexter rw and if it has been found.
+// It panics if rw is no Contexter or does not support the type, like Contexter.Context does.
+func (k Key[T]) Get(rw http.ResponseWriter) (v T, found bool) {
+	found, err := wrap.TryContext(rw, &v)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+// TryGet is like Get but returns the error instead of panicking (see wrap.TryContext)
+func (k Key[T]) TryGet(rw http.ResponseWriter) (v T, found bool, err error) {
+	found, err = wrap.TryContext(rw, &v)
+	return
+}
+
+// Set sets the value of the context type inside the Contexter rw.
+// It panics if rw is no Contexter or does not support the type, like Contexter.SetContext does.
+func (k Key[T]) Set(rw http.ResponseWriter, v T) {
+	if err := wrap.TrySetContext(rw, &v); err != nil {
+		panic(err)
+	}
+}
+
+// TrySet is like Set but returns the error instead of panicking (see wrap.TrySetContext)
+func (k Key[T]) TrySet(rw http.ResponseWriter, v T) error {
+	return wrap.TrySetContext(rw, &v)
+}
+
+// Validate gets and sets the context type via ctx and therefore panics if ctx does not support it.
+// It is meant to be called inside the ValidateContext methods of ContextWrappers using the key.
+func (k Key[T]) Validate(ctx wrap.Contexter) {
+	var v T
+	ctx.SetContext(&v)
+	ctx.Context(&v)
+}
@@ -0,0 +1,186 @@
+package wrapctx
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-on/wrap"
+)
+
+type user string
+
+var (
+	userKey  = Register[user]("user")
+	startKey = Register[time.Time]("start-time")
+)
+
+// setUser sets the user and the start time
+type setUser string
+
+func (s setUser) ValidateContext(ctx wrap.Contexter) {
+	userKey.Validate(ctx)
+	startKey.Validate(ctx)
+}
+
+func (s setUser) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		userKey.Set(rw, user(s))
+		startKey.Set(rw, time.Unix(0, 0))
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func mustPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s should panic", name)
+		}
+	}()
+	fn()
+}
+
+func TestRegister(t *testing.T) {
+	mustPanic(t, "empty name", func() { Register[int]("") })
+	mustPanic(t, "duplicate name", func() { Register[int]("user") })
+	mustPanic(t, "duplicate type", func() { Register[user]("user2") })
+	mustPanic(t, "http.ResponseWriter", func() { Register[http.ResponseWriter]("rw") })
+
+	if userKey.Name() != "user" || userKey.Type() != reflect.TypeOf(user("")) {
+		t.Errorf("unexpected key %s", userKey)
+	}
+
+	var found bool
+	for _, e := range Registered() {
+		if e.Name == "start-time" && e.Type == reflect.TypeOf(time.Time{}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("start-time is not registered: %v", Registered())
+	}
+}
+
+func TestContexter(t *testing.T) {
+	wrap.ValidateContextInjecter(&Contexter{})
+	if err := wrap.ValidateWrapperContextsAll(&Contexter{}, setUser("")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var got user
+	var found bool
+	h := wrap.Stack(&Contexter{},
+		wrap.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, found = userKey.Get(rw)
+		}),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if found {
+		t.Errorf("user should not be found before it is set")
+	}
+
+	h = wrap.Stack(&Contexter{},
+		setUser("alice"),
+		wrap.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			got, found = userKey.Get(rw)
+		}),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !found || got != "alice" {
+		t.Errorf("expected user alice, got %q (found: %v)", got, found)
+	}
+
+	if _, _, err := userKey.TryGet(httptest.NewRecorder()); !errors.Is(err, wrap.ErrNoContexter) {
+		t.Errorf("expected ErrNoContexter, got %v", err)
+	}
+	if err := userKey.TrySet(httptest.NewRecorder(), "bob"); !errors.Is(err, wrap.ErrNoContexter) {
+		t.Errorf("expected ErrNoContexter, got %v", err)
+	}
+	mustPanic(t, "Get without Contexter", func() { userKey.Get(httptest.NewRecorder()) })
+
+	var unregistered int
+	if _, err := wrap.TryContext(&Contexter{}, &unregistered); !errors.Is(err, wrap.ErrUnsupportedContext) {
+		t.Errorf("expected ErrUnsupportedContext, got %v", err)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	for name, exp := range map[string]string{
+		"user":       "user",
+		"request-id": "requestID",
+		"Start Time": "startTime",
+		"type":       "ctxType",
+		"1st":        "ctx1st",
+	} {
+		if got := fieldName(name); got != exp {
+			t.Errorf("fieldName(%q) = %q, expected %q", name, got, exp)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	entries := []Entry{
+		{"start-time", reflect.TypeOf(time.Time{})},
+		{"target", reflect.TypeOf(&url.URL{})},
+		{"ips", reflect.TypeOf([]net.IP{})},
+		{"counts", reflect.TypeOf(map[string]int{})},
+		{"events", reflect.TypeOf(make(<-chan error))},
+		{"request-id", reflect.TypeOf(wrap.RequestID(""))},
+		{"cookie", reflect.TypeOf(http.Cookie{})},
+	}
+	var bf bytes.Buffer
+	if err := generate(&bf, "app", "Context", entries); err != nil {
+		t.Fatal(err)
+	}
+	src := bf.String()
+	for _, exp := range []string{
+		"type Context struct {",
+		"case *time.Time:",
+		"case *[]net.IP:",
+		"case *<-chan error:",
+		"case *wrap.RequestID:",
+		"case *http.Cookie:",
+		"c.requestID = *ty",
+	} {
+		if !strings.Contains(src, exp) {
+			t.Errorf("expected generated code to contain %q\n%s", exp, src)
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "context_gen.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("app", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code does not type check: %v\n%s", err, src)
+	}
+
+	for _, e := range []Entry{
+		{"anon", reflect.TypeOf(struct{}{})},
+		{"fn", reflect.TypeOf(func() {})},
+	} {
+		if err := generate(&bytes.Buffer{}, "app", "Context", []Entry{e}); err == nil {
+			t.Errorf("expected error for %s", e.Type)
+		}
+	}
+	if err := generate(&bytes.Buffer{}, "app", "Context", []Entry{entries[0], {"start time", reflect.TypeOf(0)}}); err == nil {
+		t.Errorf("expected error for same field names")
+	}
+}
@@ -0,0 +1,62 @@
+package wrap
+
+import (
+	"net/http"
+)
+
+// Pattern is the context type of the http.ServeMux pattern that matches the request (see WrapMux)
+type Pattern string
+
+// PatternOf returns the pattern of the http.ServeMux wrapped by WrapMux that matches the request,
+// "" if there is none
+func PatternOf(req *http.Request) string {
+	p, _ := requestValue(req, patternKey).(string)
+	return p
+}
+
+// WrapMux returns a http.Handler that runs the given wrappers before serving the request with mux,
+// i.e. before every handler registered on mux.
+//
+// The pattern of mux that matches the request is determined before the wrappers run, so that logging
+// and metrics middleware may label by route instead of the raw path: it is set as Pattern field of
+// the request and may be retrieved via PatternOf. Add StorePattern to the wrappers (after the
+// Contexter) to store it as Pattern context type.
+func WrapMux(mux *http.ServeMux, wrapper ...Wrapper) http.Handler {
+	st := make([]Wrapper, 0, len(wrapper)+1)
+	st = append(st, wrapper...)
+	st = append(st, Handler(mux))
+	h := New(st...)
+
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		_, pattern := mux.Handler(req)
+		req = withRequestValue(req, patternKey, pattern)
+		req.Pattern = pattern
+		h.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// StorePattern is a ContextWrapper that stores the pattern determined by WrapMux as Pattern context type
+type StorePattern struct{}
+
+// make sure to fulfill the ContextWrapper interface
+var _ ContextWrapper = StorePattern{}
+
+// ValidateContext makes sure that ctx supports the Pattern type
+func (StorePattern) ValidateContext(ctx Contexter) {
+	var p Pattern
+	ctx.SetContext(&p)
+	ctx.Context(&p)
+}
+
+// Wrap implements the Wrapper interface
+func (StorePattern) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		p := Pattern(PatternOf(req))
+		rw.(Contexter).SetContext(&p)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
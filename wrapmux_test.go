@@ -0,0 +1,74 @@
+package wrap
+
+import (
+	"net/http"
+	"testing"
+)
+
+type patternContext struct {
+	http.ResponseWriter
+	pattern Pattern
+}
+
+func (c *patternContext) Context(ctxPtr interface{}) (found bool) {
+	switch ty := ctxPtr.(type) {
+	case *http.ResponseWriter:
+		*ty = c.ResponseWriter
+	case *Pattern:
+		*ty = c.pattern
+	default:
+		panic(&ErrUnsupportedContextGetter{ctxPtr})
+	}
+	return true
+}
+
+func (c *patternContext) SetContext(ctxPtr interface{}) {
+	switch ty := ctxPtr.(type) {
+	case *Pattern:
+		c.pattern = *ty
+	default:
+		panic(&ErrUnsupportedContextSetter{ctxPtr})
+	}
+}
+
+func (c patternContext) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&patternContext{ResponseWriter: rw}, req)
+	}
+	return f
+}
+
+func TestWrapMux(t *testing.T) {
+	ValidateWrapperContexts(&patternContext{}, StorePattern{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(rw http.ResponseWriter, req *http.Request) {
+		var p Pattern
+		rw.(Contexter).Context(&p)
+		rw.Write([]byte(" " + string(p) + " " + req.PathValue("id")))
+	})
+
+	var labels []string
+	h := WrapMux(mux,
+		&patternContext{},
+		StorePattern{},
+		NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+			labels = append(labels, PatternOf(req)+"|"+req.Pattern)
+			rw.Write([]byte("route"))
+			next.ServeHTTP(rw, req)
+		}),
+	)
+
+	rec, req := newTestRequest("GET", "/items/3")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "route GET /items/{id} 3", 200)
+
+	rec, req = newTestRequest("GET", "/nothing")
+	h.ServeHTTP(rec, req)
+	assertResponse(t, rec, "route404 page not found", 200)
+
+	if len(labels) != 2 || labels[0] != "GET /items/{id}|GET /items/{id}" || labels[1] != "|" {
+		t.Errorf("unexpected labels %#v", labels)
+	}
+}
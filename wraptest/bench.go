@@ -0,0 +1,59 @@
+package wraptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// discardWriter is a ResponseWriter that discards everything, so that benchmarks measure the handlers only
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+// benchmarkHandler serves b.N requests with h, returning the nanoseconds per request
+func benchmarkHandler(b *testing.B, h http.Handler, req *http.Request) float64 {
+	rw := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(rw, req)
+	}
+	b.StopTimer()
+	return float64(b.Elapsed().Nanoseconds()) / float64(b.N)
+}
+
+// BenchStack measures the overhead of a middleware stack compared to an equivalent, hand-flattened
+// handler, like the benchmarks of the README do for the stacks of the wrap package:
+//
+//	func BenchmarkApp(b *testing.B) {
+//		wraptest.BenchStack(b, app.Stack(), http.HandlerFunc(app.Flattened))
+//	}
+//
+// It runs the sub-benchmarks "flattened" and "stack", serving GET / with a ResponseWriter that discards
+// everything. The "stack" benchmark reports the ratio of its ns/op to the ns/op of the flattened handler
+// as the metric "x", e.g. 1.05 x. The ratio is also returned.
+func BenchStack(b *testing.B, stack, equivalent http.Handler) (ratio float64) {
+	return BenchStackRequest(b, stack, equivalent, httptest.NewRequest("GET", "/", nil))
+}
+
+// BenchStackRequest is like BenchStack but serves the given request
+func BenchStackRequest(b *testing.B, stack, equivalent http.Handler, req *http.Request) (ratio float64) {
+	var flat float64
+	b.Run("flattened", func(b *testing.B) {
+		flat = benchmarkHandler(b, equivalent, req)
+		b.ReportMetric(1, "x")
+	})
+	b.Run("stack", func(b *testing.B) {
+		ns := benchmarkHandler(b, stack, req)
+		if flat > 0 {
+			ratio = ns / flat
+			b.ReportMetric(ratio, "x")
+		}
+	})
+	return ratio
+}
@@ -0,0 +1,19 @@
+package wraptest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+func BenchmarkBenchStack(b *testing.B) {
+	stack := wrap.New(setHeader{"X-A", "a"}, setHeader{"X-B", "b"}, wrap.Handler(&Next{Body: "ok"}))
+	var flattened http.HandlerFunc = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-A", "a")
+		rw.Header().Set("X-B", "b")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("ok"))
+	}
+	BenchStack(b, stack, flattened)
+}
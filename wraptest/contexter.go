@@ -0,0 +1,137 @@
+package wraptest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-on/wrap"
+)
+
+// probingContexter records the context types that are set and get by ValidateContext methods
+type probingContexter struct {
+	http.ResponseWriter
+	types map[reflect.Type]bool
+}
+
+func (p *probingContexter) record(ctxPtr interface{}) {
+	if t := reflect.TypeOf(ctxPtr); t != nil && t.Kind() == reflect.Ptr {
+		p.types[t] = true
+	}
+}
+
+// Context records the type of ctxPtr
+func (p *probingContexter) Context(ctxPtr interface{}) bool {
+	p.record(ctxPtr)
+	return false
+}
+
+// SetContext records the type of ctxPtr
+func (p *probingContexter) SetContext(ctxPtr interface{}) {
+	p.record(ctxPtr)
+}
+
+// ContexterFor returns a ContextInjecter whose Contexter keeps its contexts in memory and supports
+// exactly the context types the given wrappers require, i.e. the types that are passed to the Contexter
+// by the ValidateContext methods of the wrappers that are ContextWrappers. Additionally *http.ResponseWriter
+// is supported, as required for every Contexter.
+//
+// So middleware can be unit tested without the real context implementation of the application:
+//
+//	w := MyWrapper{}
+//	h := wrap.Stack(wraptest.ContexterFor(w), w, wrap.Handler(next))
+//
+// For other types Context and SetContext panic with *wrap.ErrUnsupportedContextGetter and
+// *wrap.ErrUnsupportedContextSetter, like a Contexter of an application should do.
+func ContexterFor(wrappers ...wrap.Wrapper) wrap.ContextInjecter {
+	p := &probingContexter{types: map[reflect.Type]bool{}}
+	for _, w := range wrappers {
+		if cw, ok := w.(wrap.ContextWrapper); ok {
+			cw.ValidateContext(p)
+		}
+	}
+	delete(p.types, reflect.TypeOf((*http.ResponseWriter)(nil)))
+	return &Contexter{types: p.types}
+}
+
+// Contexter is the in-memory Contexter returned by ContexterFor. Its Wrap method injects a new
+// Contexter for each request.
+type Contexter struct {
+	http.ResponseWriter
+	types map[reflect.Type]bool
+
+	mx     sync.Mutex
+	values map[reflect.Type]reflect.Value
+}
+
+// make sure to fulfill the ContextInjecter interface
+var _ wrap.ContextInjecter = &Contexter{}
+
+// Types returns the names of the supported context types (without *http.ResponseWriter) in alphabetical order
+func (c *Contexter) Types() []string {
+	names := make([]string, 0, len(c.types))
+	for t := range c.types {
+		names = append(names, t.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the supported context types
+func (c *Contexter) String() string {
+	return fmt.Sprintf("wraptest.Contexter[%s]", strings.Join(c.Types(), ", "))
+}
+
+// Context lets ctxPtr point to the saved context of the same type and returns if there is one.
+// *http.ResponseWriter is set to the underlying ResponseWriter.
+func (c *Contexter) Context(ctxPtr interface{}) bool {
+	if rw, ok := ctxPtr.(*http.ResponseWriter); ok {
+		*rw = c.ResponseWriter
+		return true
+	}
+	t := reflect.TypeOf(ctxPtr)
+	if !c.types[t] {
+		panic(&wrap.ErrUnsupportedContextGetter{Type: ctxPtr})
+	}
+	c.mx.Lock()
+	v, has := c.values[t]
+	c.mx.Unlock()
+	if !has {
+		return false
+	}
+	reflect.ValueOf(ctxPtr).Elem().Set(v)
+	return true
+}
+
+// SetContext saves the value ctxPtr points to
+func (c *Contexter) SetContext(ctxPtr interface{}) {
+	t := reflect.TypeOf(ctxPtr)
+	if !c.types[t] {
+		panic(&wrap.ErrUnsupportedContextSetter{Type: ctxPtr})
+	}
+	c.mx.Lock()
+	if c.values == nil {
+		c.values = map[reflect.Type]reflect.Value{}
+	}
+	v := reflect.New(t.Elem()).Elem()
+	v.Set(reflect.ValueOf(ctxPtr).Elem())
+	c.values[t] = v
+	c.mx.Unlock()
+}
+
+// Flush flushes the underlying ResponseWriter if it is a http.Flusher
+func (c *Contexter) Flush() {
+	wrap.Flush(c.ResponseWriter)
+}
+
+// Wrap implements the wrap.Wrapper interface by injecting a new Contexter into the stack
+func (c *Contexter) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(&Contexter{ResponseWriter: rw, types: c.types}, req)
+	}
+	return f
+}
@@ -0,0 +1,58 @@
+package wraptest
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+func TestContexterFor(t *testing.T) {
+	override := wrap.MethodOverride{}
+	ctx := ContexterFor(override, wrap.NormalizeHeaders{}, setHeader{"X-A", "a"})
+
+	if types := ctx.(*Contexter).Types(); !reflect.DeepEqual(types, []string{"*wrap.Forwarded", "*wrap.OriginalMethod"}) {
+		t.Errorf("unexpected types %v", types)
+	}
+
+	if !wrap.ValidateContextInjecter(ctx) {
+		t.Errorf("Contexter should be valid")
+	}
+
+	next := &Next{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var orig wrap.OriginalMethod
+		rw.(wrap.Contexter).Context(&orig)
+		rw.Write([]byte(string(orig) + " as " + req.Method))
+	})}
+
+	New(wrap.Stack(ctx, override, wrap.Handler(next))).Post("/", "text/plain", "").WithHeader(wrap.MethodOverrideHeader, "DELETE").
+		Expect(t).Status(200).Body("POST as DELETE")
+}
+
+func TestContexterUnsupported(t *testing.T) {
+	ctx := ContexterFor(wrap.MethodOverride{})
+	var requestErr error
+
+	New(ctx.Wrap(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var fw wrap.Forwarded
+		_, requestErr = wrap.TryContext(rw, &fw)
+	}))).Get("/").Do()
+
+	if !errors.Is(requestErr, wrap.ErrUnsupportedContext) {
+		t.Errorf("*wrap.Forwarded should not be supported, got %v", requestErr)
+	}
+}
+
+func TestContexterCopiesValues(t *testing.T) {
+	ctx := &Contexter{types: map[reflect.Type]bool{reflect.TypeOf((*wrap.OriginalMethod)(nil)): true}}
+	orig := wrap.OriginalMethod("GET")
+	ctx.SetContext(&orig)
+	orig = "POST"
+
+	var got wrap.OriginalMethod
+	if !ctx.Context(&got) || got != "GET" {
+		t.Errorf("context should be %#v, but is %#v", "GET", got)
+	}
+}
@@ -0,0 +1,156 @@
+package wraptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// contractPanic is the value the next handler panics with in the panic scenario of RunWrapperContract
+type contractPanic string
+
+// contractWriter is the ResponseWriter of the server in RunWrapperContract. It records
+// whether the wrapper writes after the writes of the next handler reached it.
+type contractWriter struct {
+	*httptest.ResponseRecorder
+	inNext      bool
+	nextWrote   bool
+	headers     int
+	afterNext   bool
+	nextReturns bool
+}
+
+func (c *contractWriter) wrote() {
+	if c.inNext {
+		c.nextWrote = true
+		return
+	}
+	if c.nextReturns && c.nextWrote {
+		c.afterNext = true
+	}
+}
+
+// WriteHeader records the call and passes it to the recorder
+func (c *contractWriter) WriteHeader(code int) {
+	c.headers++
+	c.wrote()
+	c.ResponseRecorder.WriteHeader(code)
+}
+
+// Write records the call and passes it to the recorder
+func (c *contractWriter) Write(b []byte) (int, error) {
+	c.wrote()
+	return c.ResponseRecorder.Write(b)
+}
+
+// contractNext is the next handler of the wrapper under test in RunWrapperContract
+type contractNext struct {
+	server *contractWriter
+	calls  int
+	panics bool
+
+	// isContexter is set, if the ResponseWriter passed to the next handler is a Contexter
+	isContexter bool
+}
+
+func (n *contractNext) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	n.calls++
+	_, n.isContexter = rw.(wrap.Contexter)
+	if n.panics {
+		panic(contractPanic("next panicked"))
+	}
+	n.server.inNext = true
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("contract"))
+	n.server.inNext = false
+	n.server.nextReturns = true
+}
+
+// contractRun is a single request of RunWrapperContract
+type contractRun struct {
+	server    *contractWriter
+	next      *contractNext
+	recovered interface{}
+}
+
+// runContract serves the given request with the wrapper w behind a Contexter supporting the types w requires
+func runContract(w wrap.Wrapper, req *http.Request, panics bool) (r *contractRun) {
+	r = &contractRun{server: &contractWriter{ResponseRecorder: httptest.NewRecorder()}}
+	r.next = &contractNext{server: r.server, panics: panics}
+	h := wrap.New(ContexterFor(w), w, wrap.Handler(r.next))
+	defer func() {
+		r.recovered = recover()
+	}()
+	h.ServeHTTP(r.server, req)
+	return
+}
+
+// RunWrapperContract runs subtests checking that the given wrapper behaves as expected from any
+// Wrapper, so that authors of third-party middleware can certify the compatibility of their wrappers.
+// The wrapper is run behind a Contexter supporting the context types it requires (see ContexterFor)
+// and must
+//
+//   - call the next handler once or never
+//   - not write to the ResponseWriter after the writes of the next handler reached it and
+//     not write the status code twice
+//   - pass a Contexter to the next handler
+//   - handle requests with a nil body
+//   - either recover panics of the next handler or let them pass unchanged and serve
+//     further requests afterwards
+func RunWrapperContract(t *testing.T, w wrap.Wrapper) {
+	t.Run("calls next once or never", func(t *testing.T) {
+		r := runContract(w, httptest.NewRequest("GET", "/", nil), false)
+		if r.recovered != nil {
+			t.Fatalf("panicked: %v", r.recovered)
+		}
+		if r.next.calls > 1 {
+			t.Errorf("next handler called %d times", r.next.calls)
+		}
+	})
+
+	t.Run("no writes after next", func(t *testing.T) {
+		r := runContract(w, httptest.NewRequest("GET", "/", nil), false)
+		if r.recovered != nil {
+			t.Fatalf("panicked: %v", r.recovered)
+		}
+		if r.server.afterNext {
+			t.Errorf("wrote to the ResponseWriter after the next handler wrote to it")
+		}
+		if r.server.headers > 1 {
+			t.Errorf("status code written %d times", r.server.headers)
+		}
+	})
+
+	t.Run("preserves Contexter", func(t *testing.T) {
+		r := runContract(w, httptest.NewRequest("GET", "/", nil), false)
+		if r.recovered != nil {
+			t.Fatalf("panicked: %v", r.recovered)
+		}
+		if r.next.calls > 0 && !r.next.isContexter {
+			t.Errorf("next handler did not get a Contexter")
+		}
+	})
+
+	t.Run("nil body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Body = nil
+		r := runContract(w, req, false)
+		if r.recovered != nil {
+			t.Errorf("panicked: %v", r.recovered)
+		}
+	})
+
+	t.Run("panic in next", func(t *testing.T) {
+		r := runContract(w, httptest.NewRequest("GET", "/", nil), true)
+		if r.recovered != nil && r.recovered != contractPanic("next panicked") {
+			t.Errorf("panic of the next handler changed to %v", r.recovered)
+		}
+		r = runContract(w, httptest.NewRequest("GET", "/", nil), false)
+		if r.recovered != nil {
+			t.Errorf("panicked after a panic of the next handler: %v", r.recovered)
+		}
+	})
+}
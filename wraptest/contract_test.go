@@ -0,0 +1,99 @@
+package wraptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+func TestRunWrapperContract(t *testing.T) {
+	wrappers := map[string]wrap.Wrapper{
+		"setHeader":      setHeader{"X-A", "a"},
+		"MethodOverride": wrap.MethodOverride{},
+		"Recover":        wrap.Recover(nil),
+		"HandleError":    wrap.HandleError{},
+	}
+
+	for name, w := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			RunWrapperContract(t, w)
+		})
+	}
+}
+
+// twice calls the next handler twice
+type twice struct{}
+
+func (twice) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+// appendBody writes after the next handler
+type appendBody struct{}
+
+func (appendBody) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req)
+		rw.Write([]byte("appended"))
+	}
+	return f
+}
+
+// plainWriter passes a ResponseWriter that is no Contexter to the next handler
+type plainWriter struct{}
+
+func (plainWriter) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	return f
+}
+
+// changePanic panics with another value if the next handler panics
+type changePanic struct{}
+
+func (changePanic) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				panic("changed")
+			}
+		}()
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func TestContractViolations(t *testing.T) {
+	get := func() *http.Request { return httptest.NewRequest("GET", "/", nil) }
+
+	if r := runContract(twice{}, get(), false); r.next.calls != 2 || r.server.headers != 2 {
+		t.Errorf("calling next twice should be detected, got %d calls and %d status codes", r.next.calls, r.server.headers)
+	}
+
+	if r := runContract(appendBody{}, get(), false); !r.server.afterNext {
+		t.Errorf("writing after next should be detected")
+	}
+
+	if r := runContract(wrap.HandleError{}, get(), false); r.server.afterNext {
+		t.Errorf("flushing a buffered response should not be detected as writing after next")
+	}
+
+	if r := runContract(plainWriter{}, get(), false); r.next.isContexter {
+		t.Errorf("missing Contexter should be detected")
+	}
+
+	if r := runContract(changePanic{}, get(), true); r.recovered != "changed" {
+		t.Errorf("changed panic should be recovered, got %v", r.recovered)
+	}
+}
@@ -0,0 +1,85 @@
+package wraptest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-on/wrap"
+)
+
+// Drive is the result of DriveWriter
+type Drive struct {
+	// Ops describes the operations that have been run, for failure messages
+	Ops []string
+
+	// Written are the concatenated payloads that have been passed to Write
+	Written []byte
+
+	// Code is the last status code that has been passed to WriteHeader, 0 if there was none
+	Code int
+
+	// FirstWriteCode is the last status code that has been passed to WriteHeader before the
+	// first Write, 0 if there was none
+	FirstWriteCode int
+
+	// Header are the headers that have been set
+	Header http.Header
+
+	wrote bool
+}
+
+// DriveWriter interprets program as a sequence of calls of the methods of rw, so that fuzz targets can
+// drive ResponseWriter wrappers with arbitrary call sequences and payloads. Each operation starts with
+// an opcode byte, followed by its arguments:
+//
+//	opcode%4 == 0: Header().Set("X-Fuzz-<name>", "<value>") with a name and a value byte
+//	opcode%4 == 1: WriteHeader(200 + code%400) with a code byte
+//	opcode%4 == 2: Write of a payload with a length byte (length%64) followed by the payload
+//	opcode%4 == 3: Flush via wrap.Flush
+//
+// Missing arguments at the end of the program are treated as zero bytes and short payloads are written as
+// far as they go.
+func DriveWriter(rw http.ResponseWriter, program []byte) *Drive {
+	d := &Drive{Header: http.Header{}}
+	next := func() byte {
+		if len(program) == 0 {
+			return 0
+		}
+		b := program[0]
+		program = program[1:]
+		return b
+	}
+
+	for len(program) > 0 {
+		switch next() % 4 {
+		case 0:
+			name, value := fmt.Sprintf("X-Fuzz-%x", next()%8), fmt.Sprintf("%x", next())
+			d.Ops = append(d.Ops, "Header().Set("+name+", "+value+")")
+			rw.Header().Set(name, value)
+			d.Header.Set(name, value)
+		case 1:
+			code := 200 + int(next())%400
+			d.Ops = append(d.Ops, fmt.Sprintf("WriteHeader(%d)", code))
+			rw.WriteHeader(code)
+			d.Code = code
+			if !d.wrote {
+				d.FirstWriteCode = code
+			}
+		case 2:
+			n := int(next()) % 64
+			if n > len(program) {
+				n = len(program)
+			}
+			payload := program[:n]
+			program = program[n:]
+			d.Ops = append(d.Ops, fmt.Sprintf("Write(%q)", payload))
+			rw.Write(payload)
+			d.Written = append(d.Written, payload...)
+			d.wrote = true
+		case 3:
+			d.Ops = append(d.Ops, "Flush()")
+			wrap.Flush(rw)
+		}
+	}
+	return d
+}
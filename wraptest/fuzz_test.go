@@ -0,0 +1,103 @@
+package wraptest
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// seedPrograms are the seed corpus of the fuzz targets
+var seedPrograms = [][]byte{
+	{},
+	{2, 5, 'h', 'e', 'l', 'l', 'o'},
+	{0, 1, 2, 1, 4, 2, 3, 'a', '<', 'b'},
+	{1, 200, 2, 9, '<', 'a', ' ', 'b', '=', '"', '&', '\'', '>', 3, 2, 2, 'x', 'y'},
+	{3, 2, 4, 0xe4, 0xb8, 0x96, '&', 1, 0, 2, 2, 0xff, '>'},
+}
+
+// drive runs DriveWriter and fails on panics, except the documented ErrFlushOrder panics
+func drive(t *testing.T, rw http.ResponseWriter, program []byte) (d *Drive) {
+	t.Helper()
+	defer func() {
+		if p := recover(); p != nil {
+			if err, ok := p.(error); ok && errors.Is(err, wrap.ErrFlushOrder) {
+				return
+			}
+			t.Fatalf("panic %v for %v", p, d)
+		}
+	}()
+	return DriveWriter(rw, program)
+}
+
+func FuzzPeek(f *testing.F) {
+	for _, p := range seedPrograms {
+		f.Add(p)
+	}
+	f.Fuzz(func(t *testing.T, program []byte) {
+		for _, proceed := range []func(*wrap.Peek) bool{nil, func(p *wrap.Peek) bool {
+			p.FlushHeaders()
+			p.FlushCode()
+			return true
+		}} {
+			rec := httptest.NewRecorder()
+			p := wrap.NewPeek(rec, proceed)
+			d := drive(t, p, program)
+			if d == nil {
+				continue
+			}
+			p.FlushMissing()
+			if !bytes.Equal(rec.Body.Bytes(), d.Written) {
+				t.Fatalf("body %q should be %q after %v", rec.Body.Bytes(), d.Written, d.Ops)
+			}
+		}
+	})
+}
+
+func FuzzBuffer(f *testing.F) {
+	for _, p := range seedPrograms {
+		f.Add(p)
+	}
+	f.Fuzz(func(t *testing.T, program []byte) {
+		rec := httptest.NewRecorder()
+		bf := wrap.NewBuffer(rec)
+		d := drive(t, bf, program)
+		if !bytes.Equal(bf.Body(), d.Written) {
+			t.Fatalf("buffered body %q should be %q after %v", bf.Body(), d.Written, d.Ops)
+		}
+		if bf.Code != d.Code {
+			t.Fatalf("buffered code %d should be %d after %v", bf.Code, d.Code, d.Ops)
+		}
+		bf.FlushAll()
+		if !bytes.Equal(rec.Body.Bytes(), d.Written) {
+			t.Fatalf("body %q should be %q after %v", rec.Body.Bytes(), d.Written, d.Ops)
+		}
+		for k := range d.Header {
+			if got := rec.Header().Get(k); got != d.Header.Get(k) {
+				t.Fatalf("header %s should be %q but is %q after %v", k, d.Header.Get(k), got, d.Ops)
+			}
+		}
+	})
+}
+
+func FuzzEscapeHTML(f *testing.F) {
+	for _, p := range seedPrograms {
+		f.Add(p)
+	}
+	f.Fuzz(func(t *testing.T, program []byte) {
+		rec := httptest.NewRecorder()
+		d := drive(t, &wrap.EscapeHTML{ResponseWriter: rec}, program)
+		body := rec.Body.String()
+		if strings.ContainsAny(body, `<>"'`) {
+			t.Fatalf("body %q is not escaped after %v", body, d.Ops)
+		}
+		if unescaped := html.UnescapeString(body); unescaped != string(d.Written) {
+			t.Fatalf("unescaped body %q should be %q after %v", unescaped, d.Written, d.Ops)
+		}
+	})
+}
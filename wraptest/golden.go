@@ -0,0 +1,183 @@
+package wraptest
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// update is the flag to update golden files instead of comparing against them:
+//
+//	go test -wraptest.update
+var update = flag.Bool("wraptest.update", false, "update the golden files of wraptest instead of comparing against them")
+
+// SnapshotOf returns the recorded response as a Snapshot
+func SnapshotOf(rec *httptest.ResponseRecorder) *wrap.Snapshot {
+	res := rec.Result()
+	body, _ := io.ReadAll(res.Body)
+	return &wrap.Snapshot{Code: res.StatusCode, Header: res.Header, Body: body}
+}
+
+// MarshalSnapshot serializes the snapshot in the format of the golden files: the status line and
+// the headers (sorted by name) in HTTP/1.1 wire format with \n line endings, followed by an empty
+// line and the body
+func MarshalSnapshot(s *wrap.Snapshot) []byte {
+	var bf bytes.Buffer
+	code := s.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	fmt.Fprintf(&bf, "HTTP/1.1 %03d %s\n", code, http.StatusText(code))
+	for _, line := range headerLines(s.Header) {
+		bf.WriteString(line + "\n")
+	}
+	bf.WriteString("\n")
+	bf.Write(s.Body)
+	return bf.Bytes()
+}
+
+// UnmarshalSnapshot parses a snapshot serialized by MarshalSnapshot
+func UnmarshalSnapshot(data []byte) (*wrap.Snapshot, error) {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &wrap.Snapshot{Code: res.StatusCode, Header: res.Header, Body: body}, nil
+}
+
+// headerLines returns the lines of the header, sorted by name
+func headerLines(header http.Header) []string {
+	var lines []string
+	for k, vals := range header {
+		for _, v := range vals {
+			lines = append(lines, http.CanonicalHeaderKey(k)+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// DiffSnapshots returns a readable diff of the status codes, headers and bodies of the given snapshots,
+// an empty string if they are equal. Lines only in want are prefixed with "-", lines only in got with "+".
+func DiffSnapshots(want, got *wrap.Snapshot) string {
+	var bf bytes.Buffer
+	wantCode, gotCode := want.Code, got.Code
+	if wantCode == 0 {
+		wantCode = http.StatusOK
+	}
+	if gotCode == 0 {
+		gotCode = http.StatusOK
+	}
+	if wantCode != gotCode {
+		fmt.Fprintf(&bf, "status:\n-%d\n+%d\n", wantCode, gotCode)
+	}
+	if d := diffLines(headerLines(want.Header), headerLines(got.Header)); d != "" {
+		bf.WriteString("headers:\n" + d)
+	}
+	if !bytes.Equal(want.Body, got.Body) {
+		bf.WriteString("body:\n" + diffLines(strings.Split(string(want.Body), "\n"), strings.Split(string(got.Body), "\n")))
+	}
+	return bf.String()
+}
+
+// diffLines returns the lines of a and b, based on their longest common subsequence.
+// Common lines are prefixed with a space. It returns an empty string if a and b are equal.
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var bf bytes.Buffer
+	var changed bool
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&bf, " %s\n", a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&bf, "-%s\n", a[i])
+			changed = true
+			i++
+		default:
+			fmt.Fprintf(&bf, "+%s\n", b[j])
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return bf.String()
+}
+
+// AssertGolden serves req with h and compares the response with the snapshot inside the golden file
+// at path, reporting a diff on mismatch. If the test binary runs with the -wraptest.update flag,
+// the golden file is written instead (including missing directories).
+func AssertGolden(t testing.TB, path string, h http.Handler, req *http.Request) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assertGolden(t, path, SnapshotOf(rec), *update)
+}
+
+// ExpectGolden serves the request and compares the response with the golden file at path, see AssertGolden
+func (r *Request) ExpectGolden(t testing.TB, path string) {
+	t.Helper()
+	assertGolden(t, path, SnapshotOf(r.Do()), *update)
+}
+
+func assertGolden(t testing.TB, path string, got *wrap.Snapshot, update bool) {
+	t.Helper()
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("can't create directory of golden file: %s", err)
+		}
+		if err := os.WriteFile(path, MarshalSnapshot(got), 0644); err != nil {
+			t.Fatalf("can't write golden file: %s", err)
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("can't read golden file (run with -wraptest.update to create it): %s", err)
+	}
+	want, err := UnmarshalSnapshot(data)
+	if err != nil {
+		t.Fatalf("can't parse golden file %s: %s", path, err)
+	}
+	if d := DiffSnapshots(want, got); d != "" {
+		t.Errorf("response differs from golden file %s:\n%s", path, d)
+	}
+}
@@ -0,0 +1,66 @@
+package wraptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+func helloStack() http.Handler {
+	return wrap.New(
+		setHeader{"X-A", "a"},
+		wrap.Handler(&Next{Code: 201, Header: http.Header{"Content-Type": {"text/plain"}}, Body: "hello\nworld\n"}),
+	)
+}
+
+func TestGolden(t *testing.T) {
+	AssertGolden(t, "testdata/hello.golden", helloStack(), httptest.NewRequest("GET", "/", nil))
+	New(helloStack()).Get("/").ExpectGolden(t, "testdata/hello.golden")
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new", "hello.golden")
+	assertGolden(t, path, SnapshotOf(New(helloStack()).Get("/").Do()), true)
+	New(helloStack()).Get("/").ExpectGolden(t, path)
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	f := &failures{TB: t}
+	h := wrap.New(
+		setHeader{"X-A", "b"},
+		wrap.Handler(&Next{Code: 200, Header: http.Header{"Content-Type": {"text/plain"}}, Body: "hello\nthere\n"}),
+	)
+	AssertGolden(f, "testdata/hello.golden", h, httptest.NewRequest("GET", "/", nil))
+
+	if len(f.errors) != 1 {
+		t.Fatalf("expected 1 failure, got %d", len(f.errors))
+	}
+
+	for _, line := range []string{"status:\n-201\n+200\n", "-X-A: a\n+X-A: b\n", " hello\n-world\n+there\n"} {
+		if !strings.Contains(f.errors[0], line) {
+			t.Errorf("diff should contain %#v, but is:\n%s", line, f.errors[0])
+		}
+	}
+}
+
+func TestMarshalSnapshot(t *testing.T) {
+	s := &wrap.Snapshot{Header: http.Header{"B": {"2"}, "A": {"1", "0"}}, Body: []byte("body")}
+	data := MarshalSnapshot(s)
+
+	if expected := "HTTP/1.1 200 OK\nA: 0\nA: 1\nB: 2\n\nbody"; string(data) != expected {
+		t.Errorf("expected %#v, got %#v", expected, string(data))
+	}
+
+	parsed, err := UnmarshalSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := DiffSnapshots(s, parsed); d != "" {
+		t.Errorf("parsed snapshot differs:\n%s", d)
+	}
+}
@@ -0,0 +1,143 @@
+package wraptest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Call is a recorded call of a method of the ResponseWriter
+type Call struct {
+	// Method is the name of the method, e.g. "Flush"
+	Method string
+
+	// Args are the arguments of the call, e.g. the status code for WriteHeader
+	Args []interface{}
+}
+
+// ResponseWriter is a fake http.ResponseWriter for tests that, unlike httptest.ResponseRecorder,
+// implements all optional interfaces of the standard library: http.Flusher, http.Hijacker, http.Pusher,
+// io.ReaderFrom and http.CloseNotifier. It records every call, so that tests can check if wrappers
+// preserve the optional interfaces of the ResponseWriter they wrap.
+//
+// The status code, the headers and the body are recorded by the embedded ResponseRecorder.
+type ResponseWriter struct {
+	*httptest.ResponseRecorder
+
+	// PushErr is returned by Push
+	PushErr error
+
+	mx        sync.Mutex
+	calls     []Call
+	closeNote chan bool
+	conn      net.Conn
+}
+
+// make sure to fulfill the optional interfaces
+var (
+	_ http.Flusher       = &ResponseWriter{}
+	_ http.Hijacker      = &ResponseWriter{}
+	_ http.Pusher        = &ResponseWriter{}
+	_ io.ReaderFrom      = &ResponseWriter{}
+	_ http.CloseNotifier = &ResponseWriter{}
+)
+
+// NewResponseWriter returns a new ResponseWriter
+func NewResponseWriter() *ResponseWriter {
+	return &ResponseWriter{ResponseRecorder: httptest.NewRecorder(), closeNote: make(chan bool, 1)}
+}
+
+func (w *ResponseWriter) record(method string, args ...interface{}) {
+	w.mx.Lock()
+	w.calls = append(w.calls, Call{Method: method, Args: args})
+	w.mx.Unlock()
+}
+
+// Calls returns the recorded calls
+func (w *ResponseWriter) Calls() []Call {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return append([]Call(nil), w.calls...)
+}
+
+// Called returns how often the method of the given name has been called
+func (w *ResponseWriter) Called(method string) int {
+	var n int
+	for _, c := range w.Calls() {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Header records the call and returns the header map of the recorder
+func (w *ResponseWriter) Header() http.Header {
+	w.record("Header")
+	return w.ResponseRecorder.Header()
+}
+
+// WriteHeader records the call and writes the status code to the recorder
+func (w *ResponseWriter) WriteHeader(code int) {
+	w.record("WriteHeader", code)
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+// Write records the call and writes to the recorder
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	w.record("Write", string(b))
+	return w.ResponseRecorder.Write(b)
+}
+
+// Flush records the call and flushes the recorder
+func (w *ResponseWriter) Flush() {
+	w.record("Flush")
+	w.ResponseRecorder.Flush()
+}
+
+// ReadFrom records the call and copies r to the recorder
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.record("ReadFrom")
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+// Push records the call and returns PushErr
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	w.record("Push", target, opts)
+	return w.PushErr
+}
+
+// Hijack records the call and returns one end of an in-memory connection. The other end is
+// returned by Conn.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.record("Hijack")
+	server, client := net.Pipe()
+	w.mx.Lock()
+	w.conn = client
+	w.mx.Unlock()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+// Conn returns the client end of the connection returned by the last call of Hijack, nil if Hijack has not been called
+func (w *ResponseWriter) Conn() net.Conn {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.conn
+}
+
+// CloseNotify records the call and returns the channel that receives a value when Close is called
+func (w *ResponseWriter) CloseNotify() <-chan bool {
+	w.record("CloseNotify")
+	return w.closeNote
+}
+
+// Close simulates a client that has gone away, by notifying the channel returned by CloseNotify
+func (w *ResponseWriter) Close() {
+	select {
+	case w.closeNote <- true:
+	default:
+	}
+}
@@ -0,0 +1,78 @@
+package wraptest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+func TestResponseWriter(t *testing.T) {
+	w := NewResponseWriter()
+	w.PushErr = errors.New("no push")
+
+	w.Header().Set("X-A", "a")
+	w.WriteHeader(201)
+	w.Write([]byte("a"))
+	w.ReadFrom(strings.NewReader("b"))
+	w.Flush()
+
+	if err := w.Push("/x", nil); err != w.PushErr {
+		t.Errorf("Push should return %v, got %v", w.PushErr, err)
+	}
+
+	ch := w.CloseNotify()
+	w.Close()
+	if !<-ch {
+		t.Errorf("close should be notified")
+	}
+
+	conn, brw, err := w.Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		brw.WriteString("hijacked")
+		brw.Flush()
+		conn.Close()
+	}()
+	if got, _ := io.ReadAll(w.Conn()); string(got) != "hijacked" {
+		t.Errorf("client should read %#v, got %#v", "hijacked", string(got))
+	}
+
+	var methods []string
+	for _, c := range w.Calls() {
+		methods = append(methods, c.Method)
+	}
+	if got := strings.Join(methods, " "); got != "Header WriteHeader Write ReadFrom Flush Push CloseNotify Hijack" {
+		t.Errorf("unexpected calls %s", got)
+	}
+
+	if w.Code != 201 || w.Body.String() != "ab" || w.Header().Get("X-A") != "a" {
+		t.Errorf("unexpected response %d %#v %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestResponseWriterPreserved(t *testing.T) {
+	w := NewResponseWriter()
+	h := wrap.New(ContexterFor(), wrap.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		for _, rw := range []http.ResponseWriter{rw, wrap.NewPeek(rw, nil), wrap.NewBuffer(rw)} {
+			if !wrap.Flush(rw) {
+				t.Errorf("%T should flush", rw)
+			}
+			if _, _, _, ok := wrap.Hijack(rw); !ok {
+				t.Errorf("%T should hijack", rw)
+			}
+		}
+	}))
+
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Called("Flush") != 3 || w.Called("Hijack") != 3 {
+		t.Errorf("Flush and Hijack should be called 3 times, got %d and %d", w.Called("Flush"), w.Called("Hijack"))
+	}
+}
@@ -0,0 +1,150 @@
+package wraptest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// Case is the request and the expected outcome of a test case of RunTable
+type Case struct {
+	// Header are the headers of the request
+	Header http.Header
+
+	// Body is the body of the request
+	Body string
+
+	// Want is the expected response. The status code is checked if it is not 0, the headers
+	// are checked if they are listed and the body is checked if it is not nil.
+	Want wrap.Snapshot
+
+	// Context is run by each Checkpoint in the stack with the ResponseWriter and the request at
+	// that point, so that the contexts may be asserted. Failures are reported via t.
+	Context func(t testing.TB, rw http.ResponseWriter, req *http.Request)
+
+	// Traverse is the expected traversal of the request through the stack (see Tracer.Path).
+	// It is checked if it is not nil.
+	Traverse []string
+}
+
+// Table maps request descriptors to test cases. A descriptor is a method and a target,
+// optionally followed by " #" and a label to distinguish cases with the same request, like
+//
+//	"GET /admin #without session"
+type Table map[string]Case
+
+// caseKey is the key of the current Case inside the context of the request
+type caseKey struct{}
+
+// caseState is the current Case of RunTable
+type caseState struct {
+	t      *testing.T
+	c      Case
+	passed bool
+}
+
+// Checkpoint is a Wrapper that runs the Context function of the current Case of RunTable, so that the
+// contexts at that point of the stack may be asserted. Outside of RunTable it just calls the next handler.
+var Checkpoint wrap.Wrapper = wrap.NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	if st, ok := req.Context().Value(caseKey{}).(*caseState); ok && st.c.Context != nil {
+		st.passed = true
+		st.c.Context(st.t, rw, req)
+	}
+	next.ServeHTTP(rw, req)
+})
+
+// RunTable runs each case of the table as subtest (in the order of the descriptors) against the stack
+// returned by build. If any case has a Traverse, the stack is built after tracing has been started
+// (see StartTrace). A case with a Context function fails if the request did not pass a Checkpoint.
+func RunTable(t *testing.T, build func() http.Handler, table Table) {
+	var tr *Tracer
+	for _, c := range table {
+		if c.Traverse != nil {
+			tr = StartTrace(t)
+			break
+		}
+	}
+	h := build()
+
+	descs := make([]string, 0, len(table))
+	for desc := range table {
+		descs = append(descs, desc)
+	}
+	sort.Strings(descs)
+
+	for _, desc := range descs {
+		c := table[desc]
+		method, target := parseDescriptor(desc)
+		t.Run(desc, func(t *testing.T) {
+			if method == "" {
+				t.Fatalf("invalid request descriptor %#v, should be like \"GET /path\"", desc)
+			}
+			req := httptest.NewRequest(method, target, strings.NewReader(c.Body))
+			for k, v := range c.Header {
+				req.Header[http.CanonicalHeaderKey(k)] = v
+			}
+			st := &caseState{t: t, c: c}
+			req = req.WithContext(context.WithValue(req.Context(), caseKey{}, st))
+			if tr != nil {
+				tr.Reset()
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			checkCase(t, c, rec)
+
+			if c.Context != nil && !st.passed {
+				t.Errorf("request did not pass a Checkpoint, so the Context function did not run")
+			}
+			if c.Traverse != nil {
+				if path := tr.Path(); !reflect.DeepEqual(path, c.Traverse) {
+					t.Errorf("traversal differs:\n%s", diffLines(c.Traverse, path))
+				}
+			}
+		})
+	}
+}
+
+// parseDescriptor returns the method and the target of the descriptor, empty strings if it is invalid
+func parseDescriptor(desc string) (method, target string) {
+	if i := strings.Index(desc, " #"); i >= 0 {
+		desc = desc[:i]
+	}
+	fields := strings.Fields(desc)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+		return "", ""
+	}
+	return fields[0], fields[1]
+}
+
+// checkCase compares the response with the expected snapshot of the case
+func checkCase(t *testing.T, c Case, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	got := SnapshotOf(rec)
+	want := &wrap.Snapshot{Code: c.Want.Code, Header: http.Header{}, Body: c.Want.Body}
+	if want.Code == 0 {
+		want.Code = got.Code
+	}
+	if want.Body == nil {
+		want.Body = got.Body
+	}
+	// only compare the headers that are expected
+	checked := http.Header{}
+	for k, v := range c.Want.Header {
+		k = http.CanonicalHeaderKey(k)
+		want.Header[k] = v
+		if gv, has := got.Header[k]; has {
+			checked[k] = gv
+		}
+	}
+	got.Header = checked
+	if d := DiffSnapshots(want, got); d != "" {
+		t.Errorf("response differs:\n%s", d)
+	}
+}
@@ -0,0 +1,70 @@
+package wraptest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// admin forbids requests to /admin without the X-Admin header
+var admin = wrap.NextHandlerFunc(func(next http.Handler, rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/admin" && req.Header.Get("X-Admin") == "" {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	next.ServeHTTP(rw, req)
+})
+
+func tableStack() http.Handler {
+	override := wrap.MethodOverride{}
+	return wrap.New(
+		ContexterFor(override),
+		override,
+		setHeader{"X-A", "a"},
+		admin,
+		Checkpoint,
+		wrap.Handler(&Next{Body: "ok"}),
+	)
+}
+
+func TestRunTable(t *testing.T) {
+	RunTable(t, tableStack, Table{
+		"GET /": {
+			Want: wrap.Snapshot{Code: 200, Header: http.Header{"X-A": {"a"}}, Body: []byte("ok")},
+		},
+		"GET /admin #without header": {
+			Want:     wrap.Snapshot{Code: 403, Body: []byte{}},
+			Traverse: []string{"*wraptest.Contexter as Wrapper", "wrap.MethodOverride as Wrapper", "wraptest.setHeader as Wrapper", "wrap.NextHandlerFunc as Wrapper", "wrap.NextHandlerFunc as NextHandlerFunc"},
+		},
+		"GET /admin #with header": {
+			Header: http.Header{"X-Admin": {"1"}},
+			Want:   wrap.Snapshot{Code: 200},
+		},
+		"POST /items": {
+			Header: http.Header{wrap.MethodOverrideHeader: {"DELETE"}},
+			Context: func(t testing.TB, rw http.ResponseWriter, req *http.Request) {
+				var orig wrap.OriginalMethod
+				rw.(wrap.Contexter).Context(&orig)
+				if orig != "POST" || req.Method != "DELETE" {
+					t.Errorf("expected POST overridden with DELETE, got %s overridden with %s", orig, req.Method)
+				}
+			},
+		},
+	})
+}
+
+func TestParseDescriptor(t *testing.T) {
+	tests := map[string][2]string{
+		"GET /":           {"GET", "/"},
+		"PUT /a?b=c #x y": {"PUT", "/a?b=c"},
+		"GET":             {"", ""},
+		"GET a":           {"", ""},
+	}
+
+	for desc, expected := range tests {
+		if method, target := parseDescriptor(desc); method != expected[0] || target != expected[1] {
+			t.Errorf("parseDescriptor(%#v) = %#v, %#v, expected %#v", desc, method, target, expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package wraptest
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-on/wrap"
+)
+
+// Step is a debugged object of a stack that has been entered by a request
+type Step struct {
+	// Type is the type of the object, e.g. "wrap.HandleError"
+	Type string
+
+	// Role is the role of the object, e.g. "Wrapper" (see wrap.Debugger)
+	Role string
+
+	// Depth is the nesting level of the step, 0 for the outermost object
+	Depth int
+
+	// NextCalled is true if the object has called the next debugged object
+	NextCalled bool
+
+	// Status is the status code that has been written through the object, 0 if nothing has been written
+	Status int
+
+	// Panicked is true if the object panicked. A panic is only reported for the innermost debugged object.
+	Panicked bool
+}
+
+// String returns the type and the role of the step, like "wrap.HandleError as Wrapper"
+func (s Step) String() string {
+	return s.Type + " as " + s.Role
+}
+
+// Tracer is a Debugger that records the traversal of requests through the debugged objects of
+// stacks as Steps, so that tests can assert on them instead of matching log output.
+// Since the steps of all requests are recorded in a single slice, the stacks should serve one
+// request at a time while tracing.
+type Tracer struct {
+	mx    sync.Mutex
+	steps []Step
+	open  []int
+}
+
+// make sure to fulfill the ExitDebugger and PanicDebugger interfaces
+var (
+	_ wrap.ExitDebugger  = &Tracer{}
+	_ wrap.PanicDebugger = &Tracer{}
+)
+
+// StartTrace enables debugging with a new Tracer as Debugger and returns it. Debugging is
+// disabled again when the test has finished. Stacks must be built after StartTrace to be traced.
+func StartTrace(t testing.TB) *Tracer {
+	tr := &Tracer{}
+	wrap.SetDebugger(tr)
+	wrap.SetDebug()
+	t.Cleanup(func() {
+		wrap.DisableDebug()
+		wrap.SetDebugger(nil)
+	})
+	return tr
+}
+
+// Debug records a new step
+func (t *Tracer) Debug(req *http.Request, obj interface{}, role string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if len(t.open) > 0 {
+		t.steps[t.open[len(t.open)-1]].NextCalled = true
+	}
+	t.steps = append(t.steps, Step{Type: fmt.Sprintf("%T", obj), Role: role, Depth: len(t.open)})
+	t.open = append(t.open, len(t.steps)-1)
+}
+
+// DebugExit records the status code of the innermost open step and closes it
+func (t *Tracer) DebugExit(req *http.Request, obj interface{}, role string, status int, d time.Duration) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if len(t.open) == 0 {
+		return
+	}
+	t.steps[t.open[len(t.open)-1]].Status = status
+	t.open = t.open[:len(t.open)-1]
+}
+
+// DebugPanic marks the innermost open step as panicked
+func (t *Tracer) DebugPanic(req *http.Request, obj interface{}, role string, recovered interface{}, stack []byte) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if len(t.open) == 0 {
+		return
+	}
+	t.steps[t.open[len(t.open)-1]].Panicked = true
+}
+
+// Steps returns the recorded steps in the order they have been entered
+func (t *Tracer) Steps() []Step {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]Step(nil), t.steps...)
+}
+
+// Path returns the recorded steps as strings (see Step.String)
+func (t *Tracer) Path() []string {
+	steps := t.Steps()
+	path := make([]string, len(steps))
+	for i, s := range steps {
+		path[i] = s.String()
+	}
+	return path
+}
+
+// Reset removes the recorded steps
+func (t *Tracer) Reset() {
+	t.mx.Lock()
+	t.steps, t.open = nil, nil
+	t.mx.Unlock()
+}
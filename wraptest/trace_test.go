@@ -0,0 +1,63 @@
+package wraptest
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+type stop struct{}
+
+func (stop) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+	}
+	return f
+}
+
+func TestTracer(t *testing.T) {
+	tr := StartTrace(t)
+	h := wrap.New(setHeader{"X-A", "a"}, wrap.Handler(&Next{Code: 201}))
+
+	New(h).Get("/").Expect(t).Status(201)
+
+	expected := []Step{
+		{Type: "wraptest.setHeader", Role: "Wrapper", Depth: 0, NextCalled: true, Status: 201},
+		{Type: "wrap.NextHandlerFunc", Role: "Wrapper", Depth: 1, NextCalled: true, Status: 201},
+		{Type: "wrap.NextHandlerFunc", Role: "NextHandlerFunc", Depth: 2, NextCalled: true, Status: 201},
+		{Type: "*wraptest.Next", Role: "http.Handler", Depth: 3, Status: 201},
+	}
+
+	if steps := tr.Steps(); !reflect.DeepEqual(steps, expected) {
+		t.Errorf("expected steps %#v, got %#v", expected, steps)
+	}
+
+	tr.Reset()
+	h = wrap.New(setHeader{"X-A", "a"}, stop{}, wrap.Handler(&Next{Code: 201}))
+	New(h).Get("/").Expect(t).Status(403)
+
+	if path := tr.Path(); !reflect.DeepEqual(path, []string{"wraptest.setHeader as Wrapper", "wraptest.stop as Wrapper"}) {
+		t.Errorf("unexpected path %v", path)
+	}
+
+	if steps := tr.Steps(); steps[1].NextCalled || steps[1].Status != 403 {
+		t.Errorf("stop should not call next and write 403, got %#v", steps[1])
+	}
+}
+
+func TestTracerPanic(t *testing.T) {
+	tr := StartTrace(t)
+	h := wrap.New(wrap.Recover(nil), wrap.Handler(&Next{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})}))
+
+	New(h).Get("/").Expect(t).Status(500)
+
+	steps := tr.Steps()
+	if len(steps) != 4 || steps[0].Panicked || !steps[3].Panicked || steps[0].Status != 500 || steps[3].Status != 0 {
+		t.Errorf("panic should be recorded for the handler, got %#v", steps)
+	}
+}
@@ -0,0 +1,211 @@
+// Package wraptest provides helpers for testing middleware stacks and single wrappers of
+// github.com/go-on/wrap, built on net/http/httptest.
+//
+// Requests are built and expectations are checked fluently:
+//
+//	wraptest.New(stack).Get("/").WithHeader("Accept", "text/html").
+//		Expect(t).Status(200).BodyContains("x").HeaderEquals("Content-Type", "text/html")
+//
+// A single Wrapper may be run in isolation with a scripted next handler:
+//
+//	next := &wraptest.Next{Code: 200, Body: "next"}
+//	wraptest.Isolate(w, next).Get("/").Expect(t).Status(200)
+//	if next.Calls() != 1 { ... }
+//
+// Wrappers requiring context types may be tested with a Contexter supporting just these types, see ContexterFor.
+package wraptest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// Harness serves requests with a http.Handler
+type Harness struct {
+	handler http.Handler
+}
+
+// New returns a Harness for the given handler, usually a stack built by wrap.New or wrap.Stack
+func New(h http.Handler) *Harness {
+	return &Harness{handler: h}
+}
+
+// Isolate returns a Harness for the given Wrapper alone, wrapping the given next handler
+func Isolate(w wrap.Wrapper, next http.Handler) *Harness {
+	return New(w.Wrap(next))
+}
+
+// Request returns a Request with the given method, path and body. A nil body is allowed.
+// It panics if the request could not be created.
+func (h *Harness) Request(method, path string, body io.Reader) *Request {
+	req := httptest.NewRequest(method, path, body)
+	return &Request{harness: h, Req: req}
+}
+
+// Get returns a GET Request for the given path
+func (h *Harness) Get(path string) *Request {
+	return h.Request("GET", path, nil)
+}
+
+// Head returns a HEAD Request for the given path
+func (h *Harness) Head(path string) *Request {
+	return h.Request("HEAD", path, nil)
+}
+
+// Post returns a POST Request for the given path with the given content type and body
+func (h *Harness) Post(path, contentType, body string) *Request {
+	r := h.Request("POST", path, strings.NewReader(body))
+	r.Req.Header.Set("Content-Type", contentType)
+	return r
+}
+
+// Request is a request that is served by a Harness
+type Request struct {
+	harness *Harness
+
+	// Req is the underlying request, it may be modified before Do or Expect is called
+	Req *http.Request
+}
+
+// WithHeader sets the header of the given name to the given value
+func (r *Request) WithHeader(name, value string) *Request {
+	r.Req.Header.Set(name, value)
+	return r
+}
+
+// WithRemoteAddr sets the remote address of the request
+func (r *Request) WithRemoteAddr(addr string) *Request {
+	r.Req.RemoteAddr = addr
+	return r
+}
+
+// Do serves the request and returns the recorded response
+func (r *Request) Do() *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.harness.handler.ServeHTTP(rec, r.Req)
+	return rec
+}
+
+// Expect serves the request and returns a Response to check expectations against
+func (r *Request) Expect(t testing.TB) *Response {
+	return &Response{t: t, Recorder: r.Do()}
+}
+
+// Response checks expectations against a recorded response. Failed expectations are
+// reported via Errorf of the testing.TB, so all expectations of a chain are checked.
+type Response struct {
+	t testing.TB
+
+	// Recorder is the recorded response
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status expects the status code to be code
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("status code should be %d but is %d", code, r.Recorder.Code)
+	}
+	return r
+}
+
+// Body expects the body to be body
+func (r *Response) Body(body string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); got != body {
+		r.t.Errorf("body should be %#v but is %#v", body, got)
+	}
+	return r
+}
+
+// BodyContains expects the body to contain s
+func (r *Response) BodyContains(s string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); !strings.Contains(got, s) {
+		r.t.Errorf("body should contain %#v but is %#v", s, got)
+	}
+	return r
+}
+
+// HeaderEquals expects the response header of the given name to be value
+func (r *Response) HeaderEquals(name, value string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(name); got != value {
+		r.t.Errorf("header %s should be %#v but is %#v", name, value, got)
+	}
+	return r
+}
+
+// HeaderMissing expects the response to have no header of the given name
+func (r *Response) HeaderMissing(name string) *Response {
+	r.t.Helper()
+	if got, has := r.Recorder.Header()[http.CanonicalHeaderKey(name)]; has {
+		r.t.Errorf("header %s should be missing but is %#v", name, got)
+	}
+	return r
+}
+
+// Next is a scripted next handler for running a Wrapper in isolation. It records the requests
+// it receives. If Handler is nil, it writes Header, Code and Body.
+type Next struct {
+	// Handler serves the request, if it is not nil
+	Handler http.Handler
+
+	// Header are headers that are set before the status code is written
+	Header http.Header
+
+	// Code is the status code, http.StatusOK if 0
+	Code int
+
+	// Body is the body that is written
+	Body string
+
+	mx       sync.Mutex
+	requests []*http.Request
+}
+
+// make sure to fulfill the http.Handler interface
+var _ http.Handler = &Next{}
+
+// ServeHTTP records the request and serves it
+func (n *Next) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	n.mx.Lock()
+	n.requests = append(n.requests, req)
+	n.mx.Unlock()
+
+	if n.Handler != nil {
+		n.Handler.ServeHTTP(rw, req)
+		return
+	}
+
+	header := rw.Header()
+	for k, v := range n.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	code := n.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	rw.WriteHeader(code)
+	io.WriteString(rw, n.Body)
+}
+
+// Calls returns how often the next handler has been called
+func (n *Next) Calls() int {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return len(n.requests)
+}
+
+// Requests returns the requests the next handler has received
+func (n *Next) Requests() []*http.Request {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	return append([]*http.Request(nil), n.requests...)
+}
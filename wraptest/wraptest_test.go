@@ -0,0 +1,71 @@
+package wraptest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-on/wrap"
+)
+
+// failures is a testing.TB that records the failures instead of failing
+type failures struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failures) Helper() {}
+
+func (f *failures) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+type setHeader struct{ name, value string }
+
+func (s setHeader) Wrap(next http.Handler) http.Handler {
+	var f http.HandlerFunc
+	f = func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set(s.name, s.value)
+		next.ServeHTTP(rw, req)
+	}
+	return f
+}
+
+func TestHarness(t *testing.T) {
+	stack := wrap.New(
+		setHeader{"X-Stack", "yes"},
+		wrap.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(rw, "%s %s %s", req.Method, req.URL.Path, req.Header.Get("X-In"))
+		}),
+	)
+
+	New(stack).Get("/a").WithHeader("X-In", "in").
+		Expect(t).Status(200).Body("GET /a in").BodyContains("/a").HeaderEquals("X-Stack", "yes").HeaderMissing("X-Other")
+
+	New(stack).Post("/b", "text/plain", "body").Expect(t).BodyContains("POST /b")
+}
+
+func TestResponseFailures(t *testing.T) {
+	f := &failures{TB: t}
+	New(wrap.New(setHeader{"X-Stack", "yes"}, wrap.Handler(&Next{Body: "x"}))).Get("/").
+		Expect(f).Status(404).Body("y").BodyContains("z").HeaderEquals("X-Stack", "no").HeaderMissing("X-Stack")
+
+	if len(f.errors) != 5 {
+		t.Errorf("expected 5 failures, got %d: %v", len(f.errors), f.errors)
+	}
+}
+
+func TestIsolate(t *testing.T) {
+	next := &Next{Code: 201, Body: "next", Header: http.Header{"X-Next": {"1"}}}
+
+	Isolate(setHeader{"X-Wrapper", "w"}, next).Get("/x").
+		Expect(t).Status(201).Body("next").HeaderEquals("X-Wrapper", "w").HeaderEquals("X-Next", "1")
+
+	if next.Calls() != 1 {
+		t.Errorf("next should be called once, but was called %d times", next.Calls())
+	}
+
+	if path := next.Requests()[0].URL.Path; path != "/x" {
+		t.Errorf("next should receive /x, but received %s", path)
+	}
+}